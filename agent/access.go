@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ---------------------------------------------
+// CHECK ACCESS COMMAND
+// "Can X do Y?" for the dashboard. mode=access asks the API server's own
+// authorizer through a SubjectAccessReview (needs create on
+// subjectaccessreviews); mode=rules impersonates the subject and runs a
+// SelfSubjectRulesReview to list what it can do in a namespace, which
+// requires the agent to hold the impersonate verb, not granted by default.
+// Without a subject the agent checks its own access.
+// ---------------------------------------------
+
+// accessCheckUser is impersonated for group-only subjects: impersonation
+// always needs a user name
+const accessCheckUser = "kodo:access-check"
+
+// accessSubject resolves the params into the user and groups the API server sees
+func accessSubject(p *CheckAccessParams) (string, []string) {
+	switch p.SubjectKind {
+	case "ServiceAccount":
+		return "system:serviceaccount:" + p.SubjectNamespace + ":" + p.SubjectName,
+			[]string{"system:serviceaccounts", "system:serviceaccounts:" + p.SubjectNamespace, "system:authenticated"}
+	case "Group":
+		return "", append([]string{p.SubjectName, "system:authenticated"}, p.Groups...)
+	}
+	return p.SubjectName, append([]string{"system:authenticated"}, p.Groups...)
+}
+
+// checkAccess implements the "check_access" command
+func checkAccess(clientset kubernetes.Interface, kubeconfig *rest.Config, params map[string]interface{}) (map[string]interface{}, error) {
+	var p CheckAccessParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	user, groups := accessSubject(&p)
+	result := map[string]interface{}{
+		"action": "check_access",
+		"mode":   p.Mode,
+	}
+	if p.SubjectKind != "" {
+		result["subject"] = map[string]interface{}{"kind": p.SubjectKind, "name": p.SubjectName, "namespace": p.SubjectNamespace, "user": user, "groups": groups}
+	} else {
+		result["subject"] = "self"
+	}
+
+	if p.Mode == "rules" {
+		return accessRules(clientset, kubeconfig, &p, user, groups, result)
+	}
+
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace:   p.Namespace,
+		Verb:        p.Verb,
+		Group:       p.Group,
+		Resource:    p.Resource,
+		Subresource: p.Subresource,
+		Name:        p.Name,
+	}
+	var nonResource *authorizationv1.NonResourceAttributes
+	if p.Path != "" {
+		attributes, nonResource = nil, &authorizationv1.NonResourceAttributes{Path: p.Path, Verb: p.Verb}
+	}
+
+	var status authorizationv1.SubjectAccessReviewStatus
+	ctx, cancel := apiContext()
+	defer cancel()
+	if p.SubjectKind == "" {
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes, NonResourceAttributes: nonResource},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("SelfSubjectAccessReview failed: %w", err)
+		}
+		status = review.Status
+	} else {
+		review, err := clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{User: user, Groups: groups, ResourceAttributes: attributes, NonResourceAttributes: nonResource},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("SubjectAccessReview failed: %w", err)
+		}
+		status = review.Status
+	}
+
+	result["allowed"] = status.Allowed
+	result["denied"] = status.Denied
+	result["reason"] = status.Reason
+	if status.EvaluationError != "" {
+		result["evaluation_error"] = status.EvaluationError
+	}
+	if attributes != nil {
+		result["attributes"] = attributes
+	} else {
+		result["attributes"] = nonResource
+	}
+	return result, nil
+}
+
+// accessRules lists the subject's rules in a namespace by impersonating it
+func accessRules(clientset kubernetes.Interface, kubeconfig *rest.Config, p *CheckAccessParams, user string, groups []string, result map[string]interface{}) (map[string]interface{}, error) {
+	client := clientset
+	if p.SubjectKind != "" {
+		if kubeconfig == nil {
+			return nil, errors.New("impersonation needs the in-cluster REST config")
+		}
+		if user == "" {
+			user = accessCheckUser
+		}
+		impersonated := rest.CopyConfig(kubeconfig)
+		impersonated.Impersonate = rest.ImpersonationConfig{UserName: user, Groups: groups}
+		var err error
+		if client, err = kubernetes.NewForConfig(impersonated); err != nil {
+			return nil, fmt.Errorf("failed to build impersonating client: %w", err)
+		}
+	}
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	review, err := client.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, &authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: p.Namespace},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("SelfSubjectRulesReview failed (impersonation requires the impersonate verb): %w", err)
+	}
+
+	result["namespace"] = p.Namespace
+	result["resource_rules"] = review.Status.ResourceRules
+	result["non_resource_rules"] = review.Status.NonResourceRules
+	result["incomplete"] = review.Status.Incomplete
+	if review.Status.EvaluationError != "" {
+		result["evaluation_error"] = review.Status.EvaluationError
+	}
+	return result, nil
+}
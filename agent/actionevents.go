@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// ACTION EVENTS
+// Every command that changed an object leaves a Kubernetes Event on it
+// ("Scaled to 3 replicas by kuber-pulse on behalf of alice"), so cluster-side
+// audit trails and `kubectl describe` show what the platform did. The target
+// comes from the command result; dry runs and failures leave no Event.
+// ---------------------------------------------
+
+// actionEventComponent is the Event source shown by kubectl
+const actionEventComponent = "kuber-pulse"
+
+// actionTarget is the object a command changed and how to describe it
+type actionTarget struct {
+	ref corev1.ObjectReference
+	// resource is the plural API resource of ref, for patching it
+	resource string
+	reason   string
+	message  string
+}
+
+// commandActionTarget derives the Event target from a successful command result
+func commandActionTarget(result map[string]interface{}) (actionTarget, bool) {
+	str := func(key string) string {
+		value, _ := result[key].(string)
+		return value
+	}
+	if dryRun, _ := result["dry_run"].(bool); dryRun {
+		return actionTarget{}, false
+	}
+	namespace := str("namespace")
+	deployment := corev1.ObjectReference{Kind: "Deployment", APIVersion: "apps/v1", Namespace: namespace, Name: str("deployment")}
+	pod := corev1.ObjectReference{Kind: "Pod", APIVersion: "v1", Namespace: namespace, Name: str("pod")}
+
+	switch str("action") {
+	case "pod_deleted":
+		return actionTarget{pod, "pods", "PodDeleted", "Pod deleted"}, true
+	case "debug_container_created":
+		return actionTarget{pod, "pods", "DebugContainerAdded", fmt.Sprintf("Debug container %s (%s) added", str("container"), str("image"))}, true
+	case "deployment_scaled":
+		return actionTarget{deployment, "deployments", "Scaled", fmt.Sprintf("Scaled to %v replicas", result["replicas"])}, true
+	case "deployment_image_updated":
+		return actionTarget{deployment, "deployments", "ImageUpdated", fmt.Sprintf("Container %s image set to %s", str("container"), str("new_image"))}, true
+	case "deployment_resources_updated":
+		return actionTarget{deployment, "deployments", "ResourcesUpdated", fmt.Sprintf("Container %s resources updated", str("container"))}, true
+	case "network_policy_applied":
+		ref := corev1.ObjectReference{Kind: "NetworkPolicy", APIVersion: "networking.k8s.io/v1", Namespace: namespace, Name: str("policy"), UID: types.UID(str("uid"))}
+		return actionTarget{ref, "networkpolicies", "NetworkPolicyApplied", "NetworkPolicy applied"}, true
+	case "resource_patched":
+		ref := corev1.ObjectReference{Kind: str("kind"), APIVersion: str("api_version"), Namespace: namespace, Name: str("name"), UID: types.UID(str("uid"))}
+		return actionTarget{ref, str("resource"), "Patched", fmt.Sprintf("%s patch applied", str("patch_type"))}, true
+	}
+	return actionTarget{}, false
+}
+
+// recordActionEvent creates the Event for a successful command. Failing to
+// create it never fails the command.
+func recordActionEvent(clientset kubernetes.Interface, config AgentConfig, cmd Command, result map[string]interface{}) {
+	if !config.CommandEvents || config.dryRun() {
+		return
+	}
+	target, ok := commandActionTarget(result)
+	if !ok || target.ref.Name == "" {
+		return
+	}
+	if target.ref.UID == "" {
+		target.ref.UID = lookupActionUID(clientset, target.ref)
+	}
+	// Cluster-scoped objects get their Events in the default namespace
+	namespace := target.ref.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	onBehalf := ""
+	if requester := cmd.requester(); requester != "" {
+		onBehalf = " on behalf of " + requester
+	}
+	now := metav1.NewTime(time.Now())
+	instance, _ := os.Hostname()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			// Same naming as client-go's recorder: object name plus a unique suffix
+			Name:      fmt.Sprintf("%s.%x", target.ref.Name, now.UnixNano()),
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": fieldManager},
+			Annotations: map[string]string{
+				"kuberpulse.io/command-id":   cmd.ID,
+				"kuberpulse.io/command-type": cmd.CommandType,
+			},
+		},
+		InvolvedObject:      target.ref,
+		Reason:              target.reason,
+		Message:             fmt.Sprintf("%s by %s%s (command %s)", target.message, actionEventComponent, onBehalf, cmd.ID),
+		Type:                corev1.EventTypeNormal,
+		Source:              corev1.EventSource{Component: actionEventComponent},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: fieldManager,
+		ReportingInstance:   instance,
+	}
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	if _, err := clientset.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{FieldManager: fieldManager}); err != nil {
+		log.Printf("⚠️  Could not record Event for command %s on %s %s/%s: %v", cmd.ID, target.ref.Kind, namespace, target.ref.Name, err)
+	}
+}
+
+// lookupActionUID fills the UID kubectl describe matches Events by; deleted
+// pods may already be gone, in which case the Event is still listed by name
+func lookupActionUID(clientset kubernetes.Interface, ref corev1.ObjectReference) types.UID {
+	ctx, cancel := apiContext()
+	defer cancel()
+	switch ref.Kind {
+	case "Pod":
+		if pod, err := clientset.CoreV1().Pods(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{}); err == nil {
+			return pod.UID
+		}
+	case "Deployment":
+		if deployment, err := clientset.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{}); err == nil {
+			return deployment.UID
+		}
+	}
+	return ""
+}
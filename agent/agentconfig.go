@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ---------------------------------------------
+// KUBERPULSECONFIG CONTROLLER
+// Watches the agent's KuberPulseConfig so platform teams can change its
+// behavior through GitOps instead of env vars and restarts.
+// ---------------------------------------------
+
+var kuberPulseConfigGVR = schema.GroupVersionResource{
+	Group:    "kuberpulse.io",
+	Version:  "v1alpha1",
+	Resource: "kuberpulseconfigs",
+}
+
+const (
+	// minConfigInterval keeps a misconfigured CR from hammering the API server
+	minConfigInterval = 5 * time.Second
+	// configCRDRecheck is how often the agent looks for the CRD when it is not installed
+	configCRDRecheck = 5 * time.Minute
+	// configResync re-delivers the object periodically in case an event was missed
+	configResync = 10 * time.Minute
+)
+
+// KuberPulseConfigSpec mirrors spec in kubernetes/kuberpulseconfig-crd.yaml
+type KuberPulseConfigSpec struct {
+	Collection struct {
+		IntervalSeconds    int      `json:"intervalSeconds,omitempty"`
+		CommandPollSeconds int      `json:"commandPollSeconds,omitempty"`
+		EnabledCollectors  []string `json:"enabledCollectors,omitempty"`
+		DisabledCollectors []string `json:"disabledCollectors,omitempty"`
+	} `json:"collection,omitempty"`
+	Namespaces struct {
+		Include []string `json:"include,omitempty"`
+		Exclude []string `json:"exclude,omitempty"`
+	} `json:"namespaces,omitempty"`
+	Commands struct {
+		Allowed           []string `json:"allowed,omitempty"`
+		Denied            []string `json:"denied,omitempty"`
+		Concurrency       int      `json:"concurrency,omitempty"`
+		PatchAllowedKinds []string `json:"patchAllowedKinds,omitempty"`
+	} `json:"commands,omitempty"`
+	CustomMetrics *CustomMetricsConfig `json:"customMetrics,omitempty"`
+	Mesh          struct {
+		Telemetry *bool `json:"telemetry,omitempty"`
+	} `json:"mesh,omitempty"`
+	RBAC struct {
+		Subjects *bool `json:"subjects,omitempty"`
+	} `json:"rbac,omitempty"`
+	Secrets struct {
+		ExcludeHelm *bool `json:"excludeHelm,omitempty"`
+	} `json:"secrets,omitempty"`
+	Threats struct {
+		Suppressions       []FindingSuppression `json:"suppressions,omitempty"`
+		ConnectionSampling *bool                `json:"connectionSampling,omitempty"`
+	} `json:"threats,omitempty"`
+	Tenants *TenantPolicy `json:"tenants,omitempty"`
+}
+
+// agentConfigController applies a single named KuberPulseConfig to the runtime settings
+type agentConfigController struct {
+	client dynamic.Interface
+	config AgentConfig
+
+	// appliedUID/appliedGeneration skip updates that only touched status
+	appliedUID        string
+	appliedGeneration int64
+}
+
+// watchAgentConfig runs the KuberPulseConfig controller. It waits for the CRD
+// to be installed and never returns once the informer is running.
+func watchAgentConfig(kubeconfig *rest.Config, config AgentConfig) {
+	client, err := dynamic.NewForConfig(kubeconfig)
+	if err != nil {
+		log.Printf("⚠️  KuberPulseConfig watch disabled: %v", err)
+		return
+	}
+	disco, err := discovery.NewDiscoveryClientForConfig(kubeconfig)
+	if err != nil {
+		log.Printf("⚠️  KuberPulseConfig watch disabled: %v", err)
+		return
+	}
+
+	// Without the CRD an informer would log list errors forever; wait for it quietly
+	for !kuberPulseConfigInstalled(disco) {
+		log.Printf("ℹ️  KuberPulseConfig CRD not installed, using env settings (rechecking in %v)", configCRDRecheck)
+		time.Sleep(configCRDRecheck)
+	}
+
+	c := &agentConfigController{client: client, config: config}
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, configResync, config.Namespace, func(opts *metav1.ListOptions) {
+		opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", config.ConfigName).String()
+	})
+	informer := factory.ForResource(kuberPulseConfigGVR).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.onChange,
+		UpdateFunc: func(_, obj interface{}) { c.onChange(obj) },
+		DeleteFunc: c.onDelete,
+	})
+
+	log.Printf("👀 Watching KuberPulseConfig %s/%s", config.Namespace, config.ConfigName)
+	factory.Start(make(chan struct{}))
+}
+
+func kuberPulseConfigInstalled(disco discovery.DiscoveryInterface) bool {
+	resources, err := disco.ServerResourcesForGroupVersion(kuberPulseConfigGVR.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == kuberPulseConfigGVR.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *agentConfigController) onChange(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	if string(u.GetUID()) == c.appliedUID && u.GetGeneration() == c.appliedGeneration {
+		return
+	}
+
+	settings, err := c.settingsFromConfig(u)
+	if err != nil {
+		log.Printf("❌ Invalid KuberPulseConfig %s/%s (generation %d), keeping current settings: %v",
+			u.GetNamespace(), u.GetName(), u.GetGeneration(), err)
+	} else {
+		applySettings(settings)
+		log.Printf("🔧 Applied KuberPulseConfig %s/%s: metrics every %v, commands every %v",
+			u.GetNamespace(), u.GetName(), settings.Interval, settings.CommandInterval)
+	}
+
+	c.appliedUID = string(u.GetUID())
+	c.appliedGeneration = u.GetGeneration()
+	c.updateStatus(u, err)
+}
+
+func (c *agentConfigController) onDelete(obj interface{}) {
+	c.appliedUID = ""
+	c.appliedGeneration = 0
+	applySettings(defaultSettings(c.config))
+	log.Printf("🔧 KuberPulseConfig %s/%s deleted, reverted to env settings", c.config.Namespace, c.config.ConfigName)
+}
+
+// settingsFromConfig validates the spec and layers it over the env defaults
+func (c *agentConfigController) settingsFromConfig(u *unstructured.Unstructured) (*AgentSettings, error) {
+	var spec KuberPulseConfigSpec
+	if rawSpec, ok := u.Object["spec"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, &spec); err != nil {
+			return nil, fmt.Errorf("failed to decode spec: %v", err)
+		}
+	}
+
+	settings := defaultSettings(c.config)
+	settings.Source = fmt.Sprintf("crd:%s/%s@%d", u.GetNamespace(), u.GetName(), u.GetGeneration())
+
+	if spec.Collection.IntervalSeconds > 0 {
+		settings.Interval = time.Duration(spec.Collection.IntervalSeconds) * time.Second
+	}
+	if spec.Collection.CommandPollSeconds > 0 {
+		settings.CommandInterval = time.Duration(spec.Collection.CommandPollSeconds) * time.Second
+	}
+	if settings.Interval < minConfigInterval || settings.CommandInterval < minConfigInterval {
+		return nil, fmt.Errorf("intervals must be at least %v", minConfigInterval)
+	}
+
+	settings.EnabledCollectors = stringSet(spec.Collection.EnabledCollectors)
+	settings.DisabledCollectors = stringSet(spec.Collection.DisabledCollectors)
+	settings.IncludeNamespaces = stringSet(spec.Namespaces.Include)
+	settings.ExcludeNamespaces = stringSet(spec.Namespaces.Exclude)
+	settings.AllowedCommands = stringSet(spec.Commands.Allowed)
+	settings.DeniedCommands = stringSet(spec.Commands.Denied)
+	if len(spec.Commands.PatchAllowedKinds) > 0 {
+		settings.PatchableKinds = patchableKinds(spec.Commands.PatchAllowedKinds)
+	}
+	if spec.Commands.Concurrency > 0 {
+		settings.CommandConcurrency = clampCommandConcurrency(spec.Commands.Concurrency)
+	}
+
+	if spec.CustomMetrics != nil {
+		if err := spec.CustomMetrics.Validate(); err != nil {
+			return nil, err
+		}
+		settings.CustomMetrics = *spec.CustomMetrics
+	}
+	if spec.Mesh.Telemetry != nil {
+		settings.MeshTelemetry = *spec.Mesh.Telemetry
+	}
+	if spec.RBAC.Subjects != nil {
+		settings.RBACSubjects = *spec.RBAC.Subjects
+	}
+	if spec.Secrets.ExcludeHelm != nil {
+		settings.ExcludeHelmSecrets = *spec.Secrets.ExcludeHelm
+	}
+	if len(spec.Threats.Suppressions) > 0 {
+		for _, s := range spec.Threats.Suppressions {
+			if err := s.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		settings.Suppressions = spec.Threats.Suppressions
+	}
+	if spec.Threats.ConnectionSampling != nil {
+		settings.ConnectionSampling = *spec.Threats.ConnectionSampling
+	}
+	if spec.Tenants != nil {
+		if err := spec.Tenants.Validate(); err != nil {
+			return nil, err
+		}
+		settings.Tenants = *spec.Tenants
+	}
+
+	return settings, nil
+}
+
+// updateStatus reports which generation the agent applied (or why it refused it)
+func (c *agentConfigController) updateStatus(u *unstructured.Unstructured, applyErr error) {
+	status := map[string]interface{}{
+		"observedGeneration": u.GetGeneration(),
+		"agentVersion":       AgentVersion,
+		"lastAppliedTime":    time.Now().UTC().Format(time.RFC3339),
+		"applied":            applyErr == nil,
+	}
+	if applyErr != nil {
+		status["message"] = applyErr.Error()
+	}
+
+	updated := u.DeepCopy()
+	updated.Object["status"] = status
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	if _, err := c.client.Resource(kuberPulseConfigGVR).Namespace(u.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		log.Printf("⚠️  Failed to update KuberPulseConfig status: %v", err)
+	}
+}
+
+// stringSet turns a list into a lookup set; an empty list yields nil ("all")
+func stringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------
+// COMMAND RESULT LIMITS AND ARTIFACTS
+// Manifests, describes and logs can be megabytes. A command result above
+// commandResultMaxBytes is uploaded whole as an artifact (to a pre-signed URL
+// when the backend hands one out, otherwise in chunks to agent-command-artifact)
+// and the status update carries a truncated copy pointing at it. Without
+// artifact support the truncated copy is all the backend gets.
+// ---------------------------------------------
+
+const (
+	// commandResultMaxBytes is the largest result sent inline in a status update
+	commandResultMaxBytes = 256 * 1024
+	// artifactMaxBytes caps what is uploaded at all
+	artifactMaxBytes = 32 * 1024 * 1024
+	// artifactChunkBytes is the size of each chunked upload request
+	artifactChunkBytes = 1024 * 1024
+	// truncatedPreviewBytes of a truncated string field are kept inline
+	truncatedPreviewBytes = 4 * 1024
+)
+
+// artifactInitResponse is returned by agent-command-artifact for a new upload
+type artifactInitResponse struct {
+	ArtifactID string `json:"artifact_id"`
+	// UploadURL is a pre-signed URL taking the whole artifact in one PUT
+	UploadURL string `json:"upload_url,omitempty"`
+}
+
+// limitCommandResult returns result unchanged when its encoding fits inline,
+// otherwise uploads it as an artifact and returns a truncated copy
+func limitCommandResult(config AgentConfig, commandID string, result map[string]interface{}) map[string]interface{} {
+	full, err := marshalRedacted(result, "command_result")
+	if err != nil || len(full) <= commandResultMaxBytes {
+		return result
+	}
+
+	limited := truncateResult(result, commandResultMaxBytes)
+	limited["original_size_bytes"] = len(full)
+	if len(full) > artifactMaxBytes {
+		limited["artifact_error"] = fmt.Sprintf("result of %d bytes exceeds the %d byte artifact limit", len(full), artifactMaxBytes)
+		return limited
+	}
+	artifact, err := uploadArtifact(config, map[string]interface{}{"command_id": commandID}, full, "application/json")
+	if err != nil {
+		log.Printf("⚠️  Artifact upload for command %s failed, sending truncated result: %v", commandID, err)
+		limited["artifact_error"] = err.Error()
+		return limited
+	}
+	log.Printf("📦 Command %s result uploaded as artifact %s (%d bytes)", commandID, artifact["id"], len(full))
+	limited["artifact"] = artifact
+	return limited
+}
+
+// truncateResult shortens the largest top-level fields until the result fits
+// in limit bytes; every shortened field is listed under "truncated_fields"
+func truncateResult(result map[string]interface{}, limit int) map[string]interface{} {
+	out := make(map[string]interface{}, len(result)+3)
+	sizes := map[string]int{}
+	total := 0
+	for key, value := range result {
+		out[key] = value
+		encoded, _ := json.Marshal(value)
+		sizes[key] = len(encoded)
+		total += len(encoded)
+	}
+	keys := make([]string, 0, len(sizes))
+	for key := range sizes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return sizes[keys[i]] > sizes[keys[j]] })
+
+	var truncated []string
+	for _, key := range keys {
+		if total <= limit {
+			break
+		}
+		marker := fmt.Sprintf("…[truncated %d bytes]", sizes[key])
+		if s, ok := result[key].(string); ok && len(s) > truncatedPreviewBytes {
+			out[key] = strings.ToValidUTF8(s[:truncatedPreviewBytes], "") + marker
+			total -= sizes[key] - truncatedPreviewBytes - len(marker)
+		} else {
+			out[key] = marker
+			total -= sizes[key] - len(marker)
+		}
+		truncated = append(truncated, key)
+	}
+	sort.Strings(truncated)
+	out["truncated"] = true
+	out["truncated_fields"] = truncated
+	return out
+}
+
+// uploadArtifact registers the artifact (meta says what it belongs to, e.g.
+// command_id) and uploads it, returning its descriptor
+func uploadArtifact(config AgentConfig, meta map[string]interface{}, data []byte, contentType string) (artifact map[string]interface{}, err error) {
+	defer func(start time.Time) {
+		diagnostics.recordBackendCall("agent-command-artifact", start, err)
+	}(time.Now())
+
+	checksum := sha256Hex(data)
+	chunks := (len(data) + artifactChunkBytes - 1) / artifactChunkBytes
+	registration := map[string]interface{}{
+		"size":         len(data),
+		"sha256":       checksum,
+		"content_type": contentType,
+		"chunks":       chunks,
+	}
+	for k, v := range meta {
+		registration[k] = v
+	}
+	initBody, err := json.Marshal(registration)
+	if err != nil {
+		return nil, err
+	}
+
+	var init artifactInitResponse
+	if err := artifactRequest(config, "POST", fmt.Sprintf("%s/agent-command-artifact", config.APIEndpoint), initBody, nil, &init); err != nil {
+		return nil, fmt.Errorf("registering artifact: %w", err)
+	}
+	if init.ArtifactID == "" {
+		return nil, fmt.Errorf("backend returned no artifact_id")
+	}
+
+	method := "presigned"
+	if init.UploadURL != "" {
+		if err := putPresigned(config, init.UploadURL, data, contentType); err != nil {
+			return nil, err
+		}
+	} else {
+		method = "chunked"
+		url := fmt.Sprintf("%s/agent-command-artifact-chunk", config.APIEndpoint)
+		for i := 0; i < chunks; i++ {
+			end := (i + 1) * artifactChunkBytes
+			if end > len(data) {
+				end = len(data)
+			}
+			headers := map[string]string{
+				"Content-Type":  "application/octet-stream",
+				"x-artifact-id": init.ArtifactID,
+				"x-chunk-index": strconv.Itoa(i),
+				"x-chunk-count": strconv.Itoa(chunks),
+			}
+			if err := artifactRequest(config, "POST", url, data[i*artifactChunkBytes:end], headers, nil); err != nil {
+				return nil, fmt.Errorf("uploading chunk %d/%d: %w", i+1, chunks, err)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"id":           init.ArtifactID,
+		"size_bytes":   len(data),
+		"sha256":       checksum,
+		"content_type": contentType,
+		"upload":       method,
+	}, nil
+}
+
+// artifactRequest sends one authenticated request to the backend and decodes
+// the JSON response into out (when non-nil)
+func artifactRequest(config AgentConfig, method, url string, body []byte, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("x-agent-version", AgentVersion)
+	if err := authenticateRequest(config, req, agentAPIKey(config), body); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	resp, err := backendClient(config).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bandwidth.recordSend("agent-command-artifact", len(body), 0, len(body), time.Since(start))
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("backend returned %d: %s", resp.StatusCode, string(respBody))
+	}
+	if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
+
+// putPresigned uploads the whole artifact to a pre-signed URL (no agent credentials)
+func putPresigned(config AgentConfig, url string, data []byte, contentType string) error {
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	start := time.Now()
+	resp, err := backendClient(config).Do(req)
+	if err != nil {
+		return fmt.Errorf("pre-signed upload: %w", err)
+	}
+	defer resp.Body.Close()
+	bandwidth.recordSend("agent-command-artifact", len(data), 0, len(data), time.Since(start))
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pre-signed upload returned %d", resp.StatusCode)
+	}
+	return nil
+}
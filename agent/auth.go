@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/oauth2/jwt"
+)
+
+// ---------------------------------------------
+// BACKEND AUTHENTICATION
+// Every backend call carries x-agent-key (it identifies the cluster). When
+// the agent talks to the backend through a customer ingestion gateway, an
+// extra authenticator selected by AUTH_PROVIDER adds what the gateway
+// expects: an OAuth2 client-credentials token, an AWS SigV4 signature or a
+// GCP service-account identity token.
+// ---------------------------------------------
+
+// Values of AUTH_PROVIDER
+const (
+	authAPIKey    = "api_key"
+	authOAuth2    = "oauth2"
+	authAWSSigV4  = "aws_sigv4"
+	authGCPToken  = "gcp"
+	gcpTokenLife  = 55 * time.Minute
+	awsCredsSlack = 5 * time.Minute
+)
+
+// backendAuthenticator adds gateway credentials to a request; body is the
+// exact bytes that will be sent (nil for requests without one)
+type backendAuthenticator interface {
+	authenticate(req *http.Request, body []byte) error
+}
+
+var backendAuth struct {
+	once sync.Once
+	auth backendAuthenticator
+	err  error
+}
+
+// authenticateRequest sets x-agent-key (when apiKey is not empty) and lets
+// the configured provider sign the request. Call it after every other
+// header is set.
+func authenticateRequest(config AgentConfig, req *http.Request, apiKey string, body []byte) error {
+	if apiKey != "" {
+		req.Header.Set("x-agent-key", apiKey)
+	}
+	backendAuth.once.Do(func() {
+		backendAuth.auth, backendAuth.err = newBackendAuthenticator(config)
+	})
+	if backendAuth.err != nil {
+		return fmt.Errorf("auth provider %q: %v", config.AuthProvider, backendAuth.err)
+	}
+	if backendAuth.auth == nil {
+		return nil
+	}
+	if err := backendAuth.auth.authenticate(req, body); err != nil {
+		return fmt.Errorf("auth provider %q: %v", config.AuthProvider, err)
+	}
+	return nil
+}
+
+// newBackendAuthenticator builds the provider named by config.AuthProvider
+// (nil for the plain API key)
+func newBackendAuthenticator(config AgentConfig) (backendAuthenticator, error) {
+	switch config.AuthProvider {
+	case "", authAPIKey:
+		return nil, nil
+	case authOAuth2:
+		if config.OAuth2TokenURL == "" || config.OAuth2ClientID == "" {
+			return nil, fmt.Errorf("AUTH_OAUTH2_TOKEN_URL and AUTH_OAUTH2_CLIENT_ID are required")
+		}
+		cc := &clientcredentials.Config{
+			ClientID:     config.OAuth2ClientID,
+			ClientSecret: config.OAuth2ClientSecret,
+			TokenURL:     config.OAuth2TokenURL,
+			Scopes:       splitList(config.OAuth2Scopes),
+		}
+		return &bearerAuth{source: cc.TokenSource(context.Background())}, nil
+	case authAWSSigV4:
+		if config.AWSRegion == "" {
+			return nil, fmt.Errorf("AUTH_AWS_REGION (or AWS_REGION) is required")
+		}
+		return &sigV4Auth{region: config.AWSRegion, service: config.AWSService}, nil
+	case authGCPToken:
+		if config.GCPAudience == "" {
+			return nil, fmt.Errorf("AUTH_GCP_AUDIENCE is required")
+		}
+		source, err := gcpTokenSource(config)
+		if err != nil {
+			return nil, err
+		}
+		return &bearerAuth{source: source}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider (expected %s, %s, %s or %s)", authAPIKey, authOAuth2, authAWSSigV4, authGCPToken)
+	}
+}
+
+// splitList parses a comma/space separated list
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// bearerAuth sends a cached OAuth2/OIDC token as Authorization: Bearer
+type bearerAuth struct {
+	source oauth2.TokenSource
+}
+
+func (b *bearerAuth) authenticate(req *http.Request, _ []byte) error {
+	token, err := b.source.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+// ---------------------------------------------
+// GCP identity tokens
+// ---------------------------------------------
+
+// gcpTokenSource returns identity tokens for config.GCPAudience, minted from a
+// service-account key file when configured and from the metadata server
+// (GKE Workload Identity, GCE) otherwise
+func gcpTokenSource(config AgentConfig) (oauth2.TokenSource, error) {
+	if config.GCPCredentialsFile == "" {
+		return oauth2.ReuseTokenSource(nil, gcpMetadataSource{audience: config.GCPAudience}), nil
+	}
+
+	raw, err := os.ReadFile(config.GCPCredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var key struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("invalid service account key %s: %v", config.GCPCredentialsFile, err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	jc := &jwt.Config{
+		Email:         key.ClientEmail,
+		PrivateKey:    []byte(key.PrivateKey),
+		PrivateKeyID:  key.PrivateKeyID,
+		TokenURL:      key.TokenURI,
+		PrivateClaims: map[string]interface{}{"target_audience": config.GCPAudience},
+		UseIDToken:    true,
+	}
+	return jc.TokenSource(context.Background()), nil
+}
+
+// gcpMetadataSource fetches identity tokens from the GCE/GKE metadata server
+type gcpMetadataSource struct {
+	audience string
+}
+
+func (g gcpMetadataSource) Token() (*oauth2.Token, error) {
+	endpoint := "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity?audience=" +
+		url.QueryEscape(g.audience)
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("metadata server: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
+	}
+	// Identity tokens live one hour; renew a little earlier
+	return &oauth2.Token{AccessToken: strings.TrimSpace(string(body)), Expiry: time.Now().Add(gcpTokenLife)}, nil
+}
+
+// ---------------------------------------------
+// AWS SigV4
+// ---------------------------------------------
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiry          time.Time // zero for static credentials
+}
+
+// sigV4Auth signs requests for API Gateway (or any SigV4 service). Credentials
+// come from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY(/AWS_SESSION_TOKEN) or,
+// with IRSA, from AWS_ROLE_ARN + AWS_WEB_IDENTITY_TOKEN_FILE via STS.
+type sigV4Auth struct {
+	region  string
+	service string
+
+	mu    sync.Mutex
+	creds *awsCredentials
+}
+
+func (s *sigV4Auth) credentials() (*awsCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.creds != nil && (s.creds.Expiry.IsZero() || time.Until(s.creds.Expiry) > awsCredsSlack) {
+		return s.creds, nil
+	}
+
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		s.creds = &awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+		return s.creds, nil
+	}
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN == "" || tokenFile == "" {
+		return nil, fmt.Errorf("no AWS credentials (set AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or use IRSA)")
+	}
+	creds, err := assumeRoleWithWebIdentity(s.region, roleARN, tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	s.creds = creds
+	return creds, nil
+}
+
+// assumeRoleWithWebIdentity exchanges the projected service account token
+// for temporary credentials (the call itself is unsigned)
+func assumeRoleWithWebIdentity(region, roleARN, tokenFile string) (*awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {"kodo-agent"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/?%s", region, query.Encode())
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("sts: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sts returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed struct {
+		Result struct {
+			Credentials struct {
+				AccessKeyID     string    `xml:"AccessKeyId"`
+				SecretAccessKey string    `xml:"SecretAccessKey"`
+				SessionToken    string    `xml:"SessionToken"`
+				Expiration      time.Time `xml:"Expiration"`
+			} `xml:"Credentials"`
+		} `xml:"AssumeRoleWithWebIdentityResult"`
+	}
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid sts response: %v", err)
+	}
+	c := parsed.Result.Credentials
+	return &awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expiry: c.Expiration}, nil
+}
+
+func (s *sigV4Auth) authenticate(req *http.Request, body []byte) error {
+	creds, err := s.credentials()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalQuery encodes a query string the way SigV4 expects (sorted, %20 for spaces)
+func canonicalQuery(values url.Values) string {
+	var pairs []string
+	for key, vals := range values {
+		for _, v := range vals {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
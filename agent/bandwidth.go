@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// BANDWIDTH TELEMETRY
+// Bytes sent per metric type and per backend endpoint, compression ratios
+// and send durations, so the network footprint of the agent can be seen
+// (and tuned through collectors/intervals) per cluster. Payloads are gzipped
+// when the backend declares it accepts gzip in the capability handshake.
+// ---------------------------------------------
+
+// transferTotals accumulates the sends to one backend endpoint
+type transferTotals struct {
+	Sends      int64
+	RawBytes   int64
+	WireBytes  int64
+	GzipBytes  int64
+	Duration   time.Duration
+	LastRaw    int
+	LastWire   int
+	LastTook   time.Duration
+	LastSentAt time.Time
+}
+
+type bandwidthTracker struct {
+	mu          sync.Mutex
+	since       time.Time
+	metricBytes map[string]int64
+	lastMetric  map[string]int64
+	endpoints   map[string]*transferTotals
+}
+
+var bandwidth = &bandwidthTracker{
+	since:       time.Now(),
+	metricBytes: map[string]int64{},
+	lastMetric:  map[string]int64{},
+	endpoints:   map[string]*transferTotals{},
+}
+
+// gzipBytes compresses body; it is always computed so the ratio is known
+// even when the backend does not accept compressed payloads
+func gzipBytes(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeForWire returns the bytes to send for body, whether they are gzipped
+// (the caller then sets Content-Encoding) and the gzip size for the ratio
+func encodeForWire(body []byte) ([]byte, bool, int) {
+	compressed, err := gzipBytes(body)
+	if err != nil {
+		return body, false, 0
+	}
+	if !capabilities.acceptsGzip() || len(compressed) >= len(body) {
+		return body, false, len(compressed)
+	}
+	return compressed, true, len(compressed)
+}
+
+// recordMetricSizes attributes the encoded size of each metric to its type
+func (b *bandwidthTracker) recordMetricSizes(metrics []map[string]interface{}) {
+	sizes := make(map[string]int64, len(metrics))
+	for _, metric := range metrics {
+		encoded, err := json.Marshal(metric)
+		if err != nil {
+			continue
+		}
+		sizes[fmt.Sprint(metric["type"])] += int64(len(encoded))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastMetric = sizes
+	for metricType, size := range sizes {
+		b.metricBytes[metricType] += size
+	}
+}
+
+// recordSend stores one call to a backend endpoint
+func (b *bandwidthTracker) recordSend(endpoint string, raw, gzipSize, wire int, took time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.endpoints[endpoint]
+	if t == nil {
+		t = &transferTotals{}
+		b.endpoints[endpoint] = t
+	}
+	t.Sends++
+	t.RawBytes += int64(raw)
+	t.GzipBytes += int64(gzipSize)
+	t.WireBytes += int64(wire)
+	t.Duration += took
+	t.LastRaw, t.LastWire, t.LastTook, t.LastSentAt = raw, wire, took, time.Now()
+}
+
+// report returns the bandwidth section sent with metrics and shown by diagnose
+func (b *bandwidthTracker) report() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lastTotal int64
+	for _, size := range b.lastMetric {
+		lastTotal += size
+	}
+	perType := make(map[string]interface{}, len(b.metricBytes))
+	for metricType, total := range b.metricBytes {
+		entry := map[string]interface{}{
+			"last_bytes":  b.lastMetric[metricType],
+			"total_bytes": total,
+		}
+		if lastTotal > 0 {
+			entry["last_share_percent"] = float64(b.lastMetric[metricType]) * 100 / float64(lastTotal)
+		}
+		perType[metricType] = entry
+	}
+
+	endpoints := make(map[string]interface{}, len(b.endpoints))
+	var wireTotal int64
+	for endpoint, t := range b.endpoints {
+		wireTotal += t.WireBytes
+		entry := map[string]interface{}{
+			"sends":           t.Sends,
+			"raw_bytes":       t.RawBytes,
+			"wire_bytes":      t.WireBytes,
+			"last_raw_bytes":  t.LastRaw,
+			"last_wire_bytes": t.LastWire,
+			"last_send_ms":    t.LastTook.Milliseconds(),
+			"avg_send_ms":     (t.Duration / time.Duration(t.Sends)).Milliseconds(),
+			"last_sent_at":    t.LastSentAt.UTC().Format(time.RFC3339),
+		}
+		if t.WireBytes > 0 {
+			entry["compression_ratio"] = float64(t.RawBytes) / float64(t.WireBytes)
+		}
+		if t.GzipBytes > 0 {
+			entry["gzip_ratio"] = float64(t.RawBytes) / float64(t.GzipBytes)
+		}
+		endpoints[endpoint] = entry
+	}
+
+	result := map[string]interface{}{
+		"since":            b.since.UTC().Format(time.RFC3339),
+		"gzip_enabled":     capabilities.acceptsGzip(),
+		"metric_types":     perType,
+		"endpoints":        endpoints,
+		"total_wire_bytes": wireTotal,
+	}
+	if hours := time.Since(b.since).Hours(); hours > 0 {
+		result["wire_bytes_per_hour"] = int64(float64(wireTotal) / hours)
+	}
+	return result
+}
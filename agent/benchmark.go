@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// NODE BENCHMARK COMMAND
+// "benchmark_node" (opt-in with NODE_BENCHMARK and kubernetes/node-benchmark.yaml)
+// starts a short-lived pod pinned to a node that runs `kodo-agent benchmark`
+// from the agent's own image: CPU hashing throughput (one core and all
+// cores), disk write/fsync on the node's ephemeral storage and DNS / API
+// server connect latency. The scores come back in the container's
+// termination message and the pod is deleted afterwards.
+// ---------------------------------------------
+
+const (
+	// defaultBenchmarkSeconds is the time spent per test unless the command sets it
+	defaultBenchmarkSeconds = 5
+	// maxBenchmarkSeconds bounds duration_seconds
+	maxBenchmarkSeconds = 30
+	// benchmarkStartTimeout covers scheduling and the image pull
+	benchmarkStartTimeout = 3 * time.Minute
+	// benchmarkDiskBytes is written by the disk test (in 1 MiB blocks)
+	benchmarkDiskBytes = 256 * 1024 * 1024
+	// defaultAgentImage is used when the agent cannot read its own pod
+	defaultAgentImage = "ghcr.io/kubenetworks-group/kodo-agent:latest"
+)
+
+// benchmarkTests are the tests benchmark_node can run
+var benchmarkTests = map[string]bool{"cpu": true, "disk": true, "network": true}
+
+// benchmarkResult is what `kodo-agent benchmark` writes to its termination
+// message; it must stay under the 4 KiB kubelet limit
+type benchmarkResult struct {
+	CPU     map[string]float64 `json:"cpu,omitempty"`
+	Disk    map[string]float64 `json:"disk,omitempty"`
+	Network map[string]float64 `json:"network,omitempty"`
+	Errors  map[string]string  `json:"errors,omitempty"`
+}
+
+// benchmarkNode implements the "benchmark_node" command
+func benchmarkNode(clientset kubernetes.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	if !config.NodeBenchmark {
+		return nil, fmt.Errorf("benchmark_node is disabled; set NODE_BENCHMARK=true and apply kubernetes/node-benchmark.yaml")
+	}
+	var p BenchmarkNodeParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	seconds := p.DurationSeconds
+	if seconds == 0 {
+		seconds = defaultBenchmarkSeconds
+	}
+	tests := p.Tests
+	if len(tests) == 0 {
+		tests = sortedKeys(benchmarkTests)
+	}
+
+	ctx, cancel := apiContext()
+	node, err := clientset.CoreV1().Nodes().Get(ctx, p.NodeName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", p.NodeName, err)
+	}
+	if node.Labels[corev1.LabelOSStable] == osWindows {
+		return nil, fmt.Errorf("node %s runs Windows; benchmark_node only supports Linux nodes", p.NodeName)
+	}
+	// Always the agent's own image: the pod bypasses the scheduler and
+	// tolerates every taint, so the backend must not choose what it runs
+	image := agentImage(clientset, config)
+
+	pod := benchmarkPod(config.Namespace, p.NodeName, image, tests, seconds)
+	log.Printf("🏁 [audit] Starting benchmark pod %s/%s on node %s (%v, %ds per test)", pod.Namespace, pod.Name, p.NodeName, tests, seconds)
+	ctx, cancel = apiContext()
+	created, err := clientset.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{FieldManager: fieldManager})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create benchmark pod: %w", err)
+	}
+	defer func() {
+		ctx, cancel := apiContext()
+		defer cancel()
+		if err := clientset.CoreV1().Pods(created.Namespace).Delete(ctx, created.Name, metav1.DeleteOptions{}); err != nil {
+			log.Printf("⚠️  Failed to delete benchmark pod %s/%s: %v", created.Namespace, created.Name, err)
+		}
+	}()
+
+	started := time.Now()
+	timeout := benchmarkStartTimeout + time.Duration(len(tests)*seconds*3)*time.Second
+	terminated, err := waitForPodExit(clientset, created.Namespace, created.Name, "benchmark", timeout)
+	if err != nil {
+		return nil, err
+	}
+	var scores benchmarkResult
+	if err := json.Unmarshal([]byte(terminated.Message), &scores); err != nil {
+		return nil, fmt.Errorf("benchmark exited with code %d and no readable result: %q", terminated.ExitCode, terminated.Message)
+	}
+
+	return map[string]interface{}{
+		"action":           "node_benchmarked",
+		"node":             p.NodeName,
+		"instance_type":    node.Labels[corev1.LabelInstanceTypeStable],
+		"zone":             node.Labels[corev1.LabelTopologyZone],
+		"kernel":           node.Status.NodeInfo.KernelVersion,
+		"cpu_capacity":     node.Status.Capacity.Cpu().String(),
+		"duration_seconds": seconds,
+		"tests":            tests,
+		"scores":           scores,
+		"elapsed_seconds":  time.Since(started).Seconds(),
+	}, nil
+}
+
+// agentImage returns the image of the agent's own container
+func agentImage(clientset kubernetes.Interface, config AgentConfig) string {
+	hostname, _ := os.Hostname()
+	ctx, cancel := apiContext()
+	pod, err := clientset.CoreV1().Pods(config.Namespace).Get(ctx, hostname, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return defaultAgentImage
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "agent" {
+			return c.Image
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Image
+	}
+	return defaultAgentImage
+}
+
+// benchmarkPod builds the pod that runs the benchmark on nodeName; it
+// bypasses the scheduler and tolerates every taint so any node can be measured
+func benchmarkPod(namespace, nodeName, image string, tests []string, seconds int) *corev1.Pod {
+	args := []string{"./kodo-agent", "benchmark", fmt.Sprintf("--seconds=%d", seconds), "--dir=/bench"}
+	for _, t := range tests {
+		args = append(args, "--test="+t)
+	}
+	deadline := int64(benchmarkStartTimeout.Seconds()) + int64(len(tests)*seconds*3)
+	yes, no := true, false
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kodo-benchmark-" + rand.String(5),
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "kodo-benchmark", "app.kubernetes.io/managed-by": "kodo-agent"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                     nodeName,
+			RestartPolicy:                corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds:        &deadline,
+			AutomountServiceAccountToken: &no,
+			Tolerations:                  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:                     "benchmark",
+				Image:                    image,
+				Command:                  args,
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+				Resources: corev1.ResourceRequirements{
+					// No CPU limit: the multi-core score should see the whole node
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:              resource.MustParse("100m"),
+						corev1.ResourceMemory:           resource.MustParse("32Mi"),
+						corev1.ResourceEphemeralStorage: resource.MustParse("300Mi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceMemory:           resource.MustParse("128Mi"),
+						corev1.ResourceEphemeralStorage: resource.MustParse("512Mi"),
+					},
+				},
+				// The agent image runs from /root, so no RunAsNonRoot; every capability is dropped
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &no,
+					ReadOnlyRootFilesystem:   &yes,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "bench", MountPath: "/bench"}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "bench",
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			}},
+		},
+	}
+}
+
+// waitForPodExit waits until the first container of a short-lived pod
+// terminates; what names the pod in errors
+func waitForPodExit(clientset kubernetes.Interface, namespace, name, what string, timeout time.Duration) (*corev1.ContainerStateTerminated, error) {
+	var terminated *corev1.ContainerStateTerminated
+	var waiting string
+	err := wait.PollUntilContextTimeout(context.Background(), 3*time.Second, timeout, false, func(ctx context.Context) (bool, error) {
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Terminated != nil {
+				terminated = cs.State.Terminated
+				return true, nil
+			}
+			if cs.State.Waiting != nil {
+				waiting = cs.State.Waiting.Reason
+			}
+		}
+		if pod.Status.Phase == corev1.PodFailed {
+			return false, fmt.Errorf("%s pod failed: %s %s", what, pod.Status.Reason, pod.Status.Message)
+		}
+		return false, nil
+	})
+	if err != nil {
+		if terminated == nil && waiting != "" {
+			return nil, fmt.Errorf("%s did not finish within %v (container waiting: %s)", what, timeout, waiting)
+		}
+		return nil, fmt.Errorf("%s did not finish within %v: %w", what, timeout, err)
+	}
+	return terminated, nil
+}
+
+// runBenchmark is `kodo-agent benchmark`: it runs the tests and writes the
+// scores to the termination message (and stdout)
+func runBenchmark(args []string) error {
+	fs := flag.NewFlagSet("benchmark", flag.ExitOnError)
+	seconds := fs.Int("seconds", defaultBenchmarkSeconds, "time spent per test")
+	dir := fs.String("dir", os.TempDir(), "directory the disk test writes to")
+	out := fs.String("out", "/dev/termination-log", "file the JSON result is written to")
+	var tests stringList
+	fs.Var(&tests, "test", "test to run: cpu, disk or network (repeatable, default all)")
+	fs.Parse(args)
+	if len(tests) == 0 {
+		tests = sortedKeys(benchmarkTests)
+	}
+	duration := time.Duration(*seconds) * time.Second
+
+	result := benchmarkResult{Errors: map[string]string{}}
+	for _, test := range tests {
+		var err error
+		switch test {
+		case "cpu":
+			result.CPU = benchmarkCPU(duration)
+		case "disk":
+			result.Disk, err = benchmarkDisk(*dir, duration)
+		case "network":
+			result.Network, err = benchmarkNetwork(duration)
+		default:
+			err = fmt.Errorf("unknown test")
+		}
+		if err != nil {
+			result.Errors[test] = err.Error()
+		}
+	}
+	if len(result.Errors) == 0 {
+		result.Errors = nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	if err := os.WriteFile(*out, data, 0o644); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string     { return fmt.Sprint(*l) }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// benchmarkCPU reports SHA-256 throughput on one goroutine and on all CPUs
+func benchmarkCPU(duration time.Duration) map[string]float64 {
+	hashRate := func(workers int, d time.Duration) float64 {
+		var bytes int64
+		var wg sync.WaitGroup
+		deadline := time.Now().Add(d)
+		block := make([]byte, 64*1024)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var n int64
+				for time.Now().Before(deadline) {
+					for j := 0; j < 16; j++ {
+						sha256.Sum256(block)
+					}
+					n += int64(16 * len(block))
+				}
+				atomic.AddInt64(&bytes, n)
+			}()
+		}
+		wg.Wait()
+		return float64(bytes) / d.Seconds() / (1024 * 1024)
+	}
+	cpus := runtime.NumCPU()
+	single := hashRate(1, duration/2)
+	multi := hashRate(cpus, duration/2)
+	return map[string]float64{
+		"sha256_single_mib_s": round2(single),
+		"sha256_multi_mib_s":  round2(multi),
+		"cpus":                float64(cpus),
+		// 1.0 means perfect scaling across the visible CPUs
+		"scaling": round2(multi / single / float64(cpus)),
+	}
+}
+
+// benchmarkDisk measures sequential write throughput and small fsync latency in dir
+func benchmarkDisk(dir string, duration time.Duration) (map[string]float64, error) {
+	path := filepath.Join(dir, "kodo-benchmark.dat")
+	defer os.Remove(path)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Sequential write, made durable by one fsync at the end
+	block := make([]byte, 1024*1024)
+	start := time.Now()
+	written := 0
+	for written < benchmarkDiskBytes && time.Since(start) < duration/2 {
+		n, err := f.Write(block)
+		if err != nil {
+			return nil, err
+		}
+		written += n
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	writeSeconds := time.Since(start).Seconds()
+
+	// 4 KiB write+fsync round trips, the pattern etcd and databases care about
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	small := block[:4096]
+	var latencies []float64
+	fsyncStart := time.Now()
+	deadline := fsyncStart.Add(duration / 2)
+	for time.Now().Before(deadline) && len(latencies) < 10000 {
+		t := time.Now()
+		if _, err := f.Write(small); err != nil {
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, float64(time.Since(t).Microseconds())/1000)
+	}
+
+	return map[string]float64{
+		"seq_write_mib_s": round2(float64(written) / writeSeconds / (1024 * 1024)),
+		"fsync_p50_ms":    round2(percentileOf(latencies, 0.50)),
+		"fsync_p99_ms":    round2(percentileOf(latencies, 0.99)),
+		"fsync_ops_s":     round2(float64(len(latencies)) / time.Since(fsyncStart).Seconds()),
+	}, nil
+}
+
+// benchmarkNetwork measures DNS resolution and TCP connect latency to the API server
+func benchmarkNetwork(duration time.Duration) (map[string]float64, error) {
+	host := "kubernetes.default.svc.cluster.local"
+	resolver := &net.Resolver{}
+	var dns, connect []float64
+	var addr string
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) && len(dns) < 1000 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		t := time.Now()
+		addrs, err := resolver.LookupHost(ctx, host)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %v", host, err)
+		}
+		dns = append(dns, float64(time.Since(t).Microseconds())/1000)
+		addr = net.JoinHostPort(addrs[0], "443")
+
+		t = time.Now()
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to %s: %v", addr, err)
+		}
+		connect = append(connect, float64(time.Since(t).Microseconds())/1000)
+		conn.Close()
+		time.Sleep(20 * time.Millisecond)
+	}
+	return map[string]float64{
+		"dns_p50_ms":     round2(percentileOf(dns, 0.50)),
+		"dns_p99_ms":     round2(percentileOf(dns, 0.99)),
+		"connect_p50_ms": round2(percentileOf(connect, 0.50)),
+		"connect_p99_ms": round2(percentileOf(connect, 0.99)),
+		"samples":        float64(len(dns)),
+	}, nil
+}
+
+// percentileOf returns the q-th quantile of values (nearest rank)
+func percentileOf(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	i := int(q * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+// round2 rounds to two decimals for compact results
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// BUILD INFO (agent_info metric)
+// Agent build, enabled collectors and feature flags, and the Kubernetes
+// server version, sent every cycle so the backend can tie data quirks to an
+// agent build and gate features per agent
+// ---------------------------------------------
+
+// Set at build time: -ldflags "-X main.GitCommit=<sha> -X main.BuildDate=<rfc3339>"
+var (
+	GitCommit = ""
+	BuildDate = ""
+)
+
+// serverVersionTTL is how long the discovered Kubernetes version is reused
+const serverVersionTTL = 10 * time.Minute
+
+var serverVersionCache struct {
+	mu        sync.Mutex
+	version   string
+	platform  string
+	fetchedAt time.Time
+}
+
+// gitCommit returns the commit the agent was built from, falling back to the
+// VCS info the Go toolchain embeds when the ldflag was not set
+func gitCommit() string {
+	if GitCommit != "" {
+		return GitCommit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// kubernetesServerVersion returns the API server's version and platform, cached
+func kubernetesServerVersion(clientset kubernetes.Interface) (string, string, error) {
+	serverVersionCache.mu.Lock()
+	defer serverVersionCache.mu.Unlock()
+	if serverVersionCache.version != "" && time.Since(serverVersionCache.fetchedAt) < serverVersionTTL {
+		return serverVersionCache.version, serverVersionCache.platform, nil
+	}
+	info, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return serverVersionCache.version, serverVersionCache.platform, err
+	}
+	serverVersionCache.version = info.GitVersion
+	serverVersionCache.platform = info.Platform
+	serverVersionCache.fetchedAt = time.Now()
+	return info.GitVersion, info.Platform, nil
+}
+
+// collectAgentInfo builds the "agent_info" metric
+func collectAgentInfo(clientset kubernetes.Interface, config AgentConfig, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("agent_info", time.Now())
+
+	version, platform, err := kubernetesServerVersion(clientset)
+	if err != nil {
+		status.Partial("server_version", err)
+	}
+
+	var enabled []string
+	for metricType := range metricSchemaVersions {
+		if _, accepted := capabilities.payloadVersion(metricType); accepted && settings.CollectorEnabled(metricType) {
+			enabled = append(enabled, metricType)
+		}
+	}
+
+	return map[string]interface{}{
+		"agent_version":       AgentVersion,
+		"git_commit":          gitCommit(),
+		"build_date":          BuildDate,
+		"go_version":          runtime.Version(),
+		"os_arch":             runtime.GOOS + "/" + runtime.GOARCH,
+		"kubernetes_version":  version,
+		"kubernetes_platform": platform,
+		"enabled_collectors":  sortedKeys(stringSet(enabled)),
+		"schema_versions":     metricSchemaVersions,
+		"settings_source":     settings.Source,
+		"uptime_seconds":      int64(time.Since(diagnostics.startedAt).Seconds()),
+		"feature_flags": map[string]interface{}{
+			"mesh_telemetry":       settings.MeshTelemetry,
+			"rbac_subjects":        settings.RBACSubjects,
+			"exclude_helm_secrets": settings.ExcludeHelmSecrets,
+			"threat_suppressions":  len(settings.Suppressions),
+			"connection_sampling":  settings.ConnectionSampling,
+			"sbom":                 config.SBOMEnabled,
+			"image_verification":   config.imageVerification(),
+			"registry_credentials": config.RegistryCredentialChecks,
+			"node_benchmark":       config.NodeBenchmark,
+			"report_schedule":      reportSchedule(config),
+			"tenant_mode":          settings.Tenants.Mode,
+			"custom_metrics":       settings.CustomMetrics.PrometheusURL != "",
+			"local_api":            config.LocalAPIAddr != "" && config.LocalAPIToken != "",
+			"local_ui":             config.LocalUI && config.LocalAPIAddr != "" && config.LocalAPIToken != "",
+			"change_feed":          config.ChangeFeed,
+			"timeseries_persisted": config.TimeSeriesPath != "",
+			"memory_watchdog":      config.MemoryLimitMB > 0,
+			"bootstrap_enrollment": config.BootstrapToken != "",
+			"auth_provider":        config.AuthProvider,
+			"command_concurrency":  settings.CommandConcurrency,
+			"patchable_kinds":      sortedKeys(settings.PatchableKinds),
+			"allowed_commands":     sortedKeys(settings.AllowedCommands),
+			"denied_commands":      sortedKeys(settings.DeniedCommands),
+		},
+	}
+}
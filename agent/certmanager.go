@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// CERT-MANAGER SECRETS
+// cert-manager annotates the TLS Secrets it issues with the Certificate and
+// issuer they belong to. The agent reads those annotations and the leaf
+// certificate's validity (never the key) to tell a healthy renewal cycle from
+// a Certificate that stopped renewing.
+// ---------------------------------------------
+
+const (
+	certManagerIssuerName = "cert-manager.io/issuer-name"
+	certManagerIssuerKind = "cert-manager.io/issuer-kind"
+	certManagerCertName   = "cert-manager.io/certificate-name"
+
+	// helmReleaseSecretType is the type Helm 3 stores release history under
+	helmReleaseSecretType = "helm.sh/release.v1"
+
+	// certManagerRenewGrace is how long past the expected renewal point a
+	// certificate may still be the current one before it counts as overdue
+	certManagerRenewGrace = time.Hour
+	// maxCertManagerEntries bounds the per-certificate list in the payload
+	maxCertManagerEntries = 200
+)
+
+// certManagerCertificate is the export entry of one cert-manager Secret
+type certManagerCertificate struct {
+	Namespace   string     `json:"namespace"`
+	Secret      string     `json:"secret"`
+	Certificate string     `json:"certificate"`
+	Issuer      string     `json:"issuer"`
+	IssuerKind  string     `json:"issuer_kind"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+	// Renewal is "valid", "renewal_overdue", "expired" or "unknown"
+	Renewal string `json:"renewal"`
+}
+
+// leafValidity reads the validity window of the first certificate in a PEM bundle
+func leafValidity(data []byte) (notBefore, notAfter time.Time, ok bool) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, time.Time{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return cert.NotBefore, cert.NotAfter, true
+}
+
+// certManagerRenewal judges a certificate against cert-manager's default
+// policy of renewing once two thirds of its lifetime have passed
+func certManagerRenewal(notBefore, notAfter, now time.Time) string {
+	switch {
+	case !now.Before(notAfter):
+		return "expired"
+	case now.After(notAfter.Add(-notAfter.Sub(notBefore) / 3).Add(certManagerRenewGrace)):
+		return "renewal_overdue"
+	}
+	return "valid"
+}
+
+// certManagerCertificateOf returns the entry for a cert-manager Secret, or false
+func certManagerCertificateOf(s corev1.Secret, now time.Time) (certManagerCertificate, bool) {
+	issuer, ok := s.Annotations[certManagerIssuerName]
+	if !ok {
+		return certManagerCertificate{}, false
+	}
+	entry := certManagerCertificate{
+		Namespace:   s.Namespace,
+		Secret:      s.Name,
+		Certificate: s.Annotations[certManagerCertName],
+		Issuer:      issuer,
+		IssuerKind:  s.Annotations[certManagerIssuerKind],
+		Renewal:     "unknown",
+	}
+	if entry.IssuerKind == "" {
+		entry.IssuerKind = "Issuer"
+	}
+	if notBefore, notAfter, ok := leafValidity(s.Data[corev1.TLSCertKey]); ok {
+		entry.NotAfter = &notAfter
+		entry.Renewal = certManagerRenewal(notBefore, notAfter, now)
+	}
+	return entry, true
+}
+
+// summarizeCertManager aggregates the cert-manager entries for the secrets summary
+func summarizeCertManager(entries []certManagerCertificate) map[string]interface{} {
+	byIssuer := map[string]int{}
+	renewal := map[string]int{"valid": 0, "renewal_overdue": 0, "expired": 0, "unknown": 0}
+	for _, e := range entries {
+		byIssuer[e.IssuerKind+"/"+e.Issuer]++
+		renewal[e.Renewal]++
+	}
+	// Problems first, then soonest expiry
+	rank := map[string]int{"expired": 0, "renewal_overdue": 1, "unknown": 2, "valid": 3}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if rank[a.Renewal] != rank[b.Renewal] {
+			return rank[a.Renewal] < rank[b.Renewal]
+		}
+		if a.NotAfter != nil && b.NotAfter != nil && !a.NotAfter.Equal(*b.NotAfter) {
+			return a.NotAfter.Before(*b.NotAfter)
+		}
+		return a.Namespace+"/"+a.Secret < b.Namespace+"/"+b.Secret
+	})
+	managed := len(entries)
+	truncated := managed > maxCertManagerEntries
+	if truncated {
+		entries = entries[:maxCertManagerEntries]
+	}
+	return map[string]interface{}{
+		"managed":      managed,
+		"by_issuer":    byIssuer,
+		"renewal":      renewal,
+		"certificates": entries,
+		"truncated":    truncated,
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ---------------------------------------------
+// CHANGE FEED
+// Opt-in (CHANGE_FEED=true) watch of deployments, pods and nodes that sends
+// compact "changes" envelopes between full snapshots, so a rollout shows up
+// on the dashboard within seconds. Only fields the dashboard renders are
+// compared; status churn that does not change them (resourceVersion bumps,
+// heartbeats) is not sent. Changes to one object within a flush window are
+// coalesced, and a failed flush is dropped: the next snapshot catches up.
+// ---------------------------------------------
+
+// maxPendingChanges bounds the objects buffered between two flushes
+const maxPendingChanges = 1000
+
+// resourceChange is one object's change since the last flush
+type resourceChange struct {
+	Kind      string                 `json:"kind"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name"`
+	Op        string                 `json:"op"` // added, updated or deleted
+	At        time.Time              `json:"at"`
+	State     map[string]interface{} `json:"state,omitempty"`
+}
+
+// changeFeed buffers changes until the next flush
+type changeFeed struct {
+	mu        sync.Mutex
+	pending   map[string]*resourceChange
+	truncated bool
+}
+
+var changes = &changeFeed{pending: map[string]*resourceChange{}}
+
+// record coalesces a change with any pending one for the same object
+func (f *changeFeed) record(c resourceChange) {
+	key := c.Kind + "/" + c.Namespace + "/" + c.Name
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if prev, ok := f.pending[key]; ok {
+		// An object added and changed within one window is still "added";
+		// one added and deleted was never seen by the backend
+		switch {
+		case prev.Op == "added" && c.Op == "deleted":
+			delete(f.pending, key)
+			return
+		case prev.Op == "added" && c.Op == "updated":
+			c.Op = "added"
+		}
+		*prev = c
+		return
+	}
+	if len(f.pending) >= maxPendingChanges {
+		f.truncated = true
+		return
+	}
+	f.pending[key] = &c
+}
+
+// drain returns and clears the pending changes, oldest first
+func (f *changeFeed) drain() ([]resourceChange, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := make([]resourceChange, 0, len(f.pending))
+	for _, c := range f.pending {
+		list = append(list, *c)
+	}
+	truncated := f.truncated
+	f.pending, f.truncated = map[string]*resourceChange{}, false
+	sort.Slice(list, func(i, j int) bool { return list[i].At.Before(list[j].At) })
+	return list, truncated
+}
+
+// deploymentState is what the dashboard shows of a Deployment
+func deploymentState(d *appsv1.Deployment) map[string]interface{} {
+	images := make([]string, 0, len(d.Spec.Template.Spec.Containers))
+	for _, c := range d.Spec.Template.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	var replicas int32 = 1
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return map[string]interface{}{
+		"generation":          d.Generation,
+		"observed_generation": d.Status.ObservedGeneration,
+		"replicas":            replicas,
+		"updated_replicas":    d.Status.UpdatedReplicas,
+		"ready_replicas":      d.Status.ReadyReplicas,
+		"available_replicas":  d.Status.AvailableReplicas,
+		"images":              images,
+	}
+}
+
+// podState is what the dashboard shows of a Pod
+func podState(p *corev1.Pod) map[string]interface{} {
+	var restarts int32
+	for _, cs := range p.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+	}
+	kind, workload := podWorkload(*p)
+	return map[string]interface{}{
+		"phase":    string(p.Status.Phase),
+		"ready":    isPodReady(*p),
+		"restarts": restarts,
+		"node":     p.Spec.NodeName,
+		"workload": kind + "/" + workload,
+		"deleting": p.DeletionTimestamp != nil,
+	}
+}
+
+// nodeState is what the dashboard shows of a Node
+func nodeState(n *corev1.Node) map[string]interface{} {
+	ready := "Unknown"
+	for _, cond := range n.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			ready = string(cond.Status)
+		}
+	}
+	return map[string]interface{}{
+		"ready":         ready,
+		"unschedulable": n.Spec.Unschedulable,
+	}
+}
+
+// changeHandler turns informer events into changes; state extracts the
+// compared fields and returns false for objects of another type
+func changeHandler(kind string, state func(obj interface{}) (map[string]interface{}, bool)) cache.ResourceEventHandler {
+	emit := func(op string, obj interface{}, s map[string]interface{}) {
+		m, err := meta.Accessor(obj)
+		if err != nil || !getSettings().NamespaceAllowed(m.GetNamespace()) {
+			return
+		}
+		changes.record(resourceChange{Kind: kind, Namespace: m.GetNamespace(), Name: m.GetName(), Op: op, At: time.Now().UTC(), State: s})
+	}
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// The initial list is what the last snapshot already reported
+			if isInInitialList {
+				return
+			}
+			if s, ok := state(obj); ok {
+				emit("added", obj, s)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			before, ok1 := state(oldObj)
+			after, ok2 := state(newObj)
+			if ok1 && ok2 && !reflect.DeepEqual(before, after) {
+				emit("updated", newObj, after)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			emit("deleted", obj, nil)
+		},
+	}
+}
+
+// runChangeFeed adds its handlers to the shared informers, starts them and
+// runs the flush loop. It never returns.
+func runChangeFeed(factory informers.SharedInformerFactory, config AgentConfig) {
+	factory.Apps().V1().Deployments().Informer().AddEventHandler(changeHandler("Deployment", func(obj interface{}) (map[string]interface{}, bool) {
+		d, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			return nil, false
+		}
+		return deploymentState(d), true
+	}))
+	factory.Core().V1().Pods().Informer().AddEventHandler(changeHandler("Pod", func(obj interface{}) (map[string]interface{}, bool) {
+		p, ok := obj.(*corev1.Pod)
+		if !ok {
+			return nil, false
+		}
+		return podState(p), true
+	}))
+	factory.Core().V1().Nodes().Informer().AddEventHandler(changeHandler("Node", func(obj interface{}) (map[string]interface{}, bool) {
+		n, ok := obj.(*corev1.Node)
+		if !ok {
+			return nil, false
+		}
+		return nodeState(n), true
+	}))
+	factory.Start(make(chan struct{}))
+	log.Printf("📡 Change feed watching deployments, pods and nodes (flush every %ds)", config.ChangeFeedFlushSeconds)
+
+	flush := time.Duration(config.ChangeFeedFlushSeconds) * time.Second
+	for range time.Tick(flush) {
+		flushChanges(config)
+	}
+}
+
+// flushChanges sends the pending changes as one "changes" envelope
+func flushChanges(config AgentConfig) {
+	list, truncated := changes.drain()
+	if len(list) == 0 && !truncated {
+		return
+	}
+	version, ok := capabilities.payloadVersion("changes")
+	if !ok {
+		return
+	}
+	status := &CollectorStatus{}
+	if truncated {
+		status.Partial("changes", fmt.Errorf("more than %d objects changed; the next snapshot has the rest", maxPendingChanges))
+	}
+	metric := buildMetric("changes", map[string]interface{}{"changes": list, "truncated": truncated}, status)
+	metric["schema_version"] = version
+	if err := postMetrics(config, []map[string]interface{}{metric}); err != nil {
+		log.Printf("⚠️  Dropping %d changes: %v", len(list), err)
+	}
+}
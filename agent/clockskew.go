@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CLOCK SKEW
+// The agent's clock is compared with the Date header of backend responses
+// and of the API server. A large skew shifts every collected_at and breaks
+// the "last N minutes" event windows, so it is reported in every payload.
+// ---------------------------------------------
+
+const (
+	// clockSkewWarn is the skew from which the agent warns (Date has 1s resolution)
+	clockSkewWarn = 5 * time.Second
+	// apiServerSkewInterval is how often the API server clock is sampled
+	apiServerSkewInterval = 5 * time.Minute
+)
+
+// skewSample is one measurement; Skew > 0 means the remote clock is ahead of the agent
+type skewSample struct {
+	Skew       time.Duration
+	RTT        time.Duration
+	MeasuredAt time.Time
+}
+
+type clockSkewTracker struct {
+	mu        sync.Mutex
+	backend   *skewSample
+	apiServer *skewSample
+}
+
+var clockSkew = &clockSkewTracker{}
+
+// skewFromDate compares a response Date header with the midpoint of the request
+func skewFromDate(header http.Header, start, end time.Time) (*skewSample, bool) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return nil, false
+	}
+	midpoint := start.Add(end.Sub(start) / 2)
+	return &skewSample{Skew: date.Sub(midpoint), RTT: end.Sub(start), MeasuredAt: end}, true
+}
+
+// observeBackend records the skew seen in a backend response
+func (c *clockSkewTracker) observeBackend(resp *http.Response, start, end time.Time) {
+	sample, ok := skewFromDate(resp.Header, start, end)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.backend = sample
+	c.mu.Unlock()
+	warnClockSkew("backend", sample.Skew)
+}
+
+// measureAPIServer samples the API server clock (at most every apiServerSkewInterval)
+func (c *clockSkewTracker) measureAPIServer(clientset kubernetes.Interface) {
+	c.mu.Lock()
+	due := c.apiServer == nil || time.Since(c.apiServer.MeasuredAt) >= apiServerSkewInterval
+	c.mu.Unlock()
+	if !due {
+		return
+	}
+
+	restClient, err := coreRESTClient(clientset)
+	if err != nil || restClient.Client == nil {
+		return
+	}
+	ctx, cancel := apiContext()
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", restClient.Get().AbsPath("/version").URL().String(), nil)
+	if err != nil {
+		return
+	}
+	start := time.Now()
+	resp, err := restClient.Client.Do(req)
+	end := time.Now()
+	if err != nil {
+		log.Printf("⚠️  Could not sample API server clock: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	sample, ok := skewFromDate(resp.Header, start, end)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.apiServer = sample
+	c.mu.Unlock()
+	warnClockSkew("API server", sample.Skew)
+}
+
+// report returns the skew section attached to every payload
+func (c *clockSkewTracker) report() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := map[string]interface{}{
+		"agent_time":        time.Now().UTC().Format(time.RFC3339Nano),
+		"warn_threshold_ms": clockSkewWarn.Milliseconds(),
+		"skewed":            false,
+	}
+	for name, sample := range map[string]*skewSample{"backend": c.backend, "api_server": c.apiServer} {
+		if sample == nil {
+			continue
+		}
+		result[name] = map[string]interface{}{
+			"skew_ms":     sample.Skew.Milliseconds(),
+			"rtt_ms":      sample.RTT.Milliseconds(),
+			"measured_at": sample.MeasuredAt.UTC().Format(time.RFC3339),
+		}
+		if sample.Skew.Abs() >= clockSkewWarn {
+			result["skewed"] = true
+		}
+	}
+	return result
+}
+
+func warnClockSkew(source string, skew time.Duration) {
+	if skew.Abs() >= clockSkewWarn {
+		log.Printf("⏰ Clock skew with %s is %v (agent clock %s); timestamps and event windows will be off",
+			source, skew.Round(time.Millisecond), map[bool]string{true: "behind", false: "ahead"}[skew > 0])
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ---------------------------------------------
+// COMMAND PARAMETERS
+// Typed parameters for each command. Params arrive as loosely typed JSON from
+// the backend; decoding them into structs and validating up front turns
+// malformed input into a structured error instead of a panic.
+// ---------------------------------------------
+
+// FieldError describes one invalid command parameter
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when command params fail to decode or validate.
+// updateCommandStatus reports its fields to the backend.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "invalid command params: " + strings.Join(parts, "; ")
+}
+
+// fieldChecks accumulates field errors while validating a params struct
+type fieldChecks struct {
+	errs []FieldError
+}
+
+func (c *fieldChecks) add(field, format string, args ...interface{}) {
+	c.errs = append(c.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *fieldChecks) required(field, value string) bool {
+	if value == "" {
+		c.add(field, "is required")
+		return false
+	}
+	return true
+}
+
+// name checks a required object name (DNS-1123 subdomain)
+func (c *fieldChecks) name(field, value string) {
+	if c.required(field, value) {
+		for _, msg := range validation.IsDNS1123Subdomain(value) {
+			c.add(field, "%s", msg)
+		}
+	}
+}
+
+// namespace checks a required namespace (DNS-1123 label)
+func (c *fieldChecks) namespace(field, value string) {
+	if c.required(field, value) {
+		for _, msg := range validation.IsDNS1123Label(value) {
+			c.add(field, "%s", msg)
+		}
+	}
+}
+
+// quantity checks an optional resource quantity such as "250m" or "512Mi"
+func (c *fieldChecks) quantity(field string, value *string) {
+	if value == nil {
+		return
+	}
+	if _, err := resource.ParseQuantity(*value); err != nil {
+		c.add(field, "invalid quantity %q", *value)
+	}
+}
+
+func (c *fieldChecks) err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: c.errs}
+}
+
+// commandParams is implemented by every params struct
+type commandParams interface {
+	Validate() error
+}
+
+// decodeParams converts raw command params into out and validates them
+func decodeParams(params map[string]interface{}, out commandParams) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return &ValidationError{Fields: []FieldError{{Field: "params", Message: err.Error()}}}
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &ValidationError{Fields: []FieldError{{
+				Field:   typeErr.Field,
+				Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			}}}
+		}
+		return &ValidationError{Fields: []FieldError{{Field: "params", Message: err.Error()}}}
+	}
+	return out.Validate()
+}
+
+// PodParams targets a single pod (restart_pod, delete_pod, describe_pod)
+type PodParams struct {
+	PodName   string `json:"pod_name"`
+	Namespace string `json:"namespace"`
+}
+
+func (p *PodParams) Validate() error {
+	var c fieldChecks
+	c.name("pod_name", p.PodName)
+	c.namespace("namespace", p.Namespace)
+	return c.err()
+}
+
+// ScaleDeploymentParams are the params of scale_deployment
+type ScaleDeploymentParams struct {
+	DeploymentName string `json:"deployment_name"`
+	Namespace      string `json:"namespace"`
+	Replicas       *int32 `json:"replicas"`
+	// Force applies the change even when a GitOps tool manages the Deployment
+	Force bool `json:"force"`
+}
+
+func (p *ScaleDeploymentParams) Validate() error {
+	var c fieldChecks
+	c.name("deployment_name", p.DeploymentName)
+	c.namespace("namespace", p.Namespace)
+	if p.Replicas == nil {
+		c.add("replicas", "is required")
+	} else if *p.Replicas < 0 {
+		c.add("replicas", "must be >= 0")
+	}
+	return c.err()
+}
+
+// UpdateImageParams are the params of update_deployment_image
+type UpdateImageParams struct {
+	DeploymentName string `json:"deployment_name"`
+	Namespace      string `json:"namespace"`
+	ContainerName  string `json:"container_name"`
+	NewImage       string `json:"new_image"`
+	OldImage       string `json:"old_image"`
+	Force          bool   `json:"force"`
+}
+
+func (p *UpdateImageParams) Validate() error {
+	var c fieldChecks
+	c.name("deployment_name", p.DeploymentName)
+	c.namespace("namespace", p.Namespace)
+	if c.required("new_image", p.NewImage) && strings.ContainsAny(p.NewImage, " \t\n") {
+		c.add("new_image", "must not contain whitespace")
+	}
+	return c.err()
+}
+
+// UpdateResourcesParams are the params of update_deployment_resources.
+// Nil quantities leave the current value unchanged.
+type UpdateResourcesParams struct {
+	DeploymentName string  `json:"deployment_name"`
+	Namespace      string  `json:"namespace"`
+	ContainerName  string  `json:"container_name"`
+	CPURequest     *string `json:"cpu_request"`
+	MemoryRequest  *string `json:"memory_request"`
+	CPULimit       *string `json:"cpu_limit"`
+	MemoryLimit    *string `json:"memory_limit"`
+	Force          bool    `json:"force"`
+}
+
+func (p *UpdateResourcesParams) Validate() error {
+	var c fieldChecks
+	c.name("deployment_name", p.DeploymentName)
+	c.namespace("namespace", p.Namespace)
+	c.required("container_name", p.ContainerName)
+	c.quantity("cpu_request", p.CPURequest)
+	c.quantity("memory_request", p.MemoryRequest)
+	c.quantity("cpu_limit", p.CPULimit)
+	c.quantity("memory_limit", p.MemoryLimit)
+	if p.CPURequest == nil && p.MemoryRequest == nil && p.CPULimit == nil && p.MemoryLimit == nil {
+		c.add("params", "at least one of cpu_request, memory_request, cpu_limit, memory_limit is required")
+	}
+	return c.err()
+}
+
+// CollectNowParams are the params of collect_now; empty Collectors means all
+type CollectNowParams struct {
+	Collectors []string `json:"collectors"`
+}
+
+func (p *CollectNowParams) Validate() error {
+	var c fieldChecks
+	for i, name := range p.Collectors {
+		if name == "" {
+			c.add(fmt.Sprintf("collectors[%d]", i), "must not be empty")
+		}
+	}
+	return c.err()
+}
+
+// ManifestParams are the params of get_manifest
+type ManifestParams struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func (p *ManifestParams) Validate() error {
+	var c fieldChecks
+	c.required("kind", p.Kind)
+	c.name("name", p.Name)
+	c.namespace("namespace", p.Namespace)
+	return c.err()
+}
+
+// OpenTunnelParams are the params of open_tunnel
+type OpenTunnelParams struct {
+	TunnelID    string `json:"tunnel_id"`
+	PodName     string `json:"pod_name"`
+	Namespace   string `json:"namespace"`
+	Port        int    `json:"port"`
+	RelayURL    string `json:"relay_url"`
+	TunnelToken string `json:"tunnel_token"`
+	TTLSeconds  int    `json:"ttl_seconds"`
+}
+
+func (p *OpenTunnelParams) Validate() error {
+	var c fieldChecks
+	c.required("tunnel_id", p.TunnelID)
+	c.name("pod_name", p.PodName)
+	c.namespace("namespace", p.Namespace)
+	if p.Port < 1 || p.Port > 65535 {
+		c.add("port", "must be between 1 and 65535")
+	}
+	if c.required("relay_url", p.RelayURL) && !strings.HasPrefix(p.RelayURL, "https://") {
+		c.add("relay_url", "must be an https:// URL")
+	}
+	c.required("tunnel_token", p.TunnelToken)
+	if p.TTLSeconds < 0 {
+		c.add("ttl_seconds", "must be >= 0")
+	}
+	return c.err()
+}
+
+// CloseTunnelParams are the params of close_tunnel
+type CloseTunnelParams struct {
+	TunnelID string `json:"tunnel_id"`
+}
+
+func (p *CloseTunnelParams) Validate() error {
+	var c fieldChecks
+	c.required("tunnel_id", p.TunnelID)
+	return c.err()
+}
+
+// SelfUpdateParams are the params of self_update; empty fields use the defaults
+type SelfUpdateParams struct {
+	Namespace      string `json:"namespace"`
+	DeploymentName string `json:"deployment_name"`
+	NewImage       string `json:"new_image"`
+}
+
+func (p *SelfUpdateParams) Validate() error {
+	var c fieldChecks
+	if p.Namespace != "" {
+		c.namespace("namespace", p.Namespace)
+	}
+	if p.DeploymentName != "" {
+		c.name("deployment_name", p.DeploymentName)
+	}
+	if strings.ContainsAny(p.NewImage, " \t\n") {
+		c.add("new_image", "must not contain whitespace")
+	}
+	return c.err()
+}
+
+// PatchResourceParams are the params of patch_resource. Patch may be sent as a
+// JSON object/array or as a string containing one.
+type PatchResourceParams struct {
+	Group     string          `json:"group"`
+	Version   string          `json:"version"`
+	Kind      string          `json:"kind"`
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace"`
+	PatchType string          `json:"patch_type"`
+	Patch     json.RawMessage `json:"patch"`
+	DryRun    bool            `json:"dry_run"`
+}
+
+func (p *PatchResourceParams) Validate() error {
+	var c fieldChecks
+	c.required("version", p.Version)
+	c.required("kind", p.Kind)
+	c.name("name", p.Name)
+	if p.Namespace != "" {
+		c.namespace("namespace", p.Namespace)
+	}
+
+	if p.PatchType == "" {
+		// Strategic merge only exists for built-in types
+		p.PatchType = "strategic"
+		if strings.Contains(p.Group, ".") && !strings.HasSuffix(p.Group, ".k8s.io") {
+			p.PatchType = "merge"
+		}
+	}
+	if _, ok := patchTypes[p.PatchType]; !ok {
+		c.add("patch_type", "must be one of strategic, merge, json")
+	}
+
+	var text string
+	if json.Unmarshal(p.Patch, &text) == nil {
+		p.Patch = json.RawMessage(text)
+	}
+	switch {
+	case len(p.Patch) == 0 || string(p.Patch) == "null":
+		c.add("patch", "is required")
+	case !json.Valid(p.Patch):
+		c.add("patch", "is not valid JSON")
+	case p.PatchType == "json" && !strings.HasPrefix(strings.TrimSpace(string(p.Patch)), "["):
+		c.add("patch", "a json patch must be an array of operations")
+	case p.PatchType != "json" && !strings.HasPrefix(strings.TrimSpace(string(p.Patch)), "{"):
+		c.add("patch", "a merge patch must be an object")
+	}
+	return c.err()
+}
+
+// DebugPodParams are the params of debug_pod
+type DebugPodParams struct {
+	PodName         string   `json:"pod_name"`
+	Namespace       string   `json:"namespace"`
+	Image           string   `json:"image"`
+	TargetContainer string   `json:"target_container"`
+	Command         []string `json:"command"`
+}
+
+func (p *DebugPodParams) Validate() error {
+	var c fieldChecks
+	c.name("pod_name", p.PodName)
+	c.namespace("namespace", p.Namespace)
+	return c.err()
+}
+
+// BenchmarkNodeParams are the params of benchmark_node
+type BenchmarkNodeParams struct {
+	NodeName        string   `json:"node_name"`
+	DurationSeconds int      `json:"duration_seconds"`
+	Tests           []string `json:"tests"`
+}
+
+func (p *BenchmarkNodeParams) Validate() error {
+	var c fieldChecks
+	c.name("node_name", p.NodeName)
+	if p.DurationSeconds < 0 || p.DurationSeconds > maxBenchmarkSeconds {
+		c.add("duration_seconds", "must be between 1 and %d", maxBenchmarkSeconds)
+	}
+	for _, t := range p.Tests {
+		if !benchmarkTests[t] {
+			c.add("tests", "must only contain %s", strings.Join(sortedKeys(benchmarkTests), ", "))
+			break
+		}
+	}
+	return c.err()
+}
+
+// NetworkPolicyParams are the params of apply_network_policy
+type NetworkPolicyParams struct {
+	Namespace     string            `json:"namespace"`
+	Template      string            `json:"template"`
+	PolicyName    string            `json:"policy_name"`
+	PodSelector   map[string]string `json:"pod_selector"`
+	FromNamespace string            `json:"from_namespace"`
+	Ports         []int32           `json:"ports"`
+	DryRun        bool              `json:"dry_run"`
+}
+
+func (p *NetworkPolicyParams) Validate() error {
+	var c fieldChecks
+	c.namespace("namespace", p.Namespace)
+	if _, ok := networkPolicyTemplates[p.Template]; !ok {
+		c.add("template", "must be one of %s", strings.Join(sortedKeys(networkPolicyTemplateNames()), ", "))
+	}
+	if p.PolicyName != "" {
+		c.name("policy_name", p.PolicyName)
+	}
+	if p.Template == "allow-from-namespace" {
+		c.namespace("from_namespace", p.FromNamespace)
+	}
+	for i, port := range p.Ports {
+		if port < 1 || port > 65535 {
+			c.add(fmt.Sprintf("ports[%d]", i), "must be between 1 and 65535")
+		}
+	}
+	return c.err()
+}
+
+// RotateCredentialsParams are the params of rotate_credentials
+type RotateCredentialsParams struct {
+	APIKey string `json:"api_key"`
+}
+
+func (p *RotateCredentialsParams) Validate() error {
+	var c fieldChecks
+	c.required("api_key", p.APIKey)
+	if strings.ContainsAny(p.APIKey, " \t\n") {
+		c.add("api_key", "must not contain whitespace")
+	}
+	return c.err()
+}
+
+// CheckAccessParams are the params of check_access. An empty SubjectKind
+// checks the agent's own access.
+type CheckAccessParams struct {
+	SubjectKind      string   `json:"subject_kind"`
+	SubjectName      string   `json:"subject_name"`
+	SubjectNamespace string   `json:"subject_namespace"`
+	Groups           []string `json:"groups"`
+	Mode             string   `json:"mode"`
+	Verb             string   `json:"verb"`
+	Group            string   `json:"group"`
+	Resource         string   `json:"resource"`
+	Subresource      string   `json:"subresource"`
+	Name             string   `json:"name"`
+	Namespace        string   `json:"namespace"`
+	Path             string   `json:"path"`
+}
+
+func (p *CheckAccessParams) Validate() error {
+	var c fieldChecks
+	switch p.SubjectKind {
+	case "":
+	case "User", "Group":
+		c.required("subject_name", p.SubjectName)
+	case "ServiceAccount":
+		c.name("subject_name", p.SubjectName)
+		c.namespace("subject_namespace", p.SubjectNamespace)
+	default:
+		c.add("subject_kind", "must be one of User, Group, ServiceAccount")
+	}
+	if p.Namespace != "" {
+		c.namespace("namespace", p.Namespace)
+	}
+
+	if p.Mode == "" {
+		p.Mode = "access"
+	}
+	switch p.Mode {
+	case "access":
+		c.required("verb", p.Verb)
+		if p.Path == "" {
+			c.required("resource", p.Resource)
+		} else if !strings.HasPrefix(p.Path, "/") {
+			c.add("path", "must start with /")
+		}
+	case "rules":
+		c.namespace("namespace", p.Namespace)
+	default:
+		c.add("mode", "must be access or rules")
+	}
+	return c.err()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------
+// COMMAND SCHEDULING
+// Commands run concurrently (up to CommandConcurrency at a time) on workers
+// that outlive the poll that delivered them, but commands targeting the same
+// resource keep their arrival order so that, e.g., a scale followed by an
+// image update of one deployment never swap.
+// ---------------------------------------------
+
+// defaultCommandConcurrency is used when COMMAND_CONCURRENCY is unset
+const defaultCommandConcurrency = 4
+
+// maxCommandConcurrency bounds COMMAND_CONCURRENCY and the CRD setting
+const maxCommandConcurrency = 32
+
+// barrierCommands restart the agent or swap its credentials, so they run
+// alone after everything queued before them
+var barrierCommands = map[string]bool{
+	"self_update":        true,
+	"agent_update":       true,
+	"rotate_credentials": true,
+}
+
+// commandResourceKey identifies the resource a command acts on; commands with
+// the same key run sequentially. Commands without a target get a unique key.
+func commandResourceKey(cmd Command) string {
+	param := func(key string) string {
+		value, _ := cmd.CommandParams[key].(string)
+		return value
+	}
+
+	switch cmd.CommandType {
+	case "restart_pod", "delete_pod", "describe_pod", "debug_pod":
+		return "pod/" + param("namespace") + "/" + param("pod_name")
+	case "scale_deployment", "update_deployment_image", "update_deployment_resources":
+		return "deployment/" + param("namespace") + "/" + param("deployment_name")
+	case "get_manifest", "patch_resource":
+		return strings.ToLower(param("kind")) + "/" + param("namespace") + "/" + param("name")
+	case "open_tunnel", "close_tunnel":
+		return "tunnel/" + param("tunnel_id")
+	case "apply_network_policy":
+		name := param("policy_name")
+		if name == "" {
+			name = param("template")
+		}
+		return "networkpolicy/" + param("namespace") + "/" + name
+	case "collect_now":
+		// Overlapping on-demand collections only duplicate work
+		return "collect_now"
+	case "benchmark_node":
+		// Two benchmarks on one node would skew each other's scores
+		return "node/" + param("node_name")
+	case "set_collection_policy":
+		// Policies must land in the order they were sent
+		return "collection_policy"
+	}
+	return "command/" + cmd.ID
+}
+
+// commandScheduler keeps one worker per resource key alive while that key
+// has queued commands. The poller only enqueues, so a slow command (rollout
+// wait, log fetch, drain) delays later commands on its own resource and
+// nothing else; the next poll is never held back by the previous batch.
+type commandScheduler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit   func() int                    // max commands executing at once, re-read per command
+	queues  map[string][]scheduledCommand // pending commands per resource key
+	workers int                           // resource keys with a live worker
+	running int                           // commands executing right now
+	known   map[string]bool               // IDs queued or running, so re-delivered commands run once
+
+	barrier bool               // a barrier command is waiting for the workers to drain or running
+	held    []scheduledCommand // commands that arrived behind the barrier
+}
+
+type scheduledCommand struct {
+	cmd     Command
+	execute func(Command)
+}
+
+func newCommandScheduler(limit func() int) *commandScheduler {
+	s := &commandScheduler{
+		limit:  limit,
+		queues: map[string][]scheduledCommand{},
+		known:  map[string]bool{},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// commandQueue is the agent-wide scheduler fed by the command poller
+var commandQueue = newCommandScheduler(func() int { return getSettings().CommandConcurrency })
+
+// submit enqueues a batch in arrival order and returns immediately
+func (s *commandScheduler) submit(batch []Command, execute func(Command)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cmd := range batch {
+		if s.known[cmd.ID] {
+			log.Printf("⏭️  Command %s is already queued, ignoring duplicate delivery", cmd.ID)
+			continue
+		}
+		s.known[cmd.ID] = true
+		s.admit(scheduledCommand{cmd: cmd, execute: execute})
+	}
+}
+
+// admit routes a command to its resource queue, or holds it behind a pending
+// barrier. Callers hold s.mu.
+func (s *commandScheduler) admit(sc scheduledCommand) {
+	switch {
+	case s.barrier:
+		s.held = append(s.held, sc)
+	case barrierCommands[sc.cmd.CommandType]:
+		s.barrier = true
+		go s.runBarrier(sc)
+	default:
+		key := commandResourceKey(sc.cmd)
+		_, active := s.queues[key]
+		s.queues[key] = append(s.queues[key], sc)
+		if !active {
+			s.workers++
+			go s.work(key)
+		}
+	}
+}
+
+// work drains one resource key's queue in order, then exits
+func (s *commandScheduler) work(key string) {
+	for {
+		s.mu.Lock()
+		queue := s.queues[key]
+		if len(queue) == 0 {
+			delete(s.queues, key)
+			s.workers--
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+		sc := queue[0]
+		s.queues[key] = queue[1:]
+		s.acquire()
+		s.mu.Unlock()
+
+		sc.execute(sc.cmd)
+		s.release(sc.cmd)
+	}
+}
+
+// runBarrier waits for every command queued before it to finish, runs alone,
+// then releases the commands that arrived meanwhile
+func (s *commandScheduler) runBarrier(sc scheduledCommand) {
+	s.mu.Lock()
+	for s.workers > 0 {
+		s.cond.Wait()
+	}
+	s.acquire()
+	s.mu.Unlock()
+
+	sc.execute(sc.cmd)
+	s.release(sc.cmd)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.barrier = false
+	held := s.held
+	s.held = nil
+	for _, next := range held {
+		s.admit(next)
+	}
+}
+
+// acquire waits for a free execution slot. Callers hold s.mu.
+func (s *commandScheduler) acquire() {
+	for s.running >= clampCommandConcurrency(s.limit()) {
+		s.cond.Wait()
+	}
+	s.running++
+}
+
+func (s *commandScheduler) release(cmd Command) {
+	s.mu.Lock()
+	s.running--
+	delete(s.known, cmd.ID)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// recoverCommand turns a panic in a command handler into a command error so a
+// single bad command cannot take the agent down
+func recoverCommand(cmd Command, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("❌ Command %s (%s) panicked: %v\n%s", cmd.ID, cmd.CommandType, r, debug.Stack())
+		*err = fmt.Errorf("command panicked: %v", r)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func testCommand(id, commandType string, params map[string]interface{}) Command {
+	return Command{ID: id, CommandType: commandType, CommandParams: params}
+}
+
+func deploymentParams(name string) map[string]interface{} {
+	return map[string]interface{}{"namespace": "default", "deployment_name": name}
+}
+
+func TestCommandSchedulerSlowCommandDoesNotBlockNextPoll(t *testing.T) {
+	s := newCommandScheduler(func() int { return 4 })
+	unblock := make(chan struct{})
+	done := make(chan string, 4)
+	execute := func(cmd Command) {
+		if cmd.ID == "slow" {
+			<-unblock
+		}
+		done <- cmd.ID
+	}
+
+	s.submit([]Command{testCommand("slow", "scale_deployment", deploymentParams("api"))}, execute)
+	s.submit([]Command{testCommand("urgent", "scale_deployment", deploymentParams("web"))}, execute)
+
+	select {
+	case id := <-done:
+		if id != "urgent" {
+			t.Fatalf("first finished command = %s, want urgent", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("command from the next poll waited for the slow command of the previous one")
+	}
+	close(unblock)
+	if id := <-done; id != "slow" {
+		t.Fatalf("second finished command = %s, want slow", id)
+	}
+}
+
+func TestCommandSchedulerKeepsPerResourceOrder(t *testing.T) {
+	s := newCommandScheduler(func() int { return 4 })
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	wg.Add(3)
+	execute := func(cmd Command) {
+		defer wg.Done()
+		if cmd.ID == "1" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		order = append(order, cmd.ID)
+		mu.Unlock()
+	}
+
+	s.submit([]Command{
+		testCommand("1", "scale_deployment", deploymentParams("api")),
+		testCommand("2", "update_deployment_image", deploymentParams("api")),
+	}, execute)
+	s.submit([]Command{testCommand("3", "update_deployment_resources", deploymentParams("api"))}, execute)
+	wg.Wait()
+
+	if len(order) != 3 || order[0] != "1" || order[1] != "2" || order[2] != "3" {
+		t.Fatalf("commands on one deployment ran as %v, want [1 2 3]", order)
+	}
+}
+
+func TestCommandSchedulerBarrierRunsAlone(t *testing.T) {
+	s := newCommandScheduler(func() int { return 4 })
+	var mu sync.Mutex
+	running := 0
+	var events []string
+	var wg sync.WaitGroup
+	wg.Add(4)
+	execute := func(cmd Command) {
+		defer wg.Done()
+		mu.Lock()
+		running++
+		if cmd.CommandType == "rotate_credentials" && running != 1 {
+			t.Errorf("barrier ran alongside %d other commands", running-1)
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		events = append(events, cmd.ID)
+		mu.Unlock()
+	}
+
+	s.submit([]Command{
+		testCommand("before-a", "scale_deployment", deploymentParams("a")),
+		testCommand("before-b", "scale_deployment", deploymentParams("b")),
+		testCommand("rotate", "rotate_credentials", nil),
+		testCommand("after", "scale_deployment", deploymentParams("c")),
+	}, execute)
+	wg.Wait()
+
+	if events[2] != "rotate" || events[3] != "after" {
+		t.Fatalf("barrier did not separate the commands around it: %v", events)
+	}
+}
+
+func TestCommandSchedulerIgnoresDuplicateDelivery(t *testing.T) {
+	s := newCommandScheduler(func() int { return 1 })
+	unblock := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	var wg sync.WaitGroup
+	wg.Add(1)
+	execute := func(cmd Command) {
+		defer wg.Done()
+		<-unblock
+		mu.Lock()
+		runs++
+		mu.Unlock()
+	}
+
+	cmd := testCommand("dup", "delete_pod", map[string]interface{}{"namespace": "default", "pod_name": "p"})
+	s.submit([]Command{cmd}, execute)
+	s.submit([]Command{cmd}, execute)
+	close(unblock)
+	wg.Wait()
+	time.Sleep(10 * time.Millisecond)
+
+	if runs != 1 {
+		t.Fatalf("command re-delivered while queued ran %d times, want 1", runs)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CONFIGMAP INVENTORY
+// ConfigMaps per namespace with size and age, and the workloads that mount
+// them or read them through env/envFrom. Oversized ConfigMaps fail to update
+// (etcd rejects objects above ~1MiB); unreferenced ones are usually leftovers
+// of removed releases. Complements the Secrets counts in the security payload.
+// ---------------------------------------------
+
+const (
+	// configMapMaxBytes is the API server / etcd object size limit
+	configMapMaxBytes = 1 << 20
+	// configMapLargePercent flags ConfigMaps using this share of the limit
+	configMapLargePercent = 80
+	// rootCAConfigMap is published into every namespace by kube-controller-manager
+	rootCAConfigMap = "kube-root-ca.crt"
+)
+
+// systemConfigMapNamespaces hold ConfigMaps read through the API by control
+// plane components, never mounted, so "unreferenced" means nothing there
+var systemConfigMapNamespaces = map[string]bool{"kube-system": true, "kube-public": true, "kube-node-lease": true}
+
+// configMapSize is the stored size of the data, in bytes
+func configMapSize(cm corev1.ConfigMap) int {
+	size := 0
+	for k, v := range cm.Data {
+		size += len(k) + len(v)
+	}
+	for k, v := range cm.BinaryData {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// configMapRef is how a pod reads a ConfigMap; optional is set when every
+// reference of the pod is optional, so the pod starts without it
+type configMapRef struct {
+	via      string
+	optional bool
+}
+
+// podConfigMapRefs returns the ConfigMaps a pod reads, with how it reads them
+func podConfigMapRefs(pod corev1.Pod) map[string]configMapRef {
+	refs := map[string]configMapRef{}
+	add := func(name, via string, optional *bool) {
+		isOptional := optional != nil && *optional
+		ref, ok := refs[name]
+		if !ok {
+			refs[name] = configMapRef{via: via, optional: isOptional}
+			return
+		}
+		ref.optional = ref.optional && isOptional
+		refs[name] = ref
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.ConfigMap != nil {
+			add(v.ConfigMap.Name, "volume", v.ConfigMap.Optional)
+		}
+		if v.Projected != nil {
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					add(source.ConfigMap.Name, "volume", source.ConfigMap.Optional)
+				}
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				add(from.ConfigMapRef.Name, "envFrom", from.ConfigMapRef.Optional)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				add(env.ValueFrom.ConfigMapKeyRef.Name, "env", env.ValueFrom.ConfigMapKeyRef.Optional)
+			}
+		}
+	}
+	return refs
+}
+
+// collectConfigMaps builds the "configmaps" metric
+func collectConfigMaps(clientset kubernetes.Interface, snap *ClusterSnapshot, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("configmaps", time.Now())
+
+	ctx, cancel := apiContext()
+	cmList, err := clientset.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing ConfigMaps: %v", err)
+		status.Fail("configmaps", err)
+		return map[string]interface{}{}
+	}
+	configMaps := filterByNamespace(cmList.Items, func(cm corev1.ConfigMap) string { return cm.Namespace }, settings)
+
+	// namespace/name → referencing workloads → how
+	references := map[string]map[string]configMapRef{}
+	referencedKeys := map[string]bool{}
+	for _, pod := range snap.Pods {
+		kind, owner := podWorkload(pod)
+		workload := kind + "/" + owner
+		for name, ref := range podConfigMapRefs(pod) {
+			key := pod.Namespace + "/" + name
+			if references[key] == nil {
+				references[key] = map[string]configMapRef{}
+				referencedKeys[key] = true
+			}
+			references[key][workload] = ref
+		}
+	}
+	// Without pods every ConfigMap would look unreferenced
+	podsKnown := snap.Errors["pods"] == nil
+
+	type namespaceTotals struct {
+		Count int `json:"count"`
+		Bytes int `json:"bytes"`
+	}
+	byNamespace := map[string]*namespaceTotals{}
+	existing := map[string]bool{}
+	var inventory, large, unreferenced []map[string]interface{}
+	totalBytes := 0
+
+	for _, cm := range configMaps {
+		key := cm.Namespace + "/" + cm.Name
+		existing[key] = true
+		size := configMapSize(cm)
+		totalBytes += size
+		ns, ok := byNamespace[cm.Namespace]
+		if !ok {
+			ns = &namespaceTotals{}
+			byNamespace[cm.Namespace] = ns
+		}
+		ns.Count++
+		ns.Bytes += size
+
+		var referencedBy []map[string]interface{}
+		for workload, ref := range references[key] {
+			referencedBy = append(referencedBy, map[string]interface{}{"workload": workload, "via": ref.via})
+		}
+		sort.Slice(referencedBy, func(i, j int) bool {
+			return referencedBy[i]["workload"].(string) < referencedBy[j]["workload"].(string)
+		})
+
+		entry := map[string]interface{}{
+			"namespace":     cm.Namespace,
+			"name":          cm.Name,
+			"bytes":         size,
+			"keys":          len(cm.Data) + len(cm.BinaryData),
+			"age_seconds":   snap.TakenAt.Sub(cm.CreationTimestamp.Time).Seconds(),
+			"immutable":     cm.Immutable != nil && *cm.Immutable,
+			"referenced_by": referencedBy,
+		}
+		inventory = append(inventory, entry)
+
+		if size*100 >= configMapMaxBytes*configMapLargePercent {
+			large = append(large, entry)
+		}
+		if podsKnown && len(referencedBy) == 0 && cm.Name != rootCAConfigMap && !systemConfigMapNamespaces[cm.Namespace] {
+			unreferenced = append(unreferenced, map[string]interface{}{
+				"namespace":   cm.Namespace,
+				"name":        cm.Name,
+				"bytes":       size,
+				"age_seconds": entry["age_seconds"],
+			})
+		}
+	}
+
+	// Referenced but absent: pods fail to start unless every reference is
+	// optional; those only read no data and are listed apart
+	var missing, missingOptional []map[string]interface{}
+	for _, key := range sortedKeys(referencedKeys) {
+		if existing[key] {
+			continue
+		}
+		namespace, name, _ := strings.Cut(key, "/")
+		var required, optional []string
+		for workload, ref := range references[key] {
+			if ref.optional {
+				optional = append(optional, workload)
+			} else {
+				required = append(required, workload)
+			}
+		}
+		sort.Strings(required)
+		sort.Strings(optional)
+		if len(required) == 0 {
+			missingOptional = append(missingOptional, map[string]interface{}{
+				"namespace":     namespace,
+				"name":          name,
+				"referenced_by": optional,
+			})
+			continue
+		}
+		missing = append(missing, map[string]interface{}{
+			"namespace":     namespace,
+			"name":          name,
+			"referenced_by": required,
+		})
+	}
+
+	return map[string]interface{}{
+		"total_count":      len(configMaps),
+		"total_bytes":      totalBytes,
+		"by_namespace":     byNamespace,
+		"configmaps":       inventory,
+		"large":            large,
+		"large_threshold":  configMapMaxBytes * configMapLargePercent / 100,
+		"size_limit_bytes": configMapMaxBytes,
+		"unreferenced":     unreferenced,
+		"missing":          missing,
+		"missing_optional": missingOptional,
+		"references_known": podsKnown,
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CONNECTION ANOMALIES
+// With CONNECTION_SAMPLING enabled the agent reads the per-pod connection
+// samples of the node probes (nodeprobe.go) through the API server pod proxy
+// and flags pods that suddenly talk to many more remote IPs than usual
+// (fan-out: DDoS participation, worm spread, beaconing to rotating C2s) or
+// hit many ports of the same host (port scan). The baseline is an in-memory
+// moving average per pod, so fan-out is only judged after a few samples.
+// ---------------------------------------------
+
+const (
+	// nodeProbeSelector finds the probe pods in the agent's namespace
+	nodeProbeSelector = "app=kodo-node-probe"
+	// nodeProbeSecret holds the token the probes require ("token" key)
+	nodeProbeSecret = "kodo-node-probe"
+	// portScanPortsPerIP distinct ports of a single remote IP count as a scan
+	portScanPortsPerIP = 50
+	// synSentThreshold half-open connections at once count as a scan
+	synSentThreshold = 100
+	// fanOutMinIPs ignores fan-out below this many distinct remote IPs
+	fanOutMinIPs = 50
+	// fanOutFactor over the baseline counts as sudden
+	fanOutFactor = 4
+	// fanOutMinSamples are needed before a baseline is trusted
+	fanOutMinSamples = 3
+	// connectionBaselineAlpha weighs the newest sample in the moving average
+	connectionBaselineAlpha = 0.3
+	// connectionPodsLimit bounds the per-pod list in the payload
+	connectionPodsLimit = 20
+	// probeFetchConcurrency bounds parallel probe requests
+	probeFetchConcurrency = 8
+)
+
+// connectionBaseline is the moving average of one pod's distinct remote IPs
+type connectionBaseline struct {
+	remoteIPs float64
+	samples   int
+	seen      time.Time
+}
+
+var connectionBaselines = struct {
+	mu    sync.Mutex
+	byPod map[string]*connectionBaseline
+}{byPod: map[string]*connectionBaseline{}}
+
+// fetchProbeReport reads one probe's /connections via the API server pod proxy
+func fetchProbeReport(clientset kubernetes.Interface, probe corev1.Pod, token string) (*probeReport, error) {
+	restClient, err := coreRESTClient(clientset)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := apiContext()
+	defer cancel()
+
+	body, err := restClient.Get().
+		Namespace(probe.Namespace).
+		Resource("pods").
+		Name(fmt.Sprintf("%s:%d", probe.Name, nodeProbePort)).
+		SubResource("proxy").
+		Suffix("connections").
+		SetHeader(nodeProbeTokenHeader, token).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var report probeReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse probe report: %v", err)
+	}
+	if report.Node == "" {
+		report.Node = probe.Spec.NodeName
+	}
+	return &report, nil
+}
+
+// fetchProbeReports queries every running probe, a few at a time
+func fetchProbeReports(clientset kubernetes.Interface, namespace string, status *CollectorStatus) ([]*probeReport, []string, error) {
+	ctx, cancel := apiContext()
+	probes, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: nodeProbeSelector})
+	cancel()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel = apiContext()
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, nodeProbeSecret, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the probe token (see kubernetes/node-probe.yaml): %w", err)
+	}
+	token := string(secret.Data["token"])
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		reports []*probeReport
+		failed  []string
+	)
+	sem := make(chan struct{}, probeFetchConcurrency)
+	running := 0
+	for _, probe := range probes.Items {
+		if probe.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		running++
+		wg.Add(1)
+		go func(probe corev1.Pod) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			report, err := fetchProbeReport(clientset, probe, token)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("⚠️  Node probe %s (%s): %v", probe.Name, probe.Spec.NodeName, err)
+				failed = append(failed, probe.Spec.NodeName)
+				status.Partial("node_probe", err)
+				return
+			}
+			reports = append(reports, report)
+		}(probe)
+	}
+	wg.Wait()
+	if running == 0 {
+		return nil, nil, fmt.Errorf("no running pods matching %s in namespace %s (see kubernetes/node-probe.yaml)", nodeProbeSelector, namespace)
+	}
+	sort.Strings(failed)
+	return reports, failed, nil
+}
+
+// collectConnectionAnomalies builds the "connection_anomalies" metric
+func collectConnectionAnomalies(clientset kubernetes.Interface, snap *ClusterSnapshot, config AgentConfig, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("connection_anomalies", time.Now())
+
+	reports, failedNodes, err := fetchProbeReports(clientset, config.Namespace, status)
+	if err != nil {
+		status.Fail("node_probe", err)
+		return map[string]interface{}{}
+	}
+
+	podsByUID := map[string]corev1.Pod{}
+	for _, pod := range snap.Pods {
+		podsByUID[string(pod.UID)] = pod
+	}
+	settings := getSettings()
+
+	var pods []map[string]interface{}
+	var anomalies []map[string]interface{}
+	sampledNodes := []string{}
+	unreadable := 0
+	now := snap.TakenAt
+
+	connectionBaselines.mu.Lock()
+	for _, report := range reports {
+		sampledNodes = append(sampledNodes, report.Node)
+		unreadable += report.Unreadable
+		for _, c := range report.Pods {
+			pod, ok := podsByUID[c.PodUID]
+			if !ok || !settings.NamespaceAllowed(pod.Namespace) {
+				continue
+			}
+			key := pod.Namespace + "/" + pod.Name
+			ownerKind, ownerName := podWorkload(pod)
+			entry := map[string]interface{}{
+				"namespace":        pod.Namespace,
+				"pod_name":         pod.Name,
+				"node":             report.Node,
+				"owner_kind":       ownerKind,
+				"owner_name":       ownerName,
+				"established":      c.Established,
+				"syn_sent":         c.SynSent,
+				"remote_ips":       c.RemoteIPs,
+				"remote_ports":     c.RemotePorts,
+				"max_ports_per_ip": c.MaxPortsPerIP,
+				"top_remote_ips":   c.TopRemoteIPs,
+			}
+
+			baseline := connectionBaselines.byPod[key]
+			if baseline != nil && baseline.samples > 0 {
+				entry["baseline_remote_ips"] = int(baseline.remoteIPs + 0.5)
+			}
+			if c.MaxPortsPerIP >= portScanPortsPerIP || c.SynSent >= synSentThreshold {
+				anomalies = append(anomalies, anomalyEntry(entry, "port_scan",
+					fmt.Sprintf("%d ports on one remote IP, %d half-open connections", c.MaxPortsPerIP, c.SynSent)))
+			}
+			if baseline != nil && baseline.samples >= fanOutMinSamples && c.RemoteIPs >= fanOutMinIPs &&
+				float64(c.RemoteIPs) >= baseline.remoteIPs*fanOutFactor {
+				anomalies = append(anomalies, anomalyEntry(entry, "fan_out",
+					fmt.Sprintf("%d distinct remote IPs, usually %.0f", c.RemoteIPs, baseline.remoteIPs)))
+			}
+
+			if baseline == nil {
+				baseline = &connectionBaseline{remoteIPs: float64(c.RemoteIPs)}
+				connectionBaselines.byPod[key] = baseline
+			} else {
+				baseline.remoteIPs += connectionBaselineAlpha * (float64(c.RemoteIPs) - baseline.remoteIPs)
+			}
+			baseline.samples++
+			baseline.seen = now
+			pods = append(pods, entry)
+		}
+	}
+	// Pods not sampled for a while are gone (or their node probe is)
+	for key, b := range connectionBaselines.byPod {
+		if now.Sub(b.seen) > time.Hour {
+			delete(connectionBaselines.byPod, key)
+		}
+	}
+	connectionBaselines.mu.Unlock()
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i]["remote_ips"].(int) > pods[j]["remote_ips"].(int) })
+	sampledPods := len(pods)
+	if len(pods) > connectionPodsLimit {
+		pods = pods[:connectionPodsLimit]
+	}
+	sort.Strings(sampledNodes)
+
+	if len(anomalies) > 0 {
+		log.Printf("🔎 Connection sampling: %d anomalies across %d pods", len(anomalies), sampledPods)
+	}
+	return map[string]interface{}{
+		"anomalies":        anomalies,
+		"top_pods":         pods,
+		"sampled_pods":     sampledPods,
+		"sampled_nodes":    sampledNodes,
+		"failed_nodes":     failedNodes,
+		"unreadable_netns": unreadable,
+		"source":           "procfs",
+		"port_scan_ports":  portScanPortsPerIP,
+		"fan_out_factor":   fanOutFactor,
+		"fan_out_min_ips":  fanOutMinIPs,
+	}
+}
+
+// anomalyEntry copies a pod entry into an anomaly of the given kind
+func anomalyEntry(entry map[string]interface{}, kind, reason string) map[string]interface{} {
+	anomaly := make(map[string]interface{}, len(entry)+3)
+	for k, v := range entry {
+		anomaly[k] = v
+	}
+	anomaly["kind"] = kind
+	anomaly["threat_level"] = "high"
+	anomaly["reason"] = reason
+	return anomaly
+}
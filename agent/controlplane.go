@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CONTROL PLANE HEALTH
+// etcd health as seen by the API server (/readyz?verbose), the legacy
+// ComponentStatus API, and on kubeadm-style clusters, where the control plane
+// runs as static pods the agent can see, the components' own health ports.
+// The scheduler/controller-manager Leases back this up when the ports are
+// not reachable from the agent's pod.
+// ---------------------------------------------
+
+// controlPlaneProbeTimeout keeps an unreachable port from slowing the cycle
+const controlPlaneProbeTimeout = 3 * time.Second
+
+// controlPlaneComponent describes a static-pod component and its health port
+type controlPlaneComponent struct {
+	scheme string
+	port   int
+	path   string
+	lease  string
+}
+
+// controlPlaneComponents are keyed by the kubeadm "component" pod label
+var controlPlaneComponents = map[string]controlPlaneComponent{
+	"etcd":                    {scheme: "http", port: 2381, path: "/health"},
+	"kube-apiserver":          {scheme: "https", port: 6443, path: "/livez"},
+	"kube-scheduler":          {scheme: "https", port: 10259, path: "/healthz", lease: "kube-scheduler"},
+	"kube-controller-manager": {scheme: "https", port: 10257, path: "/healthz", lease: "kube-controller-manager"},
+}
+
+// controlPlaneProbeClient skips verification: the health ports serve
+// self-signed certificates and only /healthz-style paths are requested
+var controlPlaneProbeClient = &http.Client{
+	Timeout: controlPlaneProbeTimeout,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		DialContext:     (&net.Dialer{Timeout: controlPlaneProbeTimeout}).DialContext,
+	},
+}
+
+// probeHealthPort returns "ok", "unhealthy" or "unreachable" and a detail
+func probeHealthPort(host string, component controlPlaneComponent) (string, string) {
+	url := fmt.Sprintf("%s://%s%s", component.scheme, net.JoinHostPort(host, strconv.Itoa(component.port)), component.path)
+	resp, err := controlPlaneProbeClient.Get(url)
+	if err != nil {
+		return "unreachable", err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	detail := strings.TrimSpace(string(body))
+	// etcd answers {"health":"true"}; the others plain "ok"
+	if resp.StatusCode == http.StatusOK && !strings.Contains(detail, `"health":"false"`) {
+		return "ok", detail
+	}
+	return "unhealthy", fmt.Sprintf("HTTP %d: %s", resp.StatusCode, detail)
+}
+
+// apiServerReadyz parses /readyz?verbose into check → passing
+func apiServerReadyz(clientset kubernetes.Interface) (map[string]bool, error) {
+	restClient, err := coreRESTClient(clientset)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := apiContext()
+	defer cancel()
+	// Not ready answers HTTP 500 with the same verbose body
+	body, err := restClient.Get().AbsPath("/readyz").Param("verbose", "true").DoRaw(ctx)
+	if len(body) == 0 && err != nil {
+		return nil, err
+	}
+	checks := map[string]bool{}
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 4 || (line[:3] != "[+]" && line[:3] != "[-]") {
+			continue
+		}
+		name, _, _ := strings.Cut(line[3:], " ")
+		checks[name] = line[:3] == "[+]"
+	}
+	return checks, nil
+}
+
+// collectControlPlaneHealth builds the "control_plane" metric
+func collectControlPlaneHealth(clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("control_plane", time.Now())
+
+	result := map[string]interface{}{}
+
+	// 1. API server readiness, which includes its etcd connectivity
+	if checks, err := apiServerReadyz(clientset); err != nil {
+		if err != errNoRESTClient {
+			log.Printf("⚠️  Error reading API server /readyz: %v", err)
+			status.Partial("readyz", err)
+		}
+	} else {
+		var failing []string
+		for name, ok := range checks {
+			if !ok {
+				failing = append(failing, name)
+			}
+		}
+		sort.Strings(failing)
+		etcdOK, known := checks["etcd"]
+		result["apiserver"] = map[string]interface{}{
+			"ready":          len(failing) == 0,
+			"failing_checks": failing,
+			"checks":         len(checks),
+		}
+		if known {
+			result["etcd_reachable_from_apiserver"] = etcdOK
+		}
+	}
+
+	// 2. Legacy ComponentStatus API (deprecated, still served by most clusters)
+	ctx, cancel := apiContext()
+	componentStatuses, err := clientset.CoreV1().ComponentStatuses().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		status.Partial("componentstatuses", err)
+	} else {
+		components := map[string]interface{}{}
+		for _, cs := range componentStatuses.Items {
+			healthy, message := false, ""
+			for _, c := range cs.Conditions {
+				if c.Type == corev1.ComponentHealthy {
+					healthy = c.Status == corev1.ConditionTrue
+					message = c.Message
+					if c.Error != "" {
+						message = c.Error
+					}
+				}
+			}
+			components[cs.Name] = map[string]interface{}{"healthy": healthy, "message": message}
+		}
+		result["component_statuses"] = components
+	}
+
+	// 3. Static control-plane pods and their health ports (kubeadm-style)
+	var instances []map[string]interface{}
+	unhealthy := map[string]bool{}
+	for _, pod := range snap.Pods {
+		if pod.Namespace != "kube-system" {
+			continue
+		}
+		name := pod.Labels["component"]
+		component, ok := controlPlaneComponents[name]
+		if !ok {
+			continue
+		}
+		ready := false
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				ready = true
+			}
+		}
+		instance := map[string]interface{}{
+			"component": name,
+			"pod":       pod.Name,
+			"node":      pod.Spec.NodeName,
+			"phase":     string(pod.Status.Phase),
+			"ready":     ready,
+			"restarts":  podRestarts(pod),
+		}
+		if host := pod.Status.HostIP; host != "" && pod.Spec.HostNetwork {
+			probe, detail := probeHealthPort(host, component)
+			instance["probe"] = probe
+			instance["probe_detail"] = detail
+			if probe == "unhealthy" {
+				unhealthy[name] = true
+			}
+		}
+		if !ready {
+			unhealthy[name] = true
+		}
+		instances = append(instances, instance)
+	}
+	result["self_managed"] = len(instances) > 0
+	result["static_pods"] = instances
+
+	// 4. Leader Leases: a stale one means no active scheduler/controller-manager
+	leases := map[string]interface{}{}
+	for name, component := range controlPlaneComponents {
+		if component.lease == "" {
+			continue
+		}
+		ctx, cancel := apiContext()
+		lease, err := clientset.CoordinationV1().Leases("kube-system").Get(ctx, component.lease, metav1.GetOptions{})
+		cancel()
+		if err != nil {
+			continue // managed control planes often don't expose them
+		}
+		stale, sinceRenew := leaseStale(*lease, snap.TakenAt)
+		var holder string
+		if lease.Spec.HolderIdentity != nil {
+			holder = *lease.Spec.HolderIdentity
+		}
+		leases[name] = map[string]interface{}{
+			"holder":              holder,
+			"stale":               stale,
+			"seconds_since_renew": sinceRenew,
+		}
+		if stale {
+			unhealthy[name] = true
+		}
+	}
+	result["leader_leases"] = leases
+
+	if etcdOK, ok := result["etcd_reachable_from_apiserver"].(bool); ok && !etcdOK {
+		unhealthy["etcd"] = true
+	}
+	result["unhealthy_components"] = sortedKeys(unhealthy)
+	return result
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// COREDNS HEALTH, CONFIG AND METRICS
+// Deployment health, Corefile review and cache/error counters scraped from
+// each CoreDNS pod's Prometheus endpoint
+// ---------------------------------------------
+
+const (
+	corednsLabelSelector = "k8s-app=kube-dns"
+	corednsMetricsPort   = 9153
+	// maxCorefileBytes bounds the Corefile forwarded to the backend
+	maxCorefileBytes = 16 * 1024
+)
+
+// corednsMetrics are the counters scraped from each CoreDNS pod
+var corednsMetrics = []string{
+	"coredns_dns_requests_total",
+	"coredns_dns_responses_total",
+	"coredns_cache_hits_total",
+	"coredns_cache_misses_total",
+	"coredns_forward_requests_total",
+	"coredns_forward_responses_total",
+	"coredns_panics_total",
+	"coredns_plugin_enabled",
+}
+
+// CorefileServerBlock is one `zones { plugins }` block of the Corefile
+type CorefileServerBlock struct {
+	Zones   []string `json:"zones"`
+	Plugins []string `json:"plugins"`
+	Forward []string `json:"forward,omitempty"`
+}
+
+// collectCoreDNS builds the "coredns" metric
+func collectCoreDNS(clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("coredns", time.Now())
+
+	ctx, cancel := apiContext()
+	deployments, err := clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: corednsLabelSelector})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing CoreDNS deployments: %v", err)
+		status.Fail("deployments", err)
+		return map[string]interface{}{"detected": false}
+	}
+	if len(deployments.Items) == 0 {
+		return map[string]interface{}{"detected": false}
+	}
+
+	deployment := deployments.Items[0]
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	result := map[string]interface{}{
+		"detected":  true,
+		"name":      deployment.Name,
+		"namespace": deployment.Namespace,
+		"deployment": map[string]interface{}{
+			"replicas":    replicas,
+			"ready":       deployment.Status.ReadyReplicas,
+			"available":   deployment.Status.AvailableReplicas,
+			"updated":     deployment.Status.UpdatedReplicas,
+			"unavailable": deployment.Status.UnavailableReplicas,
+			"healthy":     deployment.Status.ReadyReplicas == replicas && replicas > 0,
+		},
+	}
+	if len(deployment.Spec.Template.Spec.Containers) > 0 {
+		result["image"] = deployment.Spec.Template.Spec.Containers[0].Image
+	}
+
+	// The Corefile lives in the configmap mounted by the deployment
+	configMapName := "coredns"
+	for _, v := range deployment.Spec.Template.Spec.Volumes {
+		if v.ConfigMap != nil {
+			configMapName = v.ConfigMap.Name
+			break
+		}
+	}
+	ctx, cancel = apiContext()
+	configMap, err := clientset.CoreV1().ConfigMaps(deployment.Namespace).Get(ctx, configMapName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error getting CoreDNS configmap %s/%s: %v", deployment.Namespace, configMapName, err)
+		status.Partial("configmap", err)
+	} else if corefile, ok := configMap.Data["Corefile"]; ok {
+		blocks := parseCorefile(corefile)
+		result["server_blocks"] = blocks
+		result["config_issues"] = checkCorefile(blocks)
+		if len(corefile) > maxCorefileBytes {
+			corefile = corefile[:maxCorefileBytes]
+			result["corefile_truncated"] = true
+		}
+		result["corefile"] = corefile
+	}
+
+	var pods []corev1.Pod
+	for _, pod := range snap.Pods {
+		if pod.Namespace == deployment.Namespace && pod.Labels["k8s-app"] == "kube-dns" {
+			pods = append(pods, pod)
+		}
+	}
+	result["pods"] = describeCoreDNSPods(pods)
+	result["metrics"] = scrapeCoreDNSMetrics(pods, status)
+
+	return result
+}
+
+func describeCoreDNSPods(pods []corev1.Pod) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, pod := range pods {
+		restarts := int32(0)
+		for _, cs := range pod.Status.ContainerStatuses {
+			restarts += cs.RestartCount
+		}
+		result = append(result, map[string]interface{}{
+			"name":     pod.Name,
+			"node":     pod.Spec.NodeName,
+			"phase":    string(pod.Status.Phase),
+			"ready":    isPodReady(pod),
+			"restarts": restarts,
+		})
+	}
+	return result
+}
+
+// scrapeCoreDNSMetrics sums the CoreDNS counters over all running pods. The
+// counters are cumulative since each pod started; the backend diffs them.
+func scrapeCoreDNSMetrics(pods []corev1.Pod, status *CollectorStatus) map[string]interface{} {
+	client := &http.Client{Timeout: scrapeTimeout}
+
+	var requests, hits, misses, panics, forwardRequests float64
+	responsesByRcode := map[string]float64{}
+	forwardByRcode := map[string]float64{}
+	scraped := 0
+
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		target := ScrapeTarget{
+			Name:    pod.Name,
+			URL:     fmt.Sprintf("http://%s/metrics", net.JoinHostPort(pod.Status.PodIP, fmt.Sprint(corednsMetricsPort))),
+			Metrics: corednsMetrics,
+		}
+		series, err := scrapeMetricsEndpoint(client, target)
+		if err != nil {
+			status.Partial("metrics/"+pod.Name, err)
+			continue
+		}
+		scraped++
+
+		for _, s := range series {
+			switch s.Name {
+			case "coredns_dns_requests_total":
+				requests += s.Value
+			case "coredns_dns_responses_total":
+				responsesByRcode[s.Labels["rcode"]] += s.Value
+			case "coredns_cache_hits_total":
+				hits += s.Value
+			case "coredns_cache_misses_total":
+				misses += s.Value
+			case "coredns_forward_requests_total":
+				forwardRequests += s.Value
+			case "coredns_forward_responses_total":
+				forwardByRcode[s.Labels["rcode"]] += s.Value
+			case "coredns_panics_total":
+				panics += s.Value
+			}
+		}
+	}
+
+	if scraped == 0 {
+		return nil
+	}
+
+	cacheHitRate := float64(0)
+	if hits+misses > 0 {
+		cacheHitRate = hits / (hits + misses) * 100
+	}
+	errorRate := float64(0)
+	if requests > 0 {
+		errorRate = responsesByRcode["SERVFAIL"] / requests * 100
+	}
+
+	return map[string]interface{}{
+		"pods_scraped":            scraped,
+		"requests_total":          requests,
+		"responses_by_rcode":      responsesByRcode,
+		"cache_hits_total":        hits,
+		"cache_misses_total":      misses,
+		"cache_hit_rate_percent":  cacheHitRate,
+		"servfail_percent":        errorRate,
+		"forward_requests_total":  forwardRequests,
+		"forward_responses_rcode": forwardByRcode,
+		"panics_total":            panics,
+	}
+}
+
+// parseCorefile splits a Corefile into server blocks and their top-level plugins
+func parseCorefile(corefile string) []CorefileServerBlock {
+	var blocks []CorefileServerBlock
+	var current *CorefileServerBlock
+	depth := 0
+
+	for _, raw := range strings.Split(corefile, "\n") {
+		line := raw
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		opens := strings.Count(line, "{")
+		closes := strings.Count(line, "}")
+		fields := strings.Fields(strings.NewReplacer("{", " ", "}", " ").Replace(line))
+
+		switch {
+		case depth == 0 && opens > 0:
+			blocks = append(blocks, CorefileServerBlock{Zones: fields})
+			current = &blocks[len(blocks)-1]
+		case depth == 1 && current != nil && len(fields) > 0:
+			current.Plugins = append(current.Plugins, fields[0])
+			if fields[0] == "forward" && len(fields) > 2 {
+				current.Forward = fields[2:]
+			}
+		}
+
+		depth += opens - closes
+		if depth <= 0 {
+			depth = 0
+			current = nil
+		}
+	}
+	return blocks
+}
+
+// checkCorefile flags common misconfigurations, including broken stub domains
+func checkCorefile(blocks []CorefileServerBlock) []string {
+	var issues []string
+	seenZones := map[string]bool{}
+	hasRoot := false
+
+	for _, block := range blocks {
+		plugins := stringSet(block.Plugins)
+		for _, zone := range block.Zones {
+			if seenZones[zone] {
+				issues = append(issues, fmt.Sprintf("zone %s is declared more than once", zone))
+			}
+			seenZones[zone] = true
+		}
+
+		isRoot := false
+		for _, zone := range block.Zones {
+			if zone == "." || strings.HasPrefix(zone, ".:") {
+				isRoot = true
+			}
+		}
+		name := strings.Join(block.Zones, " ")
+
+		if isRoot {
+			hasRoot = true
+			if !plugins["forward"] && !plugins["proxy"] {
+				issues = append(issues, "root zone has no forward plugin: external names will not resolve")
+			}
+			if !plugins["cache"] {
+				issues = append(issues, "root zone has no cache plugin")
+			}
+			if !plugins["loop"] {
+				issues = append(issues, "root zone has no loop plugin: forwarding loops will not be detected")
+			}
+			if !plugins["kubernetes"] {
+				issues = append(issues, "root zone has no kubernetes plugin: cluster names will not resolve")
+			}
+		} else if plugins["forward"] && len(block.Forward) == 0 {
+			issues = append(issues, fmt.Sprintf("stub domain %s has a forward plugin without upstreams", name))
+		}
+
+		for _, upstream := range block.Forward {
+			if !validDNSUpstream(upstream) {
+				issues = append(issues, fmt.Sprintf("zone %s forwards to invalid upstream %q", name, upstream))
+			}
+		}
+	}
+
+	if len(blocks) > 0 && !hasRoot {
+		issues = append(issues, "no root (.) server block")
+	}
+	return issues
+}
+
+// validDNSUpstream accepts resolv.conf paths and IP[:port] with an optional scheme
+func validDNSUpstream(upstream string) bool {
+	if strings.HasPrefix(upstream, "/") {
+		return true
+	}
+	for _, scheme := range []string{"dns://", "tls://", "grpc://", "https://"} {
+		upstream = strings.TrimPrefix(upstream, scheme)
+	}
+	if net.ParseIP(upstream) != nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(upstream)
+	return err == nil && net.ParseIP(host) != nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CREDENTIAL ROTATION
+// rotate_credentials receives a new API key from the backend, proves it
+// works with a heartbeat, persists it to the credentials Secret and only
+// then switches every backend call over to it
+// ---------------------------------------------
+
+// agentCredentials holds a rotated API key; until the first rotation the
+// configured (or enrolled) key is used
+var agentCredentials struct {
+	mu     sync.RWMutex
+	apiKey string
+}
+
+// agentAPIKey returns the API key backend calls must authenticate with
+func agentAPIKey(config AgentConfig) string {
+	agentCredentials.mu.RLock()
+	defer agentCredentials.mu.RUnlock()
+	if agentCredentials.apiKey != "" {
+		return agentCredentials.apiKey
+	}
+	return config.APIKey
+}
+
+func setAgentAPIKey(key string) {
+	agentCredentials.mu.Lock()
+	agentCredentials.apiKey = key
+	agentCredentials.mu.Unlock()
+}
+
+func rotateCredentials(clientset kubernetes.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p RotateCredentialsParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	previous := agentAPIKey(config)
+	if p.APIKey == previous {
+		return map[string]interface{}{
+			"rotated": false,
+			"message": "the new key is already in use",
+		}, nil
+	}
+
+	// A heartbeat with the new key proves the backend accepts it before anything changes
+	heartbeat := []map[string]interface{}{
+		buildMetric("agent_status", map[string]interface{}{
+			"state":   "rotating_credentials",
+			"version": AgentVersion,
+		}, &CollectorStatus{}),
+	}
+	if err := postMetricsWithKey(config, p.APIKey, heartbeat); err != nil {
+		return nil, fmt.Errorf("new key rejected by the backend, keeping the current key: %w", err)
+	}
+
+	if err := saveCredentials(clientset, config, p.APIKey, config.ClusterID); err != nil {
+		return nil, fmt.Errorf("could not persist the new key to Secret %s/%s, keeping the current key: %w",
+			config.Namespace, config.CredentialsSecret, err)
+	}
+
+	setAgentAPIKey(p.APIKey)
+	log.Printf("🔑 API key rotated: %s -> %s", maskAPIKey(previous), maskAPIKey(p.APIKey))
+
+	return map[string]interface{}{
+		"rotated":      true,
+		"previous_key": maskAPIKey(previous),
+		"new_key":      maskAPIKey(p.APIKey),
+		"secret":       config.Namespace + "/" + config.CredentialsSecret,
+		"rotated_at":   time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
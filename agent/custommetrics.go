@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------
+// CUSTOM METRICS (Prometheus queries + /metrics scraping)
+// Forwards selected app-level series as the "custom_metrics" type
+// ---------------------------------------------
+
+const (
+	// maxCustomSeries bounds how many series are forwarded per cycle
+	maxCustomSeries = 1000
+	// scrapeTimeout bounds each Prometheus query or /metrics scrape
+	scrapeTimeout = 10 * time.Second
+	// maxScrapeBytes bounds how much of a /metrics response is read
+	maxScrapeBytes = 8 << 20
+)
+
+// PromQuery is one instant query sent to Prometheus
+type PromQuery struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// ScrapeTarget is a /metrics endpoint scraped directly. Only the listed
+// metric names (and their _bucket/_sum/_count series) are forwarded.
+type ScrapeTarget struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Metrics []string `json:"metrics"`
+}
+
+// CustomMetricsConfig selects what the custom_metrics collector forwards
+type CustomMetricsConfig struct {
+	PrometheusURL string         `json:"prometheusURL,omitempty"`
+	Queries       []PromQuery    `json:"queries,omitempty"`
+	Targets       []ScrapeTarget `json:"targets,omitempty"`
+}
+
+// Enabled reports whether there is anything to collect
+func (c CustomMetricsConfig) Enabled() bool {
+	return (c.PrometheusURL != "" && len(c.Queries) > 0) || len(c.Targets) > 0
+}
+
+// Validate rejects configs that would forward unbounded data or cannot work
+func (c CustomMetricsConfig) Validate() error {
+	if len(c.Queries) > 0 && c.PrometheusURL == "" {
+		return fmt.Errorf("customMetrics.queries requires customMetrics.prometheusURL")
+	}
+	for _, q := range c.Queries {
+		if q.Name == "" || q.Query == "" {
+			return fmt.Errorf("every custom metrics query needs a name and a query")
+		}
+	}
+	for _, t := range c.Targets {
+		if t.Name == "" || t.URL == "" {
+			return fmt.Errorf("every scrape target needs a name and a url")
+		}
+		if len(t.Metrics) == 0 {
+			return fmt.Errorf("scrape target %s must list the metrics to forward", t.Name)
+		}
+	}
+	return nil
+}
+
+// parsePrometheusQueries parses PROMETHEUS_QUERIES: "name=query;name2=query2"
+func parsePrometheusQueries(value string) []PromQuery {
+	var queries []PromQuery
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, query, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(query) == "" {
+			log.Printf("⚠️  Ignoring invalid PROMETHEUS_QUERIES entry %q (expected name=query)", entry)
+			continue
+		}
+		queries = append(queries, PromQuery{Name: strings.TrimSpace(name), Query: strings.TrimSpace(query)})
+	}
+	return queries
+}
+
+// customSeries is one forwarded sample
+type customSeries struct {
+	Source string            `json:"source"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// collectCustomMetrics runs the configured queries and scrapes. A failing
+// source marks the section partial; it fails only when every source failed.
+func collectCustomMetrics(cfg CustomMetricsConfig, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("custom_metrics", time.Now())
+
+	client := &http.Client{Timeout: scrapeTimeout}
+	var series []customSeries
+	var sources []map[string]interface{}
+	failed := 0
+	truncated := false
+
+	record := func(name, kind string, got []customSeries, err error) {
+		source := map[string]interface{}{"name": name, "kind": kind, "ok": err == nil, "series": len(got)}
+		if err != nil {
+			failed++
+			source["error"] = err.Error()
+			status.Partial(name, err)
+		}
+		sources = append(sources, source)
+
+		if room := maxCustomSeries - len(series); len(got) > room {
+			got = got[:room]
+			truncated = true
+		}
+		series = append(series, got...)
+	}
+
+	if cfg.PrometheusURL != "" {
+		for _, q := range cfg.Queries {
+			got, err := queryPrometheus(client, cfg.PrometheusURL, q)
+			record(q.Name, "prometheus_query", got, err)
+		}
+	}
+	for _, t := range cfg.Targets {
+		got, err := scrapeMetricsEndpoint(client, t)
+		record(t.Name, "scrape", got, err)
+	}
+
+	if failed > 0 && failed == len(sources) {
+		status.Fail("custom_metrics", fmt.Errorf("all %d sources failed", failed))
+	}
+	if truncated {
+		log.Printf("⚠️  Custom metrics truncated to %d series", maxCustomSeries)
+	}
+
+	return map[string]interface{}{
+		"series":    series,
+		"sources":   sources,
+		"truncated": truncated,
+	}
+}
+
+// queryPrometheus runs an instant query against the Prometheus HTTP API
+func queryPrometheus(client *http.Client, baseURL string, q PromQuery) ([]customSeries, error) {
+	endpoint := strings.TrimRight(baseURL, "/") + "/api/v1/query?query=" + url.QueryEscape(q.Query)
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxScrapeBytes)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid Prometheus response (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s", body.Error)
+	}
+
+	var out []customSeries
+	switch body.Data.ResultType {
+	case "vector":
+		var result []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &result); err != nil {
+			return nil, err
+		}
+		for _, r := range result {
+			if value, ok := promSampleValue(r.Value); ok {
+				out = append(out, customSeries{Source: q.Name, Name: q.Name, Labels: r.Metric, Value: value})
+			}
+		}
+	case "scalar":
+		var result [2]interface{}
+		if err := json.Unmarshal(body.Data.Result, &result); err != nil {
+			return nil, err
+		}
+		if value, ok := promSampleValue(result); ok {
+			out = append(out, customSeries{Source: q.Name, Name: q.Name, Value: value})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported result type %q (use an instant vector or scalar query)", body.Data.ResultType)
+	}
+	return out, nil
+}
+
+// promSampleValue extracts a finite value from a [timestamp, "value"] pair
+func promSampleValue(sample [2]interface{}) (float64, bool) {
+	s, ok := sample[1].(string)
+	if !ok {
+		return 0, false
+	}
+	return parseFiniteFloat(s)
+}
+
+// scrapeMetricsEndpoint reads a Prometheus text exposition endpoint and keeps
+// only the series of the listed metrics
+func scrapeMetricsEndpoint(client *http.Client, t ScrapeTarget) ([]customSeries, error) {
+	resp, err := client.Get(t.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	wanted := stringSet(t.Metrics)
+	var out []customSeries
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxScrapeBytes))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, value, ok := parseExpositionLine(line)
+		if !ok || !metricWanted(name, wanted) {
+			continue
+		}
+		out = append(out, customSeries{Source: t.Name, Name: name, Labels: labels, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// metricWanted matches a series name against the requested metric names,
+// including the companion series of histograms and summaries
+func metricWanted(name string, wanted map[string]bool) bool {
+	if wanted[name] {
+		return true
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if strings.HasSuffix(name, suffix) && wanted[strings.TrimSuffix(name, suffix)] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpositionLine parses `name{label="value",...} value [timestamp]`
+func parseExpositionLine(line string) (string, map[string]string, float64, bool) {
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return "", nil, 0, false
+	}
+	name := line[:nameEnd]
+	rest := line[nameEnd:]
+
+	var labels map[string]string
+	if strings.HasPrefix(rest, "{") {
+		var end int
+		var ok bool
+		labels, end, ok = parseExpositionLabels(rest)
+		if !ok {
+			return "", nil, 0, false
+		}
+		rest = rest[end:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, false
+	}
+	value, ok := parseFiniteFloat(fields[0])
+	return name, labels, value, ok
+}
+
+// parseExpositionLabels parses a `{...}` label set and returns the index after it
+func parseExpositionLabels(s string) (map[string]string, int, bool) {
+	labels := map[string]string{}
+	i := 1
+	for i < len(s) {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i < len(s) && s[i] == '}' {
+			return labels, i + 1, true
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return nil, 0, false
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 2
+
+		var value strings.Builder
+		for i < len(s) && s[i] != '"' {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+			} else {
+				value.WriteByte(s[i])
+			}
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, false
+		}
+		labels[key] = value.String()
+		i++
+	}
+	return nil, 0, false
+}
+
+// parseFiniteFloat parses a sample value, rejecting NaN/Inf (not valid JSON)
+func parseFiniteFloat(s string) (float64, bool) {
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
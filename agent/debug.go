@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// DEBUG POD COMMAND
+// Attaches an ephemeral debug container to a running pod (the equivalent of
+// `kubectl debug -it <pod> --image=... --target=...`) for distroless workloads
+// ---------------------------------------------
+
+// defaultDebugImage is used when the command does not choose an image
+const defaultDebugImage = "busybox:1.36"
+
+// debugContainerStartTimeout is how long debug_pod waits for the container to start
+const debugContainerStartTimeout = 30 * time.Second
+
+// debugPod implements the "debug_pod" command
+func debugPod(clientset kubernetes.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p DebugPodParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	image := p.Image
+	if image == "" {
+		image = defaultDebugImage
+	}
+
+	ctx, cancel := apiContext()
+	pod, err := clientset.CoreV1().Pods(p.Namespace).Get(ctx, p.PodName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", p.Namespace, p.PodName, err)
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("pod %s/%s is %s, not Running", p.Namespace, p.PodName, pod.Status.Phase)
+	}
+	if p.TargetContainer != "" && !hasContainer(pod, p.TargetContainer) {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "target_container", Message: fmt.Sprintf("container %s not found in pod", p.TargetContainer)}}}
+	}
+
+	debugContainer := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     "debugger-" + rand.String(5),
+			Image:                    image,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+			Command:                  p.Command,
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+		},
+		TargetContainerName: p.TargetContainer,
+	}
+	if len(p.Command) == 0 {
+		// Keep the default shell alive until someone attaches, like kubectl debug -it
+		debugContainer.Stdin = true
+		debugContainer.TTY = true
+	}
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, debugContainer)
+
+	log.Printf("🐞 [audit] Adding debug container %s (%s) to %s/%s (target=%q)",
+		debugContainer.Name, image, p.Namespace, p.PodName, p.TargetContainer)
+
+	ctx, cancel = apiContext()
+	_, err = clientset.CoreV1().Pods(p.Namespace).UpdateEphemeralContainers(ctx, p.PodName, pod, metav1.UpdateOptions{FieldManager: fieldManager})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to add debug container to %s/%s: %w", p.Namespace, p.PodName, err)
+	}
+
+	state := waitForEphemeralContainer(clientset, p.Namespace, p.PodName, debugContainer.Name)
+
+	return map[string]interface{}{
+		"action":           "debug_container_created",
+		"pod":              p.PodName,
+		"namespace":        p.Namespace,
+		"container":        debugContainer.Name,
+		"image":            image,
+		"target_container": p.TargetContainer,
+		"state":            state,
+		"message":          "Ephemeral debug container added. It stays in the pod spec until the pod is deleted.",
+	}, nil
+}
+
+// waitForEphemeralContainer polls until the debug container leaves Waiting
+// (or the timeout passes) and returns its last known state
+func waitForEphemeralContainer(clientset kubernetes.Interface, namespace, podName, containerName string) map[string]interface{} {
+	state := map[string]interface{}{"status": "unknown"}
+	_ = wait.PollUntilContextTimeout(context.Background(), 2*time.Second, debugContainerStartTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, cs := range pod.Status.EphemeralContainerStatuses {
+			if cs.Name != containerName {
+				continue
+			}
+			state = getContainerState(cs.State)
+			return cs.State.Waiting == nil, nil
+		}
+		return false, nil
+	})
+	return state
+}
+
+// hasContainer reports whether the pod has a regular container with the name
+func hasContainer(pod *corev1.Pod, name string) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// DESCRIBE POD COMMAND
+// Structured equivalent of `kubectl describe pod` for the detail view
+// ---------------------------------------------
+func describePod(clientset kubernetes.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p PodParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	podName, namespace := p.PodName, p.Namespace
+
+	ctx, cancel := apiContext()
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
+	}
+
+	sanitizePodSpec(&pod.Spec)
+	sanitizeObjectMeta(&pod.ObjectMeta)
+
+	result := map[string]interface{}{
+		"action":          "describe_pod",
+		"name":            pod.Name,
+		"namespace":       pod.Namespace,
+		"node":            pod.Spec.NodeName,
+		"service_account": pod.Spec.ServiceAccountName,
+		"priority_class":  pod.Spec.PriorityClassName,
+		"labels":          pod.Labels,
+		"annotations":     pod.Annotations,
+		"created_at":      pod.CreationTimestamp.Time,
+		"status": map[string]interface{}{
+			"phase":     string(pod.Status.Phase),
+			"reason":    pod.Status.Reason,
+			"message":   pod.Status.Message,
+			"pod_ip":    pod.Status.PodIP,
+			"host_ip":   pod.Status.HostIP,
+			"qos_class": string(pod.Status.QOSClass),
+			"ready":     isPodReady(*pod),
+		},
+		"conditions":      getPodConditions(*pod),
+		"init_containers": describeContainers(pod.Spec.InitContainers, pod.Status.InitContainerStatuses),
+		"containers":      describeContainers(pod.Spec.Containers, pod.Status.ContainerStatuses),
+		"volumes":         describeVolumes(pod.Spec.Volumes),
+		"node_selector":   pod.Spec.NodeSelector,
+		"tolerations":     describeTolerations(pod.Spec.Tolerations),
+		"controller":      describeController(clientset, pod),
+		"events":          describePodEvents(clientset, pod),
+	}
+
+	if pod.DeletionTimestamp != nil {
+		result["terminating_since"] = pod.DeletionTimestamp.Time
+	}
+
+	log.Printf("🔎 Described pod %s/%s", namespace, podName)
+	return result, nil
+}
+
+func describeContainers(containers []corev1.Container, statuses []corev1.ContainerStatus) []map[string]interface{} {
+	statusByName := make(map[string]corev1.ContainerStatus, len(statuses))
+	for _, cs := range statuses {
+		statusByName[cs.Name] = cs
+	}
+
+	var result []map[string]interface{}
+	for _, c := range containers {
+		var ports []map[string]interface{}
+		for _, p := range c.Ports {
+			ports = append(ports, map[string]interface{}{
+				"name":           p.Name,
+				"container_port": p.ContainerPort,
+				"protocol":       string(p.Protocol),
+			})
+		}
+
+		var mounts []map[string]interface{}
+		for _, m := range c.VolumeMounts {
+			mounts = append(mounts, map[string]interface{}{
+				"name":       m.Name,
+				"mount_path": m.MountPath,
+				"read_only":  m.ReadOnly,
+			})
+		}
+
+		container := map[string]interface{}{
+			"name":  c.Name,
+			"image": c.Image,
+			"ports": ports,
+			"resources": map[string]interface{}{
+				"requests": resourceListToStrings(c.Resources.Requests),
+				"limits":   resourceListToStrings(c.Resources.Limits),
+			},
+			"volume_mounts":   mounts,
+			"liveness_probe":  c.LivenessProbe != nil,
+			"readiness_probe": c.ReadinessProbe != nil,
+			"startup_probe":   c.StartupProbe != nil,
+		}
+
+		if cs, ok := statusByName[c.Name]; ok {
+			container["ready"] = cs.Ready
+			container["restart_count"] = cs.RestartCount
+			container["image_id"] = cs.ImageID
+			container["state"] = getContainerState(cs.State)
+			container["last_state"] = getContainerState(cs.LastTerminationState)
+		}
+
+		result = append(result, container)
+	}
+	return result
+}
+
+// resourceListToStrings renders quantities the way kubectl prints them
+func resourceListToStrings(list corev1.ResourceList) map[string]string {
+	out := make(map[string]string, len(list))
+	for name, quantity := range list {
+		out[string(name)] = quantity.String()
+	}
+	return out
+}
+
+// describeVolumes reports each volume's name and source type (never its contents)
+func describeVolumes(volumes []corev1.Volume) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, v := range volumes {
+		volume := map[string]interface{}{"name": v.Name}
+		switch {
+		case v.PersistentVolumeClaim != nil:
+			volume["type"] = "PersistentVolumeClaim"
+			volume["claim_name"] = v.PersistentVolumeClaim.ClaimName
+		case v.ConfigMap != nil:
+			volume["type"] = "ConfigMap"
+			volume["source"] = v.ConfigMap.Name
+		case v.Secret != nil:
+			volume["type"] = "Secret"
+			volume["source"] = v.Secret.SecretName
+		case v.EmptyDir != nil:
+			volume["type"] = "EmptyDir"
+			volume["medium"] = string(v.EmptyDir.Medium)
+		case v.HostPath != nil:
+			volume["type"] = "HostPath"
+			volume["source"] = v.HostPath.Path
+		case v.Projected != nil:
+			volume["type"] = "Projected"
+		case v.DownwardAPI != nil:
+			volume["type"] = "DownwardAPI"
+		case v.CSI != nil:
+			volume["type"] = "CSI"
+			volume["source"] = v.CSI.Driver
+		case v.Ephemeral != nil:
+			volume["type"] = "Ephemeral"
+		default:
+			volume["type"] = "Other"
+		}
+		result = append(result, volume)
+	}
+	return result
+}
+
+func describeTolerations(tolerations []corev1.Toleration) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, t := range tolerations {
+		toleration := map[string]interface{}{
+			"key":      t.Key,
+			"operator": string(t.Operator),
+			"value":    t.Value,
+			"effect":   string(t.Effect),
+		}
+		if t.TolerationSeconds != nil {
+			toleration["toleration_seconds"] = *t.TolerationSeconds
+		}
+		result = append(result, toleration)
+	}
+	return result
+}
+
+// describeController returns the pod's controlling owner, resolving a
+// ReplicaSet to its Deployment when possible
+func describeController(clientset kubernetes.Interface, pod *corev1.Pod) map[string]interface{} {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+
+	controller := map[string]interface{}{
+		"kind": owner.Kind,
+		"name": owner.Name,
+	}
+
+	if owner.Kind == "ReplicaSet" {
+		ctx, cancel := apiContext()
+		rs, err := clientset.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		cancel()
+		if err != nil {
+			log.Printf("⚠️  Error getting ReplicaSet %s/%s: %v", pod.Namespace, owner.Name, err)
+		} else if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil {
+			controller["replica_set"] = owner.Name
+			controller["kind"] = rsOwner.Kind
+			controller["name"] = rsOwner.Name
+		}
+	}
+
+	return controller
+}
+
+// describePodEvents lists the events recorded for this pod
+func describePodEvents(clientset kubernetes.Interface, pod *corev1.Pod) []map[string]interface{} {
+	selector := fields.Set{
+		"involvedObject.kind": "Pod",
+		"involvedObject.name": pod.Name,
+		"involvedObject.uid":  string(pod.UID),
+	}.AsSelector().String()
+
+	ctx, cancel := apiContext()
+	events, err := clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing events for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+
+	var result []map[string]interface{}
+	for _, event := range events.Items {
+		result = append(result, map[string]interface{}{
+			"type":       event.Type,
+			"reason":     event.Reason,
+			"message":    event.Message,
+			"count":      event.Count,
+			"first_time": event.FirstTimestamp.Time,
+			"last_time":  event.LastTimestamp.Time,
+			"source":     event.Source.Component,
+		})
+	}
+	return result
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// SELF-DIAGNOSTICS
+// Runtime facts returned by the "diagnose" command
+// ---------------------------------------------
+
+// maxRecentErrors bounds how many backend errors are kept for diagnostics
+const maxRecentErrors = 20
+
+// maxRecentPayloads and maxRecentCommands bound the send and command history
+const (
+	maxRecentPayloads = 20
+	maxRecentCommands = 50
+)
+
+type backendError struct {
+	Endpoint string    `json:"endpoint"`
+	Error    string    `json:"error"`
+	At       time.Time `json:"at"`
+}
+
+// sentPayload summarizes one metrics payload
+type sentPayload struct {
+	At    time.Time      `json:"at"`
+	Bytes int            `json:"bytes"`
+	Types map[string]int `json:"types"`
+	OK    bool           `json:"ok"`
+	Error string         `json:"error,omitempty"`
+}
+
+// commandRun is the outcome of one command
+type commandRun struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type backendCall struct {
+	LatencyMs int64     `json:"latency_ms"`
+	OK        bool      `json:"ok"`
+	At        time.Time `json:"at"`
+}
+
+// AgentDiagnostics keeps the latest collection timings and backend calls
+type AgentDiagnostics struct {
+	mu                 sync.Mutex
+	startedAt          time.Time
+	collectorDurations map[string]time.Duration
+	lastCycleAt        time.Time
+	backendCalls       map[string]backendCall
+	lastSuccessAt      map[string]time.Time
+	recentErrors       []backendError
+	redactions         map[string]int64
+	recentPayloads     []sentPayload
+	recentCommands     []commandRun
+}
+
+var diagnostics = &AgentDiagnostics{
+	startedAt:          time.Now(),
+	collectorDurations: map[string]time.Duration{},
+	backendCalls:       map[string]backendCall{},
+	lastSuccessAt:      map[string]time.Time{},
+	redactions:         map[string]int64{},
+}
+
+// recordDuration stores how long a collector took. Use as
+// `defer diagnostics.recordDuration("name", time.Now())`.
+func (d *AgentDiagnostics) recordDuration(name string, start time.Time) {
+	elapsed := time.Since(start)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.collectorDurations[name] = elapsed
+	if name == "cycle" {
+		d.lastCycleAt = start
+	}
+}
+
+// recordBackendCall stores the latency and outcome of a call to the backend
+func (d *AgentDiagnostics) recordBackendCall(endpoint string, start time.Time, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.backendCalls[endpoint] = backendCall{
+		LatencyMs: time.Since(start).Milliseconds(),
+		OK:        err == nil,
+		At:        start,
+	}
+	if err == nil {
+		d.lastSuccessAt[endpoint] = start
+	} else {
+		d.recentErrors = append(d.recentErrors, backendError{Endpoint: endpoint, Error: err.Error(), At: start})
+		if len(d.recentErrors) > maxRecentErrors {
+			d.recentErrors = d.recentErrors[len(d.recentErrors)-maxRecentErrors:]
+		}
+	}
+}
+
+// lastSuccess returns when a call to endpoint last succeeded (zero if never)
+func (d *AgentDiagnostics) lastSuccess(endpoint string) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastSuccessAt[endpoint]
+}
+
+// recordRedactions counts values scrubbed from outgoing payloads; a non-zero
+// count means some collector is leaking and should sanitize at the source
+func (d *AgentDiagnostics) recordRedactions(payload string, count int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.redactions[payload] += int64(count)
+}
+
+// recordPayload stores the size and outcome of a metrics payload
+func (d *AgentDiagnostics) recordPayload(metrics []map[string]interface{}, bytes int, start time.Time, err error) {
+	types := map[string]int{}
+	for _, m := range metrics {
+		metricType, _ := m["type"].(string)
+		types[metricType]++
+	}
+	payload := sentPayload{At: start, Bytes: bytes, Types: types, OK: err == nil}
+	if err != nil {
+		payload.Error = err.Error()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recentPayloads = append(d.recentPayloads, payload)
+	if len(d.recentPayloads) > maxRecentPayloads {
+		d.recentPayloads = d.recentPayloads[len(d.recentPayloads)-maxRecentPayloads:]
+	}
+}
+
+// recordCommand stores the outcome of a command
+func (d *AgentDiagnostics) recordCommand(cmd Command, start time.Time, err error) {
+	run := commandRun{ID: cmd.ID, Type: cmd.CommandType, At: start, DurationMs: time.Since(start).Milliseconds(), OK: err == nil}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recentCommands = append(d.recentCommands, run)
+	if len(d.recentCommands) > maxRecentCommands {
+		d.recentCommands = d.recentCommands[len(d.recentCommands)-maxRecentCommands:]
+	}
+}
+
+// snapshot returns a JSON-friendly copy of the recorded state
+func (d *AgentDiagnostics) snapshot() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	durations := make(map[string]int64, len(d.collectorDurations))
+	for name, dur := range d.collectorDurations {
+		durations[name] = dur.Milliseconds()
+	}
+	calls := make(map[string]backendCall, len(d.backendCalls))
+	for endpoint, call := range d.backendCalls {
+		calls[endpoint] = call
+	}
+	errors := make([]backendError, len(d.recentErrors))
+	copy(errors, d.recentErrors)
+	payloads := make([]sentPayload, len(d.recentPayloads))
+	copy(payloads, d.recentPayloads)
+	commands := make([]commandRun, len(d.recentCommands))
+	copy(commands, d.recentCommands)
+	redactions := make(map[string]int64, len(d.redactions))
+	for payload, count := range d.redactions {
+		redactions[payload] = count
+	}
+
+	return map[string]interface{}{
+		"uptime_seconds":          int64(time.Since(d.startedAt).Seconds()),
+		"last_cycle_at":           d.lastCycleAt,
+		"collection_durations_ms": durations,
+		"backend_calls":           calls,
+		"recent_errors":           errors,
+		"redactions":              redactions,
+		"recent_payloads":         payloads,
+		"recent_commands":         commands,
+	}
+}
+
+// requiredPermissions lists the access the agent needs to collect
+var requiredPermissions = []authorizationv1.ResourceAttributes{
+	{Verb: "list", Resource: "nodes"},
+	{Verb: "get", Resource: "nodes", Subresource: "proxy"},
+	{Verb: "list", Resource: "pods"},
+	{Verb: "list", Resource: "events"},
+	{Verb: "list", Resource: "namespaces"},
+	{Verb: "list", Resource: "persistentvolumeclaims"},
+	{Verb: "list", Resource: "persistentvolumes"},
+	{Verb: "list", Resource: "services"},
+	{Verb: "list", Resource: "secrets"},
+	{Verb: "list", Group: "apps", Resource: "deployments"},
+	{Verb: "list", Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+	{Verb: "list", Group: "networking.k8s.io", Resource: "networkpolicies"},
+	{Verb: "list", Group: "metrics.k8s.io", Resource: "nodes"},
+}
+
+// commandPermissions lists, per command type, the API calls its handler makes.
+// Keep it in step with the handlers: the self-check verifies exactly these, so
+// a missing entry reports a healthy agent for a command that cannot run.
+func commandPermissions(clientset kubernetes.Interface, config AgentConfig) map[string][]authorizationv1.ResourceAttributes {
+	// Writes to the agent's own Secret and ConfigMap are granted per name
+	own := config.Namespace
+	deployments := func(verbs ...string) []authorizationv1.ResourceAttributes {
+		var attrs []authorizationv1.ResourceAttributes
+		for _, verb := range verbs {
+			attrs = append(attrs, authorizationv1.ResourceAttributes{Verb: verb, Group: "apps", Resource: "deployments"})
+		}
+		return attrs
+	}
+
+	perms := map[string][]authorizationv1.ResourceAttributes{
+		"restart_pod":                 {{Verb: "delete", Resource: "pods"}},
+		"delete_pod":                  {{Verb: "delete", Resource: "pods"}},
+		"scale_deployment":            deployments("get", "patch"),
+		"update_deployment_image":     deployments("get", "patch"),
+		"update_deployment_resources": deployments("get", "patch"),
+		"get_manifest": {
+			{Verb: "get", Resource: "pods"},
+			{Verb: "get", Resource: "services"},
+			{Verb: "get", Resource: "configmaps"},
+			{Verb: "get", Resource: "secrets"},
+			{Verb: "get", Group: "apps", Resource: "deployments"},
+			{Verb: "get", Group: "apps", Resource: "statefulsets"},
+			{Verb: "get", Group: "apps", Resource: "daemonsets"},
+		},
+		"describe_pod": {
+			{Verb: "get", Resource: "pods"},
+			{Verb: "list", Resource: "events"},
+			{Verb: "get", Group: "apps", Resource: "replicasets"},
+		},
+		"open_tunnel": {
+			{Verb: "get", Resource: "pods"},
+			{Verb: "create", Resource: "pods", Subresource: "portforward"},
+		},
+		"debug_pod": {
+			{Verb: "get", Resource: "pods"},
+			{Verb: "update", Resource: "pods", Subresource: "ephemeralcontainers"},
+		},
+		"apply_network_policy": {{Verb: "patch", Group: "networking.k8s.io", Resource: "networkpolicies"}},
+		"rotate_credentials": {
+			{Verb: "get", Resource: "secrets", Namespace: own, Name: config.CredentialsSecret},
+			{Verb: "create", Resource: "secrets", Namespace: own},
+			{Verb: "update", Resource: "secrets", Namespace: own, Name: config.CredentialsSecret},
+		},
+		"check_access": {
+			{Verb: "create", Group: "authorization.k8s.io", Resource: "subjectaccessreviews"},
+			{Verb: "impersonate", Resource: "users"},
+			{Verb: "impersonate", Resource: "groups"},
+		},
+		"benchmark_node": {
+			{Verb: "get", Resource: "nodes"},
+			{Verb: "create", Resource: "pods", Namespace: own},
+			{Verb: "delete", Resource: "pods", Namespace: own},
+		},
+		"set_collection_policy": {
+			{Verb: "get", Resource: "configmaps", Namespace: own, Name: config.CollectionPolicyConfigMap},
+			{Verb: "create", Resource: "configmaps", Namespace: own},
+			{Verb: "update", Resource: "configmaps", Namespace: own, Name: config.CollectionPolicyConfigMap},
+		},
+		"self_update": {
+			{Verb: "get", Group: "apps", Resource: "deployments", Namespace: own},
+			{Verb: "update", Group: "apps", Resource: "deployments", Namespace: own},
+		},
+	}
+	perms["agent_update"] = perms["self_update"]
+
+	// patch_resource may touch every allowlisted kind
+	for kind := range getSettings().PatchableKinds {
+		gk := schema.ParseGroupKind(kind)
+		mapping, err := kindMapping(clientset, gk.WithVersion(""))
+		if err != nil {
+			continue
+		}
+		perms["patch_resource"] = append(perms["patch_resource"], authorizationv1.ResourceAttributes{
+			Verb: "patch", Group: mapping.Resource.Group, Resource: mapping.Resource.Resource,
+		})
+	}
+
+	// Successful changes are also recorded as Events on the target
+	if config.CommandEvents {
+		for _, command := range []string{"restart_pod", "delete_pod", "scale_deployment", "update_deployment_image",
+			"update_deployment_resources", "apply_network_policy", "patch_resource"} {
+			perms[command] = append(perms[command], authorizationv1.ResourceAttributes{Verb: "create", Resource: "events"})
+		}
+	}
+	return perms
+}
+
+// checkAgentPermissions asks the API server which of the collection and
+// command permissions the agent holds. Each check lists what needs it.
+func checkAgentPermissions(clientset kubernetes.Interface, config AgentConfig) ([]map[string]interface{}, []string) {
+	var order []authorizationv1.ResourceAttributes
+	neededBy := map[authorizationv1.ResourceAttributes][]string{}
+	need := func(attrs authorizationv1.ResourceAttributes, by string) {
+		if _, ok := neededBy[attrs]; !ok {
+			order = append(order, attrs)
+		}
+		neededBy[attrs] = append(neededBy[attrs], by)
+	}
+	for _, attrs := range requiredPermissions {
+		need(attrs, "collection")
+	}
+	perms := commandPermissions(clientset, config)
+	commandTypes := make([]string, 0, len(perms))
+	for command := range perms {
+		commandTypes = append(commandTypes, command)
+	}
+	sort.Strings(commandTypes)
+	for _, command := range commandTypes {
+		for _, attrs := range perms[command] {
+			need(attrs, command)
+		}
+	}
+
+	var results []map[string]interface{}
+	blocked := map[string]bool{}
+	for _, attrs := range order {
+		attrs := attrs
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attrs},
+		}
+
+		check := map[string]interface{}{
+			"verb":        attrs.Verb,
+			"group":       attrs.Group,
+			"resource":    attrs.Resource,
+			"subresource": attrs.Subresource,
+			"namespace":   attrs.Namespace,
+			"needed_by":   neededBy[attrs],
+		}
+
+		ctx, cancel := apiContext()
+		resp, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		cancel()
+		allowed := false
+		if err != nil {
+			check["error"] = err.Error()
+		} else {
+			allowed = resp.Status.Allowed
+			if resp.Status.Reason != "" {
+				check["reason"] = resp.Status.Reason
+			}
+		}
+		check["allowed"] = allowed
+		if !allowed {
+			for _, by := range neededBy[attrs] {
+				if by != "collection" && getSettings().CommandAllowed(by) {
+					blocked[by] = true
+				}
+			}
+		}
+		results = append(results, check)
+	}
+
+	blockedCommands := make([]string, 0, len(blocked))
+	for command := range blocked {
+		blockedCommands = append(blockedCommands, command)
+	}
+	sort.Strings(blockedCommands)
+	return results, blockedCommands
+}
+
+// runDiagnostics implements the "diagnose" command
+func runDiagnostics(clientset kubernetes.Interface, config AgentConfig) (map[string]interface{}, error) {
+	log.Printf("🩺 Running agent self-diagnostics...")
+
+	permissions, blockedCommands := checkAgentPermissions(clientset, config)
+	denied := 0
+	for _, p := range permissions {
+		if allowed, _ := p["allowed"].(bool); !allowed {
+			denied++
+		}
+	}
+
+	serverVersion := ""
+	if info, err := clientset.Discovery().ServerVersion(); err == nil {
+		serverVersion = info.GitVersion
+	}
+
+	return map[string]interface{}{
+		"action":         "diagnose",
+		"agent_version":  AgentVersion,
+		"git_commit":     gitCommit(),
+		"go_version":     runtime.Version(),
+		"server_version": serverVersion,
+		"config": map[string]interface{}{
+			"api_endpoint":     config.APIEndpoint,
+			"cluster_id":       config.ClusterID,
+			"api_key":          maskAPIKey(agentAPIKey(config)),
+			"interval_seconds": config.Interval,
+			"startup_splay":    config.StartupSplay,
+			"jitter_percent":   config.JitterPercent,
+			"settings_source":  getSettings().Source,
+		},
+		"permissions":        permissions,
+		"permissions_denied": denied,
+		"blocked_commands":   blockedCommands,
+		"runtime":            diagnostics.snapshot(),
+		"bandwidth":          bandwidth.report(),
+		"goroutines":         runtime.NumGoroutine(),
+	}, nil
+}
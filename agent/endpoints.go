@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// SERVICE ROUTING (EndpointSlices)
+// Services whose traffic has nowhere to go, split by cause: the selector
+// matches no pods ("service broken"), the pods exist but none are ready
+// ("pods broken"), or ready pods are missing from the endpoints (routing gap)
+// ---------------------------------------------
+
+// collectServiceRouting builds the "service_routing" metric
+func collectServiceRouting(clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("service_routing", time.Now())
+
+	ctx, cancel := apiContext()
+	sliceList, err := clientset.DiscoveryV1().EndpointSlices("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing EndpointSlices: %v", err)
+		status.Partial("endpointslices", err)
+	}
+
+	type endpointCounts struct{ ready, notReady, terminating int }
+	endpointsByService := map[string]*endpointCounts{}
+	if sliceList != nil {
+		for _, slice := range sliceList.Items {
+			service := slice.Labels[discoveryv1.LabelServiceName]
+			if service == "" {
+				continue
+			}
+			key := slice.Namespace + "/" + service
+			counts, ok := endpointsByService[key]
+			if !ok {
+				counts = &endpointCounts{}
+				endpointsByService[key] = counts
+			}
+			for _, ep := range slice.Endpoints {
+				switch {
+				case ep.Conditions.Terminating != nil && *ep.Conditions.Terminating:
+					counts.terminating++
+				case ep.Conditions.Ready == nil || *ep.Conditions.Ready:
+					counts.ready++
+				default:
+					counts.notReady++
+				}
+			}
+		}
+	}
+
+	podsByNamespace := map[string][]corev1.Pod{}
+	for _, pod := range snap.Pods {
+		if pod.DeletionTimestamp == nil && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			podsByNamespace[pod.Namespace] = append(podsByNamespace[pod.Namespace], pod)
+		}
+	}
+
+	var gaps []map[string]interface{}
+	summary := map[string]int{"ok": 0, "no_matching_pods": 0, "pods_not_ready": 0, "no_ready_endpoints": 0, "target_port_missing": 0}
+	checked := 0
+	for _, svc := range snap.Services {
+		// Services without a selector manage their endpoints by hand
+		if len(svc.Spec.Selector) == 0 || svc.Spec.Type == corev1.ServiceTypeExternalName {
+			continue
+		}
+		checked++
+
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		var matching []corev1.Pod
+		readyPods := 0
+		for _, pod := range podsByNamespace[svc.Namespace] {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				matching = append(matching, pod)
+				if isPodReady(pod) {
+					readyPods++
+				}
+			}
+		}
+
+		counts := endpointsByService[svc.Namespace+"/"+svc.Name]
+		if counts == nil {
+			counts = &endpointCounts{}
+		}
+
+		problem := ""
+		var missingPorts []string
+		switch {
+		case len(matching) == 0:
+			problem = "no_matching_pods"
+		case readyPods == 0:
+			problem = "pods_not_ready"
+		default:
+			missingPorts = missingTargetPorts(svc, matching[0])
+			if len(missingPorts) > 0 {
+				problem = "target_port_missing"
+			} else if sliceList != nil && counts.ready == 0 {
+				problem = "no_ready_endpoints"
+			}
+		}
+
+		if problem == "" {
+			summary["ok"]++
+			continue
+		}
+		summary[problem]++
+		gaps = append(gaps, map[string]interface{}{
+			"namespace":            svc.Namespace,
+			"service":              svc.Name,
+			"service_type":         string(svc.Spec.Type),
+			"selector":             svc.Spec.Selector,
+			"problem":              problem,
+			"matching_pods":        len(matching),
+			"ready_pods":           readyPods,
+			"ready_endpoints":      counts.ready,
+			"not_ready_endpoints":  counts.notReady,
+			"terminating":          counts.terminating,
+			"missing_target_ports": missingPorts,
+		})
+	}
+
+	return map[string]interface{}{
+		"services_checked": checked,
+		"summary":          summary,
+		"routing_gaps":     gaps,
+		"endpoint_slices":  len(endpointsByService),
+	}
+}
+
+// missingTargetPorts returns the named targetPorts that no container of the pod declares
+func missingTargetPorts(svc corev1.Service, pod corev1.Pod) []string {
+	declared := map[string]bool{}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name != "" {
+				declared[p.Name] = true
+			}
+		}
+	}
+	var missing []string
+	for _, port := range svc.Spec.Ports {
+		if port.TargetPort.Type == intstr.String && port.TargetPort.StrVal != "" && !declared[port.TargetPort.StrVal] {
+			missing = append(missing, port.TargetPort.StrVal)
+		}
+	}
+	return missing
+}
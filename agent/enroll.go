@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// ENROLLMENT (first-boot registration)
+// Instead of pre-provisioning API_KEY/CLUSTER_ID per cluster, the agent can be
+// installed with a BOOTSTRAP_TOKEN. On first boot it exchanges the token for
+// a cluster-specific key and ID and stores them in a Secret, which later
+// boots read back.
+// ---------------------------------------------
+
+// Keys of the credentials Secret, matching the env var names
+const (
+	credentialsAPIKey    = "API_KEY"
+	credentialsClusterID = "CLUSTER_ID"
+)
+
+// registrationResponse is the body returned by agent-register
+type registrationResponse struct {
+	APIKey    string `json:"api_key"`
+	ClusterID string `json:"cluster_id"`
+}
+
+// enrollAgent resolves config.APIKey/ClusterID. The credentials Secret wins
+// over the env vars (it holds enrolled or rotated keys) unless it belongs to
+// another cluster ID; without either, the agent registers with the bootstrap
+// token (retrying until the backend accepts it).
+func enrollAgent(clientset kubernetes.Interface, config AgentConfig) AgentConfig {
+	if apiKey, clusterID, err := loadCredentials(clientset, config); err != nil {
+		log.Printf("⚠️  Could not read credentials Secret %s/%s: %v", config.Namespace, config.CredentialsSecret, err)
+	} else if apiKey != "" && clusterID != "" && (config.ClusterID == "" || config.ClusterID == clusterID) {
+		log.Printf("🔑 Using credentials from Secret %s/%s", config.Namespace, config.CredentialsSecret)
+		config.APIKey, config.ClusterID = apiKey, clusterID
+		return config
+	}
+
+	if config.APIKey != "" && config.ClusterID != "" {
+		return config
+	}
+
+	if config.BootstrapToken == "" {
+		log.Printf("❌ No API_KEY/CLUSTER_ID and no BOOTSTRAP_TOKEN: the backend will reject every call")
+		return config
+	}
+
+	backoff := startupInitialBackoff
+	for attempt := 1; ; attempt++ {
+		creds, err := registerAgent(clientset, config)
+		if err == nil {
+			config.APIKey, config.ClusterID = creds.APIKey, creds.ClusterID
+			log.Printf("✅ Agent enrolled as cluster %s (key %s)", config.ClusterID, maskAPIKey(config.APIKey))
+			break
+		}
+		log.Printf("⚠️  Registration attempt %d failed: %v (retrying in %v)", attempt, err, backoff)
+		time.Sleep(jitteredInterval(backoff, config.JitterPercent))
+		backoff *= 2
+		if backoff > startupMaxBackoff {
+			backoff = startupMaxBackoff
+		}
+	}
+
+	// The bootstrap token may be single-use; losing the credentials would
+	// strand the agent on its next restart, so keep trying to store them
+	backoff = startupInitialBackoff
+	for attempt := 1; attempt <= startupQuickAttempts; attempt++ {
+		err := saveCredentials(clientset, config, config.APIKey, config.ClusterID)
+		if err == nil {
+			log.Printf("🔑 Stored credentials in Secret %s/%s", config.Namespace, config.CredentialsSecret)
+			return config
+		}
+		log.Printf("⚠️  Could not store credentials (attempt %d): %v", attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("❌ Credentials are only kept in memory; the agent will need a new bootstrap token after a restart")
+	return config
+}
+
+// loadCredentials reads the enrolled key and cluster ID; a missing Secret returns empty values
+func loadCredentials(clientset kubernetes.Interface, config AgentConfig) (string, string, error) {
+	ctx, cancel := apiContext()
+	defer cancel()
+	secret, err := clientset.CoreV1().Secrets(config.Namespace).Get(ctx, config.CredentialsSecret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return string(secret.Data[credentialsAPIKey]), string(secret.Data[credentialsClusterID]), nil
+}
+
+// saveCredentials creates or updates the credentials Secret
+func saveCredentials(clientset kubernetes.Interface, config AgentConfig, apiKey, clusterID string) error {
+	secrets := clientset.CoreV1().Secrets(config.Namespace)
+	data := map[string][]byte{
+		credentialsAPIKey:    []byte(apiKey),
+		credentialsClusterID: []byte(clusterID),
+	}
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	existing, err := secrets.Get(ctx, config.CredentialsSecret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      config.CredentialsSecret,
+				Namespace: config.Namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": fieldManager},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}, metav1.CreateOptions{FieldManager: fieldManager})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Data = data
+	_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{FieldManager: fieldManager})
+	return err
+}
+
+// registerAgent exchanges the bootstrap token for cluster credentials. The
+// kube-system namespace UID identifies the cluster, so a re-registration of
+// the same cluster can be matched to its existing ID by the backend.
+func registerAgent(clientset kubernetes.Interface, config AgentConfig) (creds registrationResponse, err error) {
+	defer func(start time.Time) {
+		diagnostics.recordBackendCall("agent-register", start, err)
+	}(time.Now())
+
+	request := map[string]interface{}{
+		"bootstrap_token": config.BootstrapToken,
+		"agent_version":   AgentVersion,
+	}
+	ctx, cancel := apiContext()
+	if ns, err := clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{}); err == nil {
+		request["cluster_uid"] = string(ns.UID)
+	}
+	cancel()
+	if version, err := clientset.Discovery().ServerVersion(); err == nil {
+		request["kubernetes_version"] = version.GitVersion
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return creds, err
+	}
+	url := fmt.Sprintf("%s/agent-register", config.APIEndpoint)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return creds, fmt.Errorf("failed to create registration request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-agent-version", AgentVersion)
+	// No agent key yet, but an ingestion gateway still needs its credentials
+	if err := authenticateRequest(config, req, "", body); err != nil {
+		return creds, err
+	}
+
+	client := backendClient(config)
+	resp, err := client.Do(req)
+	if err != nil {
+		return creds, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return creds, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return creds, fmt.Errorf("backend returned %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	if err := json.Unmarshal(responseBody, &creds); err != nil {
+		return creds, fmt.Errorf("invalid registration response: %v", err)
+	}
+	if creds.APIKey == "" || creds.ClusterID == "" {
+		return creds, fmt.Errorf("registration response is missing api_key or cluster_id")
+	}
+	return creds, nil
+}
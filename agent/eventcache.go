@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// EVENT CACHE
+// Events are the largest list of a cycle and mostly unchanged between
+// cycles, so they are kept across cycles. After one full list, each cycle
+// only reads what changed since the remembered resourceVersion through a
+// short watch, replaying the backlog and stopping once it is idle. When the
+// watch is gone (410 Gone: the resourceVersion expired) the next cycle lists
+// again. When watching is not allowed, the API offers no "newer than"
+// selector for events, so the list is instead served from the API server's
+// watch cache (resourceVersion=0) and only repeated every
+// EVENTS_RELIST_SECONDS, with the cached events used in between. Failed
+// lists back off exponentially up to maxEventRelistBackoff, also serving the
+// cached events, marked stale through the snapshot error.
+// ---------------------------------------------
+
+const (
+	// eventWatchIdle ends a catch-up watch once no change arrived for this long
+	eventWatchIdle = 300 * time.Millisecond
+	// eventWatchTimeoutSeconds bounds one catch-up watch server-side
+	eventWatchTimeoutSeconds int64 = 5
+	maxEventRelistBackoff          = 5 * time.Minute
+)
+
+type eventCache struct {
+	mu     sync.Mutex
+	events map[types.UID]corev1.Event
+	// resourceVersion is where the next watch resumes ("" forces a list)
+	resourceVersion string
+	watchForbidden  bool
+	listedAt        time.Time
+	failures        int
+	retryAt         time.Time
+	lastErr         error
+}
+
+var eventStore = &eventCache{events: map[types.UID]corev1.Event{}}
+
+// refresh brings the cache up to date and returns its events. On failure the
+// cached events are returned with the error.
+func (c *eventCache) refresh(clientset kubernetes.Interface, config AgentConfig, now time.Time) ([]corev1.Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case now.Before(c.retryAt):
+		// Backing off after a failed list
+		return c.list(), c.lastErr
+	case c.resourceVersion != "" && !c.watchForbidden:
+		if err := c.catchUp(clientset); err == nil {
+			return c.list(), nil
+		}
+	case c.resourceVersion != "" && now.Sub(c.listedAt) < time.Duration(config.EventsRelistSeconds)*time.Second:
+		// List-only mode between re-lists
+		return c.list(), nil
+	}
+
+	if err := c.relist(clientset, now); err != nil {
+		c.failures++
+		backoff := min(time.Duration(config.Interval)*time.Second<<min(c.failures, 10), maxEventRelistBackoff)
+		c.retryAt, c.lastErr = now.Add(backoff), err
+		log.Printf("⚠️  Listing events failed (%d in a row), serving %d cached events for %v: %v", c.failures, len(c.events), backoff, err)
+		return c.list(), err
+	}
+	c.failures, c.retryAt, c.lastErr = 0, time.Time{}, nil
+	return c.list(), nil
+}
+
+// relist replaces the cache with a full list; the caller holds c.mu
+func (c *eventCache) relist(clientset kubernetes.Interface, now time.Time) error {
+	opts := metav1.ListOptions{}
+	if c.watchForbidden {
+		// Served from the API server cache instead of etcd; slightly stale is fine
+		opts.ResourceVersion = "0"
+	}
+	ctx, cancel := apiContext()
+	defer cancel()
+	list, err := clientset.CoreV1().Events("").List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	c.events = make(map[types.UID]corev1.Event, len(list.Items))
+	for _, e := range list.Items {
+		c.events[e.UID] = e
+	}
+	c.resourceVersion, c.listedAt = list.ResourceVersion, now
+	return nil
+}
+
+// catchUp applies the changes since resourceVersion; the caller holds c.mu.
+// An error means the cache must be re-listed.
+func (c *eventCache) catchUp(clientset kubernetes.Interface) error {
+	timeout := eventWatchTimeoutSeconds
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout+1)*time.Second)
+	defer cancel()
+	w, err := clientset.CoreV1().Events("").Watch(ctx, metav1.ListOptions{
+		ResourceVersion:     c.resourceVersion,
+		AllowWatchBookmarks: true,
+		TimeoutSeconds:      &timeout,
+	})
+	if apierrors.IsForbidden(err) {
+		log.Printf("⚠️  Watching events is not allowed, re-listing them every few cycles instead: %v", err)
+		c.watchForbidden = true
+		return err
+	}
+	if err != nil {
+		c.resourceVersion = ""
+		return err
+	}
+	defer w.Stop()
+
+	idle := time.NewTimer(eventWatchIdle)
+	defer idle.Stop()
+	for {
+		select {
+		case <-idle.C:
+			return nil
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			if ev.Type == watch.Error {
+				// 410 Gone (expired resourceVersion) or another watch failure
+				if status, ok := ev.Object.(*metav1.Status); ok && status.Code != http.StatusGone {
+					log.Printf("⚠️  Event watch failed, re-listing: %s", status.Message)
+				}
+				c.resourceVersion = ""
+				return apierrors.FromObject(ev.Object)
+			}
+			e, ok := ev.Object.(*corev1.Event)
+			if !ok {
+				continue
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				c.events[e.UID] = *e
+			case watch.Deleted:
+				delete(c.events, e.UID)
+			}
+			c.resourceVersion = e.ResourceVersion
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(eventWatchIdle)
+		}
+	}
+}
+
+// list returns the cached events in a stable order; the caller holds c.mu
+func (c *eventCache) list() []corev1.Event {
+	list := make([]corev1.Event, 0, len(c.events))
+	for _, e := range c.events {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Namespace != list[j].Namespace {
+			return list[i].Namespace < list[j].Namespace
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
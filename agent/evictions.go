@@ -0,0 +1,232 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// NODE-PRESSURE EVICTIONS
+// The kubelet evicts pods when a node runs low on memory, disk or inodes and
+// records it only as an "Evicted" event and a Failed pod status. The agent
+// parses the victim, the resource under pressure and the threshold from the
+// message, and attaches the node's eviction signals from the local time
+// series as they were around the eviction.
+// ---------------------------------------------
+
+const (
+	// evictionHistoryWindow is how long observed evictions are remembered
+	evictionHistoryWindow = 24 * time.Hour
+	// evictionStatsTolerance is how far a time series sample may be from the eviction
+	evictionStatsTolerance = 2 * time.Minute
+)
+
+var (
+	// "The node was low on resource: memory. Threshold quantity: 100Mi, available: 51200Ki."
+	evictionResourceRe  = regexp.MustCompile(`low on resource: ([a-z-]+)\.`)
+	evictionThresholdRe = regexp.MustCompile(`Threshold quantity: ([^,]+), available: ([^.]+)\.`)
+	// "Container app was using 1Gi, request is 100Mi, has larger consumption of memory."
+	evictionContainerRe = regexp.MustCompile(`Container (\S+) was using (\S+), request is (\S+)`)
+)
+
+// evictionSignals are the node series relevant to each starved resource
+var evictionSignals = map[string][]string{
+	"memory":            {"memory_available_bytes"},
+	"ephemeral-storage": {"nodefs_available_bytes", "imagefs_available_bytes"},
+	"inodes":            {"nodefs_inodes_free"},
+}
+
+// evictionVictim is one container named in the eviction message
+type evictionVictim struct {
+	Container string `json:"container"`
+	Usage     string `json:"usage"`
+	Request   string `json:"request"`
+}
+
+// evictionEvent is one evicted pod
+type evictionEvent struct {
+	Namespace    string    `json:"namespace"`
+	Pod          string    `json:"pod"`
+	Node         string    `json:"node"`
+	WorkloadKind string    `json:"workload_kind,omitempty"`
+	Workload     string    `json:"workload,omitempty"`
+	Time         time.Time `json:"time"`
+	// Cause is "node_pressure" or "ephemeral_storage_limit" (the pod's own limit)
+	Cause     string           `json:"cause"`
+	Resource  string           `json:"resource,omitempty"`
+	Threshold string           `json:"threshold,omitempty"`
+	Available string           `json:"available,omitempty"`
+	QOSClass  string           `json:"qos_class,omitempty"`
+	Victims   []evictionVictim `json:"victims,omitempty"`
+	NodeStats map[string]int64 `json:"node_stats,omitempty"`
+	StatsAt   *time.Time       `json:"node_stats_at,omitempty"`
+	Message   string           `json:"message"`
+}
+
+// evictionHistoryStore remembers evictions between cycles (lost on restart)
+type evictionHistoryStore struct {
+	mu     sync.Mutex
+	events map[string]evictionEvent
+}
+
+var evictionHistory = &evictionHistoryStore{events: map[string]evictionEvent{}}
+
+// reset forgets every remembered eviction (memory watchdog)
+func (h *evictionHistoryStore) reset() {
+	h.mu.Lock()
+	h.events = map[string]evictionEvent{}
+	h.mu.Unlock()
+}
+
+// record keeps the first sighting of every eviction (its stats were sampled
+// closest to the eviction), drops expired ones and returns the rest, oldest first
+func (h *evictionHistoryStore) record(observed []evictionEvent, now time.Time) []evictionEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range observed {
+		key := e.Namespace + "/" + e.Pod
+		if _, ok := h.events[key]; !ok {
+			h.events[key] = e
+		}
+	}
+
+	events := make([]evictionEvent, 0, len(h.events))
+	for key, e := range h.events {
+		if now.Sub(e.Time) > evictionHistoryWindow {
+			delete(h.events, key)
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+// parseEviction fills cause, resource, threshold and victims from the kubelet message
+func parseEviction(e *evictionEvent, message string) {
+	e.Message = message
+	e.Cause = "node_pressure"
+	if strings.Contains(message, "ephemeral local storage") || strings.Contains(message, "exceeded its local ephemeral storage limit") {
+		e.Cause = "ephemeral_storage_limit"
+		e.Resource = "ephemeral-storage"
+	}
+	if m := evictionResourceRe.FindStringSubmatch(message); m != nil {
+		e.Resource = m[1]
+	}
+	if m := evictionThresholdRe.FindStringSubmatch(message); m != nil {
+		e.Threshold, e.Available = strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+	}
+	for _, m := range evictionContainerRe.FindAllStringSubmatch(message, -1) {
+		e.Victims = append(e.Victims, evictionVictim{Container: m[1], Usage: strings.TrimSuffix(m[2], ","), Request: strings.TrimSuffix(m[3], ",")})
+	}
+}
+
+// attachNodeStats looks up the node's eviction signals around the eviction
+func attachNodeStats(e *evictionEvent) {
+	signals, ok := evictionSignals[e.Resource]
+	if !ok || e.Node == "" {
+		return
+	}
+	for _, signal := range signals {
+		sample, found := tsStore.sampleAt("node/"+e.Node+"/"+signal, e.Time, evictionStatsTolerance)
+		if !found {
+			continue
+		}
+		if e.NodeStats == nil {
+			e.NodeStats = map[string]int64{}
+			at := time.Unix(sample.T, 0).UTC()
+			e.StatsAt = &at
+		}
+		e.NodeStats[signal] = int64(sample.V)
+	}
+}
+
+// evictedAt estimates the eviction time of a pod whose event has expired:
+// the moment it stopped being Ready
+func evictedAt(pod corev1.Pod) time.Time {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady && c.Status != corev1.ConditionTrue && !c.LastTransitionTime.IsZero() {
+			return c.LastTransitionTime.Time
+		}
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// collectEvictions builds the "evictions" metric
+func collectEvictions(snap *ClusterSnapshot, settings *AgentSettings) map[string]interface{} {
+	defer diagnostics.recordDuration("evictions", time.Now())
+
+	pods := map[string]corev1.Pod{}
+	for _, pod := range snap.Pods {
+		pods[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	observed := map[string]evictionEvent{}
+	newEviction := func(namespace, name, node string, at time.Time, message string) {
+		key := namespace + "/" + name
+		if _, ok := observed[key]; ok || !settings.NamespaceAllowed(namespace) {
+			return
+		}
+		e := evictionEvent{Namespace: namespace, Pod: name, Node: node, Time: at}
+		if pod, ok := pods[key]; ok {
+			e.WorkloadKind, e.Workload = podWorkload(pod)
+			e.QOSClass = string(pod.Status.QOSClass)
+			if e.Node == "" {
+				e.Node = pod.Spec.NodeName
+			}
+		}
+		parseEviction(&e, message)
+		attachNodeStats(&e)
+		observed[key] = e
+	}
+
+	// Events carry the eviction time; the pod status outlives them
+	thresholdEvents := map[string]int{}
+	for _, ev := range snap.Events {
+		switch {
+		case ev.InvolvedObject.Kind == "Pod" && ev.Reason == "Evicted":
+			newEviction(ev.InvolvedObject.Namespace, ev.InvolvedObject.Name, ev.Source.Host, eventTime(ev), ev.Message)
+		case ev.InvolvedObject.Kind == "Node" && ev.Reason == "EvictionThresholdMet":
+			thresholdEvents[ev.InvolvedObject.Name]++
+		}
+	}
+	for _, pod := range snap.Pods {
+		if pod.Status.Reason != "Evicted" {
+			continue
+		}
+		newEviction(pod.Namespace, pod.Name, pod.Spec.NodeName, evictedAt(pod), pod.Status.Message)
+	}
+
+	list := make([]evictionEvent, 0, len(observed))
+	for _, e := range observed {
+		list = append(list, e)
+	}
+	events := evictionHistory.record(list, snap.TakenAt)
+
+	byResource := map[string]int{}
+	byNode := map[string]int{}
+	byCause := map[string]int{}
+	for _, e := range events {
+		if e.Resource != "" {
+			byResource[e.Resource]++
+		}
+		byNode[e.Node]++
+		byCause[e.Cause]++
+	}
+
+	return map[string]interface{}{
+		"evictions":             events,
+		"total":                 len(events),
+		"by_resource":           byResource,
+		"by_node":               byNode,
+		"by_cause":              byCause,
+		"threshold_met_by_node": thresholdEvents,
+		"window_seconds":        int64(evictionHistoryWindow.Seconds()),
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ---------------------------------------------
+// STUCK DELETIONS (Terminating namespaces + finalizers)
+// Objects whose deletion has been blocked for longer than a threshold,
+// with the finalizers holding them and the controllers expected to remove them
+// ---------------------------------------------
+
+// stuckDeletionThreshold is how long a deletion may pend before it is reported
+const stuckDeletionThreshold = 10 * time.Minute
+
+// finalizerOwners maps well-known finalizers (or, for dotted keys, their domain) to the
+// controller responsible for removing them
+var finalizerOwners = map[string]string{
+	"kubernetes":                   "namespace controller",
+	"foregroundDeletion":           "garbage collector",
+	"orphan":                       "garbage collector",
+	"kubernetes.io/pvc-protection": "kube-controller-manager (pvc-protection)",
+	"kubernetes.io/pv-protection":  "kube-controller-manager (pv-protection)",
+	"external-attacher":            "CSI external-attacher",
+	"external-provisioner.volume.kubernetes.io/finalizer": "CSI external-provisioner",
+	"service.kubernetes.io/load-balancer-cleanup":         "cloud-controller-manager (service)",
+	"batch.kubernetes.io/job-tracking":                    "job controller",
+	"resources-finalizer.argocd.argoproj.io":              "ArgoCD application controller",
+	"finalizers.fluxcd.io":                                "Flux controllers",
+	"cert-manager.io":                                     "cert-manager",
+	"elbv2.k8s.aws":                                       "AWS Load Balancer Controller",
+}
+
+// collectStuckDeletions builds the "stuck_deletions" metric
+func collectStuckDeletions(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("stuck_deletions", time.Now())
+
+	now := time.Now()
+	var namespaces []map[string]interface{}
+	for _, ns := range snap.Namespaces {
+		if ns.Status.Phase != corev1.NamespaceTerminating || !deletionStuck(ns.ObjectMeta, now) {
+			continue
+		}
+		info := map[string]interface{}{
+			"name":              ns.Name,
+			"terminating_since": ns.DeletionTimestamp.Time,
+			"stuck_for_seconds": int64(now.Sub(ns.DeletionTimestamp.Time).Seconds()),
+			"finalizers":        describeFinalizers(namespaceFinalizers(ns)),
+		}
+		// The namespace controller explains what is left in its conditions
+		var conditions []map[string]interface{}
+		for _, cond := range ns.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			conditions = append(conditions, map[string]interface{}{
+				"type":    string(cond.Type),
+				"reason":  cond.Reason,
+				"message": cond.Message,
+			})
+			if cond.Type == corev1.NamespaceFinalizersRemaining {
+				info["blocking_finalizers"] = describeFinalizers(finalizersFromConditionMessage(cond.Message))
+			}
+		}
+		info["conditions"] = conditions
+		namespaces = append(namespaces, info)
+	}
+
+	var objects []map[string]interface{}
+	addObject := func(kind string, meta metav1.ObjectMeta) {
+		if !deletionStuck(meta, now) || len(meta.Finalizers) == 0 {
+			return
+		}
+		objects = append(objects, map[string]interface{}{
+			"kind":              kind,
+			"namespace":         meta.Namespace,
+			"name":              meta.Name,
+			"deleting_since":    meta.DeletionTimestamp.Time,
+			"stuck_for_seconds": int64(now.Sub(meta.DeletionTimestamp.Time).Seconds()),
+			"finalizers":        describeFinalizers(meta.Finalizers),
+		})
+	}
+	for _, pod := range snap.Pods {
+		addObject("Pod", pod.ObjectMeta)
+	}
+	for _, pvc := range snap.PVCs {
+		addObject("PersistentVolumeClaim", pvc.ObjectMeta)
+	}
+	for _, pv := range snap.PVs {
+		addObject("PersistentVolume", pv.ObjectMeta)
+	}
+	for _, svc := range snap.Services {
+		addObject("Service", svc.ObjectMeta)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i]["stuck_for_seconds"].(int64) > objects[j]["stuck_for_seconds"].(int64)
+	})
+
+	return map[string]interface{}{
+		"threshold_seconds":      int64(stuckDeletionThreshold.Seconds()),
+		"terminating_namespaces": namespaces,
+		"stuck_objects":          objects,
+	}
+}
+
+// deletionStuck reports whether an object has been deleting for longer than the threshold
+func deletionStuck(meta metav1.ObjectMeta, now time.Time) bool {
+	return meta.DeletionTimestamp != nil && now.Sub(meta.DeletionTimestamp.Time) > stuckDeletionThreshold
+}
+
+// namespaceFinalizers returns both spec.finalizers and metadata.finalizers
+func namespaceFinalizers(ns corev1.Namespace) []string {
+	finalizers := append([]string{}, ns.Finalizers...)
+	for _, f := range ns.Spec.Finalizers {
+		finalizers = append(finalizers, string(f))
+	}
+	return finalizers
+}
+
+// finalizersFromConditionMessage parses the NamespaceFinalizersRemaining message:
+// "Some content in the namespace has finalizers remaining: a.io/x in 2 resource instances, b in 1 resource instances"
+func finalizersFromConditionMessage(message string) []string {
+	_, list, found := strings.Cut(message, "finalizers remaining:")
+	if !found {
+		return nil
+	}
+	var finalizers []string
+	for _, part := range strings.Split(list, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), " in ")
+		if name != "" {
+			finalizers = append(finalizers, name)
+		}
+	}
+	return finalizers
+}
+
+// describeFinalizers pairs each finalizer with the controller expected to remove it
+func describeFinalizers(finalizers []string) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, f := range finalizers {
+		result = append(result, map[string]interface{}{
+			"name":       f,
+			"controller": finalizerOwner(f),
+		})
+	}
+	return result
+}
+
+func finalizerOwner(finalizer string) string {
+	if owner, ok := finalizerOwners[finalizer]; ok {
+		return owner
+	}
+	// Finalizers are conventionally "<controller domain>/<name>"
+	domain, _, found := strings.Cut(finalizer, "/")
+	for key, owner := range finalizerOwners {
+		if strings.Contains(key, ".") && strings.HasSuffix(domain, key) {
+			return owner
+		}
+	}
+	if found {
+		return domain
+	}
+	return "unknown"
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// THREAT FINDING LIFECYCLE
+// Threat entries are rebuilt every cycle. Each one gets a stable finding_id
+// (hash of the rule and the object it was raised on) and first/last-seen
+// times, and findings that disappear are reported as resolved for a while so
+// the backend can open and close alerts instead of diffing snapshots. Pod
+// findings are keyed by the owning workload, not the pod, so rollouts and
+// reschedules keep the same finding. The state lives in memory: after a
+// restart open findings get a new first_seen.
+// ---------------------------------------------
+
+// resolvedFindingRetention is how long a resolved finding keeps being reported,
+// so a missed payload does not leave the alert open
+const resolvedFindingRetention = time.Hour
+
+// findingIdentity lists, per threat category, the fields naming the rule and
+// the object; the rest (images, limits, counts) may change without making it
+// a different finding
+var findingIdentity = map[string][]string{
+	"suspicious_pods":       {"namespace", "owner_kind", "owner_name", "container_name", "reason"},
+	"privileged_containers": {"namespace", "owner_kind", "owner_name", "container_name", "capability", "reason"},
+	"resource_anomalies":    {"namespace", "owner_kind", "owner_name", "container_name", "reason"},
+	"host_network_pods":     {"namespace", "owner_kind", "owner_name"},
+	"host_pid_pods":         {"namespace", "owner_kind", "owner_name"},
+	"host_socket_mounts":    {"namespace", "owner_kind", "owner_name", "volume", "host_path"},
+	"suspicious_events":     {"namespace", "kind", "object", "reason"},
+	"network_anomalies":     {"namespace", "service_name", "port", "reason"},
+	"privileged_daemonsets": {"namespace", "name"},
+}
+
+// findingSource is the snapshot resource each category is built from; when
+// its list failed the category is not evaluated, so open findings stay open
+var findingSource = map[string]string{
+	"suspicious_pods":       "pods",
+	"privileged_containers": "pods",
+	"resource_anomalies":    "pods",
+	"host_network_pods":     "pods",
+	"host_pid_pods":         "pods",
+	"host_socket_mounts":    "pods",
+	"suspicious_events":     "events",
+	"network_anomalies":     "services",
+}
+
+// threatFinding is the remembered state of one finding
+type threatFinding struct {
+	ID          string
+	Category    string
+	Namespace   string
+	Object      string
+	ThreatLevel string
+	Reason      string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	ResolvedAt  time.Time
+	// Suppressed is set when an accepted-risk rule matched it this cycle
+	Suppressed bool
+}
+
+// threatFindingStore tracks findings between cycles
+type threatFindingStore struct {
+	mu       sync.Mutex
+	findings map[string]*threatFinding
+}
+
+var threatFindings = &threatFindingStore{findings: map[string]*threatFinding{}}
+
+// findingID hashes the category and identity fields of an entry
+func findingID(category string, entry map[string]interface{}) string {
+	key := category
+	for _, field := range findingIdentity[category] {
+		key += "\x00" + fmt.Sprint(entry[field])
+	}
+	return sha256Hex([]byte(key))[:16]
+}
+
+// findingObject names the object a finding was raised on
+func findingObject(entry map[string]interface{}) string {
+	for _, field := range []string{"owner_name", "pod_name", "service_name", "object", "name"} {
+		if v, ok := entry[field].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// threatEntries returns the entry list of a category in the threats payload
+func threatEntries(data map[string]interface{}, category string) ([]map[string]interface{}, bool) {
+	switch v := data[category].(type) {
+	case []map[string]interface{}:
+		return v, true
+	case map[string]interface{}:
+		entries, ok := v["daemonsets"].([]map[string]interface{})
+		return entries, ok
+	}
+	return nil, false
+}
+
+// stampPodOwners adds owner_kind and owner_name to the pod entries of the
+// threats payload, from the pods of the snapshot
+func stampPodOwners(data map[string]interface{}, pods []corev1.Pod) {
+	byName := make(map[string]corev1.Pod, len(pods))
+	for _, pod := range pods {
+		byName[pod.Namespace+"/"+pod.Name] = pod
+	}
+	for category, source := range findingSource {
+		if source != "pods" {
+			continue
+		}
+		entries, _ := threatEntries(data, category)
+		for _, entry := range entries {
+			namespace, _ := entry["namespace"].(string)
+			name, _ := entry["pod_name"].(string)
+			kind, owner := "Pod", name
+			if pod, ok := byName[namespace+"/"+name]; ok {
+				kind, owner = podWorkload(pod)
+			}
+			entry["owner_kind"] = kind
+			entry["owner_name"] = owner
+		}
+	}
+}
+
+// track stamps finding_id, first_seen and last_seen on every entry of the
+// threats payload and returns the findings resolved within the retention.
+// Categories missing from data or whose snapshot source failed (sourceErrors)
+// are left untouched.
+func (s *threatFindingStore) track(data map[string]interface{}, sourceErrors map[string]error, now time.Time) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evaluated := map[string]bool{}
+	seen := map[string]bool{}
+	for category := range findingIdentity {
+		if _, failed := sourceErrors[findingSource[category]]; failed {
+			continue
+		}
+		entries, ok := threatEntries(data, category)
+		if !ok {
+			continue
+		}
+		evaluated[category] = true
+		for _, entry := range entries {
+			id := findingID(category, entry)
+			seen[id] = true
+			f, ok := s.findings[id]
+			if !ok || !f.ResolvedAt.IsZero() {
+				// New, or reopened after being resolved
+				f = &threatFinding{ID: id, Category: category, FirstSeen: now}
+				s.findings[id] = f
+			}
+			f.LastSeen = now
+			f.Suppressed = false
+			f.Namespace, _ = entry["namespace"].(string)
+			f.Object = findingObject(entry)
+			f.ThreatLevel, _ = entry["threat_level"].(string)
+			f.Reason, _ = entry["reason"].(string)
+			entry["finding_id"] = id
+			entry["first_seen"] = f.FirstSeen.UTC()
+			entry["last_seen"] = now.UTC()
+		}
+	}
+
+	var resolved []*threatFinding
+	for id, f := range s.findings {
+		if seen[id] || !evaluated[f.Category] {
+			continue
+		}
+		if f.ResolvedAt.IsZero() {
+			f.ResolvedAt = now
+		}
+		if now.Sub(f.ResolvedAt) > resolvedFindingRetention {
+			delete(s.findings, id)
+			continue
+		}
+		resolved = append(resolved, f)
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].ResolvedAt.After(resolved[j].ResolvedAt) })
+
+	out := make([]map[string]interface{}, 0, len(resolved))
+	for _, f := range resolved {
+		out = append(out, map[string]interface{}{
+			"finding_id":   f.ID,
+			"category":     f.Category,
+			"namespace":    f.Namespace,
+			"object":       f.Object,
+			"threat_level": f.ThreatLevel,
+			"reason":       f.Reason,
+			"first_seen":   f.FirstSeen.UTC(),
+			"last_seen":    f.LastSeen.UTC(),
+			"resolved_at":  f.ResolvedAt.UTC(),
+		})
+	}
+	return out
+}
+
+// markSuppressed flags the findings moved to suppressed_findings this cycle
+func (s *threatFindingStore) markSuppressed(entries []map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		id, _ := entry["finding_id"].(string)
+		if f, ok := s.findings[id]; ok {
+			f.Suppressed = true
+		}
+	}
+}
+
+// reset forgets every finding (memory watchdog); the ones still present
+// open again on the next cycle with a new first_seen
+func (s *threatFindingStore) reset() {
+	s.mu.Lock()
+	s.findings = map[string]*threatFinding{}
+	s.mu.Unlock()
+}
+
+// open returns a copy of the findings that are neither resolved nor suppressed
+func (s *threatFindingStore) open() []threatFinding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var open []threatFinding
+	for _, f := range s.findings {
+		if f.ResolvedAt.IsZero() && !f.Suppressed {
+			open = append(open, *f)
+		}
+	}
+	return open
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// AGENT FOOTPRINT AND MEMORY WATCHDOG
+// The agent reports its own CPU, memory, GC and goroutine figures so a
+// misbehaving agent shows up in the dashboard before it shows up as an
+// incident. The memory cap comes from the container limit (downward API,
+// AGENT_MEMORY_LIMIT_MB); above it the watchdog restarts the metrics loop,
+// which drops the state it keeps between cycles (time series, evictions, RBAC
+// baseline, threat findings, Kubelet configs, SBOM records) and rebuilds it
+// from the next cycle. When memory is still over the cap after that, the
+// agent exits for a clean restart.
+// ---------------------------------------------
+
+const (
+	// watchdogInterval is how often memory is checked against the cap
+	watchdogInterval = 10 * time.Second
+	// watchdogMaxStrikes consecutive checks over the cap after the loops
+	// released their state make the agent exit and leave the restart to
+	// the kubelet
+	watchdogMaxStrikes = 6
+	// softLimitPercent of the cap is handed to the Go runtime (GOMEMLIMIT)
+	softLimitPercent = 80
+)
+
+// processStats reads the agent's CPU time and resident memory from /proc
+// (Linux only; ok is false elsewhere)
+func processStats() (cpuSeconds float64, rssBytes uint64, ok bool) {
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	// The command name may contain spaces: fields start after its ")"
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	if len(fields) < 13 {
+		return 0, 0, false
+	}
+	utime, _ := strconv.ParseFloat(fields[11], 64)
+	stime, _ := strconv.ParseFloat(fields[12], 64)
+	cpuSeconds = (utime + stime) / 100 // USER_HZ
+
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return cpuSeconds, 0, false
+	}
+	if parts := strings.Fields(string(statm)); len(parts) > 1 {
+		pages, _ := strconv.ParseUint(parts[1], 10, 64)
+		rssBytes = pages * uint64(os.Getpagesize())
+	}
+	return cpuSeconds, rssBytes, true
+}
+
+// memoryWatchdog enforces the memory cap and counts what it had to do
+type memoryWatchdog struct {
+	mu          sync.Mutex
+	capBytes    uint64
+	strikes     int
+	restarts    int
+	lastRestart time.Time
+	lastReason  string
+	// pending holds the loops that have not restarted yet since the last trigger
+	pending map[string]bool
+}
+
+var watchdog = &memoryWatchdog{pending: map[string]bool{}}
+
+// footprintCPU remembers the previous CPU sample for a usage rate
+var footprintCPU = struct {
+	mu      sync.Mutex
+	seconds float64
+	at      time.Time
+}{}
+
+// loopState is what each runJittered loop keeps between cycles; the
+// watchdog restarts a loop by dropping it
+var loopState = map[string][]func(){
+	"metrics": {
+		tsStore.reset,
+		evictionHistory.reset,
+		resetRBACBaseline,
+		threatFindings.reset,
+		kubeletConfigs.reset,
+		resetSBOMRecords,
+	},
+}
+
+// startMemoryWatchdog derives the cap from the container limit and checks it
+// in the background; a zero limit only disables enforcement
+func startMemoryWatchdog(config AgentConfig) {
+	if config.MemoryLimitMB <= 0 || config.MemoryCapPercent <= 0 {
+		log.Printf("🐕 Memory watchdog disabled (AGENT_MEMORY_LIMIT_MB not set)")
+		return
+	}
+	capBytes := uint64(config.MemoryLimitMB) * 1024 * 1024 * uint64(config.MemoryCapPercent) / 100
+	watchdog.mu.Lock()
+	watchdog.capBytes = capBytes
+	watchdog.mu.Unlock()
+
+	// Make the GC work harder before the cap instead of after it
+	if os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(int64(capBytes * softLimitPercent / 100))
+	}
+	log.Printf("🐕 Memory watchdog: cap %d MiB (%d%% of %d MiB)", capBytes>>20, config.MemoryCapPercent, config.MemoryLimitMB)
+
+	go func() {
+		for range time.Tick(watchdogInterval) {
+			watchdog.check()
+		}
+	}()
+}
+
+// check compares resident memory (heap when /proc is unavailable) with the cap
+func (w *memoryWatchdog) check() {
+	_, used, ok := processStats()
+	if !ok {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		used = m.HeapInuse + m.StackInuse
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if used <= w.capBytes {
+		w.strikes = 0
+		return
+	}
+
+	if w.strikes == 0 {
+		w.strikes++
+		w.restarts++
+		w.lastRestart = time.Now()
+		w.lastReason = fmt.Sprintf("memory %d MiB above cap %d MiB", used>>20, w.capBytes>>20)
+		log.Printf("🐕 %s: restarting collection loops", w.lastReason)
+		for loop := range loopState {
+			w.pending[loop] = true
+		}
+		return
+	}
+	// Strikes only count once every loop has dropped its state
+	if len(w.pending) > 0 {
+		return
+	}
+	w.strikes++
+	if w.strikes > watchdogMaxStrikes {
+		log.Printf("🐕 Memory still %d MiB above cap after restarting the loops, exiting for a clean restart", (used-w.capBytes)>>20)
+		os.Exit(1)
+	}
+}
+
+// restartLoop drops loop's state if the watchdog fired since its last
+// cycle; it runs between cycles so no collector sees a half-reset store.
+// It reports whether the loop was restarted.
+func (w *memoryWatchdog) restartLoop(loop string) bool {
+	w.mu.Lock()
+	pending := w.pending[loop]
+	w.mu.Unlock()
+	if !pending {
+		return false
+	}
+	for _, reset := range loopState[loop] {
+		reset()
+	}
+	debug.FreeOSMemory()
+
+	w.mu.Lock()
+	delete(w.pending, loop)
+	w.mu.Unlock()
+	return true
+}
+
+// collectAgentFootprint builds the "agent_footprint" metric
+func collectAgentFootprint(status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("agent_footprint", time.Now())
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	var lastPause time.Duration
+	if m.NumGC > 0 {
+		lastPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+
+	result := map[string]interface{}{
+		"goroutines":       runtime.NumGoroutine(),
+		"heap_alloc_bytes": m.HeapAlloc,
+		"heap_inuse_bytes": m.HeapInuse,
+		"heap_objects":     m.HeapObjects,
+		"stack_bytes":      m.StackInuse,
+		"sys_bytes":        m.Sys,
+		"gc": map[string]interface{}{
+			"cycles":         m.NumGC,
+			"pause_total_ms": float64(m.PauseTotalNs) / 1e6,
+			"last_pause_ms":  float64(lastPause) / 1e6,
+			"cpu_fraction":   m.GCCPUFraction,
+			"next_target":    m.NextGC,
+		},
+		"uptime_seconds": int64(time.Since(diagnostics.startedAt).Seconds()),
+	}
+
+	if limit := debug.SetMemoryLimit(-1); limit < math.MaxInt64 {
+		result["gc"].(map[string]interface{})["soft_limit_bytes"] = limit
+	}
+
+	if cpuSeconds, rss, ok := processStats(); ok {
+		now := time.Now()
+		footprintCPU.mu.Lock()
+		if !footprintCPU.at.IsZero() {
+			if elapsed := now.Sub(footprintCPU.at).Seconds(); elapsed > 0 {
+				result["cpu_millicores"] = int64((cpuSeconds - footprintCPU.seconds) / elapsed * 1000)
+			}
+		}
+		footprintCPU.seconds, footprintCPU.at = cpuSeconds, now
+		footprintCPU.mu.Unlock()
+		result["cpu_seconds_total"] = cpuSeconds
+		result["rss_bytes"] = rss
+	} else {
+		status.Partial("procfs", fmt.Errorf("/proc/self not readable, CPU and RSS unavailable"))
+	}
+
+	watchdog.mu.Lock()
+	w := map[string]interface{}{
+		"enabled":       watchdog.capBytes > 0,
+		"cap_bytes":     watchdog.capBytes,
+		"loop_restarts": watchdog.restarts,
+		"over_cap":      watchdog.strikes > 0,
+	}
+	if !watchdog.lastRestart.IsZero() {
+		w["last_restart_at"] = watchdog.lastRestart.UTC()
+		w["last_reason"] = watchdog.lastReason
+	}
+	watchdog.mu.Unlock()
+	result["watchdog"] = w
+	return result
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ---------------------------------------------
+// GITOPS SYNC STATUS (ArgoCD + Flux)
+// Reports whether workloads match their declared state in Git
+// ---------------------------------------------
+
+// maxDriftedResources bounds the out-of-sync resources reported per ArgoCD Application
+const maxDriftedResources = 50
+
+var (
+	argoApplicationsGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+
+	// Flux has shipped several API versions; the first one served is used
+	fluxKustomizationVersions = []schema.GroupVersionResource{
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"},
+		{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Resource: "kustomizations"},
+	}
+	fluxHelmReleaseVersions = []schema.GroupVersionResource{
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"},
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2beta2", Resource: "helmreleases"},
+		{Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Resource: "helmreleases"},
+	}
+)
+
+// collectGitOpsStatus lists ArgoCD Applications and Flux Kustomizations and
+// HelmReleases. A tool that is not installed is reported as such, not as an error.
+func collectGitOpsStatus(dynamicClient dynamic.Interface, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("gitops", time.Now())
+
+	summary := map[string]int{"total": 0, "synced": 0, "out_of_sync": 0, "unhealthy": 0, "suspended": 0}
+
+	argoApps, argoInstalled := listGitOpsResources(dynamicClient, []schema.GroupVersionResource{argoApplicationsGVR}, "argocd_applications", status)
+	var applications []map[string]interface{}
+	for _, app := range argoApps {
+		if !settings.NamespaceAllowed(app.GetNamespace()) {
+			continue
+		}
+		info := describeArgoApplication(app)
+		applications = append(applications, info)
+		countGitOpsState(summary, info)
+	}
+
+	kustomizationItems, kustomizeInstalled := listGitOpsResources(dynamicClient, fluxKustomizationVersions, "flux_kustomizations", status)
+	var kustomizations []map[string]interface{}
+	for _, k := range kustomizationItems {
+		if !settings.NamespaceAllowed(k.GetNamespace()) {
+			continue
+		}
+		info := describeFluxResource(k)
+		info["path"], _, _ = unstructured.NestedString(k.Object, "spec", "path")
+		sourceKind, _, _ := unstructured.NestedString(k.Object, "spec", "sourceRef", "kind")
+		sourceName, _, _ := unstructured.NestedString(k.Object, "spec", "sourceRef", "name")
+		info["source"] = sourceKind + "/" + sourceName
+		kustomizations = append(kustomizations, info)
+		countGitOpsState(summary, info)
+	}
+
+	helmItems, helmInstalled := listGitOpsResources(dynamicClient, fluxHelmReleaseVersions, "flux_helmreleases", status)
+	var helmReleases []map[string]interface{}
+	for _, hr := range helmItems {
+		if !settings.NamespaceAllowed(hr.GetNamespace()) {
+			continue
+		}
+		info := describeFluxResource(hr)
+		info["chart"], _, _ = unstructured.NestedString(hr.Object, "spec", "chart", "spec", "chart")
+		info["chart_version"], _, _ = unstructured.NestedString(hr.Object, "spec", "chart", "spec", "version")
+		helmReleases = append(helmReleases, info)
+		countGitOpsState(summary, info)
+	}
+
+	if argoInstalled || kustomizeInstalled || helmInstalled {
+		log.Printf("🔁 GitOps: %d resources (%d out of sync, %d unhealthy)", summary["total"], summary["out_of_sync"], summary["unhealthy"])
+	}
+
+	return map[string]interface{}{
+		"argocd": map[string]interface{}{
+			"installed":    argoInstalled,
+			"applications": applications,
+		},
+		"flux": map[string]interface{}{
+			"installed":      kustomizeInstalled || helmInstalled,
+			"kustomizations": kustomizations,
+			"helm_releases":  helmReleases,
+		},
+		"summary": summary,
+	}
+}
+
+// listGitOpsResources lists the first served version among gvrs. It returns
+// installed=false when none is served; other errors mark the status partial.
+func listGitOpsResources(dynamicClient dynamic.Interface, gvrs []schema.GroupVersionResource, source string, status *CollectorStatus) ([]unstructured.Unstructured, bool) {
+	if dynamicClient == nil {
+		return nil, false
+	}
+	for _, gvr := range gvrs {
+		ctx, cancel := apiContext()
+		list, err := dynamicClient.Resource(gvr).Namespace("").List(ctx, metav1.ListOptions{})
+		cancel()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("⚠️  Error listing %s: %v", gvr.Resource, err)
+			status.Partial(source, err)
+			return nil, true
+		}
+		return list.Items, true
+	}
+	return nil, false
+}
+
+// describeArgoApplication extracts sync/health state and the drifted resources
+func describeArgoApplication(app unstructured.Unstructured) map[string]interface{} {
+	syncStatus, _, _ := unstructured.NestedString(app.Object, "status", "sync", "status")
+	health, _, _ := unstructured.NestedString(app.Object, "status", "health", "status")
+	revision, _, _ := unstructured.NestedString(app.Object, "status", "sync", "revision")
+	repoURL, _, _ := unstructured.NestedString(app.Object, "spec", "source", "repoURL")
+	targetRevision, _, _ := unstructured.NestedString(app.Object, "spec", "source", "targetRevision")
+	path, _, _ := unstructured.NestedString(app.Object, "spec", "source", "path")
+	destNamespace, _, _ := unstructured.NestedString(app.Object, "spec", "destination", "namespace")
+	operationPhase, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "phase")
+	operationMessage, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "message")
+	finishedAt, _, _ := unstructured.NestedString(app.Object, "status", "operationState", "finishedAt")
+	_, autoSync, _ := unstructured.NestedMap(app.Object, "spec", "syncPolicy", "automated")
+
+	// Multi-source applications keep their sources in spec.sources
+	if repoURL == "" {
+		if sources, found, _ := unstructured.NestedSlice(app.Object, "spec", "sources"); found && len(sources) > 0 {
+			if first, ok := sources[0].(map[string]interface{}); ok {
+				repoURL, _ = first["repoURL"].(string)
+				targetRevision, _ = first["targetRevision"].(string)
+				path, _ = first["path"].(string)
+			}
+		}
+	}
+
+	var drifted []map[string]interface{}
+	driftedCount := 0
+	resources, _, _ := unstructured.NestedSlice(app.Object, "status", "resources")
+	for _, r := range resources {
+		res, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if resStatus, _ := res["status"].(string); resStatus == "Synced" {
+			continue
+		}
+		driftedCount++
+		if len(drifted) >= maxDriftedResources {
+			continue
+		}
+		entry := map[string]interface{}{
+			"kind":      res["kind"],
+			"namespace": res["namespace"],
+			"name":      res["name"],
+			"status":    res["status"],
+		}
+		if resHealth, ok := res["health"].(map[string]interface{}); ok {
+			entry["health"] = resHealth["status"]
+		}
+		drifted = append(drifted, entry)
+	}
+
+	return map[string]interface{}{
+		"kind":                  "Application",
+		"name":                  app.GetName(),
+		"namespace":             app.GetNamespace(),
+		"destination_namespace": destNamespace,
+		"repo_url":              repoURL,
+		"target_revision":       targetRevision,
+		"path":                  path,
+		"revision":              revision,
+		"sync_status":           syncStatus,
+		"health":                health,
+		"auto_sync":             autoSync,
+		"in_sync":               syncStatus == "Synced",
+		"healthy":               health == "Healthy" || health == "",
+		"suspended":             false,
+		"last_operation": map[string]interface{}{
+			"phase":       operationPhase,
+			"message":     operationMessage,
+			"finished_at": finishedAt,
+		},
+		"drifted_resources":       drifted,
+		"drifted_resources_count": driftedCount,
+	}
+}
+
+// describeFluxResource extracts the Ready condition and revisions shared by
+// Flux Kustomizations and HelmReleases
+func describeFluxResource(obj unstructured.Unstructured) map[string]interface{} {
+	suspended, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend")
+	appliedRevision, _, _ := unstructured.NestedString(obj.Object, "status", "lastAppliedRevision")
+	attemptedRevision, _, _ := unstructured.NestedString(obj.Object, "status", "lastAttemptedRevision")
+
+	ready, reason, message := "Unknown", "", ""
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Ready" {
+			continue
+		}
+		ready, _ = cond["status"].(string)
+		reason, _ = cond["reason"].(string)
+		message, _ = cond["message"].(string)
+	}
+
+	// A newer revision that failed to apply means the cluster lags behind Git
+	inSync := ready == "True" && (attemptedRevision == "" || attemptedRevision == appliedRevision)
+
+	return map[string]interface{}{
+		"kind":                    obj.GetKind(),
+		"name":                    obj.GetName(),
+		"namespace":               obj.GetNamespace(),
+		"ready":                   ready,
+		"reason":                  reason,
+		"message":                 message,
+		"last_applied_revision":   appliedRevision,
+		"last_attempted_revision": attemptedRevision,
+		"in_sync":                 inSync,
+		"healthy":                 ready == "True",
+		"suspended":               suspended,
+	}
+}
+
+func countGitOpsState(summary map[string]int, info map[string]interface{}) {
+	summary["total"]++
+	if inSync, _ := info["in_sync"].(bool); inSync {
+		summary["synced"]++
+	} else {
+		summary["out_of_sync"]++
+	}
+	if healthy, _ := info["healthy"].(bool); !healthy {
+		summary["unhealthy"]++
+	}
+	if suspended, _ := info["suspended"].(bool); suspended {
+		summary["suspended"]++
+	}
+}
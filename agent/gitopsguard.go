@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// ---------------------------------------------
+// GITOPS CONFLICTS
+// scale/image/resource commands on a Deployment that ArgoCD or Flux
+// reconciles would be reverted on the next sync. Before patching, the
+// Deployment's tracking labels/annotations are checked and, per
+// GITOPS_CONFLICT_POLICY:
+//   require_force  refuse unless the command sets force (default)
+//   warn           apply and report the conflict in the result
+//   suspend        apply and mark the object so Flux stops reverting it;
+//                  ArgoCD has no per-object switch, so it needs force there
+//   ignore         no check
+// ---------------------------------------------
+
+const (
+	gitopsConflictRequireForce = "require_force"
+	gitopsConflictWarn         = "warn"
+	gitopsConflictSuspend      = "suspend"
+	gitopsConflictIgnore       = "ignore"
+)
+
+// gitopsOwner is the GitOps tool and app reconciling an object
+type gitopsOwner struct {
+	tool string // "argocd" or "flux"
+	app  string
+	// suspendAnnotation stops the tool from correcting this object, when it has one
+	suspendAnnotation string
+}
+
+// detectGitOpsOwner reads the tracking metadata ArgoCD and Flux put on the
+// objects they apply. Objects already excluded from reconciliation are not
+// reported. instanceLabel opts into ArgoCD's label tracking mode.
+func detectGitOpsOwner(labels, annotations map[string]string, instanceLabel bool) (gitopsOwner, bool) {
+	if name := labels["kustomize.toolkit.fluxcd.io/name"]; name != "" {
+		if annotations["kustomize.toolkit.fluxcd.io/reconcile"] == "disabled" {
+			return gitopsOwner{}, false
+		}
+		return gitopsOwner{"flux", "Kustomization " + labels["kustomize.toolkit.fluxcd.io/namespace"] + "/" + name, "kustomize.toolkit.fluxcd.io/reconcile"}, true
+	}
+	if name := labels["helm.toolkit.fluxcd.io/name"]; name != "" {
+		if annotations["helm.toolkit.fluxcd.io/driftDetection"] == "disabled" {
+			return gitopsOwner{}, false
+		}
+		return gitopsOwner{"flux", "HelmRelease " + labels["helm.toolkit.fluxcd.io/namespace"] + "/" + name, "helm.toolkit.fluxcd.io/driftDetection"}, true
+	}
+	if tracking := annotations["argocd.argoproj.io/tracking-id"]; tracking != "" {
+		app, _, _ := strings.Cut(tracking, ":")
+		return gitopsOwner{tool: "argocd", app: "Application " + app}, true
+	}
+	// ArgoCD's label tracking uses app.kubernetes.io/instance, but so do
+	// kustomize, operators and plain manifests following the recommended
+	// labels; it only proves ownership where the operator says ArgoCD uses it
+	if !instanceLabel {
+		return gitopsOwner{}, false
+	}
+	if app := labels["app.kubernetes.io/instance"]; app != "" && labels["app.kubernetes.io/managed-by"] != "Helm" && annotations["meta.helm.sh/release-name"] == "" {
+		return gitopsOwner{tool: "argocd", app: "Application " + app}, true
+	}
+	return gitopsOwner{}, false
+}
+
+// gitopsConflictPolicy returns the configured policy, defaulting unknown values to require_force
+func gitopsConflictPolicy(config AgentConfig) string {
+	switch config.GitOpsConflictPolicy {
+	case gitopsConflictWarn, gitopsConflictSuspend, gitopsConflictIgnore:
+		return config.GitOpsConflictPolicy
+	}
+	return gitopsConflictRequireForce
+}
+
+// checkGitOpsConflict decides whether a command may patch the deployment.
+// It returns the annotations to add to the patch (suspend) and the
+// conflict description for the command result (nil when unmanaged).
+func checkGitOpsConflict(config AgentConfig, deployment *appsv1.Deployment, force bool) (map[string]interface{}, map[string]interface{}, error) {
+	policy := gitopsConflictPolicy(config)
+	if policy == gitopsConflictIgnore {
+		return nil, nil, nil
+	}
+	owner, managed := detectGitOpsOwner(deployment.Labels, deployment.Annotations, config.GitOpsInstanceLabel)
+	if !managed {
+		return nil, nil, nil
+	}
+
+	conflict := map[string]interface{}{"tool": owner.tool, "app": owner.app, "policy": policy}
+	switch {
+	case policy == gitopsConflictSuspend && owner.suspendAnnotation != "":
+		conflict["handling"] = "suspended"
+		conflict["message"] = fmt.Sprintf("%s stops reconciling this Deployment until %s is removed", owner.tool, owner.suspendAnnotation)
+		return map[string]interface{}{owner.suspendAnnotation: "disabled"}, conflict, nil
+	case force:
+		conflict["handling"] = "forced"
+		conflict["message"] = fmt.Sprintf("%s (%s) will revert this change on its next sync unless Git is updated", owner.tool, owner.app)
+		return nil, conflict, nil
+	case policy == gitopsConflictWarn:
+		conflict["handling"] = "warned"
+		conflict["message"] = fmt.Sprintf("%s (%s) will revert this change on its next sync unless Git is updated", owner.tool, owner.app)
+		log.Printf("   ⚠️  Deployment %s/%s is managed by %s (%s)", deployment.Namespace, deployment.Name, owner.tool, owner.app)
+		return nil, conflict, nil
+	}
+	return nil, nil, fmt.Errorf("deployment %s/%s is managed by %s (%s) and the change would be reverted; update Git or retry with force=true",
+		deployment.Namespace, deployment.Name, owner.tool, owner.app)
+}
+
+// withAnnotations adds metadata annotations to a deployment patch
+func withAnnotations(patch map[string]interface{}, annotations map[string]interface{}) map[string]interface{} {
+	if len(annotations) > 0 {
+		patch["metadata"] = map[string]interface{}{"annotations": annotations}
+	}
+	return patch
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// RUNTIME SOCKET AND KUBELET DIRECTORY MOUNTS
+// A container that can reach the Docker, containerd or CRI-O socket can start
+// a privileged container on the node, and one that can write the kubelet
+// directories can read every pod's service account token. Both are common
+// escape vectors that privileged/hostPID checks miss, so hostPath volumes are
+// matched against these paths, including parent directories that expose them.
+// ---------------------------------------------
+
+// sensitiveHostPath is a node path whose exposure is a container-escape vector
+type sensitiveHostPath struct {
+	Path   string
+	Kind   string
+	Reason string
+}
+
+var sensitiveHostPaths = []sensitiveHostPath{
+	{"/var/run/docker.sock", "docker_socket", "Docker socket mounted: full control of the node's container runtime"},
+	{"/run/docker.sock", "docker_socket", "Docker socket mounted: full control of the node's container runtime"},
+	{"/var/run/containerd/containerd.sock", "containerd_socket", "containerd socket mounted: full control of the node's container runtime"},
+	{"/run/containerd/containerd.sock", "containerd_socket", "containerd socket mounted: full control of the node's container runtime"},
+	{"/var/run/crio/crio.sock", "crio_socket", "CRI-O socket mounted: full control of the node's container runtime"},
+	{"/run/crio/crio.sock", "crio_socket", "CRI-O socket mounted: full control of the node's container runtime"},
+	{"/var/run/cri-dockerd.sock", "docker_socket", "cri-dockerd socket mounted: full control of the node's container runtime"},
+	{"/var/lib/kubelet", "kubelet_dir", "kubelet directory mounted: exposes every pod's volumes and service account tokens"},
+	{"/etc/kubernetes", "kubelet_dir", "Kubernetes config directory mounted: exposes kubelet credentials"},
+	{"/var/lib/docker", "runtime_dir", "Docker data directory mounted: exposes every container's filesystem"},
+	{"/var/lib/containerd", "runtime_dir", "containerd data directory mounted: exposes every container's filesystem"},
+}
+
+// windowsRuntimePipes are the named pipes Windows runtimes listen on
+var windowsRuntimePipes = map[string]string{
+	`\\.\pipe\docker_engine`:         "docker_socket",
+	`\\.\pipe\containerd-containerd`: "containerd_socket",
+}
+
+// matchSensitiveHostPath returns the sensitive paths a hostPath exposes: the
+// path itself, anything below it, or a directory inside it
+func matchSensitiveHostPath(hostPath string) []sensitiveHostPath {
+	if kind, ok := windowsRuntimePipes[strings.ToLower(hostPath)]; ok {
+		return []sensitiveHostPath{{hostPath, kind, "Container runtime named pipe mounted: full control of the node's container runtime"}}
+	}
+	if !strings.HasPrefix(hostPath, "/") {
+		return nil
+	}
+	p := path.Clean(hostPath)
+	var matches []sensitiveHostPath
+	for _, s := range sensitiveHostPaths {
+		if p == s.Path || p == "/" || strings.HasPrefix(s.Path, p+"/") || strings.HasPrefix(p, s.Path+"/") {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// podContainerMounts maps volume names to the containers mounting them,
+// init and ephemeral containers included
+func podContainerMounts(pod corev1.Pod) map[string][]string {
+	mounts := map[string][]string{}
+	add := func(container string, vms []corev1.VolumeMount) {
+		for _, vm := range vms {
+			mounts[vm.Name] = append(mounts[vm.Name], container)
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		add(c.Name, c.VolumeMounts)
+	}
+	for _, c := range pod.Spec.Containers {
+		add(c.Name, c.VolumeMounts)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		add(c.Name, c.VolumeMounts)
+	}
+	return mounts
+}
+
+// hostSocketMounts returns one finding per hostPath volume of the pod that
+// exposes a runtime socket or kubelet directory to at least one container
+func hostSocketMounts(pod corev1.Pod) []map[string]interface{} {
+	var findings []map[string]interface{}
+	mounts := podContainerMounts(pod)
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath == nil {
+			continue
+		}
+		containers := mounts[v.Name]
+		if len(containers) == 0 {
+			continue
+		}
+		matches := matchSensitiveHostPath(v.HostPath.Path)
+		if len(matches) == 0 {
+			continue
+		}
+		kinds := map[string]bool{}
+		var exposed []string
+		for _, m := range matches {
+			kinds[m.Kind] = true
+			exposed = append(exposed, m.Path)
+		}
+		sort.Strings(exposed)
+		reason := matches[0].Reason
+		if len(kinds) > 1 {
+			reason = "Host directory mounted: exposes " + strings.Join(sortedKeys(kinds), ", ")
+		}
+		ownerKind, ownerName := podWorkload(pod)
+		findings = append(findings, map[string]interface{}{
+			"pod_name":        pod.Name,
+			"namespace":       pod.Namespace,
+			"node":            pod.Spec.NodeName,
+			"owner_kind":      ownerKind,
+			"owner_name":      ownerName,
+			"volume":          v.Name,
+			"host_path":       v.HostPath.Path,
+			"exposes":         exposed,
+			"kinds":           sortedKeys(kinds),
+			"containers":      containers,
+			"service_account": pod.Spec.ServiceAccountName,
+			"threat_level":    "high",
+			"reason":          reason,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// BACKEND HTTP CLIENT
+// One shared client for every backend call, so connections (and TLS
+// sessions) are reused across cycles and every request has a deadline; a
+// stalled backend used to hang the metrics cycle forever. The tunnel relay
+// keeps its own client because it waits indefinitely by design.
+// ---------------------------------------------
+
+var backendHTTP struct {
+	once   sync.Once
+	client *http.Client
+}
+
+// backendClient returns the shared client, built from config on first use
+func backendClient(config AgentConfig) *http.Client {
+	backendHTTP.once.Do(func() {
+		backendHTTP.client = newBackendClient(config)
+	})
+	return backendHTTP.client
+}
+
+func newBackendClient(config AgentConfig) *http.Client {
+	seconds := func(n int) time.Duration { return time.Duration(n) * time.Second }
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   seconds(config.HTTPDialTimeout),
+			KeepAlive: seconds(config.HTTPKeepAlive),
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   seconds(config.HTTPDialTimeout),
+		ResponseHeaderTimeout: seconds(config.HTTPTimeout),
+		IdleConnTimeout:       seconds(config.HTTPIdleTimeout),
+		MaxIdleConns:          config.HTTPMaxConns,
+		MaxIdleConnsPerHost:   config.HTTPMaxConns,
+		MaxConnsPerHost:       config.HTTPMaxConns,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     config.HTTPKeepAlive < 0,
+	}
+
+	log.Printf("🌐 Backend HTTP client: timeout=%ds dial=%ds keepalive=%ds idle=%ds max_conns=%d",
+		config.HTTPTimeout, config.HTTPDialTimeout, config.HTTPKeepAlive, config.HTTPIdleTimeout, config.HTTPMaxConns)
+	return &http.Client{
+		Transport: transport,
+		Timeout:   seconds(config.HTTPTimeout),
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// ---------------------------------------------
+// DEFAULT NAMESPACE / SERVICEACCOUNT HYGIENE
+// Workloads deployed into `default`, and workloads running as a namespace's
+// `default` ServiceAccount after someone bound permissions to it (every pod
+// in the namespace that doesn't pick a ServiceAccount inherits them).
+// Reported as low-severity findings in the security payload.
+// ---------------------------------------------
+
+const defaultName = "default"
+
+// harmlessServiceAccountRoles are bound to every ServiceAccount by default
+// (kubeadm and most distributions) and grant nothing worth reporting
+var harmlessServiceAccountRoles = map[string]bool{
+	"ClusterRole/system:service-account-issuer-discovery": true,
+	"ClusterRole/system:basic-user":                       true,
+	"ClusterRole/system:discovery":                        true,
+	"ClusterRole/system:public-info-viewer":               true,
+}
+
+// defaultServiceAccountGrants maps namespace → roles bound to its default
+// ServiceAccount, directly or through the system:serviceaccounts groups. A
+// RoleBinding may bind the default ServiceAccount of another namespace; that
+// role is listed under the ServiceAccount's namespace as "<role> in <binding
+// namespace>".
+func defaultServiceAccountGrants(clusterBindings []rbacv1.ClusterRoleBinding, bindings []rbacv1.RoleBinding) map[string][]string {
+	grants := map[string]map[string]bool{}
+	grant := func(namespace, role, scope string) {
+		if harmlessServiceAccountRoles[role] {
+			return
+		}
+		if scope != "" && scope != namespace {
+			role += " in " + scope
+		}
+		if grants[namespace] == nil {
+			grants[namespace] = map[string]bool{}
+		}
+		grants[namespace][role] = true
+	}
+	// "*" stands for every namespace (cluster-wide grants)
+	matches := func(subject rbacv1.Subject, bindingNamespace string) (string, bool) {
+		switch {
+		case subject.Kind == rbacv1.ServiceAccountKind && subject.Name == defaultName:
+			namespace := subject.Namespace
+			if namespace == "" {
+				namespace = bindingNamespace
+			}
+			return namespace, namespace != ""
+		case subject.Kind == rbacv1.GroupKind && subject.Name == "system:serviceaccounts":
+			return "*", true
+		case subject.Kind == rbacv1.GroupKind && strings.HasPrefix(subject.Name, "system:serviceaccounts:"):
+			return strings.TrimPrefix(subject.Name, "system:serviceaccounts:"), true
+		}
+		return "", false
+	}
+
+	for _, crb := range clusterBindings {
+		for _, subject := range crb.Subjects {
+			if namespace, ok := matches(subject, ""); ok {
+				grant(namespace, crb.RoleRef.Kind+"/"+crb.RoleRef.Name, "")
+			}
+		}
+	}
+	for _, rb := range bindings {
+		for _, subject := range rb.Subjects {
+			// A RoleBinding only grants inside its own namespace, but to
+			// subjects of any namespace
+			if namespace, ok := matches(subject, rb.Namespace); ok {
+				grant(namespace, rb.RoleRef.Kind+"/"+rb.RoleRef.Name, rb.Namespace)
+			}
+		}
+	}
+
+	result := make(map[string][]string, len(grants))
+	for namespace, roles := range grants {
+		result[namespace] = sortedKeys(roles)
+	}
+	return result
+}
+
+// checkDefaultHygiene reports workloads in the default namespace and
+// workloads using a default ServiceAccount that has permissions bound
+func checkDefaultHygiene(snap *ClusterSnapshot, clusterBindings []rbacv1.ClusterRoleBinding, bindings []rbacv1.RoleBinding) map[string]interface{} {
+	grants := defaultServiceAccountGrants(clusterBindings, bindings)
+
+	var findings []map[string]interface{}
+	seen := map[string]bool{}
+	inDefaultNamespace, usingGrantedDefault := 0, 0
+	for _, pod := range snap.Pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		kind, name := podWorkload(pod)
+		workload := pod.Namespace + "/" + kind + "/" + name
+
+		if pod.Namespace == defaultName && !seen["ns:"+workload] {
+			seen["ns:"+workload] = true
+			inDefaultNamespace++
+			findings = append(findings, map[string]interface{}{
+				"check":     "default_namespace",
+				"severity":  "low",
+				"namespace": pod.Namespace,
+				"kind":      kind,
+				"name":      name,
+				"message":   fmt.Sprintf("%s %s runs in the default namespace", kind, name),
+			})
+		}
+
+		serviceAccount := pod.Spec.ServiceAccountName
+		if serviceAccount != "" && serviceAccount != defaultName {
+			continue
+		}
+		roles := append(append([]string{}, grants[pod.Namespace]...), grants["*"]...)
+		if len(roles) == 0 || seen["sa:"+workload] {
+			continue
+		}
+		seen["sa:"+workload] = true
+		usingGrantedDefault++
+		sort.Strings(roles)
+		findings = append(findings, map[string]interface{}{
+			"check":         "default_service_account",
+			"severity":      "low",
+			"namespace":     pod.Namespace,
+			"kind":          kind,
+			"name":          name,
+			"roles":         roles,
+			"token_mounted": pod.Spec.AutomountServiceAccountToken == nil || *pod.Spec.AutomountServiceAccountToken,
+			"message":       fmt.Sprintf("%s %s runs as the default ServiceAccount, which is bound to %d role(s)", kind, name, len(roles)),
+		})
+	}
+
+	grantedNamespaces := make([]string, 0, len(grants))
+	for namespace := range grants {
+		grantedNamespaces = append(grantedNamespaces, namespace)
+	}
+	sort.Strings(grantedNamespaces)
+
+	return map[string]interface{}{
+		"workloads_in_default_namespace":      inDefaultNamespace,
+		"workloads_using_granted_default_sa":  usingGrantedDefault,
+		"default_service_accounts_with_roles": grantedNamespaces,
+		"findings":                            findings,
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// IMAGE FILESYSTEM
+// Usage of the runtime's image filesystem (imageFs) against the kubelet's
+// image GC thresholds. Above imageGCHighThresholdPercent the kubelet deletes
+// unused images until it reaches the low threshold; a node that stays there
+// re-pulls images constantly, and one that cannot free enough starts evicting.
+// ---------------------------------------------
+
+const (
+	// Kubelet defaults when /configz is unavailable or leaves them unset
+	defaultImageGCHigh = 85
+	defaultImageGCLow  = 80
+	// imageGCWarnMargin flags nodes within this many points of the high threshold
+	imageGCWarnMargin = 5
+	// nodeStatusMaxImages is the kubelet default cap on node.status.images
+	nodeStatusMaxImages = 50
+)
+
+// imageFsUsage describes a node's image filesystem, or nil without Kubelet stats
+func imageFsUsage(node corev1.Node, summary *StatsSummary, cfg *KubeletConfig) map[string]interface{} {
+	if summary == nil || summary.Node.Runtime == nil || summary.Node.Runtime.ImageFs == nil {
+		return nil
+	}
+	fs := summary.Node.Runtime.ImageFs
+	if fs.CapacityBytes == nil || *fs.CapacityBytes == 0 {
+		return nil
+	}
+
+	high, low := int32(defaultImageGCHigh), int32(defaultImageGCLow)
+	if cfg != nil {
+		if cfg.ImageGCHighThresholdPercent != nil {
+			high = *cfg.ImageGCHighThresholdPercent
+		}
+		if cfg.ImageGCLowThresholdPercent != nil {
+			low = *cfg.ImageGCLowThresholdPercent
+		}
+	}
+
+	var used, available uint64
+	if fs.UsedBytes != nil {
+		used = *fs.UsedBytes
+	}
+	if fs.AvailableBytes != nil {
+		available = *fs.AvailableBytes
+	}
+	capacity := *fs.CapacityBytes
+	// The kubelet compares (capacity - available) with the thresholds
+	usagePercent := float64(capacity-available) / float64(capacity) * 100
+
+	var imagesBytes int64
+	for _, image := range node.Status.Images {
+		imagesBytes += image.SizeBytes
+	}
+
+	// A shared imageFs is the same device as the node's root filesystem
+	shared := summary.Node.Fs != nil && summary.Node.Fs.CapacityBytes != nil && *summary.Node.Fs.CapacityBytes == capacity
+
+	return map[string]interface{}{
+		"capacity_bytes":         capacity,
+		"used_bytes":             used,
+		"available_bytes":        available,
+		"usage_percent":          usagePercent,
+		"shared_with_nodefs":     shared,
+		"images_count":           len(node.Status.Images),
+		"images_truncated":       len(node.Status.Images) >= nodeStatusMaxImages,
+		"images_bytes":           imagesBytes,
+		"gc_high_threshold":      high,
+		"gc_low_threshold":       low,
+		"gc_active":              usagePercent >= float64(high),
+		"gc_pressure":            usagePercent >= float64(high-imageGCWarnMargin),
+		"bytes_to_low_threshold": bytesAbovePercent(capacity, capacity-available, low),
+		"inodes":                 inodeUsage(fs),
+	}
+}
+
+// bytesAbovePercent is how much must be freed for used to drop to percent of capacity
+func bytesAbovePercent(capacity, used uint64, percent int32) uint64 {
+	target := capacity * uint64(percent) / 100
+	if used <= target {
+		return 0
+	}
+	return used - target
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// IMAGE PULLS
+// Pull failures and durations aggregated per image and per registry, from
+// kubelet events (Pulled/Failed/BackOff) and from containers currently stuck
+// in ErrImagePull/ImagePullBackOff, so a flaky registry or a wrong pull
+// secret shows up as one line instead of hundreds of events
+// ---------------------------------------------
+
+var (
+	// `Successfully pulled image "nginx:1.25" in 3.457s (3.457s including waiting)`
+	pulledMessage = regexp.MustCompile(`pulled image "([^"]+)" in ([0-9.]+[a-zµ]+)`)
+	// `Failed to pull image "x": ...` and `Back-off pulling image "x"`
+	pullImageMessage = regexp.MustCompile(`(?:pull|pulling) image "([^"]+)"`)
+)
+
+// imagePullStats accumulates one image (or registry)
+type imagePullStats struct {
+	Pulls           int64    `json:"pulls"`
+	Failures        int64    `json:"failures"`
+	BackOffs        int64    `json:"backoffs"`
+	StuckContainers int      `json:"stuck_containers"`
+	AvgPullSeconds  float64  `json:"avg_pull_seconds,omitempty"`
+	MaxPullSeconds  float64  `json:"max_pull_seconds,omitempty"`
+	ErrorKinds      []string `json:"error_kinds,omitempty"`
+	LastError       string   `json:"last_error,omitempty"`
+	totalSeconds    float64
+	timedPulls      int64
+	lastErrorAt     time.Time
+	errorKinds      map[string]bool
+}
+
+func (s *imagePullStats) addError(kind, message string, at time.Time) {
+	if s.errorKinds == nil {
+		s.errorKinds = map[string]bool{}
+	}
+	s.errorKinds[kind] = true
+	if at.After(s.lastErrorAt) || s.LastError == "" {
+		s.LastError, s.lastErrorAt = message, at
+	}
+}
+
+// imageRegistry returns the registry host of an image reference
+func imageRegistry(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
+
+// pullErrorKind classifies a pull failure message
+func pullErrorKind(message string) string {
+	m := strings.ToLower(message)
+	switch {
+	case strings.Contains(m, "unauthorized"), strings.Contains(m, "authentication required"),
+		strings.Contains(m, "denied"), strings.Contains(m, "forbidden"), strings.Contains(m, "401"), strings.Contains(m, "403"):
+		return "auth"
+	case strings.Contains(m, "not found"), strings.Contains(m, "manifest unknown"), strings.Contains(m, "does not exist"):
+		return "not_found"
+	case strings.Contains(m, "toomanyrequests"), strings.Contains(m, "rate limit"), strings.Contains(m, "429"):
+		return "rate_limited"
+	case strings.Contains(m, "timeout"), strings.Contains(m, "dial tcp"), strings.Contains(m, "no such host"),
+		strings.Contains(m, "connection refused"), strings.Contains(m, "tls"), strings.Contains(m, "x509"):
+		return "network"
+	case strings.Contains(m, "no match for platform"), strings.Contains(m, "exec format"):
+		return "platform"
+	case strings.Contains(m, "back-off"):
+		return "backoff"
+	}
+	return "other"
+}
+
+// collectImagePulls builds the "image_pulls" metric
+func collectImagePulls(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("image_pulls", time.Now())
+
+	byImage := map[string]*imagePullStats{}
+	stats := func(image string) *imagePullStats {
+		s, ok := byImage[image]
+		if !ok {
+			s = &imagePullStats{}
+			byImage[image] = s
+		}
+		return s
+	}
+
+	for _, e := range snap.Events {
+		if e.InvolvedObject.Kind != "Pod" {
+			continue
+		}
+		count := int64(e.Count)
+		if count == 0 {
+			count = 1
+		}
+		switch e.Reason {
+		case "Pulled":
+			match := pulledMessage.FindStringSubmatch(e.Message)
+			if match == nil {
+				continue // "already present on machine"
+			}
+			s := stats(match[1])
+			s.Pulls += count
+			if d, err := time.ParseDuration(match[2]); err == nil {
+				seconds := d.Seconds()
+				s.totalSeconds += seconds
+				s.timedPulls++
+				if seconds > s.MaxPullSeconds {
+					s.MaxPullSeconds = seconds
+				}
+			}
+		case "Failed", "ErrImagePull", "InspectFailed":
+			match := pullImageMessage.FindStringSubmatch(e.Message)
+			if match == nil {
+				continue
+			}
+			s := stats(match[1])
+			s.Failures += count
+			s.addError(pullErrorKind(e.Message), e.Message, eventTime(e))
+		case "BackOff":
+			match := pullImageMessage.FindStringSubmatch(e.Message)
+			if match == nil {
+				continue // container restart back-off
+			}
+			stats(match[1]).BackOffs += count
+		}
+	}
+
+	// Containers waiting on a pull right now, whether or not events survived
+	var stuck []map[string]interface{}
+	for _, pod := range snap.Pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.State.Waiting == nil {
+				continue
+			}
+			reason := cs.State.Waiting.Reason
+			if reason != "ErrImagePull" && reason != "ImagePullBackOff" && reason != "InvalidImageName" {
+				continue
+			}
+			s := stats(cs.Image)
+			s.StuckContainers++
+			kind := pullErrorKind(cs.State.Waiting.Message)
+			if reason == "InvalidImageName" {
+				kind = "invalid_name"
+			}
+			s.addError(kind, cs.State.Waiting.Message, snap.TakenAt)
+			stuck = append(stuck, map[string]interface{}{
+				"namespace":  pod.Namespace,
+				"pod":        pod.Name,
+				"container":  cs.Name,
+				"image":      cs.Image,
+				"reason":     reason,
+				"error_kind": kind,
+				"message":    cs.State.Waiting.Message,
+			})
+		}
+	}
+
+	byRegistry := map[string]*imagePullStats{}
+	images := make(map[string]interface{}, len(byImage))
+	for image, s := range byImage {
+		if s.timedPulls > 0 {
+			s.AvgPullSeconds = s.totalSeconds / float64(s.timedPulls)
+		}
+		s.ErrorKinds = sortedKeys(s.errorKinds)
+		images[image] = s
+
+		r, ok := byRegistry[imageRegistry(image)]
+		if !ok {
+			r = &imagePullStats{}
+			byRegistry[imageRegistry(image)] = r
+		}
+		r.Pulls += s.Pulls
+		r.Failures += s.Failures
+		r.BackOffs += s.BackOffs
+		r.StuckContainers += s.StuckContainers
+		r.totalSeconds += s.totalSeconds
+		r.timedPulls += s.timedPulls
+		if s.MaxPullSeconds > r.MaxPullSeconds {
+			r.MaxPullSeconds = s.MaxPullSeconds
+		}
+		for kind := range s.errorKinds {
+			r.addError(kind, s.LastError, s.lastErrorAt)
+		}
+	}
+	registries := make(map[string]interface{}, len(byRegistry))
+	var failing []string
+	for registry, r := range byRegistry {
+		if r.timedPulls > 0 {
+			r.AvgPullSeconds = r.totalSeconds / float64(r.timedPulls)
+		}
+		r.ErrorKinds = sortedKeys(r.errorKinds)
+		registries[registry] = r
+		if r.Failures > 0 || r.StuckContainers > 0 {
+			failing = append(failing, registry)
+		}
+	}
+	sort.Strings(failing)
+
+	return map[string]interface{}{
+		"images":             images,
+		"registries":         registries,
+		"failing_registries": failing,
+		"stuck_containers":   stuck,
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// INGRESS BACKEND CONSISTENCY
+// Ingress backends cross-checked against the Services and ports that exist,
+// plus the IngressClass each Ingress relies on. A dangling backend answers
+// 503 at the controller even though the Ingress itself looks fine.
+// ---------------------------------------------
+
+// ingressClassAnnotation is the legacy (pre-IngressClass) way to pick a controller
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+// defaultIngressClassAnnotation marks the IngressClass used when an Ingress names none
+const defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+// checkIngressBackends lists Ingresses and IngressClasses and reports dangling backends
+func checkIngressBackends(parent context.Context, clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	ctx, cancel := apiContextWithin(parent)
+	ingressList, err := clientset.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing Ingresses: %v", err)
+		status.Partial("ingresses", err)
+		return map[string]interface{}{}
+	}
+	ingresses := filterByNamespace(ingressList.Items, func(ing networkingv1.Ingress) string { return ing.Namespace }, getSettings())
+
+	ctx, cancel = apiContextWithin(parent)
+	classList, err := clientset.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing IngressClasses: %v", err)
+		status.Partial("ingressclasses", err)
+	}
+
+	classes := map[string]bool{}
+	var defaultClasses []string
+	if classList != nil {
+		for _, ic := range classList.Items {
+			classes[ic.Name] = true
+			if ic.Annotations[defaultIngressClassAnnotation] == "true" {
+				defaultClasses = append(defaultClasses, ic.Name)
+			}
+		}
+	}
+
+	services := map[string]corev1.Service{}
+	for _, svc := range snap.Services {
+		services[svc.Namespace+"/"+svc.Name] = svc
+	}
+
+	var issues []map[string]interface{}
+	summary := map[string]int{"service_missing": 0, "port_mismatch": 0, "missing_ingress_class": 0, "no_ingress_class": 0}
+	backendsChecked := 0
+	addIssue := func(ing networkingv1.Ingress, problem, host, path, detail string) {
+		summary[problem]++
+		issues = append(issues, map[string]interface{}{
+			"namespace": ing.Namespace,
+			"ingress":   ing.Name,
+			"problem":   problem,
+			"host":      host,
+			"path":      path,
+			"detail":    detail,
+		})
+	}
+
+	for _, ing := range ingresses {
+		// IngressClass: spec.ingressClassName must exist; without any class the
+		// Ingress depends on a default class (or is ignored by every controller)
+		switch {
+		case ing.Spec.IngressClassName != nil:
+			if classList != nil && !classes[*ing.Spec.IngressClassName] {
+				addIssue(ing, "missing_ingress_class", "", "", fmt.Sprintf("IngressClass %q does not exist", *ing.Spec.IngressClassName))
+			}
+		case ing.Annotations[ingressClassAnnotation] != "":
+			// Legacy annotation, resolved by the controller itself
+		case classList != nil && len(defaultClasses) == 0:
+			addIssue(ing, "no_ingress_class", "", "", "no ingressClassName and no default IngressClass")
+		}
+
+		check := func(backend *networkingv1.IngressBackend, host, path string) {
+			// Without the Service list every backend would look dangling
+			if backend == nil || backend.Service == nil || snap.Errors["services"] != nil {
+				return
+			}
+			backendsChecked++
+			svc, ok := services[ing.Namespace+"/"+backend.Service.Name]
+			if !ok {
+				addIssue(ing, "service_missing", host, path, fmt.Sprintf("Service %s not found", backend.Service.Name))
+				return
+			}
+			if !serviceHasPort(svc, backend.Service.Port) {
+				addIssue(ing, "port_mismatch", host, path, fmt.Sprintf("Service %s has no port %s", svc.Name, ingressPortString(backend.Service.Port)))
+			}
+		}
+
+		check(ing.Spec.DefaultBackend, "", "")
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, p := range rule.HTTP.Paths {
+				backend := p.Backend
+				check(&backend, rule.Host, p.Path)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"ingresses":              len(ingresses),
+		"backends_checked":       backendsChecked,
+		"ingress_classes":        sortedKeys(classes),
+		"default_ingress_class":  defaultClasses,
+		"summary":                summary,
+		"dangling_backends":      issues,
+		"dangling_backend_count": len(issues),
+	}
+}
+
+// serviceHasPort reports whether the Service exposes the port an Ingress backend refers to
+func serviceHasPort(svc corev1.Service, port networkingv1.ServiceBackendPort) bool {
+	for _, p := range svc.Spec.Ports {
+		if port.Name != "" && p.Name == port.Name {
+			return true
+		}
+		if port.Name == "" && p.Port == port.Number {
+			return true
+		}
+	}
+	return false
+}
+
+func ingressPortString(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return strconv.Itoa(int(port.Number))
+}
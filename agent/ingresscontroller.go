@@ -0,0 +1,483 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ---------------------------------------------
+// INGRESS CONTROLLER DETECTION
+// Runs on its own slow loop against a shared informer cache instead of
+// listing Deployments and DaemonSets in every namespace each metrics cycle.
+// The result is cached and only recomputed when a candidate controller
+// workload, an IngressClass or an Ingress changes, or when it gets old.
+// ---------------------------------------------
+
+const (
+	// ingressDetectionInterval is how often the loop looks for relevant changes
+	ingressDetectionInterval = time.Minute
+	// ingressDetectionMaxAge forces a refresh; RBAC bindings are not watched
+	ingressDetectionMaxAge = 30 * time.Minute
+)
+
+// ingressControllerSpec describes how to recognize one ingress controller
+type ingressControllerSpec struct {
+	name           string
+	labelSelectors []string
+	namespaces     []string
+	namePatterns   []string // Deployment/DaemonSet name patterns to search
+}
+
+// Common ingress controller identifiers with more label options
+var ingressControllerSpecs = []ingressControllerSpec{
+	{
+		name:           "nginx",
+		labelSelectors: []string{"app.kubernetes.io/name=ingress-nginx", "app=ingress-nginx", "app.kubernetes.io/component=controller", "app=nginx-ingress"},
+		namespaces:     []string{"ingress-nginx", "nginx-ingress", "kube-system", "default"},
+		namePatterns:   []string{"ingress-nginx", "nginx-ingress", "nginx-controller"},
+	},
+	{
+		name:           "traefik",
+		labelSelectors: []string{"app.kubernetes.io/name=traefik", "app=traefik", "app.kubernetes.io/instance=traefik"},
+		namespaces:     []string{"traefik", "traefik-system", "kube-system", "default"},
+		namePatterns:   []string{"traefik"},
+	},
+	{
+		name:           "haproxy",
+		labelSelectors: []string{"app.kubernetes.io/name=haproxy-ingress", "app=haproxy-ingress", "app=haproxy"},
+		namespaces:     []string{"haproxy-controller", "haproxy-ingress", "kube-system"},
+		namePatterns:   []string{"haproxy"},
+	},
+	{
+		name:           "kong",
+		labelSelectors: []string{"app.kubernetes.io/name=kong", "app=kong", "app.kubernetes.io/instance=kong"},
+		namespaces:     []string{"kong", "kong-system", "kube-system"},
+		namePatterns:   []string{"kong"},
+	},
+	{
+		name:           "istio",
+		labelSelectors: []string{"app=istiod", "istio=ingressgateway", "app=istio-ingressgateway"},
+		namespaces:     []string{"istio-system", "istio-ingress"},
+		namePatterns:   []string{"istiod", "istio-ingressgateway"},
+	},
+	{
+		name:           "contour",
+		labelSelectors: []string{"app.kubernetes.io/name=contour", "app=contour", "app=envoy"},
+		namespaces:     []string{"projectcontour", "contour", "kube-system"},
+		namePatterns:   []string{"contour", "envoy"},
+	},
+	{
+		name:           "ambassador",
+		labelSelectors: []string{"app.kubernetes.io/name=ambassador", "app=ambassador", "product=aes"},
+		namespaces:     []string{"ambassador", "emissary", "kube-system"},
+		namePatterns:   []string{"ambassador", "emissary"},
+	},
+	{
+		name:           "aws-alb",
+		labelSelectors: []string{"app.kubernetes.io/name=aws-load-balancer-controller"},
+		namespaces:     []string{"kube-system"},
+		namePatterns:   []string{"aws-load-balancer-controller"},
+	},
+}
+
+// ingressDetectionCache holds the last detection result between cycles
+type ingressDetectionCache struct {
+	mu          sync.Mutex
+	controllers []map[string]interface{}
+	detectedAt  time.Time
+	dirty       bool
+}
+
+var ingressDetection = &ingressDetectionCache{dirty: true}
+
+func (c *ingressDetectionCache) markDirty() {
+	c.mu.Lock()
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+func (c *ingressDetectionCache) needsRefresh(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirty || c.detectedAt.IsZero() || now.Sub(c.detectedAt) >= ingressDetectionMaxAge
+}
+
+func (c *ingressDetectionCache) store(controllers []map[string]interface{}, now time.Time) {
+	c.mu.Lock()
+	c.controllers = controllers
+	c.detectedAt = now
+	c.dirty = false
+	c.mu.Unlock()
+}
+
+// current returns the first detected controller (the single-controller shape
+// older backends read) with every detected controller under "controllers", or
+// an undetected placeholder before the first run
+func (c *ingressDetectionCache) current() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := newIngressControllerResult()
+	if c.detectedAt.IsZero() {
+		result["pending"] = true
+	} else {
+		if len(c.controllers) > 0 {
+			for k, v := range c.controllers[0] {
+				result[k] = v
+			}
+		}
+		result["detected_at"] = c.detectedAt
+	}
+	result["controllers"] = c.controllers
+	result["controller_count"] = len(c.controllers)
+	return result
+}
+
+// ingressListers reads the informer caches used by detection
+type ingressListers struct {
+	deployments    appslisters.DeploymentLister
+	daemonSets     appslisters.DaemonSetLister
+	ingressClasses networkinglisters.IngressClassLister
+	ingresses      networkinglisters.IngressLister
+}
+
+// runIngressDetection starts its informers on the shared factory and runs
+// the detection loop. It never returns.
+func runIngressDetection(clientset kubernetes.Interface, factory informers.SharedInformerFactory, config AgentConfig) {
+	deployments := factory.Apps().V1().Deployments()
+	daemonSets := factory.Apps().V1().DaemonSets()
+	ingressClasses := factory.Networking().V1().IngressClasses()
+	ingresses := factory.Networking().V1().Ingresses()
+
+	// Workloads change constantly (status, scaling); only spec changes of
+	// objects that look like an ingress controller invalidate the result
+	deployments.Informer().AddEventHandler(ingressChangeHandler(isIngressControllerCandidate))
+	daemonSets.Informer().AddEventHandler(ingressChangeHandler(isIngressControllerCandidate))
+	ingressClasses.Informer().AddEventHandler(ingressChangeHandler(nil))
+	ingresses.Informer().AddEventHandler(ingressChangeHandler(nil))
+
+	listers := ingressListers{
+		deployments:    deployments.Lister(),
+		daemonSets:     daemonSets.Lister(),
+		ingressClasses: ingressClasses.Lister(),
+		ingresses:      ingresses.Lister(),
+	}
+	synced := []cache.InformerSynced{
+		deployments.Informer().HasSynced,
+		daemonSets.Informer().HasSynced,
+		ingressClasses.Informer().HasSynced,
+		ingresses.Informer().HasSynced,
+	}
+	factory.Start(make(chan struct{}))
+
+	runJittered("ingress detection", func() time.Duration { return ingressDetectionInterval }, 0, config.JitterPercent, func() {
+		for _, hasSynced := range synced {
+			if !hasSynced() {
+				log.Printf("⏳ Ingress detection waiting for informer caches to sync")
+				return
+			}
+		}
+		now := time.Now()
+		if !ingressDetection.needsRefresh(now) {
+			return
+		}
+		ingressDetection.store(detectIngressControllers(clientset, listers), now)
+	})
+}
+
+// ingressChangeHandler marks the detection dirty on adds, deletes and spec
+// (generation) changes of objects accepted by relevant (nil accepts all)
+func ingressChangeHandler(relevant func(metav1.Object) bool) cache.ResourceEventHandler {
+	check := func(objs ...interface{}) {
+		for _, obj := range objs {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			m, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			if relevant == nil || relevant(m) {
+				ingressDetection.markDirty()
+				return
+			}
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { check(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, err1 := meta.Accessor(oldObj)
+			newMeta, err2 := meta.Accessor(newObj)
+			if err1 == nil && err2 == nil && oldMeta.GetGeneration() == newMeta.GetGeneration() {
+				return
+			}
+			check(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) { check(obj) },
+	}
+}
+
+// isIngressControllerCandidate reports whether a workload matches any controller's labels or name patterns
+func isIngressControllerCandidate(obj metav1.Object) bool {
+	for _, ic := range ingressControllerSpecs {
+		if matchesNamePattern(obj.GetName(), ic.namePatterns) {
+			return true
+		}
+		if !containsString(ic.namespaces, obj.GetNamespace()) {
+			continue
+		}
+		for _, labelSelector := range ic.labelSelectors {
+			if selector, err := labels.Parse(labelSelector); err == nil && selector.Matches(labels.Set(obj.GetLabels())) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newIngressControllerResult() map[string]interface{} {
+	return map[string]interface{}{
+		"type":            "unknown",
+		"detected":        false,
+		"namespace":       "",
+		"has_rbac":        false,
+		"rbac_details":    map[string]interface{}{},
+		"deployment_name": "",
+		"service_account": "",
+		"version":         "",
+		"workloads":       []string{},
+		"ingress_classes": []string{},
+		"default_class":   false,
+	}
+}
+
+// ingressWorkload is a Deployment or DaemonSet considered as a controller
+type ingressWorkload struct {
+	namespace, name string
+	labels          map[string]string
+	spec            corev1.PodSpec
+}
+
+// matches reports whether a workload belongs to this controller: well-known
+// labels in its usual namespaces, or a name pattern in any allowed namespace
+func (ic ingressControllerSpec) matches(w ingressWorkload, settings *AgentSettings) bool {
+	if containsString(ic.namespaces, w.namespace) {
+		for _, labelSelector := range ic.labelSelectors {
+			if selector, err := labels.Parse(labelSelector); err == nil && selector.Matches(labels.Set(w.labels)) {
+				return true
+			}
+		}
+	}
+	return settings.NamespaceAllowed(w.namespace) && matchesNamePattern(w.name, ic.namePatterns)
+}
+
+// detectIngressControllers finds every ingress controller in the cluster
+// (one entry per type and namespace), checks each one's RBAC and maps the
+// IngressClasses it serves
+func detectIngressControllers(clientset kubernetes.Interface, l ingressListers) []map[string]interface{} {
+	log.Printf("🔍 Detecting Ingress Controllers...")
+	settings := getSettings()
+
+	deployments, _ := l.deployments.List(labels.Everything())
+	daemonsets, _ := l.daemonSets.List(labels.Everything())
+	sortDeployments(deployments)
+	sortDaemonSets(daemonsets)
+	var workloads []ingressWorkload
+	for _, d := range deployments {
+		workloads = append(workloads, ingressWorkload{namespace: d.Namespace, name: d.Name, labels: d.Labels, spec: d.Spec.Template.Spec})
+	}
+	for _, ds := range daemonsets {
+		workloads = append(workloads, ingressWorkload{namespace: ds.Namespace, name: ds.Name + " (DaemonSet)", labels: ds.Labels, spec: ds.Spec.Template.Spec})
+	}
+
+	// A workload counts once, for the first controller that matches it
+	controllers := []map[string]interface{}{}
+	byTypeAndNamespace := map[string]map[string]interface{}{}
+	claimed := map[string]bool{}
+	for _, ic := range ingressControllerSpecs {
+		for _, w := range workloads {
+			workloadKey := w.namespace + "/" + w.name
+			if claimed[workloadKey] || !ic.matches(w, settings) {
+				continue
+			}
+			claimed[workloadKey] = true
+
+			if c, ok := byTypeAndNamespace[ic.name+"/"+w.namespace]; ok {
+				c["workloads"] = append(c["workloads"].([]string), w.name)
+				continue
+			}
+			log.Printf("✅ Detected %s ingress controller in namespace %s (%s)", ic.name, w.namespace, w.name)
+			c := describeIngressController(clientset, ic.name, w.namespace, w.name, w.spec)
+			byTypeAndNamespace[ic.name+"/"+w.namespace] = c
+			controllers = append(controllers, c)
+		}
+	}
+
+	// Map IngressClasses to the controllers serving them; a class whose
+	// controller has no recognizable workload still reveals a controller
+	classes, _ := l.ingressClasses.List(labels.Everything())
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+	for _, class := range classes {
+		controllerType := ingressControllerType(class.Spec.Controller)
+		isDefault := class.Annotations[defaultIngressClassAnnotation] == "true"
+		log.Printf("📋 Found IngressClass: %s with controller: %s", class.Name, class.Spec.Controller)
+
+		matched := false
+		for _, c := range controllers {
+			if c["type"] == controllerType {
+				addIngressClass(c, class.Name, isDefault)
+				matched = true
+			}
+		}
+		if !matched {
+			c := newIngressControllerResult()
+			c["type"] = controllerType
+			c["detected"] = true
+			c["deployment_name"] = class.Name + " (IngressClass)"
+			addIngressClass(c, class.Name, isDefault)
+			controllers = append(controllers, c)
+			log.Printf("✅ Detected ingress controller from IngressClass: %s -> %s", class.Name, controllerType)
+		}
+	}
+	if len(controllers) > 0 {
+		return controllers
+	}
+
+	// Last resort, infer the controller from Ingress class hints
+	ingresses, _ := l.ingresses.List(labels.Everything())
+	sort.Slice(ingresses, func(i, j int) bool {
+		return ingresses[i].Namespace+"/"+ingresses[i].Name < ingresses[j].Namespace+"/"+ingresses[j].Name
+	})
+	for _, ing := range ingresses {
+		result := newIngressControllerResult()
+
+		// Check annotations for controller hints
+		if className, ok := ing.Annotations[ingressClassAnnotation]; ok {
+			log.Printf("📋 Found Ingress %s/%s with class annotation: %s", ing.Namespace, ing.Name, className)
+			result["type"] = ingressClassType(className)
+			result["detected"] = true
+			result["deployment_name"] = className + " (from annotation)"
+			return append(controllers, result)
+		}
+
+		// Check spec.ingressClassName
+		if ing.Spec.IngressClassName != nil {
+			log.Printf("📋 Found Ingress %s/%s with ingressClassName: %s", ing.Namespace, ing.Name, *ing.Spec.IngressClassName)
+			result["type"] = ingressClassType(*ing.Spec.IngressClassName)
+			result["detected"] = true
+			result["deployment_name"] = *ing.Spec.IngressClassName + " (from spec)"
+			return append(controllers, result)
+		}
+	}
+
+	log.Printf("⚠️ No ingress controller detected after all checks")
+	return controllers
+}
+
+// describeIngressController builds the entry for a detected controller workload and checks its RBAC
+func describeIngressController(clientset kubernetes.Interface, controllerType, namespace, workload string, spec corev1.PodSpec) map[string]interface{} {
+	result := newIngressControllerResult()
+	result["type"] = controllerType
+	result["detected"] = true
+	result["namespace"] = namespace
+	result["deployment_name"] = workload
+	result["workloads"] = []string{workload}
+	if spec.ServiceAccountName != "" {
+		result["service_account"] = spec.ServiceAccountName
+	}
+	if len(spec.Containers) > 0 {
+		result["version"] = spec.Containers[0].Image
+	}
+
+	rbacDetails := checkIngressControllerRBAC(clientset, namespace, result["service_account"].(string), controllerType)
+	result["has_rbac"] = rbacDetails["has_proper_rbac"]
+	result["rbac_details"] = rbacDetails
+	return result
+}
+
+func addIngressClass(controller map[string]interface{}, class string, isDefault bool) {
+	controller["ingress_classes"] = append(controller["ingress_classes"].([]string), class)
+	if isDefault {
+		controller["default_class"] = true
+	}
+}
+
+// ingressControllerType maps an IngressClass spec.controller to a known controller name
+func ingressControllerType(controllerName string) string {
+	controllerLower := strings.ToLower(controllerName)
+	switch {
+	case strings.Contains(controllerLower, "nginx"):
+		return "nginx"
+	case strings.Contains(controllerLower, "traefik"):
+		return "traefik"
+	case strings.Contains(controllerLower, "haproxy"):
+		return "haproxy"
+	case strings.Contains(controllerLower, "kong"):
+		return "kong"
+	case strings.Contains(controllerLower, "istio"):
+		return "istio"
+	case strings.Contains(controllerLower, "contour"):
+		return "contour"
+	case strings.Contains(controllerLower, "ambassador") || strings.Contains(controllerLower, "emissary"):
+		return "ambassador"
+	case strings.Contains(controllerLower, "alb") || strings.Contains(controllerLower, "aws"):
+		return "aws-alb"
+	}
+	return controllerName
+}
+
+// ingressClassType maps an Ingress class name to a known controller name
+func ingressClassType(className string) string {
+	classLower := strings.ToLower(className)
+	switch {
+	case strings.Contains(classLower, "nginx"):
+		return "nginx"
+	case strings.Contains(classLower, "traefik"):
+		return "traefik"
+	}
+	return className
+}
+
+func matchesNamePattern(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortDeployments(items []*appsv1.Deployment) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Namespace+"/"+items[i].Name < items[j].Namespace+"/"+items[j].Name
+	})
+}
+
+func sortDaemonSets(items []*appsv1.DaemonSet) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Namespace+"/"+items[i].Name < items[j].Namespace+"/"+items[j].Name
+	})
+}
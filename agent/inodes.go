@@ -0,0 +1,46 @@
+package main
+
+// ---------------------------------------------
+// INODE USAGE
+// A filesystem can run out of inodes (many small files: caches, node_modules,
+// mail spools) long before it runs out of bytes; new files then fail with
+// ENOSPC and the kubelet evicts pods while byte metrics look healthy
+// ---------------------------------------------
+
+// inodeUsageWarnPercent flags filesystems whose inode usage reaches this share
+const inodeUsageWarnPercent = 90
+
+// inodeUsage reports the inode counters of a filesystem, or nil when the
+// Kubelet did not report them (e.g. some CSI drivers)
+func inodeUsage(fs *FsStats) map[string]interface{} {
+	if fs == nil || fs.Inodes == nil || *fs.Inodes == 0 {
+		return nil
+	}
+	total := *fs.Inodes
+	var used, free uint64
+	switch {
+	case fs.InodesUsed != nil:
+		used = *fs.InodesUsed
+	case fs.InodesFree != nil && *fs.InodesFree <= total:
+		used = total - *fs.InodesFree
+	}
+	if fs.InodesFree != nil {
+		free = *fs.InodesFree
+	} else if used <= total {
+		free = total - used
+	}
+	percent := float64(used) / float64(total) * 100
+	return map[string]interface{}{
+		"inodes":        total,
+		"inodes_used":   used,
+		"inodes_free":   free,
+		"usage_percent": percent,
+		"pressure":      percent >= inodeUsageWarnPercent,
+	}
+}
+
+// inodePressure reports whether an inodeUsage result is above the threshold
+func inodePressure(usage map[string]interface{}) bool {
+	pressure, _ := usage["pressure"].(bool)
+	return pressure
+}
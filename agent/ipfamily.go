@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// IP FAMILIES (IPv4 / IPv6 / dual-stack)
+// ---------------------------------------------
+
+// ipFamilyOf returns "IPv4", "IPv6" or "" for an IP or CIDR string
+func ipFamilyOf(address string) string {
+	if i := strings.IndexByte(address, '/'); i >= 0 {
+		address = address[:i]
+	}
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return string(corev1.IPv4Protocol)
+	default:
+		return string(corev1.IPv6Protocol)
+	}
+}
+
+// familiesOf returns the distinct IP families of a list of addresses, IPv4 first
+func familiesOf(addresses []string) []string {
+	seen := map[string]bool{}
+	for _, a := range addresses {
+		if family := ipFamilyOf(a); family != "" {
+			seen[family] = true
+		}
+	}
+	var families []string
+	for _, family := range []string{string(corev1.IPv4Protocol), string(corev1.IPv6Protocol)} {
+		if seen[family] {
+			families = append(families, family)
+		}
+	}
+	return families
+}
+
+// podIPFamilies returns the families of the pod's assigned IPs
+func podIPFamilies(pod corev1.Pod) []string {
+	var ips []string
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = []string{pod.Status.PodIP}
+	}
+	return familiesOf(ips)
+}
+
+// serviceIPFamilies returns the families a service is reachable on, including
+// the external addresses of a LoadBalancer
+func serviceIPFamilies(svc corev1.Service) []string {
+	var addresses []string
+	addresses = append(addresses, svc.Spec.ClusterIPs...)
+	addresses = append(addresses, svc.Spec.ExternalIPs...)
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		addresses = append(addresses, ingress.IP)
+	}
+	families := familiesOf(addresses)
+	if len(families) == 0 {
+		for _, f := range svc.Spec.IPFamilies {
+			families = append(families, string(f))
+		}
+	}
+	return families
+}
+
+// serviceExposedOnIPv6 reports whether an externally reachable service has an IPv6 address
+func serviceExposedOnIPv6(svc corev1.Service) bool {
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer && svc.Spec.Type != corev1.ServiceTypeNodePort && len(svc.Spec.ExternalIPs) == 0 {
+		return false
+	}
+	for _, family := range serviceIPFamilies(svc) {
+		if family == string(corev1.IPv6Protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectIPFamilies summarizes pod, service and node IP families and whether
+// the cluster runs dual-stack
+func collectIPFamilies(snap *ClusterSnapshot) map[string]interface{} {
+	podsByFamily := map[string]int{"IPv4": 0, "IPv6": 0, "dual_stack": 0}
+	for _, pod := range snap.Pods {
+		countFamilies(podsByFamily, podIPFamilies(pod))
+	}
+
+	servicesByPolicy := map[string]int{}
+	servicesByFamily := map[string]int{"IPv4": 0, "IPv6": 0, "dual_stack": 0}
+	var ipv6Exposed []map[string]interface{}
+	for _, svc := range snap.Services {
+		if svc.Spec.IPFamilyPolicy != nil {
+			servicesByPolicy[string(*svc.Spec.IPFamilyPolicy)]++
+		}
+		families := serviceIPFamilies(svc)
+		countFamilies(servicesByFamily, families)
+		if serviceExposedOnIPv6(svc) {
+			ipv6Exposed = append(ipv6Exposed, map[string]interface{}{
+				"name":         svc.Name,
+				"namespace":    svc.Namespace,
+				"service_type": string(svc.Spec.Type),
+				"ip_families":  families,
+			})
+		}
+	}
+
+	nodesByFamily := map[string]int{"IPv4": 0, "IPv6": 0, "dual_stack": 0}
+	for _, node := range snap.Nodes {
+		cidrs := node.Spec.PodCIDRs
+		if len(cidrs) == 0 && node.Spec.PodCIDR != "" {
+			cidrs = []string{node.Spec.PodCIDR}
+		}
+		countFamilies(nodesByFamily, familiesOf(cidrs))
+	}
+
+	return map[string]interface{}{
+		"dual_stack":            nodesByFamily["dual_stack"] > 0 || podsByFamily["dual_stack"] > 0,
+		"ipv6_enabled":          nodesByFamily["IPv6"]+nodesByFamily["dual_stack"]+podsByFamily["IPv6"]+podsByFamily["dual_stack"] > 0,
+		"pods":                  podsByFamily,
+		"services":              servicesByFamily,
+		"services_by_policy":    servicesByPolicy,
+		"node_pod_cidrs":        nodesByFamily,
+		"services_exposed_ipv6": ipv6Exposed,
+	}
+}
+
+// countFamilies increments "dual_stack" for two families, otherwise the single family
+func countFamilies(counts map[string]int, families []string) {
+	switch len(families) {
+	case 0:
+	case 1:
+		counts[families[0]]++
+	default:
+		counts["dual_stack"]++
+	}
+}
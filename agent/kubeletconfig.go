@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// KUBELET CONFIGZ
+// Per-node kubelet settings (eviction thresholds, max pods, feature gates,
+// auth) fetched from /configz through the node proxy
+// ---------------------------------------------
+
+// kubeletConfigTTL is how long a node's /configz is reused; it only changes on kubelet restart
+const kubeletConfigTTL = 15 * time.Minute
+
+// KubeletConfig is the subset of KubeletConfiguration the agent reports.
+// Based on: k8s.io/kubelet/config/v1beta1
+type KubeletConfig struct {
+	MaxPods                     int32             `json:"maxPods"`
+	PodPidsLimit                *int64            `json:"podPidsLimit,omitempty"`
+	EvictionHard                map[string]string `json:"evictionHard,omitempty"`
+	EvictionSoft                map[string]string `json:"evictionSoft,omitempty"`
+	EvictionSoftGracePeriod     map[string]string `json:"evictionSoftGracePeriod,omitempty"`
+	SystemReserved              map[string]string `json:"systemReserved,omitempty"`
+	KubeReserved                map[string]string `json:"kubeReserved,omitempty"`
+	FeatureGates                map[string]bool   `json:"featureGates,omitempty"`
+	CgroupDriver                string            `json:"cgroupDriver,omitempty"`
+	ContainerRuntimeEndpoint    string            `json:"containerRuntimeEndpoint,omitempty"`
+	ImageGCHighThresholdPercent *int32            `json:"imageGCHighThresholdPercent,omitempty"`
+	ImageGCLowThresholdPercent  *int32            `json:"imageGCLowThresholdPercent,omitempty"`
+	ContainerLogMaxSize         string            `json:"containerLogMaxSize,omitempty"`
+	ContainerLogMaxFiles        *int32            `json:"containerLogMaxFiles,omitempty"`
+	ReadOnlyPort                int32             `json:"readOnlyPort,omitempty"`
+	ProtectKernelDefaults       bool              `json:"protectKernelDefaults,omitempty"`
+	RotateCertificates          bool              `json:"rotateCertificates,omitempty"`
+	ServerTLSBootstrap          bool              `json:"serverTLSBootstrap,omitempty"`
+	Authentication              struct {
+		Anonymous struct {
+			Enabled *bool `json:"enabled,omitempty"`
+		} `json:"anonymous"`
+		Webhook struct {
+			Enabled *bool `json:"enabled,omitempty"`
+		} `json:"webhook"`
+	} `json:"authentication"`
+	Authorization struct {
+		Mode string `json:"mode,omitempty"`
+	} `json:"authorization"`
+}
+
+type cachedKubeletConfig struct {
+	config    *KubeletConfig
+	fetchedAt time.Time
+}
+
+// kubeletConfigCache keeps each node's /configz for kubeletConfigTTL
+type kubeletConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedKubeletConfig
+}
+
+var kubeletConfigs = &kubeletConfigCache{entries: map[string]cachedKubeletConfig{}}
+
+// get returns the node's kubelet config, fetching it when missing or stale
+func (c *kubeletConfigCache) get(clientset kubernetes.Interface, nodeName string) (*KubeletConfig, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[nodeName]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < kubeletConfigTTL {
+		return entry.config, nil
+	}
+
+	config, err := fetchKubeletConfig(clientset, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[nodeName] = cachedKubeletConfig{config: config, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return config, nil
+}
+
+// prune drops the configs of nodes that are no longer in the cluster
+func (c *kubeletConfigCache) prune(nodes []corev1.Node) {
+	current := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		current[node.Name] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name := range c.entries {
+		if !current[name] {
+			delete(c.entries, name)
+		}
+	}
+}
+
+// reset drops every cached config (memory watchdog)
+func (c *kubeletConfigCache) reset() {
+	c.mu.Lock()
+	c.entries = map[string]cachedKubeletConfig{}
+	c.mu.Unlock()
+}
+
+// fetchKubeletConfig calls the Kubelet /configz API of one node via the API server proxy
+func fetchKubeletConfig(clientset kubernetes.Interface, nodeName string) (*KubeletConfig, error) {
+	restClient, err := coreRESTClient(clientset)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := apiContext()
+	defer cancel()
+
+	responseBytes, err := restClient.Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("configz").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var configz struct {
+		KubeletConfig KubeletConfig `json:"kubeletconfig"`
+	}
+	if err := json.Unmarshal(responseBytes, &configz); err != nil {
+		return nil, fmt.Errorf("failed to parse configz: %v", err)
+	}
+	return &configz.KubeletConfig, nil
+}
+
+// kubeletSecurityFindings flags kubelet settings that weaken node security
+func kubeletSecurityFindings(cfg *KubeletConfig) []map[string]interface{} {
+	var findings []map[string]interface{}
+	add := func(check, severity, message string) {
+		findings = append(findings, map[string]interface{}{"check": check, "severity": severity, "message": message})
+	}
+
+	if cfg.Authentication.Anonymous.Enabled != nil && *cfg.Authentication.Anonymous.Enabled {
+		add("anonymous_auth", "critical", "Kubelet accepts anonymous requests")
+	}
+	if cfg.Authentication.Webhook.Enabled != nil && !*cfg.Authentication.Webhook.Enabled {
+		add("webhook_auth", "high", "Kubelet webhook authentication is disabled")
+	}
+	if cfg.Authorization.Mode == "AlwaysAllow" {
+		add("authorization_mode", "critical", "Kubelet authorization mode is AlwaysAllow")
+	}
+	if cfg.ReadOnlyPort != 0 {
+		add("read_only_port", "high", fmt.Sprintf("Kubelet read-only port %d is open", cfg.ReadOnlyPort))
+	}
+	if !cfg.RotateCertificates {
+		add("rotate_certificates", "medium", "Kubelet client certificate rotation is disabled")
+	}
+	if !cfg.ProtectKernelDefaults {
+		add("protect_kernel_defaults", "low", "protectKernelDefaults is disabled")
+	}
+	if cfg.PodPidsLimit == nil || *cfg.PodPidsLimit <= 0 {
+		add("pod_pids_limit", "low", "No per-pod PID limit: a fork bomb can exhaust the node")
+	}
+	return findings
+}
+
+// collectKubeletConfigs builds the "kubelet_config" metric: per-node settings,
+// pod capacity usage and security findings
+func collectKubeletConfigs(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("kubelet_config", time.Now())
+
+	podsPerNode := map[string]int{}
+	for _, pod := range snap.Pods {
+		if pod.Spec.NodeName != "" && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			podsPerNode[pod.Spec.NodeName]++
+		}
+	}
+
+	var nodes []map[string]interface{}
+	findingsTotal := 0
+	for _, node := range snap.Nodes {
+		cfg, ok := snap.NodeConfigs[node.Name]
+		if !ok {
+			continue
+		}
+		findings := kubeletSecurityFindings(cfg)
+		findingsTotal += len(findings)
+
+		podUsage := float64(0)
+		if cfg.MaxPods > 0 {
+			podUsage = float64(podsPerNode[node.Name]) / float64(cfg.MaxPods) * 100
+		}
+
+		nodes = append(nodes, map[string]interface{}{
+			"node":                       node.Name,
+			"kubelet_version":            node.Status.NodeInfo.KubeletVersion,
+			"max_pods":                   cfg.MaxPods,
+			"pods":                       podsPerNode[node.Name],
+			"pod_capacity_usage_percent": podUsage,
+			"pod_pids_limit":             cfg.PodPidsLimit,
+			"eviction_hard":              cfg.EvictionHard,
+			"eviction_soft":              cfg.EvictionSoft,
+			"eviction_soft_grace_period": cfg.EvictionSoftGracePeriod,
+			"system_reserved":            cfg.SystemReserved,
+			"kube_reserved":              cfg.KubeReserved,
+			"feature_gates":              cfg.FeatureGates,
+			"cgroup_driver":              cfg.CgroupDriver,
+			"image_gc_high_percent":      cfg.ImageGCHighThresholdPercent,
+			"image_gc_low_percent":       cfg.ImageGCLowThresholdPercent,
+			"container_log_max_size":     cfg.ContainerLogMaxSize,
+			"container_log_max_files":    cfg.ContainerLogMaxFiles,
+			"security_findings":          findings,
+		})
+	}
+
+	return map[string]interface{}{
+		"nodes":          nodes,
+		"findings_total": findingsTotal,
+	}
+}
+
+// summarizeKubeletSecurity counts kubelet findings per check for the security metric
+func summarizeKubeletSecurity(snap *ClusterSnapshot) map[string]interface{} {
+	byCheck := map[string]int{}
+	affected := 0
+	for _, cfg := range snap.NodeConfigs {
+		findings := kubeletSecurityFindings(cfg)
+		for _, f := range findings {
+			byCheck[f["check"].(string)]++
+		}
+		if len(findings) > 0 {
+			affected++
+		}
+	}
+	return map[string]interface{}{
+		"nodes_checked":  len(snap.NodeConfigs),
+		"nodes_affected": affected,
+		"findings":       byCheck,
+	}
+}
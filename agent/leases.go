@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// LEASES / CONTROLLER LEADERSHIP
+// Leader-election Leases tell which replica of the scheduler,
+// controller-manager or an operator is active, since when, and how often
+// leadership moved. A Lease not renewed within its duration means nobody
+// leads: the component is down or stuck (reconciliation silently stops).
+// Node heartbeat Leases in kube-node-lease are summarised, not listed.
+// ---------------------------------------------
+
+const (
+	nodeLeaseNamespace = "kube-node-lease"
+	// apiServerIdentityLabel marks the per-apiserver identity Leases
+	apiServerIdentityLabel = "apiserver.kubernetes.io/identity"
+	// staleLeaseGrace tolerates renewals that are merely late
+	staleLeaseGrace = 2
+	// leaseFlappingTransitions flags leadership that keeps moving
+	leaseFlappingTransitions = 10
+)
+
+// controlPlaneLeases are the well-known leader-election Lease names
+var controlPlaneLeases = map[string]bool{
+	"kube-scheduler":           true,
+	"kube-controller-manager":  true,
+	"cloud-controller-manager": true,
+}
+
+// leaseStale reports whether the holder stopped renewing the Lease
+func leaseStale(lease coordinationv1.Lease, now time.Time) (bool, float64) {
+	if lease.Spec.RenewTime == nil {
+		return true, 0
+	}
+	sinceRenew := now.Sub(lease.Spec.RenewTime.Time).Seconds()
+	duration := float64(15) // client-go leader election default
+	if lease.Spec.LeaseDurationSeconds != nil {
+		duration = float64(*lease.Spec.LeaseDurationSeconds)
+	}
+	return sinceRenew > duration*staleLeaseGrace, sinceRenew
+}
+
+// leaseHolderPod maps a holder identity ("<pod>_<uuid>" for client-go
+// leader election) to a pod in the Lease's namespace, when there is one
+func leaseHolderPod(holder string, pods map[string]bool, namespace string) string {
+	name, _, _ := strings.Cut(holder, "_")
+	if pods[namespace+"/"+name] {
+		return name
+	}
+	return ""
+}
+
+// collectLeases builds the "leases" metric
+func collectLeases(clientset kubernetes.Interface, snap *ClusterSnapshot, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("leases", time.Now())
+
+	ctx, cancel := apiContext()
+	leaseList, err := clientset.CoordinationV1().Leases("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing Leases: %v", err)
+		status.Fail("leases", err)
+		return map[string]interface{}{}
+	}
+
+	pods := map[string]bool{}
+	for _, pod := range snap.Pods {
+		pods[pod.Namespace+"/"+pod.Name] = true
+	}
+
+	now := snap.TakenAt
+	var leaders, stale, flapping []map[string]interface{}
+	var nodeLeases, staleNodeLeases, apiServers int
+	var staleNodes []string
+	for _, lease := range leaseList.Items {
+		isStale, sinceRenew := leaseStale(lease, now)
+
+		if lease.Namespace == nodeLeaseNamespace {
+			nodeLeases++
+			if isStale {
+				staleNodeLeases++
+				staleNodes = append(staleNodes, lease.Name)
+			}
+			continue
+		}
+		if lease.Labels[apiServerIdentityLabel] != "" {
+			apiServers++
+			continue
+		}
+		if !settings.NamespaceAllowed(lease.Namespace) {
+			continue
+		}
+
+		var holder string
+		if lease.Spec.HolderIdentity != nil {
+			holder = *lease.Spec.HolderIdentity
+		}
+		var transitions int32
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions
+		}
+		entry := map[string]interface{}{
+			"namespace":           lease.Namespace,
+			"name":                lease.Name,
+			"holder":              holder,
+			"holder_pod":          leaseHolderPod(holder, pods, lease.Namespace),
+			"control_plane":       lease.Namespace == "kube-system" && controlPlaneLeases[lease.Name],
+			"transitions":         transitions,
+			"seconds_since_renew": sinceRenew,
+			"stale":               isStale,
+		}
+		if lease.Spec.LeaseDurationSeconds != nil {
+			entry["duration_seconds"] = *lease.Spec.LeaseDurationSeconds
+		}
+		if lease.Spec.AcquireTime != nil {
+			entry["held_for_seconds"] = now.Sub(lease.Spec.AcquireTime.Time).Seconds()
+		}
+		leaders = append(leaders, entry)
+
+		// An empty holder is a released Lease (graceful shutdown), not a stale one
+		if isStale && holder != "" {
+			stale = append(stale, entry)
+		}
+		if transitions >= leaseFlappingTransitions {
+			flapping = append(flapping, entry)
+		}
+	}
+
+	sort.Slice(leaders, func(i, j int) bool {
+		a, b := leaders[i], leaders[j]
+		if a["namespace"] != b["namespace"] {
+			return a["namespace"].(string) < b["namespace"].(string)
+		}
+		return a["name"].(string) < b["name"].(string)
+	})
+	sort.Strings(staleNodes)
+
+	return map[string]interface{}{
+		"leader_leases":        leaders,
+		"stale":                stale,
+		"flapping":             flapping,
+		"apiserver_identities": apiServers,
+		"node_leases":          nodeLeases,
+		"stale_node_leases":    staleNodeLeases,
+		"stale_nodes":          staleNodes,
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// POD LIFECYCLE TIMING
+// Scheduling latency (created → PodScheduled) and startup latency
+// (PodScheduled → Ready) read from pod conditions, summarised per namespace.
+// High scheduling latency points at an overloaded scheduler or a cluster out
+// of room; high startup latency at slow images, init containers or probes.
+// ---------------------------------------------
+
+const (
+	// lifecycleWindow only samples pods created recently, so long-lived pods
+	// don't drown out how the cluster behaves today
+	lifecycleWindow = 24 * time.Hour
+	// slowestPodsLimit is how many of the slowest pods are listed
+	slowestPodsLimit = 10
+)
+
+// latencyDistribution summarises a set of durations in seconds
+func latencyDistribution(seconds []float64) map[string]interface{} {
+	if len(seconds) == 0 {
+		return nil
+	}
+	sort.Float64s(seconds)
+	var total float64
+	for _, s := range seconds {
+		total += s
+	}
+	at := func(q float64) float64 {
+		return seconds[int(q*float64(len(seconds)-1))]
+	}
+	return map[string]interface{}{
+		"count": len(seconds),
+		"avg":   total / float64(len(seconds)),
+		"p50":   at(0.50),
+		"p90":   at(0.90),
+		"p99":   at(0.99),
+		"max":   seconds[len(seconds)-1],
+	}
+}
+
+// podConditionTime returns when the given condition last turned True
+func podConditionTime(pod corev1.Pod, condition corev1.PodConditionType) (time.Time, bool) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == condition && c.Status == corev1.ConditionTrue && !c.LastTransitionTime.IsZero() {
+			return c.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// podRestarts sums restarts across the pod's containers
+func podRestarts(pod corev1.Pod) int32 {
+	var restarts int32
+	for _, cs := range pod.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+	}
+	return restarts
+}
+
+// collectPodLifecycle builds the "pod_lifecycle" metric
+func collectPodLifecycle(snap *ClusterSnapshot, settings *AgentSettings) map[string]interface{} {
+	defer diagnostics.recordDuration("pod_lifecycle", time.Now())
+
+	type namespaceTimes struct {
+		scheduling, startup []float64
+		pending             int
+		oldestPending       float64
+	}
+	namespaces := map[string]*namespaceTimes{}
+	var allScheduling, allStartup []float64
+	var slowest []map[string]interface{}
+	since := snap.TakenAt.Add(-lifecycleWindow)
+
+	for _, pod := range snap.Pods {
+		if !settings.NamespaceAllowed(pod.Namespace) || pod.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		ns, ok := namespaces[pod.Namespace]
+		if !ok {
+			ns = &namespaceTimes{}
+			namespaces[pod.Namespace] = ns
+		}
+		created := pod.CreationTimestamp.Time
+
+		scheduled, ok := podConditionTime(pod, corev1.PodScheduled)
+		if !ok {
+			// Still waiting for the scheduler
+			if pod.Status.Phase == corev1.PodPending {
+				ns.pending++
+				if waiting := snap.TakenAt.Sub(created).Seconds(); waiting > ns.oldestPending {
+					ns.oldestPending = waiting
+				}
+			}
+			continue
+		}
+		if scheduling := scheduled.Sub(created).Seconds(); scheduling >= 0 {
+			ns.scheduling = append(ns.scheduling, scheduling)
+			allScheduling = append(allScheduling, scheduling)
+		}
+
+		// Ready's transition time moves on every restart or probe flap, so
+		// only pods that came up cleanly say anything about startup
+		ready, ok := podConditionTime(pod, corev1.PodReady)
+		if !ok || podRestarts(pod) > 0 {
+			continue
+		}
+		startup := ready.Sub(scheduled).Seconds()
+		if startup < 0 {
+			continue
+		}
+		ns.startup = append(ns.startup, startup)
+		allStartup = append(allStartup, startup)
+		slowest = append(slowest, map[string]interface{}{
+			"namespace":          pod.Namespace,
+			"pod":                pod.Name,
+			"node":               pod.Spec.NodeName,
+			"startup_seconds":    startup,
+			"init_containers":    len(pod.Spec.InitContainers),
+			"scheduling_seconds": scheduled.Sub(created).Seconds(),
+		})
+	}
+
+	sort.Slice(slowest, func(i, j int) bool {
+		return slowest[i]["startup_seconds"].(float64) > slowest[j]["startup_seconds"].(float64)
+	})
+	if len(slowest) > slowestPodsLimit {
+		slowest = slowest[:slowestPodsLimit]
+	}
+
+	byNamespace := make(map[string]interface{}, len(namespaces))
+	for name, ns := range namespaces {
+		entry := map[string]interface{}{
+			"scheduling_seconds": latencyDistribution(ns.scheduling),
+			"startup_seconds":    latencyDistribution(ns.startup),
+			"unscheduled_pods":   ns.pending,
+		}
+		if ns.pending > 0 {
+			entry["oldest_unscheduled_seconds"] = ns.oldestPending
+		}
+		byNamespace[name] = entry
+	}
+
+	return map[string]interface{}{
+		"window_seconds":     lifecycleWindow.Seconds(),
+		"scheduling_seconds": latencyDistribution(allScheduling),
+		"startup_seconds":    latencyDistribution(allStartup),
+		"namespaces":         byNamespace,
+		"slowest_startups":   slowest,
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// LOCAL API
+// Opt-in read-only HTTP API (LOCAL_API_ADDR) serving the latest collected
+// data to other in-cluster tools, so they can reuse it instead of listing
+// the API server again. Every /api/v1 request needs
+// "Authorization: Bearer $LOCAL_API_TOKEN"; payloads are redacted like the
+// ones sent to the backend, and tenancy does not apply. With LOCAL_UI=true
+// the same server also serves a small debugging page at "/", which logs in
+// with HTTP basic auth (any user name, the token as password).
+// ---------------------------------------------
+
+//go:embed ui/index.html
+var localUIPage []byte
+
+// localAPIAliases are the short routes for the most used metric types
+var localAPIAliases = map[string]string{
+	"pods":     "pod_details",
+	"nodes":    "nodes",
+	"events":   "events",
+	"pvcs":     "pvcs",
+	"security": "security_threats",
+}
+
+// latestMetrics keeps the newest envelope of every metric type; partial
+// cycles (collect_now) only replace the types they collected
+type latestMetrics struct {
+	mu        sync.RWMutex
+	envelopes map[string]map[string]interface{}
+	updatedAt time.Time
+}
+
+var latest = &latestMetrics{envelopes: map[string]map[string]interface{}{}}
+
+// record stores a cycle's envelopes. They are copied because the offline
+// buffer marks its own envelopes as replayed.
+func (l *latestMetrics) record(metrics []map[string]interface{}, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range metrics {
+		metricType, _ := m["type"].(string)
+		envelope := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			envelope[k] = v
+		}
+		l.envelopes[metricType] = envelope
+	}
+	l.updatedAt = at
+}
+
+// get returns one envelope
+func (l *latestMetrics) get(metricType string) (map[string]interface{}, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	envelope, ok := l.envelopes[metricType]
+	return envelope, ok
+}
+
+// snapshot returns every envelope keyed by type
+func (l *latestMetrics) snapshot() map[string]interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	metrics := make(map[string]interface{}, len(l.envelopes))
+	for metricType, envelope := range l.envelopes {
+		metrics[metricType] = envelope
+	}
+	return map[string]interface{}{
+		"updated_at": l.updatedAt.UTC(),
+		"metrics":    metrics,
+	}
+}
+
+// summaries returns every envelope without its data, and when the last
+// cycle was recorded
+func (l *latestMetrics) summaries() (map[string]interface{}, time.Time) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	summaries := make(map[string]interface{}, len(l.envelopes))
+	for metricType, envelope := range l.envelopes {
+		summary := map[string]interface{}{}
+		for _, key := range []string{"status", "collected_at", "error", "stale_since", "schema_version"} {
+			if v, ok := envelope[key]; ok {
+				summary[key] = v
+			}
+		}
+		summaries[metricType] = summary
+	}
+	return summaries, l.updatedAt
+}
+
+// types lists the metric types available
+func (l *latestMetrics) types() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	types := make([]string, 0, len(l.envelopes))
+	for metricType := range l.envelopes {
+		types = append(types, metricType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// startLocalAPI serves the local API in the background
+func startLocalAPI(config AgentConfig) {
+	if config.LocalAPIToken == "" {
+		log.Printf("⚠️  Local API disabled: LOCAL_API_ADDR is set but LOCAL_API_TOKEN is empty")
+		return
+	}
+	server := &http.Server{
+		Addr:              config.LocalAPIAddr,
+		Handler:           localAPIHandler(config),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Printf("🔌 Local API listening on %s", config.LocalAPIAddr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("⚠️  Local API stopped: %v", err)
+		}
+	}()
+}
+
+// localAPIHandler routes the local API
+func localAPIHandler(config AgentConfig) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		snapshot := latest.snapshot()
+		snapshot["cluster_id"] = config.ClusterID
+		writeLocalJSON(w, http.StatusOK, snapshot)
+	})
+	api.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeLocalJSON(w, http.StatusOK, localStatus(config))
+	})
+	api.HandleFunc("GET /api/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		writeLocalJSON(w, http.StatusOK, map[string]interface{}{"types": latest.types()})
+	})
+	api.HandleFunc("GET /api/v1/metrics/{type}", func(w http.ResponseWriter, r *http.Request) {
+		serveLatest(w, r.PathValue("type"))
+	})
+	for route, metricType := range localAPIAliases {
+		api.HandleFunc("GET /api/v1/"+route, func(w http.ResponseWriter, r *http.Request) {
+			serveLatest(w, metricType)
+		})
+	}
+	mux.Handle("/api/", requireLocalToken(config.LocalAPIToken, api))
+
+	if config.LocalUI {
+		mux.Handle("GET /{$}", requireLocalToken(config.LocalAPIToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+			w.Write(localUIPage)
+		})))
+	}
+	return mux
+}
+
+// localStatus is what the debugging page shows: agent, collector health,
+// the latest envelope of every type (without data), sends and commands
+func localStatus(config AgentConfig) map[string]interface{} {
+	metrics, updatedAt := latest.summaries()
+	return map[string]interface{}{
+		"agent_version":     AgentVersion,
+		"cluster_id":        config.ClusterID,
+		"endpoint":          config.APIEndpoint,
+		"updated_at":        updatedAt.UTC(),
+		"metrics":           metrics,
+		"collection":        collectorHealth.report(),
+		"diagnostics":       diagnostics.snapshot(),
+		"offline_buffer":    offline.report(),
+		"collection_policy": collectionPolicy.report(),
+	}
+}
+
+// serveLatest writes one metric type's latest envelope, 404 before it was collected
+func serveLatest(w http.ResponseWriter, metricType string) {
+	envelope, ok := latest.get(metricType)
+	if !ok {
+		writeLocalJSON(w, http.StatusNotFound, map[string]interface{}{
+			"error": "no data collected yet for " + metricType,
+		})
+		return
+	}
+	writeLocalJSON(w, http.StatusOK, envelope)
+}
+
+// requireLocalToken rejects requests without the token, given as a bearer
+// token or as the basic auth password (so browsers can log in to the page)
+func requireLocalToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, presented, ok = r.BasicAuth()
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Add("WWW-Authenticate", `Basic realm="kodo-agent"`)
+			w.Header().Add("WWW-Authenticate", `Bearer realm="kodo-agent"`)
+			writeLocalJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeLocalJSON encodes a redacted JSON response
+func writeLocalJSON(w http.ResponseWriter, code int, v interface{}) {
+	body, err := marshalRedacted(v, "local_api")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
@@ -2,20 +2,26 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
@@ -28,96 +34,427 @@ const AgentVersion = "v0.0.51"
 // CONFIG
 // ---------------------------------------------
 type AgentConfig struct {
-	APIEndpoint string
-	APIKey      string
-	ClusterID   string
-	Interval    int
+	APIEndpoint   string
+	APIKey        string
+	ClusterID     string
+	Interval      int
+	StartupSplay  int    // max random delay (seconds) before the first cycle
+	JitterPercent int    // random ± spread applied to every cycle interval
+	Namespace     string // namespace the agent runs in (where its KuberPulseConfig lives)
+	ConfigName    string // name of the KuberPulseConfig to watch
+
+	CommandConcurrency int    // max commands executing at once
+	PatchAllowedKinds  string // "Kind.group,Kind" allowed for patch_resource
+
+	PrometheusURL     string // in-cluster Prometheus used by custom_metrics
+	PrometheusQueries string // "name=query;name2=query2"
+	MeshTelemetry     bool   // collect mesh success rate/latency from Prometheus
+	RBACSubjects      bool   // export effective permissions per RBAC subject
+
+	ExcludeHelmSecrets bool // leave Helm release Secrets out of the secrets counts
+
+	ThreatSuppressions string // "rule:namespace/object;rule2:namespace" accepted threat findings
+	ConnectionSampling bool   // read per-pod connection samples from the node probes
+
+	SBOMEnabled       bool   // generate and upload SBOMs of the running images
+	SBOMFormat        string // "cyclonedx" or "spdx"
+	SBOMIntervalHours int    // time between SBOM scan rounds
+	SBOMSyftImage     string // image of the Syft scan pods
+
+	ImageVerifyKeys            string // PEM file or directory of trusted cosign public keys
+	ImageVerifyIdentities      string // "issuer=subject-regexp;..." trusted keyless signers
+	ImageVerifyRoots           string // PEM file or directory of Fulcio roots for keyless signers
+	ImageVerifyRekorKeys       string // PEM file or directory of Rekor public keys for keyless signers
+	ImageVerifyIntervalMinutes int    // time between image verification rounds
+
+	RegistryCredentialChecks   bool // probe that referenced imagePullSecrets still authenticate
+	RegistryCheckIntervalHours int  // time between credential check rounds
+
+	NodeBenchmark bool // allow the benchmark_node command (needs kubernetes/node-benchmark.yaml)
+
+	CommandEvents      bool // create a Kubernetes Event on every object a command changed
+	CommandAnnotations bool // annotate changed objects with the last command, time and user
+
+	GitOpsConflictPolicy string // require_force, warn, suspend or ignore for ArgoCD/Flux-managed Deployments
+	GitOpsInstanceLabel  bool   // also treat app.kubernetes.io/instance outside Helm as ArgoCD label tracking
+
+	ReportSchedule           string  // "daily" or "weekly" aggregate report payloads ("" disables them)
+	ReportStatePath          string  // file the running report period is persisted to
+	ReportCPUCoreHourPrice   float64 // price of one core-hour for the report's cost estimate
+	ReportMemoryGiBHourPrice float64 // price of one GiB-hour of memory
+	ReportCurrency           string  // currency the prices are in
+
+	OfflineBufferMB               int // memory for cycles that could not be sent (0 disables, -1 sizes it from MemoryLimitMB)
+	OfflineDownsampleAfterMinutes int // buffered cycles older than this are downsampled
+	OfflineKeepEvery              int // of the downsampled cycles, every Nth keeps all its metrics
+
+	TenantMode    string // "tag" or "split" payloads per tenant ("" disables tenancy)
+	TenantRules   string // "tenant=selector;tenant=ns:glob,glob" namespace → tenant rules
+	TenantLabel   string // namespace label naming the tenant when no rule matches
+	TenantDefault string // tenant of unmatched namespaces and cluster-scoped data
+	TenantAPIKeys string // "tenant=key;..." API keys of the split payloads
+
+	TimeSeriesPath string // file the short-term time series are persisted to ("" keeps them in memory)
+
+	MemoryLimitMB    int // container memory limit, from the downward API (0 disables the watchdog)
+	MemoryCapPercent int // share of MemoryLimitMB the watchdog enforces
+
+	BootstrapToken    string // exchanged for API_KEY/CLUSTER_ID on first boot
+	CredentialsSecret string // Secret (in Namespace) holding the enrolled credentials
+
+	CollectionPolicyConfigMap string // ConfigMap (in Namespace) persisting the backend's collection policy
+
+	LocalAPIAddr  string // address of the read-only local API ("" disables it)
+	LocalAPIToken string // bearer token the local API requires
+	LocalUI       bool   // also serve the debugging web page on LOCAL_API_ADDR
+
+	ChangeFeed             bool // watch deployments/pods/nodes and send changes between snapshots
+	ChangeFeedFlushSeconds int  // how long changes are coalesced before being sent
+
+	EventsRelistSeconds int // time between full event lists when events cannot be watched
+
+	PodLabels      []string // pod label keys copied into pod_details ("prefix/*" for a prefix)
+	PodAnnotations []string // pod annotation keys copied into pod_details
+
+	NodeLabels      []string // node label keys copied into node info (pool/zone grouping)
+	NodeAnnotations []string // node annotation keys copied into node info
+	NodeTaints      bool     // also report each node's taints
+
+	Output     string // "backend" (default), or "stdout"/"file" for a dry run
+	OutputPath string // file payloads are appended to when Output is "file"
+
+	// Extra authentication for ingestion gateways (see auth.go)
+	AuthProvider       string // api_key (default), oauth2, aws_sigv4 or gcp
+	OAuth2TokenURL     string
+	OAuth2ClientID     string
+	OAuth2ClientSecret string
+	OAuth2Scopes       string // comma separated
+	AWSRegion          string
+	AWSService         string // SigV4 service name, "execute-api" for API Gateway
+	GCPAudience        string // audience of the identity token
+	GCPCredentialsFile string // service account key; empty uses the metadata server
+
+	// Shared backend HTTP client (see httpclient.go), in seconds
+	HTTPTimeout     int // whole request, including reading the response
+	HTTPDialTimeout int // TCP connect and TLS handshake
+	HTTPKeepAlive   int // TCP keep-alive period; negative disables connection reuse
+	HTTPIdleTimeout int // how long an idle pooled connection is kept
+	HTTPMaxConns    int // connections per backend host
 }
 
 func loadConfig() AgentConfig {
 	return AgentConfig{
-		APIEndpoint: os.Getenv("API_ENDPOINT"),
-		APIKey:      os.Getenv("API_KEY"),
-		ClusterID:   os.Getenv("CLUSTER_ID"),
-		Interval:    15,
+		APIEndpoint:   os.Getenv("API_ENDPOINT"),
+		APIKey:        os.Getenv("API_KEY"),
+		ClusterID:     os.Getenv("CLUSTER_ID"),
+		Interval:      15,
+		StartupSplay:  getEnvInt("STARTUP_SPLAY_SECONDS", 15),
+		JitterPercent: getEnvInt("INTERVAL_JITTER_PERCENT", 10),
+		Namespace:     getEnvString("POD_NAMESPACE", "kodo"),
+		ConfigName:    getEnvString("AGENT_CONFIG_NAME", "kodo-agent"),
+
+		CommandConcurrency: getEnvInt("COMMAND_CONCURRENCY", defaultCommandConcurrency),
+		PatchAllowedKinds:  os.Getenv("PATCH_ALLOWED_KINDS"),
+
+		PrometheusURL:     os.Getenv("PROMETHEUS_URL"),
+		PrometheusQueries: os.Getenv("PROMETHEUS_QUERIES"),
+		MeshTelemetry:     os.Getenv("MESH_TELEMETRY") == "true",
+		RBACSubjects:      os.Getenv("RBAC_SUBJECTS") == "true",
+
+		ExcludeHelmSecrets: os.Getenv("SECRETS_EXCLUDE_HELM") == "true",
+
+		ThreatSuppressions: os.Getenv("THREAT_SUPPRESSIONS"),
+		ConnectionSampling: os.Getenv("CONNECTION_SAMPLING") == "true",
+
+		SBOMEnabled:       os.Getenv("SBOM_ENABLED") == "true",
+		SBOMFormat:        getEnvString("SBOM_FORMAT", "cyclonedx"),
+		SBOMIntervalHours: getEnvInt("SBOM_INTERVAL_HOURS", 24),
+		SBOMSyftImage:     getEnvString("SBOM_SYFT_IMAGE", defaultSyftImage),
+
+		ImageVerifyKeys:            os.Getenv("IMAGE_VERIFY_KEYS"),
+		ImageVerifyIdentities:      os.Getenv("IMAGE_VERIFY_IDENTITIES"),
+		ImageVerifyRoots:           os.Getenv("IMAGE_VERIFY_ROOTS"),
+		ImageVerifyRekorKeys:       os.Getenv("IMAGE_VERIFY_REKOR_KEYS"),
+		ImageVerifyIntervalMinutes: getEnvInt("IMAGE_VERIFY_INTERVAL_MINUTES", 60),
+
+		RegistryCredentialChecks:   os.Getenv("REGISTRY_CREDENTIAL_CHECKS") == "true",
+		RegistryCheckIntervalHours: getEnvInt("REGISTRY_CHECK_INTERVAL_HOURS", 6),
+
+		NodeBenchmark: os.Getenv("NODE_BENCHMARK") == "true",
+
+		CommandEvents:      getEnvString("COMMAND_EVENTS", "true") == "true",
+		CommandAnnotations: getEnvString("COMMAND_ANNOTATIONS", "true") == "true",
+
+		GitOpsConflictPolicy: getEnvString("GITOPS_CONFLICT_POLICY", gitopsConflictRequireForce),
+		GitOpsInstanceLabel:  os.Getenv("GITOPS_ARGOCD_INSTANCE_LABEL") == "true",
+
+		ReportSchedule:           os.Getenv("REPORT_SCHEDULE"),
+		ReportStatePath:          os.Getenv("REPORT_STATE_PATH"),
+		ReportCPUCoreHourPrice:   getEnvFloat("REPORT_CPU_CORE_HOUR_PRICE", 0),
+		ReportMemoryGiBHourPrice: getEnvFloat("REPORT_MEMORY_GIB_HOUR_PRICE", 0),
+		ReportCurrency:           getEnvString("REPORT_CURRENCY", "USD"),
+
+		OfflineBufferMB:               getEnvInt("OFFLINE_BUFFER_MB", -1),
+		OfflineDownsampleAfterMinutes: getEnvInt("OFFLINE_DOWNSAMPLE_AFTER_MINUTES", 10),
+		OfflineKeepEvery:              getEnvInt("OFFLINE_KEEP_EVERY", 8),
+
+		TenantMode:    os.Getenv("TENANT_MODE"),
+		TenantRules:   os.Getenv("TENANT_RULES"),
+		TenantLabel:   os.Getenv("TENANT_LABEL"),
+		TenantDefault: getEnvString("TENANT_DEFAULT", "default"),
+		TenantAPIKeys: os.Getenv("TENANT_API_KEYS"),
+
+		TimeSeriesPath: os.Getenv("TIMESERIES_PATH"),
+
+		MemoryLimitMB:    getEnvInt("AGENT_MEMORY_LIMIT_MB", 0),
+		MemoryCapPercent: getEnvInt("AGENT_MEMORY_CAP_PERCENT", 90),
+
+		BootstrapToken:    os.Getenv("BOOTSTRAP_TOKEN"),
+		CredentialsSecret: getEnvString("CREDENTIALS_SECRET", "kodo-agent-credentials"),
+
+		CollectionPolicyConfigMap: getEnvString("COLLECTION_POLICY_CONFIGMAP", "kodo-collection-policy"),
+
+		LocalAPIAddr:  os.Getenv("LOCAL_API_ADDR"),
+		LocalAPIToken: os.Getenv("LOCAL_API_TOKEN"),
+		LocalUI:       os.Getenv("LOCAL_UI") == "true",
+
+		ChangeFeed:             os.Getenv("CHANGE_FEED") == "true",
+		ChangeFeedFlushSeconds: getEnvInt("CHANGE_FEED_FLUSH_SECONDS", 2),
+
+		EventsRelistSeconds: getEnvInt("EVENTS_RELIST_SECONDS", 60),
+
+		PodLabels:      splitList(getEnvString("POD_LABELS", "app,app.kubernetes.io/name,app.kubernetes.io/instance,app.kubernetes.io/version")),
+		PodAnnotations: splitList(os.Getenv("POD_ANNOTATIONS")),
+
+		NodeLabels: splitList(getEnvString("NODE_LABELS", "node.kubernetes.io/instance-type,topology.kubernetes.io/zone,"+
+			"node-role.kubernetes.io/master,node-role.kubernetes.io/control-plane,pool,agentpool")),
+		NodeAnnotations: splitList(os.Getenv("NODE_ANNOTATIONS")),
+		NodeTaints:      os.Getenv("NODE_TAINTS") == "true",
+
+		Output:     getEnvString("OUTPUT", outputBackend),
+		OutputPath: getEnvString("OUTPUT_PATH", "/tmp/kodo-payloads.json"),
+
+		AuthProvider:       getEnvString("AUTH_PROVIDER", authAPIKey),
+		OAuth2TokenURL:     os.Getenv("AUTH_OAUTH2_TOKEN_URL"),
+		OAuth2ClientID:     os.Getenv("AUTH_OAUTH2_CLIENT_ID"),
+		OAuth2ClientSecret: os.Getenv("AUTH_OAUTH2_CLIENT_SECRET"),
+		OAuth2Scopes:       os.Getenv("AUTH_OAUTH2_SCOPES"),
+		AWSRegion:          getEnvString("AUTH_AWS_REGION", os.Getenv("AWS_REGION")),
+		AWSService:         getEnvString("AUTH_AWS_SERVICE", "execute-api"),
+		GCPAudience:        os.Getenv("AUTH_GCP_AUDIENCE"),
+		GCPCredentialsFile: os.Getenv("AUTH_GCP_CREDENTIALS_FILE"),
+
+		HTTPTimeout:     getEnvInt("HTTP_TIMEOUT_SECONDS", 60),
+		HTTPDialTimeout: getEnvInt("HTTP_DIAL_TIMEOUT_SECONDS", 10),
+		HTTPKeepAlive:   getEnvInt("HTTP_KEEPALIVE_SECONDS", 30),
+		HTTPIdleTimeout: getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 90),
+		HTTPMaxConns:    getEnvInt("HTTP_MAX_CONNS", 4),
+	}
+}
+
+// getEnvInt reads an integer env var, falling back to def when unset or invalid
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// getEnvFloat reads a decimal env var, falling back to def when unset or invalid
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %v", key, value, def)
+		return def
+	}
+	return f
+}
+
+// getEnvString reads a string env var, falling back to def when unset
+func getEnvString(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return def
 }
 
 // ---------------------------------------------
 // MAIN
 // ---------------------------------------------
 func main() {
-	log.Printf("🚀 Kodo Agent %s starting...", AgentVersion)
+	log.Printf("🚀 Kodo Agent %s (%s) starting...", AgentVersion, gitCommit())
+
+	// "record" and "replay" are one-shot subcommands; without one the agent runs
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runSubcommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	simulate := flag.Bool("simulate", false, "run against a fake cluster seeded from --fixtures instead of the real one")
+	fixtures := flag.String("fixtures", "", "fixture YAML file or directory used by --simulate")
+	flag.Parse()
 
 	config := loadConfig()
+	applySettings(defaultSettings(config))
+	tsStore.load(config.TimeSeriesPath)
+	reports.load(config.ReportStatePath)
 
-	// Connect to Kubernetes
-	kubeconfig, err := rest.InClusterConfig()
-	if err != nil {
-		log.Fatalf("❌ Failed to load Kubernetes config: %v", err)
+	if *simulate {
+		runSimulation(config, *fixtures)
+		return
 	}
 
-	clientset, err := kubernetes.NewForConfig(kubeconfig)
-	if err != nil {
-		log.Fatalf("❌ Failed to create Kubernetes client: %v", err)
-	}
+	// Connect to Kubernetes, retrying (and reporting degraded) instead of exiting
+	clientset, kubeconfig := connectKubernetesWithRetry(config)
 
-	// Create metrics client with insecure TLS (common for local clusters)
-	metricsConfig := *kubeconfig
-	metricsConfig.TLSClientConfig.Insecure = true
-	metricsConfig.TLSClientConfig.CAData = nil
-	metricsConfig.TLSClientConfig.CAFile = ""
-	
-	metricsClient, err := metricsv.NewForConfig(&metricsConfig)
-	if err != nil {
-		log.Printf("⚠️  Failed to create Metrics client: %v", err)
-		log.Println("⚠️  Metrics API not available - will use capacity values")
-		metricsClient = nil
+	// Without pre-provisioned credentials, enroll with the bootstrap token
+	if config.dryRun() {
+		log.Printf("📝 Dry run: payloads are written to %s instead of %s", dryRunTarget(config), config.APIEndpoint)
 	} else {
-		log.Println("✅ Metrics Server client created (TLS verification disabled for local clusters)")
+		config = enrollAgent(clientset, config)
 	}
+	loadCollectionPolicy(clientset, config)
+
+	metricsClient, dynamicClient := newAuxiliaryClients(kubeconfig)
 
 	log.Println("✅ Connected to Kubernetes cluster")
 	log.Printf("📡 Sending metrics every %ds", config.Interval)
 	log.Printf("🔧 API Endpoint: %s", config.APIEndpoint)
 	log.Printf("🔧 Cluster ID: %s", config.ClusterID)
-	log.Printf("🔧 API Key: %s...%s", config.APIKey[:8], config.APIKey[len(config.APIKey)-4:])
+	log.Printf("🔧 API Key: %s", maskAPIKey(config.APIKey))
 
-	ticker := time.NewTicker(time.Duration(config.Interval) * time.Second)
+	// Intervals, collectors, namespaces and command policy can be overridden
+	// at runtime by a KuberPulseConfig
+	go watchAgentConfig(kubeconfig, config)
 
-	for {
-		select {
-		case <-ticker.C:
-			sendMetrics(clientset, metricsClient, config)
-			getCommands(clientset, config)
-		}
+	// Restarts the loops below (or the agent) when memory exceeds the cap
+	startMemoryWatchdog(config)
+
+	// Opt-in SBOMs of the running images, uploaded as artifacts on a slow schedule
+	if config.SBOMEnabled && config.SBOMIntervalHours > 0 && !config.dryRun() {
+		go runSBOMScanner(clientset, config)
+	}
+
+	// Opt-in cosign verification of the running images against trusted keys/identities
+	if config.imageVerification() && config.ImageVerifyIntervalMinutes > 0 {
+		startImageVerifier(clientset, config)
+	}
+
+	// Opt-in HEAD probes proving the referenced pull secrets still authenticate
+	if config.RegistryCredentialChecks && config.RegistryCheckIntervalHours > 0 {
+		go runCredentialChecks(clientset, config)
+	}
+
+	// Opt-in read-only API serving the latest collected data in the cluster
+	if config.LocalAPIAddr != "" {
+		startLocalAPI(config)
+	}
+
+	// The change feed and ingress detection share one informer factory, so
+	// the deployments both watch are cached once
+	factory := newInformerFactory(clientset)
+
+	// Opt-in watch of deployments/pods/nodes pushing changes between snapshots
+	if config.ChangeFeed && config.ChangeFeedFlushSeconds > 0 {
+		go runChangeFeed(factory, config)
+	}
+
+	// Ingress controller detection watches the shared informer cache and refreshes slowly
+	go runIngressDetection(clientset, factory, config)
+
+	splay := time.Duration(config.StartupSplay) * time.Second
+
+	// Metrics and command polling run on independent jittered timers so agents
+	// started together drift apart instead of hitting the backend in lockstep
+	if !config.dryRun() {
+		go runJittered("commands", func() time.Duration { return getSettings().CommandInterval }, splay, config.JitterPercent, func() {
+			getCommands(clientset, metricsClient, dynamicClient, kubeconfig, config)
+		})
 	}
+	runJittered("metrics", func() time.Duration { return getSettings().Interval }, splay, config.JitterPercent, func() {
+		sendMetrics(clientset, metricsClient, dynamicClient, config)
+	})
 }
 
 // ---------------------------------------------
 // POD DETAILS COLLECTION
 // ---------------------------------------------
-func collectPodDetails(clientset *kubernetes.Clientset) []map[string]interface{} {
-	pods, _ := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+func collectPodDetails(snap *ClusterSnapshot, config AgentConfig) []map[string]interface{} {
+	defer diagnostics.recordDuration("pod_details", time.Now())
 
 	var podDetails []map[string]interface{}
 
-	for _, pod := range pods.Items {
+	// Real per-container usage from the Kubelet (cAdvisor), when available
+	podStats := podStatsByKey(snap)
+
+	for _, pod := range snap.Pods {
 		totalRestarts := int32(0)
 		var containerStatuses []map[string]interface{}
 
+		usageByContainer := map[string]map[string]interface{}{}
+		if stats, ok := podStats[pod.Namespace+"/"+pod.Name]; ok {
+			for _, cs := range stats.Containers {
+				usageByContainer[cs.Name] = containerUsage(cs)
+			}
+		}
+
 		for _, cs := range pod.Status.ContainerStatuses {
 			totalRestarts += cs.RestartCount
-			containerStatuses = append(containerStatuses, map[string]interface{}{
-				"name":          cs.Name,
-				"ready":         cs.Ready,
-				"restart_count": cs.RestartCount,
-				"state":         getContainerState(cs.State),
-				"last_state":    getContainerState(cs.LastTerminationState),
-			})
+			containerStatus := containerStatusDetail(cs)
+			if injector := sidecarInjector(cs.Name); injector != "" {
+				containerStatus["injected_by"] = injector
+			}
+			if usage, ok := usageByContainer[cs.Name]; ok {
+				containerStatus["usage"] = usage
+			}
+			containerStatuses = append(containerStatuses, containerStatus)
+		}
+
+		// Init containers run in order before the others; a failing or hanging
+		// one is why many pods never become ready
+		sidecars := map[string]bool{}
+		for _, c := range pod.Spec.InitContainers {
+			sidecars[c.Name] = c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways
+		}
+		var initStatuses []map[string]interface{}
+		for _, cs := range pod.Status.InitContainerStatuses {
+			containerStatus := containerStatusDetail(cs)
+			containerStatus["sidecar"] = sidecars[cs.Name]
+			if injector := sidecarInjector(cs.Name); injector != "" {
+				containerStatus["injected_by"] = injector
+			}
+			if usage, ok := usageByContainer[cs.Name]; ok {
+				containerStatus["usage"] = usage
+			}
+			initStatuses = append(initStatuses, containerStatus)
+		}
+
+		// Debug containers added with kubectl debug or the debug command
+		targets := map[string]string{}
+		for _, c := range pod.Spec.EphemeralContainers {
+			targets[c.Name] = c.TargetContainerName
+		}
+		var ephemeralStatuses []map[string]interface{}
+		for _, cs := range pod.Status.EphemeralContainerStatuses {
+			containerStatus := containerStatusDetail(cs)
+			containerStatus["image"] = cs.Image
+			if target := targets[cs.Name]; target != "" {
+				containerStatus["target"] = target
+			}
+			ephemeralStatuses = append(ephemeralStatuses, containerStatus)
 		}
 
-		podDetails = append(podDetails, map[string]interface{}{
+		detail := map[string]interface{}{
 			"name":           pod.Name,
 			"namespace":      pod.Namespace,
 			"phase":          string(pod.Status.Phase),
@@ -125,14 +462,78 @@ func collectPodDetails(clientset *kubernetes.Clientset) []map[string]interface{}
 			"ready":          isPodReady(pod),
 			"containers":     containerStatuses,
 			"node":           pod.Spec.NodeName,
+			"ip_families":    podIPFamilies(pod),
 			"created_at":     pod.CreationTimestamp.Time,
 			"conditions":     getPodConditions(pod),
-		})
+			"qos_class":      string(podQOSClass(pod)),
+		}
+		// Lower priority pods are preempted first
+		if pod.Spec.Priority != nil {
+			detail["priority"] = *pod.Spec.Priority
+		}
+		if pod.Spec.PriorityClassName != "" {
+			detail["priority_class"] = pod.Spec.PriorityClassName
+		}
+		if len(initStatuses) > 0 {
+			detail["init_containers"] = initStatuses
+			if blocked := blockingInitContainer(pod, sidecars); blocked != "" {
+				detail["blocked_on_init"] = blocked
+			}
+		}
+		if len(ephemeralStatuses) > 0 {
+			detail["ephemeral_containers"] = ephemeralStatuses
+		}
+		// Requests and usage of injected sidecars apart from the app's
+		if split := podResourceSplit(pod, podStats[pod.Namespace+"/"+pod.Name]); split != nil {
+			detail["resources_split"] = split
+		}
+		// Only the configured keys, so the backend can group pods by app/team
+		if labels := selectMetadata(pod.Labels, config.PodLabels); labels != nil {
+			detail["labels"] = labels
+		}
+		if annotations := selectMetadata(pod.Annotations, config.PodAnnotations); annotations != nil {
+			detail["annotations"] = annotations
+		}
+		podDetails = append(podDetails, detail)
 	}
 
 	return podDetails
 }
 
+// containerStatusDetail is the status reported for any kind of container
+func containerStatusDetail(cs corev1.ContainerStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          cs.Name,
+		"ready":         cs.Ready,
+		"restart_count": cs.RestartCount,
+		"state":         getContainerState(cs.State),
+		"last_state":    getContainerState(cs.LastTerminationState),
+	}
+}
+
+// blockingInitContainer names the init container a not yet initialized pod
+// is waiting on: the first one that has not completed (or, for a native
+// sidecar, not started)
+func blockingInitContainer(pod corev1.Pod, sidecars map[string]bool) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodInitialized && condition.Status == corev1.ConditionTrue {
+			return ""
+		}
+	}
+	for _, cs := range pod.Status.InitContainerStatuses {
+		if sidecars[cs.Name] {
+			if cs.Started == nil || !*cs.Started {
+				return cs.Name
+			}
+			continue
+		}
+		if cs.State.Terminated == nil || cs.State.Terminated.ExitCode != 0 {
+			return cs.Name
+		}
+	}
+	return ""
+}
+
 func getContainerState(state corev1.ContainerState) map[string]interface{} {
 	if state.Running != nil {
 		return map[string]interface{}{
@@ -184,14 +585,14 @@ func getPodConditions(pod corev1.Pod) []map[string]interface{} {
 // ---------------------------------------------
 // KUBERNETES EVENTS COLLECTION
 // ---------------------------------------------
-func collectKubernetesEvents(clientset *kubernetes.Clientset) []map[string]interface{} {
-	// Get events from the last 30 minutes
-	events, _ := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
+func collectKubernetesEvents(snap *ClusterSnapshot) []map[string]interface{} {
+	defer diagnostics.recordDuration("events", time.Now())
 
+	// Get events from the last 30 minutes
 	var eventDetails []map[string]interface{}
 	thirtyMinutesAgo := time.Now().Add(-30 * time.Minute)
 
-	for _, event := range events.Items {
+	for _, event := range snap.Events {
 		// Only include recent events
 		if event.LastTimestamp.Time.Before(thirtyMinutesAgo) {
 			continue
@@ -227,14 +628,62 @@ type StatsSummary struct {
 }
 
 type NodeStats struct {
-	NodeName string   `json:"nodeName"`
-	Fs       *FsStats `json:"fs,omitempty"`
+	NodeName string       `json:"nodeName"`
+	CPU      *CPUStats    `json:"cpu,omitempty"`
+	Memory   *MemoryStats  `json:"memory,omitempty"`
+	Fs       *FsStats      `json:"fs,omitempty"`
+	Runtime  *RuntimeStats `json:"runtime,omitempty"`
+	// SystemContainers are the kubelet, runtime, misc and pods (all pods) cgroups
+	SystemContainers []ContainerStats `json:"systemContainers,omitempty"`
+}
+
+// RuntimeStats holds the container runtime filesystems (the only fs some Windows kubelets report)
+type RuntimeStats struct {
+	ImageFs *FsStats `json:"imageFs,omitempty"`
 }
 
 type PodStats struct {
-	PodRef         PodReference  `json:"podRef"`
-	VolumeStats    []VolumeStats `json:"volume,omitempty"`
-	EphemeralStorage *FsStats    `json:"ephemeral-storage,omitempty"`
+	PodRef           PodReference     `json:"podRef"`
+	Containers       []ContainerStats `json:"containers,omitempty"`
+	CPU              *CPUStats        `json:"cpu,omitempty"`
+	Memory           *MemoryStats     `json:"memory,omitempty"`
+	VolumeStats      []VolumeStats    `json:"volume,omitempty"`
+	EphemeralStorage *FsStats         `json:"ephemeral-storage,omitempty"`
+	Network          *NetworkStats    `json:"network,omitempty"`
+}
+
+// NetworkStats holds cumulative counters of the pod's network namespace (all interfaces)
+type NetworkStats struct {
+	Time     string  `json:"time,omitempty"`
+	RxBytes  *uint64 `json:"rxBytes,omitempty"`
+	RxErrors *uint64 `json:"rxErrors,omitempty"`
+	TxBytes  *uint64 `json:"txBytes,omitempty"`
+	TxErrors *uint64 `json:"txErrors,omitempty"`
+}
+
+// ContainerStats is the per-container usage reported by cAdvisor through the Kubelet
+type ContainerStats struct {
+	Name   string       `json:"name"`
+	CPU    *CPUStats    `json:"cpu,omitempty"`
+	Memory *MemoryStats `json:"memory,omitempty"`
+	Rootfs *FsStats     `json:"rootfs,omitempty"`
+	Logs   *FsStats     `json:"logs,omitempty"`
+}
+
+type CPUStats struct {
+	Time                 string  `json:"time,omitempty"`
+	UsageNanoCores       *uint64 `json:"usageNanoCores,omitempty"`
+	UsageCoreNanoSeconds *uint64 `json:"usageCoreNanoSeconds,omitempty"`
+}
+
+type MemoryStats struct {
+	Time            string  `json:"time,omitempty"`
+	AvailableBytes  *uint64 `json:"availableBytes,omitempty"`
+	UsageBytes      *uint64 `json:"usageBytes,omitempty"`
+	WorkingSetBytes *uint64 `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64 `json:"rssBytes,omitempty"`
+	PageFaults      *uint64 `json:"pageFaults,omitempty"`
+	MajorPageFaults *uint64 `json:"majorPageFaults,omitempty"`
 }
 
 type PodReference struct {
@@ -269,39 +718,165 @@ type PVCVolumeUsage struct {
 	UsedBytes      int64
 	CapacityBytes  int64
 	AvailableBytes int64
+	Inodes         map[string]interface{} // inodeUsage of the volume, nil if not reported
 }
 
-func collectPVCVolumeStats(clientset *kubernetes.Clientset) map[string]PVCVolumeUsage {
-	pvcUsage := make(map[string]PVCVolumeUsage)
-	
-	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+// kubeletStatsWorkers bounds how many nodes are queried for stats/summary at once
+const kubeletStatsWorkers = 8
+
+// fetchNodeStatsSummaries calls the Kubelet stats/summary API (and /configz,
+// cached) of every node through a bounded worker pool. Nodes that fail are
+// logged and left out of the returned maps, keyed by node name; the number of
+// nodes whose stats and configz failed is returned.
+func fetchNodeStatsSummaries(clientset kubernetes.Interface, nodes []corev1.Node) (map[string]*StatsSummary, map[string]*KubeletConfig, int, int) {
+	summaries := make(map[string]*StatsSummary, len(nodes))
+	configs := make(map[string]*KubeletConfig, len(nodes))
+	if len(nodes) == 0 {
+		return summaries, configs, 0, 0
+	}
+
+	workers := kubeletStatsWorkers
+	if len(nodes) < workers {
+		workers = len(nodes)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	nodeNames := make(chan string)
+	failed, failedConfigz := 0, 0
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for nodeName := range nodeNames {
+				summary, err := fetchNodeStatsSummary(clientset, nodeName)
+				kubeletConfig, configErr := kubeletConfigs.get(clientset, nodeName)
+
+				mu.Lock()
+				if err != nil {
+					log.Printf("⚠️  Error fetching stats from node %s: %v", nodeName, err)
+					failed++
+				} else {
+					summaries[nodeName] = summary
+				}
+				if configErr != nil {
+					log.Printf("⚠️  Error fetching configz from node %s: %v", nodeName, configErr)
+					failedConfigz++
+				} else {
+					configs[nodeName] = kubeletConfig
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, node := range nodes {
+		nodeNames <- node.Name
+	}
+	close(nodeNames)
+	wg.Wait()
+
+	return summaries, configs, failed, failedConfigz
+}
+
+// fetchNodeStatsSummary calls the Kubelet stats/summary API of one node via the API server proxy
+func fetchNodeStatsSummary(clientset kubernetes.Interface, nodeName string) (*StatsSummary, error) {
+	restClient, err := coreRESTClient(clientset)
 	if err != nil {
-		log.Printf("⚠️  Error listing nodes for PVC stats: %v", err)
-		return pvcUsage
+		return nil, err
 	}
+	ctx, cancel := apiContext()
+	defer cancel()
 
-	log.Printf("🔍 Fetching PVC volume stats from %d nodes...", len(nodes.Items))
-	
-	totalVolumes := 0
-	totalPVCVolumes := 0
+	responseBytes, err := restClient.Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, node := range nodes.Items {
-		// Call Kubelet stats/summary API via API server proxy
-		request := clientset.CoreV1().RESTClient().Get().
-			Resource("nodes").
-			Name(node.Name).
-			SubResource("proxy").
-			Suffix("stats/summary")
-
-		responseBytes, err := request.DoRaw(context.Background())
-		if err != nil {
-			log.Printf("⚠️  Error fetching stats from node %s: %v", node.Name, err)
-			continue
+	var summary StatsSummary
+	if err := json.Unmarshal(responseBytes, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse stats summary: %v", err)
+	}
+	return &summary, nil
+}
+
+// Usage sources, from most to least accurate
+const (
+	usageSourceMetricsAPI = "metrics_api"
+	usageSourceKubelet    = "kubelet"
+	usageSourceRequests   = "requests"
+)
+
+// nodeUsage returns a node's CPU (millicores) and memory (bytes) usage from
+// the Metrics API, falling back to the Kubelet stats summary and, only when
+// neither is available, to the sum of pod requests.
+func nodeUsage(node corev1.Node, nodeMetricsMap map[string]map[string]int64, snap *ClusterSnapshot) (int64, int64, string) {
+	if metrics, ok := nodeMetricsMap[node.Name]; ok {
+		return metrics["cpu"], metrics["memory"], usageSourceMetricsAPI
+	}
+	if summary, ok := snap.NodeStats[node.Name]; ok {
+		cpu, mem := summary.Node.CPU, summary.Node.Memory
+		if cpu != nil && cpu.UsageNanoCores != nil && mem != nil && mem.WorkingSetBytes != nil {
+			return int64(*cpu.UsageNanoCores / 1e6), int64(*mem.WorkingSetBytes), usageSourceKubelet
+		}
+	}
+	cpuMillis, memBytes := getPodResourcesOnNode(snap.Pods, node.Name)
+	return cpuMillis, memBytes, usageSourceRequests
+}
+
+// podStatsByKey indexes the Kubelet pod stats of all nodes by "namespace/name"
+func podStatsByKey(snap *ClusterSnapshot) map[string]*PodStats {
+	index := make(map[string]*PodStats)
+	for _, summary := range snap.NodeStats {
+		for i := range summary.Pods {
+			ref := summary.Pods[i].PodRef
+			index[ref.Namespace+"/"+ref.Name] = &summary.Pods[i]
 		}
+	}
+	return index
+}
+
+// containerUsage flattens one container's Kubelet stats (CPU in millicores,
+// memory working set, rootfs and log usage in bytes)
+func containerUsage(cs ContainerStats) map[string]interface{} {
+	usage := map[string]interface{}{}
+	if cs.CPU != nil && cs.CPU.UsageNanoCores != nil {
+		usage["cpu_millicores"] = int64(*cs.CPU.UsageNanoCores / 1e6)
+	}
+	if cs.Memory != nil {
+		if cs.Memory.WorkingSetBytes != nil {
+			usage["memory_working_set_bytes"] = *cs.Memory.WorkingSetBytes
+		}
+		if cs.Memory.RSSBytes != nil {
+			usage["memory_rss_bytes"] = *cs.Memory.RSSBytes
+		}
+	}
+	if cs.Rootfs != nil && cs.Rootfs.UsedBytes != nil {
+		usage["rootfs_used_bytes"] = *cs.Rootfs.UsedBytes
+	}
+	if cs.Logs != nil && cs.Logs.UsedBytes != nil {
+		usage["logs_used_bytes"] = *cs.Logs.UsedBytes
+	}
+	return usage
+}
+
+func collectPVCVolumeStats(snap *ClusterSnapshot) map[string]PVCVolumeUsage {
+	pvcUsage := make(map[string]PVCVolumeUsage)
+
+	log.Printf("🔍 Reading PVC volume stats from %d node summaries...", len(snap.NodeStats))
 
-		var summary StatsSummary
-		if err := json.Unmarshal(responseBytes, &summary); err != nil {
-			log.Printf("⚠️  Error parsing stats from node %s: %v", node.Name, err)
+	totalVolumes := 0
+	totalPVCVolumes := 0
+
+	for _, node := range snap.Nodes {
+		summary, ok := snap.NodeStats[node.Name]
+		if !ok {
 			continue
 		}
 
@@ -333,6 +908,7 @@ func collectPVCVolumeStats(clientset *kubernetes.Clientset) map[string]PVCVolume
 				if vol.AvailableBytes != nil {
 					usage.AvailableBytes = int64(*vol.AvailableBytes)
 				}
+				usage.Inodes = inodeUsage(&vol.FsStats)
 
 				// Log each PVC's real usage
 				if usage.UsedBytes > 0 || usage.CapacityBytes > 0 {
@@ -358,34 +934,22 @@ func collectPVCVolumeStats(clientset *kubernetes.Clientset) map[string]PVCVolume
 // ---------------------------------------------
 // PVC COLLECTION
 // ---------------------------------------------
-func collectPVCs(clientset *kubernetes.Clientset) []map[string]interface{} {
-	pvcs, err := clientset.CoreV1().PersistentVolumeClaims("").List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error collecting PVCs: %v", err)
-		return []map[string]interface{}{}
-	}
+func collectPVCs(snap *ClusterSnapshot) []map[string]interface{} {
+	defer diagnostics.recordDuration("pvcs", time.Now())
 
 	// Get real PVC usage from Kubelet
-	pvcVolumeStats := collectPVCVolumeStats(clientset)
-
-	// Get PVs to match with PVCs
-	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Warning: Could not fetch PVs: %v", err)
-	}
+	pvcVolumeStats := collectPVCVolumeStats(snap)
 
 	// Create a map of PV name to PV for quick lookup
 	pvMap := make(map[string]corev1.PersistentVolume)
 	boundPVs := make(map[string]bool) // Track which PVs are bound
-	if pvs != nil {
-		for _, pv := range pvs.Items {
-			pvMap[pv.Name] = pv
-		}
+	for _, pv := range snap.PVs {
+		pvMap[pv.Name] = pv
 	}
 
 	var pvcDetails []map[string]interface{}
 
-	for _, pvc := range pvcs.Items {
+	for _, pvc := range snap.PVCs {
 		requestedBytes := int64(0)
 		if pvc.Spec.Resources.Requests != nil {
 			if storage, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
@@ -396,6 +960,7 @@ func collectPVCs(clientset *kubernetes.Clientset) []map[string]interface{} {
 		usedBytes := int64(0)
 		capacityBytes := int64(0)
 		actualCapacity := int64(0)
+		var inodes map[string]interface{}
 		
 		// Get actual capacity from the bound PV
 		if pvc.Spec.VolumeName != "" {
@@ -411,6 +976,7 @@ func collectPVCs(clientset *kubernetes.Clientset) []map[string]interface{} {
 		if stats, exists := pvcVolumeStats[pvcKey]; exists {
 			usedBytes = stats.UsedBytes
 			capacityBytes = stats.CapacityBytes
+			inodes = stats.Inodes
 			log.Printf("📊 PVC %s: real usage = %.2f GB / %.2f GB", 
 				pvcKey, float64(usedBytes)/(1024*1024*1024), float64(capacityBytes)/(1024*1024*1024))
 		} else {
@@ -446,6 +1012,8 @@ func collectPVCs(clientset *kubernetes.Clientset) []map[string]interface{} {
 			"capacity_bytes":  capacityBytes,
 			"volume_name":     pvc.Spec.VolumeName,
 			"created_at":      pvc.CreationTimestamp.Time,
+			"inodes":          inodes,
+			"inode_pressure":  inodePressure(inodes),
 		})
 		
 		// Mark PV as bound
@@ -462,16 +1030,12 @@ func collectPVCs(clientset *kubernetes.Clientset) []map[string]interface{} {
 // ---------------------------------------------
 // STANDALONE PV COLLECTION (Released, Available, Failed)
 // ---------------------------------------------
-func collectStandalonePVs(clientset *kubernetes.Clientset) []map[string]interface{} {
-	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error collecting PVs: %v", err)
-		return []map[string]interface{}{}
-	}
+func collectStandalonePVs(snap *ClusterSnapshot) []map[string]interface{} {
+	defer diagnostics.recordDuration("standalone_pvs", time.Now())
 
 	var pvDetails []map[string]interface{}
 
-	for _, pv := range pvs.Items {
+	for _, pv := range snap.PVs {
 		// Only collect Released, Available, or Failed PVs
 		status := string(pv.Status.Phase)
 		if status != "Released" && status != "Available" && status != "Failed" {
@@ -531,19 +1095,12 @@ func collectStandalonePVs(clientset *kubernetes.Clientset) []map[string]interfac
 // ---------------------------------------------
 // STORAGE METRICS COLLECTION (from Persistent Volumes)
 // ---------------------------------------------
-func collectStorageMetrics(clientset *kubernetes.Clientset) map[string]interface{} {
-	pvs, err := clientset.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error collecting storage metrics from PVs: %v", err)
-		return map[string]interface{}{
-			"total_bytes":       int64(0),
-			"allocatable_bytes": int64(0),
-		}
-	}
+func collectStorageMetrics(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("storage", time.Now())
 
 	var totalStorage int64
 
-	for _, pv := range pvs.Items {
+	for _, pv := range snap.PVs {
 		if storage, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok {
 			totalStorage += storage.Value()
 		}
@@ -561,55 +1118,47 @@ func collectStorageMetrics(clientset *kubernetes.Clientset) map[string]interface
 // ---------------------------------------------
 // NODE STORAGE METRICS COLLECTION (Physical disk from nodes via Kubelet)
 // ---------------------------------------------
-func collectNodeStorageMetrics(clientset *kubernetes.Clientset) map[string]interface{} {
-	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error collecting node storage: %v", err)
-		return map[string]interface{}{
-			"total_physical_bytes":     int64(0),
-			"used_physical_bytes":      int64(0),
-			"available_physical_bytes": int64(0),
-			"nodes":                    []map[string]interface{}{},
-		}
-	}
+func collectNodeStorageMetrics(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("node_storage", time.Now())
 
 	var totalCapacity int64
 	var totalUsed int64
 	var totalAvailable int64
 	var nodeStorageDetails []map[string]interface{}
+	var inodePressureNodes []string
+	var imageGCPressureNodes []string
 
-	log.Printf("🔍 Fetching real storage metrics from %d nodes via Kubelet...", len(nodes.Items))
-
-	for _, node := range nodes.Items {
-		// Try to get REAL storage usage from Kubelet stats/summary API
-		request := clientset.CoreV1().RESTClient().Get().
-			Resource("nodes").
-			Name(node.Name).
-			SubResource("proxy").
-			Suffix("stats/summary")
-
-		responseBytes, err := request.DoRaw(context.Background())
+	log.Printf("🔍 Reading real storage metrics from %d node summaries...", len(snap.NodeStats))
 
+	for _, node := range snap.Nodes {
 		var nodeCapacity int64
 		var nodeUsed int64
 		var nodeAvailable int64
 		var source string
 
-		if err == nil {
-			var summary StatsSummary
-			if err := json.Unmarshal(responseBytes, &summary); err == nil && summary.Node.Fs != nil {
-				// Use REAL data from Kubelet
-				if summary.Node.Fs.CapacityBytes != nil {
-					nodeCapacity = int64(*summary.Node.Fs.CapacityBytes)
-				}
-				if summary.Node.Fs.UsedBytes != nil {
-					nodeUsed = int64(*summary.Node.Fs.UsedBytes)
-				}
-				if summary.Node.Fs.AvailableBytes != nil {
-					nodeAvailable = int64(*summary.Node.Fs.AvailableBytes)
-				}
-				source = "kubelet"
+		// Try to get REAL storage usage from the Kubelet stats/summary fetched this cycle
+		var fs *FsStats
+		summary, ok := snap.NodeStats[node.Name]
+		if ok {
+			fs = nodeFsStats(summary)
+		}
+		imageFs := imageFsUsage(node, summary, snap.NodeConfigs[node.Name])
+		if pressure, _ := imageFs["gc_pressure"].(bool); pressure {
+			imageGCPressureNodes = append(imageGCPressureNodes, node.Name)
+			log.Printf("   ⚠️  Node %s imageFs at %.1f%% (image GC starts at %d%%)",
+				node.Name, imageFs["usage_percent"], imageFs["gc_high_threshold"])
+		}
+		if fs != nil {
+			if fs.CapacityBytes != nil {
+				nodeCapacity = int64(*fs.CapacityBytes)
+			}
+			if fs.UsedBytes != nil {
+				nodeUsed = int64(*fs.UsedBytes)
+			}
+			if fs.AvailableBytes != nil {
+				nodeAvailable = int64(*fs.AvailableBytes)
 			}
+			source = "kubelet"
 		}
 
 		// Fallback to node status if Kubelet stats unavailable
@@ -634,12 +1183,21 @@ func collectNodeStorageMetrics(clientset *kubernetes.Clientset) map[string]inter
 			float64(nodeUsed)/(1024*1024*1024),
 			float64(nodeAvailable)/(1024*1024*1024))
 
+		inodes := inodeUsage(fs)
+		if inodePressure(inodes) {
+			inodePressureNodes = append(inodePressureNodes, node.Name)
+			log.Printf("   ⚠️  Node %s filesystem is at %.1f%% inode usage", node.Name, inodes["usage_percent"])
+		}
+
 		nodeStorageDetails = append(nodeStorageDetails, map[string]interface{}{
 			"node_name":         node.Name,
 			"capacity_bytes":    nodeCapacity,
 			"used_bytes":        nodeUsed,
 			"available_bytes":   nodeAvailable,
 			"source":            source,
+			"os":                nodeOS(node),
+			"inodes":            inodes,
+			"image_fs":          imageFs,
 		})
 	}
 
@@ -647,631 +1205,21 @@ func collectNodeStorageMetrics(clientset *kubernetes.Clientset) map[string]inter
 		float64(totalCapacity)/(1024*1024*1024),
 		float64(totalUsed)/(1024*1024*1024),
 		float64(totalAvailable)/(1024*1024*1024),
-		len(nodes.Items))
+		len(snap.Nodes))
 
 	return map[string]interface{}{
 		"total_physical_bytes":     totalCapacity,
 		"used_physical_bytes":      totalUsed,
 		"available_physical_bytes": totalAvailable,
 		"nodes":                    nodeStorageDetails,
+		"inode_pressure_nodes":     inodePressureNodes,
+		"inode_warn_percent":       inodeUsageWarnPercent,
+		"image_gc_pressure_nodes":  imageGCPressureNodes,
 	}
 }
 
-// ---------------------------------------------
-// SECURITY DATA COLLECTION
-// ---------------------------------------------
-func collectSecurityData(clientset *kubernetes.Clientset) map[string]interface{} {
-	ctx := context.Background()
-	
-	// Initialize RBAC data
-	rbacData := map[string]interface{}{
-		"cluster_roles_count":          0,
-		"cluster_role_bindings_count":  0,
-		"roles_count":                  0,
-		"role_bindings_count":          0,
-		"has_rbac":                     false,
-		"cluster_roles":                []string{},
-	}
-	
-	// Initialize security data with all fields
-	securityData := map[string]interface{}{
-		"rbac":               rbacData,
-		"network_policies":   map[string]interface{}{},
-		"secrets":            map[string]interface{}{},
-		"resource_quotas":    map[string]interface{}{},
-		"limit_ranges":       map[string]interface{}{},
-		"pod_security":       map[string]interface{}{},
-		"ingress_controller": map[string]interface{}{},
-	}
-
-	// 1. Collect RBAC data (ClusterRoles, ClusterRoleBindings, Roles, RoleBindings)
-	log.Printf("🔍 Collecting RBAC data...")
-	clusterRolesCount := 0
-	clusterRoleBindingsCount := 0
-	
-	log.Printf("🔍 Attempting to list ClusterRoles...")
-	clusterRoles, err := clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("❌ ERROR listing ClusterRoles: %v", err)
-	} else {
-		clusterRolesCount = len(clusterRoles.Items)
-		// Only store first 50 names to avoid huge payloads
-		maxRolesToStore := 50
-		if clusterRolesCount < maxRolesToStore {
-			maxRolesToStore = clusterRolesCount
-		}
-		roleNames := make([]string, 0, maxRolesToStore)
-		for i, cr := range clusterRoles.Items {
-			if i < maxRolesToStore {
-				roleNames = append(roleNames, cr.Name)
-			}
-		}
-		rbacData["cluster_roles_count"] = clusterRolesCount
-		rbacData["cluster_roles"] = roleNames
-		log.Printf("✅ Found %d ClusterRoles (storing %d names)", clusterRolesCount, len(roleNames))
-	}
-
-	log.Printf("🔍 Attempting to list ClusterRoleBindings...")
-	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("❌ ERROR listing ClusterRoleBindings: %v", err)
-	} else {
-		clusterRoleBindingsCount = len(clusterRoleBindings.Items)
-		rbacData["cluster_role_bindings_count"] = clusterRoleBindingsCount
-		log.Printf("✅ Found %d ClusterRoleBindings", clusterRoleBindingsCount)
-	}
-
-	// Count roles and rolebindings across namespaces
-	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error listing Namespaces: %v", err)
-		namespaces = &corev1.NamespaceList{}
-	} else {
-		log.Printf("✅ Found %d namespaces to scan", len(namespaces.Items))
-	}
-	
-	totalRoles := 0
-	totalRoleBindings := 0
-	rolesByNamespace := make(map[string]int)
-	
-	for _, ns := range namespaces.Items {
-		roles, err := clientset.RbacV1().Roles(ns.Name).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			log.Printf("⚠️  Error listing Roles in namespace %s: %v", ns.Name, err)
-		} else {
-			roleCount := len(roles.Items)
-			totalRoles += roleCount
-			if roleCount > 0 {
-				rolesByNamespace[ns.Name] = roleCount
-			}
-		}
-		roleBindings, err := clientset.RbacV1().RoleBindings(ns.Name).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			log.Printf("⚠️  Error listing RoleBindings in namespace %s: %v", ns.Name, err)
-		} else {
-			totalRoleBindings += len(roleBindings.Items)
-		}
-	}
-	
-	hasRbac := (clusterRolesCount > 0 || clusterRoleBindingsCount > 0 || totalRoles > 0 || totalRoleBindings > 0)
-	log.Printf("📊 RBAC scan complete: %d ClusterRoles, %d ClusterRoleBindings, %d Roles, %d RoleBindings, has_rbac=%v", 
-		clusterRolesCount, clusterRoleBindingsCount, totalRoles, totalRoleBindings, hasRbac)
-	
-	if len(rolesByNamespace) > 0 {
-		log.Printf("📋 Roles by namespace: %v", rolesByNamespace)
-	}
-	
-	// Update RBAC data with all counts
-	rbacData["roles_count"] = totalRoles
-	rbacData["role_bindings_count"] = totalRoleBindings
-	rbacData["roles_by_namespace"] = rolesByNamespace
-	rbacData["has_rbac"] = hasRbac
-	
-	// Update the security data with the complete RBAC data
-	securityData["rbac"] = rbacData
-	
-	// Debug: Print final RBAC data
-	log.Printf("🔒 Final RBAC data: cluster_roles=%d, cluster_role_bindings=%d, roles=%d, role_bindings=%d, has_rbac=%v",
-		rbacData["cluster_roles_count"], rbacData["cluster_role_bindings_count"], 
-		rbacData["roles_count"], rbacData["role_bindings_count"], rbacData["has_rbac"])
-
-	// 2. Collect NetworkPolicies - iterate through ALL namespaces
-	networkPoliciesData := map[string]interface{}{
-		"total_count":              0,
-		"namespaces_with_policies": 0,
-		"has_network_policies":     false,
-		"policies":                 []map[string]interface{}{},
-	}
-	
-	totalNetworkPolicies := 0
-	namespacesWithPolicies := 0
-	networkPolicyDetails := []map[string]interface{}{}
-	
-	log.Printf("🔍 Scanning NetworkPolicies in %d namespaces...", len(namespaces.Items))
-	for _, ns := range namespaces.Items {
-		netPolicies, err := clientset.NetworkingV1().NetworkPolicies(ns.Name).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			log.Printf("⚠️  Error listing NetworkPolicies in namespace %s: %v", ns.Name, err)
-			continue
-		}
-		if len(netPolicies.Items) > 0 {
-			totalNetworkPolicies += len(netPolicies.Items)
-			namespacesWithPolicies++
-			// Store details for each namespace with policies
-			for _, np := range netPolicies.Items {
-				networkPolicyDetails = append(networkPolicyDetails, map[string]interface{}{
-					"name":      np.Name,
-					"namespace": np.Namespace,
-				})
-			}
-			log.Printf("✅ Found %d NetworkPolicies in namespace: %s", len(netPolicies.Items), ns.Name)
-		}
-	}
-	log.Printf("📊 NetworkPolicies scan complete: found %d policies in %d namespaces", totalNetworkPolicies, namespacesWithPolicies)
-	
-	networkPoliciesData["total_count"] = totalNetworkPolicies
-	networkPoliciesData["namespaces_with_policies"] = namespacesWithPolicies
-	networkPoliciesData["has_network_policies"] = totalNetworkPolicies > 0
-	networkPoliciesData["policies"] = networkPolicyDetails
-	securityData["network_policies"] = networkPoliciesData
-
-	// 3. Collect Secrets info (count only, not content)
-	secretsData := map[string]interface{}{
-		"total_count": 0,
-		"types":       map[string]int{},
-		"has_secrets": false,
-	}
-	
-	log.Printf("🔍 Collecting Secrets data from %d namespaces...", len(namespaces.Items))
-	totalSecrets := 0
-	secretTypes := make(map[string]int)
-	secretsByNamespace := make(map[string]int)
-	for _, ns := range namespaces.Items {
-		secrets, err := clientset.CoreV1().Secrets(ns.Name).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			log.Printf("❌ ERROR listing Secrets in namespace %s: %v", ns.Name, err)
-			continue
-		}
-		secretCount := len(secrets.Items)
-		totalSecrets += secretCount
-		if secretCount > 0 {
-			secretsByNamespace[ns.Name] = secretCount
-		}
-		for _, s := range secrets.Items {
-			secretTypes[string(s.Type)]++
-		}
-	}
-	log.Printf("✅ Secrets scan complete: found %d secrets across namespaces", totalSecrets)
-	if len(secretsByNamespace) > 0 {
-		log.Printf("📋 Secrets by namespace: %v", secretsByNamespace)
-	}
-	
-	secretsData["total_count"] = totalSecrets
-	secretsData["types"] = secretTypes
-	secretsData["has_secrets"] = totalSecrets > 0
-	secretsData["by_namespace"] = secretsByNamespace
-	securityData["secrets"] = secretsData
-
-	// 4. Collect ResourceQuotas
-	resourceQuotasData := map[string]interface{}{
-		"total_count": 0,
-		"has_quotas":  false,
-	}
-	
-	log.Printf("🔍 Collecting ResourceQuotas...")
-	totalQuotas := 0
-	for _, ns := range namespaces.Items {
-		quotas, err := clientset.CoreV1().ResourceQuotas(ns.Name).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			log.Printf("⚠️  Error listing ResourceQuotas in namespace %s: %v", ns.Name, err)
-			continue
-		}
-		totalQuotas += len(quotas.Items)
-	}
-	log.Printf("📊 ResourceQuotas scan complete: found %d quotas", totalQuotas)
-	
-	resourceQuotasData["total_count"] = totalQuotas
-	resourceQuotasData["has_quotas"] = totalQuotas > 0
-	securityData["resource_quotas"] = resourceQuotasData
-
-	// 5. Collect LimitRanges
-	limitRangesData := map[string]interface{}{
-		"total_count":      0,
-		"has_limit_ranges": false,
-	}
-	
-	totalLimitRanges := 0
-	for _, ns := range namespaces.Items {
-		limitRanges, err := clientset.CoreV1().LimitRanges(ns.Name).List(ctx, metav1.ListOptions{})
-		if err == nil {
-			totalLimitRanges += len(limitRanges.Items)
-		}
-	}
-	
-	limitRangesData["total_count"] = totalLimitRanges
-	limitRangesData["has_limit_ranges"] = totalLimitRanges > 0
-	securityData["limit_ranges"] = limitRangesData
-
-	// 6. Analyze Pod Security (containers running as root, privileged, etc.)
-	podSecurityData := map[string]interface{}{
-		"total_pods":                   0,
-		"pods_with_security_context":  0,
-		"pods_running_as_non_root":    0,
-		"pods_with_resource_limits":   0,
-		"privileged_containers":       0,
-		"has_pod_security":            false,
-		"security_context_percentage": float64(0),
-		"resource_limits_percentage":  float64(0),
-	}
-	
-	pods, _ := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
-	podsWithSecurityContext := 0
-	podsRunningAsNonRoot := 0
-	podsWithResourceLimits := 0
-	privilegedContainers := 0
-
-	for _, pod := range pods.Items {
-		hasSecurityContext := false
-		isNonRoot := false
-		hasLimits := false
-
-		// Check pod-level security context
-		if pod.Spec.SecurityContext != nil {
-			hasSecurityContext = true
-			if pod.Spec.SecurityContext.RunAsNonRoot != nil && *pod.Spec.SecurityContext.RunAsNonRoot {
-				isNonRoot = true
-			}
-		}
-
-		// Check container-level settings
-		for _, container := range pod.Spec.Containers {
-			if container.SecurityContext != nil {
-				hasSecurityContext = true
-				if container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
-					privilegedContainers++
-				}
-				if container.SecurityContext.RunAsNonRoot != nil && *container.SecurityContext.RunAsNonRoot {
-					isNonRoot = true
-				}
-			}
-			if container.Resources.Limits != nil && len(container.Resources.Limits) > 0 {
-				hasLimits = true
-			}
-		}
-
-		if hasSecurityContext {
-			podsWithSecurityContext++
-		}
-		if isNonRoot {
-			podsRunningAsNonRoot++
-		}
-		if hasLimits {
-			podsWithResourceLimits++
-		}
-	}
-
-	totalPods := len(pods.Items)
-	podSecurityData["total_pods"] = totalPods
-	podSecurityData["pods_with_security_context"] = podsWithSecurityContext
-	podSecurityData["pods_running_as_non_root"] = podsRunningAsNonRoot
-	podSecurityData["pods_with_resource_limits"] = podsWithResourceLimits
-	podSecurityData["privileged_containers"] = privilegedContainers
-	podSecurityData["has_pod_security"] = podsWithSecurityContext > 0
-
-	// Calculate percentages
-	if totalPods > 0 {
-		podSecurityData["security_context_percentage"] = float64(podsWithSecurityContext) / float64(totalPods) * 100
-		podSecurityData["resource_limits_percentage"] = float64(podsWithResourceLimits) / float64(totalPods) * 100
-	}
-	securityData["pod_security"] = podSecurityData
-
-	// 7. Detect Ingress Controller and verify its RBAC
-	log.Printf("🔍 Detecting Ingress Controller...")
-	ingressControllerInfo := detectIngressController(clientset, ctx)
-	securityData["ingress_controller"] = ingressControllerInfo
-
-	log.Printf("🔒 Security data collected: RBAC=%v, NetworkPolicies=%d, Secrets=%d, Quotas=%d, LimitRanges=%d, PodsWithLimits=%d/%d, IngressController=%s",
-		securityData["rbac"].(map[string]interface{})["has_rbac"],
-		totalNetworkPolicies,
-		totalSecrets,
-		totalQuotas,
-		totalLimitRanges,
-		podsWithResourceLimits,
-		totalPods,
-		ingressControllerInfo["type"])
-
-	return securityData
-}
-
-// detectIngressController identifies the ingress controller type and checks its RBAC configuration
-func detectIngressController(clientset *kubernetes.Clientset, ctx context.Context) map[string]interface{} {
-	result := map[string]interface{}{
-		"type":             "unknown",
-		"detected":         false,
-		"namespace":        "",
-		"has_rbac":         false,
-		"rbac_details":     map[string]interface{}{},
-		"deployment_name":  "",
-		"service_account":  "",
-		"version":          "",
-	}
-
-	// Common ingress controller identifiers with more label options
-	ingressControllers := []struct {
-		name           string
-		labelSelectors []string
-		namespaces     []string
-		namePatterns   []string // Deployment/DaemonSet name patterns to search
-	}{
-		{
-			name:           "nginx",
-			labelSelectors: []string{"app.kubernetes.io/name=ingress-nginx", "app=ingress-nginx", "app.kubernetes.io/component=controller", "app=nginx-ingress"},
-			namespaces:     []string{"ingress-nginx", "nginx-ingress", "kube-system", "default"},
-			namePatterns:   []string{"ingress-nginx", "nginx-ingress", "nginx-controller"},
-		},
-		{
-			name:           "traefik",
-			labelSelectors: []string{"app.kubernetes.io/name=traefik", "app=traefik", "app.kubernetes.io/instance=traefik"},
-			namespaces:     []string{"traefik", "traefik-system", "kube-system", "default"},
-			namePatterns:   []string{"traefik"},
-		},
-		{
-			name:           "haproxy",
-			labelSelectors: []string{"app.kubernetes.io/name=haproxy-ingress", "app=haproxy-ingress", "app=haproxy"},
-			namespaces:     []string{"haproxy-controller", "haproxy-ingress", "kube-system"},
-			namePatterns:   []string{"haproxy"},
-		},
-		{
-			name:           "kong",
-			labelSelectors: []string{"app.kubernetes.io/name=kong", "app=kong", "app.kubernetes.io/instance=kong"},
-			namespaces:     []string{"kong", "kong-system", "kube-system"},
-			namePatterns:   []string{"kong"},
-		},
-		{
-			name:           "istio",
-			labelSelectors: []string{"app=istiod", "istio=ingressgateway", "app=istio-ingressgateway"},
-			namespaces:     []string{"istio-system", "istio-ingress"},
-			namePatterns:   []string{"istiod", "istio-ingressgateway"},
-		},
-		{
-			name:           "contour",
-			labelSelectors: []string{"app.kubernetes.io/name=contour", "app=contour", "app=envoy"},
-			namespaces:     []string{"projectcontour", "contour", "kube-system"},
-			namePatterns:   []string{"contour", "envoy"},
-		},
-		{
-			name:           "ambassador",
-			labelSelectors: []string{"app.kubernetes.io/name=ambassador", "app=ambassador", "product=aes"},
-			namespaces:     []string{"ambassador", "emissary", "kube-system"},
-			namePatterns:   []string{"ambassador", "emissary"},
-		},
-		{
-			name:           "aws-alb",
-			labelSelectors: []string{"app.kubernetes.io/name=aws-load-balancer-controller"},
-			namespaces:     []string{"kube-system"},
-			namePatterns:   []string{"aws-load-balancer-controller"},
-		},
-	}
-
-	// First, check by label selectors
-	log.Printf("🔍 Checking ingress controllers by labels...")
-	for _, ic := range ingressControllers {
-		for _, ns := range ic.namespaces {
-			for _, labelSelector := range ic.labelSelectors {
-				// Check for Deployments
-				deployments, err := clientset.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{
-					LabelSelector: labelSelector,
-				})
-				if err == nil && len(deployments.Items) > 0 {
-					deploy := deployments.Items[0]
-					result["type"] = ic.name
-					result["detected"] = true
-					result["namespace"] = ns
-					result["deployment_name"] = deploy.Name
-					
-					if deploy.Spec.Template.Spec.ServiceAccountName != "" {
-						result["service_account"] = deploy.Spec.Template.Spec.ServiceAccountName
-					}
-					
-					if len(deploy.Spec.Template.Spec.Containers) > 0 {
-						result["version"] = deploy.Spec.Template.Spec.Containers[0].Image
-					}
-					
-					log.Printf("✅ Detected %s ingress controller in namespace %s (deployment: %s, label: %s)", ic.name, ns, deploy.Name, labelSelector)
-					
-					rbacDetails := checkIngressControllerRBAC(clientset, ctx, ns, result["service_account"].(string), ic.name)
-					result["has_rbac"] = rbacDetails["has_proper_rbac"]
-					result["rbac_details"] = rbacDetails
-					
-					return result
-				}
-				
-				// Check DaemonSets
-				daemonsets, err := clientset.AppsV1().DaemonSets(ns).List(ctx, metav1.ListOptions{
-					LabelSelector: labelSelector,
-				})
-				if err == nil && len(daemonsets.Items) > 0 {
-					ds := daemonsets.Items[0]
-					result["type"] = ic.name
-					result["detected"] = true
-					result["namespace"] = ns
-					result["deployment_name"] = ds.Name + " (DaemonSet)"
-					
-					if ds.Spec.Template.Spec.ServiceAccountName != "" {
-						result["service_account"] = ds.Spec.Template.Spec.ServiceAccountName
-					}
-					
-					if len(ds.Spec.Template.Spec.Containers) > 0 {
-						result["version"] = ds.Spec.Template.Spec.Containers[0].Image
-					}
-					
-					log.Printf("✅ Detected %s ingress controller (DaemonSet) in namespace %s", ic.name, ns)
-					
-					rbacDetails := checkIngressControllerRBAC(clientset, ctx, ns, result["service_account"].(string), ic.name)
-					result["has_rbac"] = rbacDetails["has_proper_rbac"]
-					result["rbac_details"] = rbacDetails
-					
-					return result
-				}
-			}
-		}
-	}
-
-	// Second, search by deployment/daemonset name patterns across all namespaces
-	log.Printf("🔍 Checking ingress controllers by name patterns...")
-	allNamespaces, _ := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
-	for _, ic := range ingressControllers {
-		for _, ns := range allNamespaces.Items {
-			// Get all deployments in namespace
-			deployments, err := clientset.AppsV1().Deployments(ns.Name).List(ctx, metav1.ListOptions{})
-			if err == nil {
-				for _, deploy := range deployments.Items {
-					for _, pattern := range ic.namePatterns {
-						if strings.Contains(strings.ToLower(deploy.Name), pattern) {
-							result["type"] = ic.name
-							result["detected"] = true
-							result["namespace"] = ns.Name
-							result["deployment_name"] = deploy.Name
-							
-							if deploy.Spec.Template.Spec.ServiceAccountName != "" {
-								result["service_account"] = deploy.Spec.Template.Spec.ServiceAccountName
-							}
-							
-							if len(deploy.Spec.Template.Spec.Containers) > 0 {
-								result["version"] = deploy.Spec.Template.Spec.Containers[0].Image
-							}
-							
-							log.Printf("✅ Detected %s ingress controller by name pattern in namespace %s (deployment: %s)", ic.name, ns.Name, deploy.Name)
-							
-							rbacDetails := checkIngressControllerRBAC(clientset, ctx, ns.Name, result["service_account"].(string), ic.name)
-							result["has_rbac"] = rbacDetails["has_proper_rbac"]
-							result["rbac_details"] = rbacDetails
-							
-							return result
-						}
-					}
-				}
-			}
-			
-			// Get all daemonsets in namespace
-			daemonsets, err := clientset.AppsV1().DaemonSets(ns.Name).List(ctx, metav1.ListOptions{})
-			if err == nil {
-				for _, ds := range daemonsets.Items {
-					for _, pattern := range ic.namePatterns {
-						if strings.Contains(strings.ToLower(ds.Name), pattern) {
-							result["type"] = ic.name
-							result["detected"] = true
-							result["namespace"] = ns.Name
-							result["deployment_name"] = ds.Name + " (DaemonSet)"
-							
-							if ds.Spec.Template.Spec.ServiceAccountName != "" {
-								result["service_account"] = ds.Spec.Template.Spec.ServiceAccountName
-							}
-							
-							if len(ds.Spec.Template.Spec.Containers) > 0 {
-								result["version"] = ds.Spec.Template.Spec.Containers[0].Image
-							}
-							
-							log.Printf("✅ Detected %s ingress controller (DaemonSet) by name pattern in namespace %s", ic.name, ns.Name)
-							
-							rbacDetails := checkIngressControllerRBAC(clientset, ctx, ns.Name, result["service_account"].(string), ic.name)
-							result["has_rbac"] = rbacDetails["has_proper_rbac"]
-							result["rbac_details"] = rbacDetails
-							
-							return result
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// Third, check IngressClass resources
-	log.Printf("🔍 Checking IngressClass resources...")
-	ingressClasses, err := clientset.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
-	if err == nil && len(ingressClasses.Items) > 0 {
-		for _, ic := range ingressClasses.Items {
-			controllerName := ic.Spec.Controller
-			log.Printf("📋 Found IngressClass: %s with controller: %s", ic.Name, controllerName)
-			
-			controllerLower := strings.ToLower(controllerName)
-			if strings.Contains(controllerLower, "nginx") {
-				result["type"] = "nginx"
-			} else if strings.Contains(controllerLower, "traefik") {
-				result["type"] = "traefik"
-			} else if strings.Contains(controllerLower, "haproxy") {
-				result["type"] = "haproxy"
-			} else if strings.Contains(controllerLower, "kong") {
-				result["type"] = "kong"
-			} else if strings.Contains(controllerLower, "istio") {
-				result["type"] = "istio"
-			} else if strings.Contains(controllerLower, "contour") {
-				result["type"] = "contour"
-			} else if strings.Contains(controllerLower, "ambassador") || strings.Contains(controllerLower, "emissary") {
-				result["type"] = "ambassador"
-			} else if strings.Contains(controllerLower, "alb") || strings.Contains(controllerLower, "aws") {
-				result["type"] = "aws-alb"
-			} else {
-				result["type"] = controllerName
-			}
-			result["detected"] = true
-			result["deployment_name"] = ic.Name + " (IngressClass)"
-			
-			log.Printf("✅ Detected ingress controller from IngressClass: %s -> %s", ic.Name, result["type"])
-			break
-		}
-	}
-
-	// Fourth, check Ingress resources to infer controller
-	if !result["detected"].(bool) {
-		log.Printf("🔍 Checking existing Ingress resources...")
-		ingresses, err := clientset.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
-		if err == nil && len(ingresses.Items) > 0 {
-			for _, ing := range ingresses.Items {
-				// Check annotations for controller hints
-				if className, ok := ing.Annotations["kubernetes.io/ingress.class"]; ok {
-					log.Printf("📋 Found Ingress %s/%s with class annotation: %s", ing.Namespace, ing.Name, className)
-					classLower := strings.ToLower(className)
-					if strings.Contains(classLower, "nginx") {
-						result["type"] = "nginx"
-					} else if strings.Contains(classLower, "traefik") {
-						result["type"] = "traefik"
-					} else {
-						result["type"] = className
-					}
-					result["detected"] = true
-					result["deployment_name"] = className + " (from annotation)"
-					break
-				}
-				
-				// Check spec.ingressClassName
-				if ing.Spec.IngressClassName != nil {
-					log.Printf("📋 Found Ingress %s/%s with ingressClassName: %s", ing.Namespace, ing.Name, *ing.Spec.IngressClassName)
-					classLower := strings.ToLower(*ing.Spec.IngressClassName)
-					if strings.Contains(classLower, "nginx") {
-						result["type"] = "nginx"
-					} else if strings.Contains(classLower, "traefik") {
-						result["type"] = "traefik"
-					} else {
-						result["type"] = *ing.Spec.IngressClassName
-					}
-					result["detected"] = true
-					result["deployment_name"] = *ing.Spec.IngressClassName + " (from spec)"
-					break
-				}
-			}
-		}
-	}
-
-	if !result["detected"].(bool) {
-		log.Printf("⚠️ No ingress controller detected after all checks")
-	}
-
-	return result
-}
-
 // checkIngressControllerRBAC verifies RBAC configuration for the ingress controller
-func checkIngressControllerRBAC(clientset *kubernetes.Clientset, ctx context.Context, namespace, serviceAccount, controllerType string) map[string]interface{} {
+func checkIngressControllerRBAC(clientset kubernetes.Interface, namespace, serviceAccount, controllerType string) map[string]interface{} {
 	rbacDetails := map[string]interface{}{
 		"has_proper_rbac":         false,
 		"cluster_role":            "",
@@ -1288,7 +1236,9 @@ func checkIngressControllerRBAC(clientset *kubernetes.Clientset, ctx context.Con
 	}
 
 	// Check ClusterRoleBindings for this service account
+	ctx, cancel := apiContext()
 	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	cancel()
 	if err != nil {
 		log.Printf("⚠️ Error listing ClusterRoleBindings: %v", err)
 		return rbacDetails
@@ -1303,7 +1253,9 @@ func checkIngressControllerRBAC(clientset *kubernetes.Clientset, ctx context.Con
 				rbacDetails["cluster_role"] = crb.RoleRef.Name
 				
 				// Verify the ClusterRole has required permissions
+				ctx, cancel := apiContext()
 				clusterRole, err := clientset.RbacV1().ClusterRoles().Get(ctx, crb.RoleRef.Name, metav1.GetOptions{})
+				cancel()
 				if err == nil {
 					missingPerms := checkRequiredPermissions(clusterRole.Rules, controllerType)
 					rbacDetails["missing_permissions"] = missingPerms
@@ -1320,7 +1272,9 @@ func checkIngressControllerRBAC(clientset *kubernetes.Clientset, ctx context.Con
 	}
 
 	// Check namespace-scoped RoleBindings as well
+	ctx, cancel = apiContext()
 	roleBindings, err := clientset.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
+	cancel()
 	if err == nil {
 		for _, rb := range roleBindings.Items {
 			for _, subject := range rb.Subjects {
@@ -1422,11 +1376,33 @@ func getPodResourcesOnNode(pods []corev1.Pod, nodeName string) (cpuMillis int64,
 // ---------------------------------------------
 // MÉTRICAS
 // ---------------------------------------------
-func sendMetrics(clientset *kubernetes.Clientset, metricsClient *metricsv.Clientset, config AgentConfig) {
+func sendMetrics(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, config AgentConfig) {
+	if _, err := collectAndSendMetrics(clientset, metricsClient, dynamicClient, config, nil); err != nil {
+		log.Printf("❌ Error sending metrics: %v", err)
+		return
+	}
+	if config.dryRun() {
+		log.Printf("📝 Metrics written to %s", dryRunTarget(config))
+		return
+	}
+	log.Println("✅ Metrics sent successfully")
+}
+
+// collectAndSendMetrics runs one collection cycle and posts the result. When
+// only is non-nil, just the metric types it contains are collected. It
+// returns the metric types that were sent.
+func collectAndSendMetrics(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, config AgentConfig, only map[string]bool) ([]string, error) {
 	log.Println("📊 Collecting metrics...")
+	defer diagnostics.recordDuration("cycle", time.Now())
+
+	// Settings are read once so a config change never splits a cycle
+	settings := getSettings()
+	refreshCapabilities(config)
 
-	nodes, _ := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
-	pods, _ := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	// List shared resources once and hand the snapshot to every collector
+	snap := buildClusterSnapshot(clientset, config, settings)
+	clockSkew.measureAPIServer(clientset)
+	updateTenants(snap.Namespaces, settings.Tenants)
 
 	// Calcular métricas agregadas
 	var totalCPU, totalMemory, usedCPU, usedMemory int64
@@ -1434,8 +1410,11 @@ func sendMetrics(clientset *kubernetes.Clientset, metricsClient *metricsv.Client
 
 	// Tentar obter métricas reais da Metrics API
 	var nodeMetricsMap map[string]map[string]int64
+	var metricsErr error
 	if metricsClient != nil {
-		nodeMetricsList, err := metricsClient.MetricsV1beta1().NodeMetricses().List(context.Background(), metav1.ListOptions{})
+		ctx, cancel := apiContext()
+		nodeMetricsList, err := metricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+		cancel()
 		if err == nil {
 			nodeMetricsMap = make(map[string]map[string]int64)
 			for _, nm := range nodeMetricsList.Items {
@@ -1447,193 +1426,545 @@ func sendMetrics(clientset *kubernetes.Clientset, metricsClient *metricsv.Client
 			log.Printf("✅ Fetched real metrics for %d nodes from Metrics API", len(nodeMetricsMap))
 		} else {
 			log.Printf("⚠️  Metrics API unavailable: %v", err)
+			metricsErr = err
 		}
 	}
 
-	for _, node := range nodes.Items {
+	usageSources := map[string]int{}
+	for _, node := range snap.Nodes {
 		cpu := node.Status.Capacity.Cpu().MilliValue()
 		mem := node.Status.Capacity.Memory().Value()
 		totalCPU += cpu
 		totalMemory += mem
 
-		// Usar métricas reais da Metrics API se disponível
-		if metrics, ok := nodeMetricsMap[node.Name]; ok {
-			usedCPU += metrics["cpu"]
-			usedMemory += metrics["memory"]
-		} else {
-			// Fallback: estimar baseado em requests dos pods no node
-			nodePodsCPU, nodePodsMem := getPodResourcesOnNode(pods.Items, node.Name)
-			usedCPU += nodePodsCPU
-			usedMemory += nodePodsMem
-		}
+		// Metrics API, depois Kubelet (cAdvisor), e só então requests dos pods
+		nodeCPU, nodeMem, source := nodeUsage(node, nodeMetricsMap, snap)
+		usedCPU += nodeCPU
+		usedMemory += nodeMem
+		usageSources[source]++
+	}
+	if usageSources[usageSourceRequests] > 0 {
+		log.Printf("⚠️  Usage estimated from pod requests on %d nodes (no Metrics API or Kubelet stats)", usageSources[usageSourceRequests])
+	}
+
+	for _, pod := range snap.Pods {
+		if pod.Status.Phase == corev1.PodRunning {
+			runningPods++
+		}
+	}
+
+	cpuPercent := float64(0)
+	if totalCPU > 0 {
+		cpuPercent = float64(usedCPU) / float64(totalCPU) * 100
+	}
+
+	memoryPercent := float64(0)
+	if totalMemory > 0 {
+		memoryPercent = float64(usedMemory) / float64(totalMemory) * 100
+	}
+
+	// Status per section: failed when its primary source could not be listed,
+	// partial when a secondary source (Metrics API, kubelet stats...) failed
+	nodesStatus := &CollectorStatus{}
+	nodesStatus.Requires(snap, "nodes")
+	nodesStatus.Uses(snap, "pods")
+	if metricsErr != nil {
+		nodesStatus.Partial("metrics_api", metricsErr)
+	}
+
+	podsStatus := &CollectorStatus{}
+	podsStatus.Requires(snap, "pods")
+
+	eventsStatus := &CollectorStatus{}
+	eventsStatus.Requires(snap, "events")
+
+	pvcsStatus := &CollectorStatus{}
+	pvcsStatus.Requires(snap, "pvcs")
+	pvcsStatus.Uses(snap, "pvs", "kubelet_stats")
+
+	pvsStatus := &CollectorStatus{}
+	pvsStatus.Requires(snap, "pvs")
+
+	nodeStorageStatus := &CollectorStatus{}
+	nodeStorageStatus.Requires(snap, "nodes")
+	nodeStorageStatus.Uses(snap, "kubelet_stats", "kubelet_configz")
+
+	securityStatus := &CollectorStatus{}
+
+	threatsStatus := &CollectorStatus{}
+	threatsStatus.Requires(snap, "pods")
+	threatsStatus.Uses(snap, "events", "services")
+
+	var metrics []map[string]interface{}
+	var sentTypes []string
+	attempted := map[string]bool{}
+	add := func(metricType string, status *CollectorStatus, collect func() map[string]interface{}) {
+		if only != nil && !only[metricType] {
+			return
+		}
+		attempted[metricType] = true
+		if only == nil && !settings.CollectorEnabled(metricType) {
+			collectorHealth.skip(metricType, "disabled by settings", snap.TakenAt)
+			return
+		}
+		if only == nil {
+			if reason := collectionPolicy.skipReason(metricType, snap.TakenAt); reason != "" {
+				collectorHealth.skip(metricType, reason, snap.TakenAt)
+				return
+			}
+			if reason := slowCollectors.skipReason(metricType, snap.TakenAt); reason != "" {
+				collectorHealth.skip(metricType, reason, snap.TakenAt)
+				return
+			}
+		}
+		// Types the backend does not accept are not even collected
+		version, ok := capabilities.payloadVersion(metricType)
+		if !ok {
+			collectorHealth.skip(metricType, "not accepted by the backend", snap.TakenAt)
+			return
+		}
+		metric := buildMetric(metricType, adaptMetricData(metricType, version, collect()), status)
+		metric["schema_version"] = version
+		// The backend keeps showing the last good data; say since when
+		if lastSuccess := collectorHealth.observe(metricType, status, snap.TakenAt); status.State() == StatusFailed {
+			metric["stale_since"] = nil
+			if !lastSuccess.IsZero() {
+				metric["stale_since"] = lastSuccess.UTC().Format(time.RFC3339)
+			}
+		}
+		metrics = append(metrics, metric)
+		sentTypes = append(sentTypes, metricType)
+	}
+
+	agentInfoStatus := &CollectorStatus{}
+	add("agent_info", agentInfoStatus, func() map[string]interface{} {
+		return collectAgentInfo(clientset, config, settings, agentInfoStatus)
+	})
+
+	// Formato esperado pela Edge Function
+	add("cpu", nodesStatus, func() map[string]interface{} {
+		return map[string]interface{}{
+			"usage_percent": cpuPercent,
+			"total_cores":   totalCPU / 1000,
+			"used_cores":    usedCPU / 1000,
+			"usage_sources": usageSources,
+		}
+	})
+	add("memory", nodesStatus, func() map[string]interface{} {
+		return map[string]interface{}{
+			"usage_percent": memoryPercent,
+			"total_bytes":   totalMemory,
+			"used_bytes":    usedMemory,
+			"usage_sources": usageSources,
+		}
+	})
+	add("pods", podsStatus, func() map[string]interface{} {
+		return map[string]interface{}{
+			"running": runningPods,
+			"total":   len(snap.Pods),
+		}
+	})
+	add("nodes", nodesStatus, func() map[string]interface{} {
+		return map[string]interface{}{
+			"count": len(snap.Nodes),
+			"nodes": extractNodeInfo(snap, config, nodeMetricsMap),
+		}
+	})
+	add("pod_details", podsStatus, func() map[string]interface{} {
+		return map[string]interface{}{"pods": collectPodDetails(snap, config)}
+	})
+	add("events", eventsStatus, func() map[string]interface{} {
+		return map[string]interface{}{"events": collectionPolicy.trimEvents(collectKubernetesEvents(snap))}
+	})
+	add("pvcs", pvcsStatus, func() map[string]interface{} {
+		return map[string]interface{}{"pvcs": collectPVCs(snap)}
+	})
+	add("standalone_pvs", pvsStatus, func() map[string]interface{} {
+		return map[string]interface{}{"pvs": collectStandalonePVs(snap)}
+	})
+	add("storage", pvsStatus, func() map[string]interface{} {
+		return collectStorageMetrics(snap)
+	})
+	add("node_storage", nodeStorageStatus, func() map[string]interface{} {
+		return collectNodeStorageMetrics(snap)
+	})
+	// Each security area is its own metric; the combined "security" metric is
+	// only assembled for backends that have not declared the per-area types
+	security := newSecurityCycle(clientset, snap)
+	for _, area := range securityAreas {
+		area := area
+		areaStatus := &CollectorStatus{}
+		add(area.metricType(), areaStatus, func() map[string]interface{} {
+			return security.collect(area, areaStatus)
+		})
+	}
+	if !capabilities.declares(securityAreas[0].metricType()) {
+		add("security", securityStatus, func() map[string]interface{} {
+			return security.legacy(securityStatus)
+		})
+	}
+	add("security_threats", threatsStatus, func() map[string]interface{} {
+		return collectSecurityThreatsData(clientset, snap, threatsStatus)
+	})
+	networkStatus := &CollectorStatus{}
+	networkStatus.Requires(snap, "nodes")
+	networkStatus.Uses(snap, "pods", "events")
+	add("network", networkStatus, func() map[string]interface{} {
+		return collectNetworkHealth(clientset, dynamicClient, snap, networkStatus)
+	})
+
+	corednsStatus := &CollectorStatus{}
+	corednsStatus.Uses(snap, "pods")
+	add("coredns", corednsStatus, func() map[string]interface{} {
+		return collectCoreDNS(clientset, snap, corednsStatus)
+	})
+
+	priorityStatus := &CollectorStatus{}
+	priorityStatus.Uses(snap, "pods", "events")
+	add("priority", priorityStatus, func() map[string]interface{} {
+		return collectPriorityData(clientset, snap, priorityStatus)
+	})
+
+	stuckStatus := &CollectorStatus{}
+	stuckStatus.Requires(snap, "namespaces")
+	stuckStatus.Uses(snap, "pods", "pvcs", "pvs", "services")
+	add("stuck_deletions", stuckStatus, func() map[string]interface{} {
+		return collectStuckDeletions(snap)
+	})
+
+	kubeletConfigStatus := &CollectorStatus{}
+	kubeletConfigStatus.Requires(snap, "nodes")
+	kubeletConfigStatus.Uses(snap, "pods", "kubelet_configz")
+	add("kubelet_config", kubeletConfigStatus, func() map[string]interface{} {
+		return collectKubeletConfigs(snap)
+	})
+
+	placementStatus := &CollectorStatus{}
+	placementStatus.Requires(snap, "pods")
+	placementStatus.Uses(snap, "nodes")
+	add("workload_placement", placementStatus, func() map[string]interface{} {
+		return collectWorkloadPlacement(snap)
+	})
+
+	topologyStatus := &CollectorStatus{}
+	topologyStatus.Requires(snap, "nodes")
+	topologyStatus.Uses(snap, "pods")
+	add("zone_topology", topologyStatus, func() map[string]interface{} {
+		return collectZoneTopology(snap)
+	})
+
+	nodeConditionsStatus := &CollectorStatus{}
+	nodeConditionsStatus.Requires(snap, "nodes")
+	add("node_conditions", nodeConditionsStatus, func() map[string]interface{} {
+		return collectNodeConditions(snap)
+	})
+
+	replicaSetsStatus := &CollectorStatus{}
+	add("replicasets", replicaSetsStatus, func() map[string]interface{} {
+		return collectReplicaSets(clientset, settings, replicaSetsStatus)
+	})
+
+	routingStatus := &CollectorStatus{}
+	routingStatus.Requires(snap, "services")
+	routingStatus.Uses(snap, "pods")
+	add("service_routing", routingStatus, func() map[string]interface{} {
+		return collectServiceRouting(clientset, snap, routingStatus)
+	})
+
+	oomStatus := &CollectorStatus{}
+	oomStatus.Requires(snap, "pods")
+	oomStatus.Uses(snap, "nodes", "events", "kubelet_stats")
+	add("oom_events", oomStatus, func() map[string]interface{} {
+		return collectOOMEvents(snap)
+	})
+
+	restartStatus := &CollectorStatus{}
+	restartStatus.Requires(snap, "pods")
+	restartStatus.Uses(snap, "events")
+	add("restart_reasons", restartStatus, func() map[string]interface{} {
+		return collectRestartReasons(snap)
+	})
+
+	schedulingStatus := &CollectorStatus{}
+	schedulingStatus.Requires(snap, "pods", "nodes")
+	add("scheduling_constraints", schedulingStatus, func() map[string]interface{} {
+		return collectSchedulingConstraints(snap)
+	})
+
+	timeSeriesStatus := &CollectorStatus{}
+	timeSeriesStatus.Requires(snap, "nodes")
+	timeSeriesStatus.Uses(snap, "pods", "kubelet_stats")
+	add("timeseries", timeSeriesStatus, func() map[string]interface{} {
+		return collectTimeSeries(snap, cpuPercent, memoryPercent, settings, timeSeriesStatus)
+	})
+
+	talkersStatus := &CollectorStatus{}
+	talkersStatus.Uses(snap, "kubelet_stats", "pods")
+	add("network_top_talkers", talkersStatus, func() map[string]interface{} {
+		return collectTopTalkers(snap, settings, talkersStatus)
+	})
+
+	overheadStatus := &CollectorStatus{}
+	overheadStatus.Requires(snap, "nodes")
+	overheadStatus.Uses(snap, "kubelet_stats")
+	add("system_overhead", overheadStatus, func() map[string]interface{} {
+		return collectSystemOverhead(snap, overheadStatus)
+	})
+
+	imagePullsStatus := &CollectorStatus{}
+	imagePullsStatus.Uses(snap, "pods", "events")
+	add("image_pulls", imagePullsStatus, func() map[string]interface{} {
+		return collectImagePulls(snap)
+	})
+
+	lifecycleStatus := &CollectorStatus{}
+	lifecycleStatus.Requires(snap, "pods")
+	add("pod_lifecycle", lifecycleStatus, func() map[string]interface{} {
+		return collectPodLifecycle(snap, settings)
+	})
+
+	graphStatus := &CollectorStatus{}
+	graphStatus.Uses(snap, "nodes", "pods", "services", "pvcs", "pvs")
+	add("topology", graphStatus, func() map[string]interface{} {
+		return collectTopologyGraph(clientset, snap, settings, graphStatus)
+	})
+
+	configMapsStatus := &CollectorStatus{}
+	configMapsStatus.Uses(snap, "pods")
+	add("configmaps", configMapsStatus, func() map[string]interface{} {
+		return collectConfigMaps(clientset, snap, settings, configMapsStatus)
+	})
+
+	leasesStatus := &CollectorStatus{}
+	leasesStatus.Uses(snap, "pods")
+	add("leases", leasesStatus, func() map[string]interface{} {
+		return collectLeases(clientset, snap, settings, leasesStatus)
+	})
+
+	controlPlaneStatus := &CollectorStatus{}
+	controlPlaneStatus.Uses(snap, "pods")
+	add("control_plane", controlPlaneStatus, func() map[string]interface{} {
+		return collectControlPlaneHealth(clientset, snap, controlPlaneStatus)
+	})
+
+	rbacChangesStatus := &CollectorStatus{}
+	add("rbac_changes", rbacChangesStatus, func() map[string]interface{} {
+		return collectRBACChanges(clientset, settings, rbacChangesStatus)
+	})
+
+	// Opt-in: the export is large even when paginated
+	if settings.RBACSubjects {
+		rbacSubjectsStatus := &CollectorStatus{}
+		add("rbac_subjects", rbacSubjectsStatus, func() map[string]interface{} {
+			return collectRBACSubjects(clientset, settings, rbacSubjectsStatus)
+		})
+	}
+
+	upgradeStatus := &CollectorStatus{}
+	upgradeStatus.Requires(snap, "nodes")
+	upgradeStatus.Uses(snap, "kubelet_configz")
+	add("upgrade_readiness", upgradeStatus, func() map[string]interface{} {
+		return collectUpgradeReadiness(clientset, snap, upgradeStatus)
+	})
+
+	evictionsStatus := &CollectorStatus{}
+	evictionsStatus.Requires(snap, "pods")
+	evictionsStatus.Uses(snap, "events", "kubelet_stats")
+	add("evictions", evictionsStatus, func() map[string]interface{} {
+		return collectEvictions(snap, settings)
+	})
+
+	// Opt-in: needs the privileged node probe DaemonSet
+	if settings.ConnectionSampling {
+		connectionsStatus := &CollectorStatus{}
+		connectionsStatus.Requires(snap, "pods")
+		add("connection_anomalies", connectionsStatus, func() map[string]interface{} {
+			return collectConnectionAnomalies(clientset, snap, config, connectionsStatus)
+		})
+	}
+
+	if config.SBOMEnabled {
+		add("sbom", &CollectorStatus{}, func() map[string]interface{} {
+			return collectSBOMStatus(config)
+		})
+	}
+
+	if config.imageVerification() {
+		signaturesStatus := &CollectorStatus{}
+		signaturesStatus.Requires(snap, "pods")
+		add("image_signatures", signaturesStatus, func() map[string]interface{} {
+			return collectImageSignatures(snap, signaturesStatus)
+		})
+	}
+
+	if config.RegistryCredentialChecks {
+		add("registry_credentials", &CollectorStatus{}, func() map[string]interface{} {
+			return collectRegistryCredentials(config)
+		})
+	}
+
+	footprintStatus := &CollectorStatus{}
+	add("agent_footprint", footprintStatus, func() map[string]interface{} {
+		return collectAgentFootprint(footprintStatus)
+	})
+
+	meshStatus := &CollectorStatus{}
+	meshStatus.Requires(snap, "pods")
+	meshStatus.Uses(snap, "namespaces")
+	add("mesh", meshStatus, func() map[string]interface{} {
+		return collectServiceMesh(snap, settings, meshStatus)
+	})
+
+	gitopsStatus := &CollectorStatus{}
+	add("gitops", gitopsStatus, func() map[string]interface{} {
+		return collectGitOpsStatus(dynamicClient, settings, gitopsStatus)
+	})
+	if settings.CustomMetrics.Enabled() {
+		customStatus := &CollectorStatus{}
+		add("custom_metrics", customStatus, func() map[string]interface{} {
+			return collectCustomMetrics(settings.CustomMetrics, customStatus)
+		})
+	}
+
+	// Opt-in collectors that were not wired this cycle are reported as skipped
+	if only == nil {
+		for metricType := range metricSchemaVersions {
+			// agent_status is the startup/enrollment heartbeat, report the
+			// scheduled summary and changes the change feed, not collectors
+			if !attempted[metricType] && metricType != "agent_status" && metricType != "report" && metricType != "changes" {
+				collectorHealth.skip(metricType, "not enabled", snap.TakenAt)
+			}
+		}
+	}
+
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("no known metric types requested")
+	}
+
+	log.Printf("🔍 Metrics: CPU=%.2f%%, Memory=%.2f%%, Pods=%d, Nodes=%d",
+		cpuPercent, memoryPercent, runningPods, len(snap.Nodes))
+
+	latest.record(metrics, snap.TakenAt)
+
+	// Scheduled reports aggregate full cycles only and go out on their own
+	if only == nil {
+		reports.observe(config, reportCycle{
+			at: snap.TakenAt, cpuMillis: totalCPU, usedCPUMillis: usedCPU,
+			memoryBytes: totalMemory, usedMemBytes: usedMemory,
+			nodes: len(snap.Nodes), runningPods: runningPods, pods: snap.Pods,
+		})
+		defer reports.sendPending(config)
 	}
 
-	for _, pod := range pods.Items {
-		if pod.Status.Phase == corev1.PodRunning {
-			runningPods++
+	if err := postMetrics(config, metrics); err != nil {
+		if only == nil {
+			offline.push(config, metrics, snap.TakenAt, err)
 		}
+		return nil, err
 	}
-
-	cpuPercent := float64(0)
-	if totalCPU > 0 {
-		cpuPercent = float64(usedCPU) / float64(totalCPU) * 100
+	if only == nil {
+		offline.replay(config)
 	}
+	return sentTypes, nil
+}
 
-	memoryPercent := float64(0)
-	if totalMemory > 0 {
-		memoryPercent = float64(usedMemory) / float64(totalMemory) * 100
+// postMetrics sends a batch of metric envelopes to agent-receive-metrics,
+// tagged or split per tenant when tenancy is enabled
+func postMetrics(config AgentConfig, metrics []map[string]interface{}) error {
+	batches, err := partitionMetrics(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to partition metrics by tenant: %v", err)
 	}
-
-	// Formato esperado pela Edge Function
-	metrics := []map[string]interface{}{
-		{
-			"type": "cpu",
-			"data": map[string]interface{}{
-				"usage_percent": cpuPercent,
-				"total_cores":   totalCPU / 1000,
-				"used_cores":    usedCPU / 1000,
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "memory",
-			"data": map[string]interface{}{
-				"usage_percent": memoryPercent,
-				"total_bytes":   totalMemory,
-				"used_bytes":    usedMemory,
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "pods",
-			"data": map[string]interface{}{
-				"running": runningPods,
-				"total":   len(pods.Items),
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "nodes",
-			"data": map[string]interface{}{
-				"count": len(nodes.Items),
-				"nodes": extractNodeInfo(nodes.Items, metricsClient),
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "pod_details",
-			"data": map[string]interface{}{
-				"pods": collectPodDetails(clientset),
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "events",
-			"data": map[string]interface{}{
-				"events": collectKubernetesEvents(clientset),
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "pvcs",
-			"data": map[string]interface{}{
-				"pvcs": collectPVCs(clientset),
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type": "standalone_pvs",
-			"data": map[string]interface{}{
-				"pvs": collectStandalonePVs(clientset),
-			},
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type":         "storage",
-			"data":         collectStorageMetrics(clientset),
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type":         "node_storage",
-			"data":         collectNodeStorageMetrics(clientset),
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type":         "security",
-			"data":         collectSecurityData(clientset),
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
-		{
-			"type":         "security_threats",
-			"data":         collectSecurityThreatsData(clientset),
-			"collected_at": time.Now().UTC().Format(time.RFC3339),
-		},
+	if whole, ok := batches[""]; ok {
+		return postMetricsWithKey(config, agentAPIKey(config), whole)
 	}
+	return postTenantBatches(config, batches)
+}
+
+// postMetricsWithKey is postMetrics authenticated with an explicit API key
+func postMetricsWithKey(config AgentConfig, apiKey string, metrics []map[string]interface{}) (err error) {
+	defer func(start time.Time) {
+		diagnostics.recordBackendCall("agent-receive-metrics", start, err)
+	}(time.Now())
 
 	payload := map[string]interface{}{
-		"metrics": metrics,
+		"metrics":    metrics,
+		"clock_skew": clockSkew.report(),
+		"bandwidth":  bandwidth.report(),
+	}
+	if collection := collectorHealth.report(); collection != nil {
+		payload["collection"] = collection
 	}
+	if buffered := offline.report(); buffered != nil {
+		payload["offline_buffer"] = buffered
+	}
+	if policy := collectionPolicy.report(); policy != nil {
+		payload["collection_policy"] = policy
+	}
+	bandwidth.recordMetricSizes(metrics)
 
-	body, _ := json.Marshal(payload)
+	body, err := marshalRedacted(payload, "metrics")
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics payload: %v", err)
+	}
+	defer func(start time.Time) {
+		diagnostics.recordPayload(metrics, len(body), start, err)
+	}(time.Now())
+	if config.dryRun() {
+		return writePayload(config, "agent-receive-metrics", body)
+	}
 
 	url := fmt.Sprintf("%s/agent-receive-metrics", config.APIEndpoint)
 	log.Printf("🔍 Sending to: %s", url)
-	log.Printf("🔍 Payload size: %d bytes", len(body))
-	log.Printf("🔍 Metrics: CPU=%.2f%%, Memory=%.2f%%, Pods=%d, Nodes=%d",
-		cpuPercent, memoryPercent, runningPods, len(nodes.Items))
+	wire, gzipped, gzipSize := encodeForWire(body)
+	log.Printf("🔍 Payload size: %d bytes (%d on the wire)", len(body), len(wire))
 
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(wire))
+	if err != nil {
+		return fmt.Errorf("failed to create metrics request: %v", err)
+	}
 
 	// Headers for authentication and version tracking
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-agent-key", config.APIKey)
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("x-agent-version", AgentVersion)
+	if err := authenticateRequest(config, req, apiKey, wire); err != nil {
+		return err
+	}
 
-	log.Printf("🔍 Headers: Content-Type=application/json, x-agent-key=%s...%s, x-agent-version=%s",
-		config.APIKey[:8], config.APIKey[len(config.APIKey)-4:], AgentVersion)
+	log.Printf("🔍 Headers: Content-Type=application/json, x-agent-key=%s, x-agent-version=%s",
+		maskAPIKey(apiKey), AgentVersion)
 
-	client := &http.Client{}
+	client := backendClient(config)
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("❌ Error sending metrics: %v", err)
-		return
+		return err
 	}
 	defer resp.Body.Close()
+	clockSkew.observeBackend(resp, start, time.Now())
+	bandwidth.recordSend("agent-receive-metrics", len(body), gzipSize, len(wire), time.Since(start))
 
-	responseBody, _ := ioutil.ReadAll(resp.Body)
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("⚠️  Error reading metrics response: %v", err)
+	}
 	log.Printf("🔍 Response status: %d", resp.StatusCode)
 	log.Printf("🔍 Response body: %s", string(responseBody))
 
 	if resp.StatusCode != 200 {
-		log.Printf("❌ Failed to send metrics: %s", string(responseBody))
-	} else {
-		log.Println("✅ Metrics sent successfully")
+		return &backendStatusError{code: resp.StatusCode, body: string(responseBody)}
 	}
+	return nil
 }
 
-// Extrai cpu/mem com usage real da Metrics API
-func extractNodeInfo(nodes []corev1.Node, metricsClient *metricsv.Clientset) []map[string]interface{} {
+// Extrai cpu/mem com usage real (Metrics API, Kubelet ou requests)
+func extractNodeInfo(snap *ClusterSnapshot, config AgentConfig, nodeMetricsMap map[string]map[string]int64) []map[string]interface{} {
 	var result []map[string]interface{}
 
-	// Try to get node metrics from Metrics API
-	var nodeMetricsMap map[string]map[string]int64
-	if metricsClient != nil {
-		nodeMetricsList, err := metricsClient.MetricsV1beta1().NodeMetricses().List(context.Background(), metav1.ListOptions{})
-		if err == nil {
-			nodeMetricsMap = make(map[string]map[string]int64)
-			for _, nm := range nodeMetricsList.Items {
-				nodeMetricsMap[nm.Name] = map[string]int64{
-					"cpu":    nm.Usage.Cpu().MilliValue(),
-					"memory": nm.Usage.Memory().Value(),
-				}
-			}
-			log.Printf("✅ Fetched metrics for %d nodes from Metrics API", len(nodeMetricsMap))
-		} else {
-			log.Printf("⚠️  Failed to fetch node metrics: %v", err)
-		}
-	}
-
-	for _, node := range nodes {
+	for _, node := range snap.Nodes {
 		// Capacity values
 		cpuCapacity := node.Status.Capacity.Cpu().MilliValue()
 		memCapacity := node.Status.Capacity.Memory().Value()
@@ -1641,24 +1972,19 @@ func extractNodeInfo(nodes []corev1.Node, metricsClient *metricsv.Clientset) []m
 		nodeInfo := map[string]interface{}{
 			"name":   node.Name,
 			"status": getNodeStatus(node),
+			"os":     nodeOS(node),
 			"capacity": map[string]interface{}{
 				"cpu":    cpuCapacity,
 				"memory": memCapacity,
 			},
 		}
 
-		// Usage values from Metrics API
-		if metrics, ok := nodeMetricsMap[node.Name]; ok {
-			nodeInfo["usage"] = map[string]interface{}{
-				"cpu":    metrics["cpu"],
-				"memory": metrics["memory"],
-			}
-		} else {
-			// Fallback: use allocatable as approximation
-			nodeInfo["usage"] = map[string]interface{}{
-				"cpu":    cpuCapacity - node.Status.Allocatable.Cpu().MilliValue(),
-				"memory": memCapacity - node.Status.Allocatable.Memory().Value(),
-			}
+		// Usage values, with the source they came from
+		usedCPU, usedMemory, source := nodeUsage(node, nodeMetricsMap, snap)
+		nodeInfo["usage"] = map[string]interface{}{
+			"cpu":    usedCPU,
+			"memory": usedMemory,
+			"source": source,
 		}
 
 		// Add OS information
@@ -1673,21 +1999,18 @@ func extractNodeInfo(nodes []corev1.Node, metricsClient *metricsv.Clientset) []m
 		}
 
 		// Add node labels (useful for pool identification)
-		if len(node.Labels) > 0 {
-			labels := make(map[string]string)
-			for k, v := range node.Labels {
-				// Include relevant labels
-				if k == "node.kubernetes.io/instance-type" ||
-					k == "topology.kubernetes.io/zone" ||
-					k == "node-role.kubernetes.io/master" ||
-					k == "node-role.kubernetes.io/control-plane" ||
-					k == "pool" || k == "agentpool" {
-					labels[k] = v
-				}
-			}
-			if len(labels) > 0 {
-				nodeInfo["labels"] = labels
+		if labels := selectMetadata(node.Labels, config.NodeLabels); labels != nil {
+			nodeInfo["labels"] = labels
+		}
+		if annotations := selectMetadata(node.Annotations, config.NodeAnnotations); annotations != nil {
+			nodeInfo["annotations"] = annotations
+		}
+		if config.NodeTaints && len(node.Spec.Taints) > 0 {
+			taints := make([]map[string]interface{}, 0, len(node.Spec.Taints))
+			for _, t := range node.Spec.Taints {
+				taints = append(taints, map[string]interface{}{"key": t.Key, "value": t.Value, "effect": string(t.Effect)})
 			}
+			nodeInfo["taints"] = taints
 		}
 
 		result = append(result, nodeInfo)
@@ -1714,37 +2037,67 @@ type Command struct {
 	ID            string                 `json:"id"`
 	CommandType   string                 `json:"command_type"`
 	CommandParams map[string]interface{} `json:"command_params"`
+	// UserID is the platform user who issued the command (agent_commands.user_id)
+	UserID string `json:"user_id,omitempty"`
+	// RequestedBy is that user's email, resolved by agent-get-commands
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// requester names the platform user behind the command: the email when the
+// backend resolved it, else the user ID ("" when unknown)
+func (c Command) requester() string {
+	if c.RequestedBy != "" {
+		return c.RequestedBy
+	}
+	return c.UserID
 }
 
 type CommandsResponse struct {
 	Commands []Command `json:"commands"`
 }
 
-func getCommands(clientset *kubernetes.Clientset, config AgentConfig) {
+func getCommands(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, kubeconfig *rest.Config, config AgentConfig) {
 	url := fmt.Sprintf("%s/agent-get-commands", config.APIEndpoint)
 	log.Printf("🔍 Polling commands from: %s", url)
 
-	req, _ := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		log.Printf("❌ Error creating commands request: %v", err)
+		return
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-agent-key", config.APIKey)
 	req.Header.Set("x-agent-version", AgentVersion)
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := backendClient(config)
+	start := time.Now()
+	if err := authenticateRequest(config, req, agentAPIKey(config), nil); err != nil {
+		diagnostics.recordBackendCall("agent-get-commands", start, err)
+		log.Printf("❌ Error authenticating commands request: %v", err)
+		return
+	}
 	resp, err := client.Do(req)
 	if err != nil {
+		diagnostics.recordBackendCall("agent-get-commands", start, err)
 		log.Printf("❌ Error polling commands: %v", err)
 		return
 	}
 	defer resp.Body.Close()
+	clockSkew.observeBackend(resp, start, time.Now())
 
-	body, _ := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("❌ Error reading commands response: %v", err)
+		return
+	}
 
 	if resp.StatusCode != 200 {
+		diagnostics.recordBackendCall("agent-get-commands", start, fmt.Errorf("backend returned %d", resp.StatusCode))
 		log.Printf("⚠️  Commands request returned %d: %s", resp.StatusCode, string(body))
 		return
 	}
+	diagnostics.recordBackendCall("agent-get-commands", start, nil)
 
-	log.Printf("📥 Commands response: %s", string(body))
+	log.Printf("📥 Commands response: %s", redactForLog(body))
 
 	var commandsResp CommandsResponse
 	if err := json.Unmarshal(body, &commandsResp); err != nil {
@@ -1755,9 +2108,9 @@ func getCommands(clientset *kubernetes.Clientset, config AgentConfig) {
 	if len(commandsResp.Commands) > 0 {
 		log.Printf("📥 Received %d commands to execute", len(commandsResp.Commands))
 		for i, cmd := range commandsResp.Commands {
-			log.Printf("  [%d] ID=%s Type=%s Params=%v", i+1, cmd.ID, cmd.CommandType, cmd.CommandParams)
+			log.Printf("  [%d] ID=%s Type=%s", i+1, cmd.ID, cmd.CommandType)
 		}
-		executeCommands(clientset, config, commandsResp.Commands)
+		executeCommands(clientset, metricsClient, dynamicClient, kubeconfig, config, commandsResp.Commands)
 	} else {
 		log.Printf("📭 No pending commands")
 	}
@@ -1766,52 +2119,120 @@ func getCommands(clientset *kubernetes.Clientset, config AgentConfig) {
 // ---------------------------------------------
 // COMMAND EXECUTION
 // ---------------------------------------------
-func executeCommands(clientset *kubernetes.Clientset, config AgentConfig, commands []Command) {
-	for _, cmd := range commands {
-		log.Printf("⚡ Executing command: %s (ID: %s)", cmd.CommandType, cmd.ID)
-		log.Printf("   Params: %v", cmd.CommandParams)
-
-		var result map[string]interface{}
-		var err error
-
-		switch cmd.CommandType {
-		case "restart_pod", "delete_pod":
-			log.Printf("   → Deleting/restarting pod...")
-			result, err = deletePod(clientset, cmd.CommandParams)
-		case "scale_deployment":
-			log.Printf("   → Scaling deployment...")
-			result, err = scaleDeployment(clientset, cmd.CommandParams)
-		case "update_deployment_image":
-			log.Printf("   → Updating deployment image...")
-			result, err = updateDeploymentImage(clientset, cmd.CommandParams)
-		case "update_deployment_resources":
-			log.Printf("   → Updating deployment resources...")
-			result, err = updateDeploymentResources(clientset, cmd.CommandParams)
-		case "self_update", "agent_update":
-			log.Printf("   → Self-updating agent...")
-			result, err = selfUpdate(clientset, cmd.CommandParams)
-			// After successful update, the pod will restart and won't continue execution
-		default:
-			err = fmt.Errorf("unknown command type: %s", cmd.CommandType)
-			log.Printf("   ❌ Unknown command type!")
-		}
-
-		if err != nil {
-			log.Printf("   ❌ Command failed: %v", err)
-		} else {
-			log.Printf("   ✅ Command succeeded: %v", result)
-		}
+func executeCommands(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, kubeconfig *rest.Config, config AgentConfig, commands []Command) {
+	commandQueue.submit(commands, func(cmd Command) {
+		executeCommand(clientset, metricsClient, dynamicClient, kubeconfig, config, cmd)
+	})
+}
+
+func executeCommand(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, kubeconfig *rest.Config, config AgentConfig, cmd Command) {
+	log.Printf("⚡ Executing command: %s (ID: %s)", cmd.CommandType, cmd.ID)
+	if params, err := json.Marshal(cmd.CommandParams); err == nil {
+		log.Printf("   Params: %s", redactForLog(params))
+	}
+	start := time.Now()
+
+	if !getSettings().CommandAllowed(cmd.CommandType) {
+		err := fmt.Errorf("command type %s is not allowed by the agent command policy", cmd.CommandType)
+		log.Printf("   ❌ Command %s rejected by policy", cmd.ID)
+		diagnostics.recordCommand(cmd, start, err)
+		updateCommandStatus(config, cmd.ID, nil, err)
+		return
+	}
 
-		updateCommandStatus(config, cmd.ID, result, err)
+	result, err := dispatchCommand(clientset, metricsClient, dynamicClient, kubeconfig, config, cmd)
+	diagnostics.recordCommand(cmd, start, err)
+	if err != nil {
+		log.Printf("   ❌ Command %s failed: %v", cmd.ID, err)
+	} else {
+		resultJSON, _ := json.Marshal(result)
+		log.Printf("   ✅ Command %s succeeded: %s", cmd.ID, redactForLog(resultJSON))
+		recordActionEvent(clientset, config, cmd, result)
+		stampOwnership(dynamicClient, config, cmd, result)
 	}
+
+	updateCommandStatus(config, cmd.ID, result, err)
+}
+
+// dispatchCommand runs the handler for the command type
+func dispatchCommand(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, kubeconfig *rest.Config, config AgentConfig, cmd Command) (result map[string]interface{}, err error) {
+	defer recoverCommand(cmd, &err)
+
+	switch cmd.CommandType {
+	case "restart_pod", "delete_pod":
+		log.Printf("   → Deleting/restarting pod...")
+		result, err = deletePod(clientset, cmd.CommandParams)
+	case "scale_deployment":
+		log.Printf("   → Scaling deployment...")
+		result, err = scaleDeployment(clientset, config, cmd.CommandParams)
+	case "update_deployment_image":
+		log.Printf("   → Updating deployment image...")
+		result, err = updateDeploymentImage(clientset, config, cmd.CommandParams)
+	case "update_deployment_resources":
+		log.Printf("   → Updating deployment resources...")
+		result, err = updateDeploymentResources(clientset, config, cmd.CommandParams)
+	case "collect_now":
+		log.Printf("   → Running on-demand collection...")
+		result, err = collectNow(clientset, metricsClient, dynamicClient, config, cmd.CommandParams)
+	case "get_manifest":
+		log.Printf("   → Fetching resource manifest...")
+		result, err = getManifest(clientset, cmd.CommandParams)
+	case "describe_pod":
+		log.Printf("   → Describing pod...")
+		result, err = describePod(clientset, cmd.CommandParams)
+	case "open_tunnel":
+		log.Printf("   → Opening port-forward tunnel...")
+		result, err = openTunnel(clientset, kubeconfig, config, cmd.CommandParams)
+	case "close_tunnel":
+		log.Printf("   → Closing port-forward tunnel...")
+		result, err = closeTunnel(cmd.CommandParams)
+	case "debug_pod":
+		log.Printf("   → Adding ephemeral debug container...")
+		result, err = debugPod(clientset, cmd.CommandParams)
+	case "apply_network_policy":
+		log.Printf("   → Applying NetworkPolicy template...")
+		result, err = applyNetworkPolicy(clientset, cmd.CommandParams)
+	case "patch_resource":
+		log.Printf("   → Patching resource...")
+		result, err = patchResource(clientset, dynamicClient, cmd.CommandParams)
+	case "rotate_credentials":
+		log.Printf("   → Rotating agent credentials...")
+		result, err = rotateCredentials(clientset, config, cmd.CommandParams)
+	case "check_access":
+		log.Printf("   → Checking access...")
+		result, err = checkAccess(clientset, kubeconfig, cmd.CommandParams)
+	case "diagnose":
+		log.Printf("   → Running self-diagnostics...")
+		result, err = runDiagnostics(clientset, config)
+	case "benchmark_node":
+		log.Printf("   → Benchmarking node...")
+		result, err = benchmarkNode(clientset, config, cmd.CommandParams)
+	case "set_collection_policy":
+		log.Printf("   → Applying collection policy...")
+		result, err = setCollectionPolicy(clientset, config, cmd.CommandParams)
+	case "self_update", "agent_update":
+		log.Printf("   → Self-updating agent...")
+		result, err = selfUpdate(clientset, cmd.CommandParams)
+		// After successful update, the pod will restart and won't continue execution
+	default:
+		err = fmt.Errorf("unknown command type: %s", cmd.CommandType)
+		log.Printf("   ❌ Unknown command type!")
+	}
+	return result, err
 }
 
-func deletePod(clientset *kubernetes.Clientset, params map[string]interface{}) (map[string]interface{}, error) {
-	podName := params["pod_name"].(string)
-	namespace := params["namespace"].(string)
+func deletePod(clientset kubernetes.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p PodParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	podName, namespace := p.PodName, p.Namespace
+
+	ctx, cancel := apiContext()
+	defer cancel()
 
 	err := clientset.CoreV1().Pods(namespace).Delete(
-		context.Background(),
+		ctx,
 		podName,
 		metav1.DeleteOptions{},
 	)
@@ -1828,70 +2249,70 @@ func deletePod(clientset *kubernetes.Clientset, params map[string]interface{}) (
 	}, nil
 }
 
-func scaleDeployment(clientset *kubernetes.Clientset, params map[string]interface{}) (map[string]interface{}, error) {
-	deploymentName := params["deployment_name"].(string)
-	namespace := params["namespace"].(string)
-	replicas := int32(params["replicas"].(float64))
+func scaleDeployment(clientset kubernetes.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p ScaleDeploymentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	deploymentName, namespace, replicas := p.DeploymentName, p.Namespace, *p.Replicas
 
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(
-		context.Background(),
-		deploymentName,
-		metav1.GetOptions{},
-	)
+	ctx, cancel := apiContext()
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	annotations, conflict, err := checkGitOpsConflict(config, deployment, p.Force)
 	if err != nil {
 		return nil, err
 	}
 
-	deployment.Spec.Replicas = &replicas
-
-	_, err = clientset.AppsV1().Deployments(namespace).Update(
-		context.Background(),
-		deployment,
-		metav1.UpdateOptions{},
-	)
-
-	if err != nil {
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": replicas},
+	}
+	if err := patchDeployment(clientset, namespace, deploymentName, withAnnotations(patch, annotations)); err != nil {
 		return nil, err
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"action":     "deployment_scaled",
 		"deployment": deploymentName,
 		"namespace":  namespace,
 		"replicas":   replicas,
-	}, nil
+	}
+	if conflict != nil {
+		result["gitops_conflict"] = conflict
+	}
+	return result, nil
 }
 
-func updateDeploymentImage(clientset *kubernetes.Clientset, params map[string]interface{}) (map[string]interface{}, error) {
-	deploymentName, _ := params["deployment_name"].(string)
-	namespace, _ := params["namespace"].(string)
-	containerName, _ := params["container_name"].(string)
-	newImage, _ := params["new_image"].(string)
-	oldImage, _ := params["old_image"].(string)
-
-	if deploymentName == "" || namespace == "" || newImage == "" {
-		return nil, fmt.Errorf("missing required params: deployment_name, namespace, new_image")
+func updateDeploymentImage(clientset kubernetes.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p UpdateImageParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
 	}
+	deploymentName, namespace := p.DeploymentName, p.Namespace
+	containerName, newImage, oldImage := p.ContainerName, p.NewImage, p.OldImage
 
+	ctx, cancel := apiContext()
 	deployment, err := clientset.AppsV1().Deployments(namespace).Get(
-		context.Background(),
+		ctx,
 		deploymentName,
 		metav1.GetOptions{},
 	)
+	cancel()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get deployment: %v", err)
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
 
-	// Find and update the container image
-	updated := false
+	// Find the container to update
+	containers := deployment.Spec.Template.Spec.Containers
 	updatedContainer := ""
 
 	// 1) Prefer explicit container name when provided
 	if containerName != "" {
-		for i, container := range deployment.Spec.Template.Spec.Containers {
+		for _, container := range containers {
 			if container.Name == containerName {
-				deployment.Spec.Template.Spec.Containers[i].Image = newImage
-				updated = true
 				updatedContainer = container.Name
 				break
 			}
@@ -1899,41 +2320,42 @@ func updateDeploymentImage(clientset *kubernetes.Clientset, params map[string]in
 	}
 
 	// 2) If container not provided or not found, try match by old_image
-	if !updated && oldImage != "" {
-		for i, container := range deployment.Spec.Template.Spec.Containers {
+	if updatedContainer == "" && oldImage != "" {
+		for _, container := range containers {
 			if container.Image == oldImage {
-				deployment.Spec.Template.Spec.Containers[i].Image = newImage
-				updated = true
 				updatedContainer = container.Name
 				break
 			}
 		}
 	}
 
-	// 3) If still not updated and there's only one container, update it
-	if !updated && len(deployment.Spec.Template.Spec.Containers) == 1 {
-		deployment.Spec.Template.Spec.Containers[0].Image = newImage
-		updated = true
-		updatedContainer = deployment.Spec.Template.Spec.Containers[0].Name
+	// 3) If still not found and there's only one container, update it
+	if updatedContainer == "" && len(containers) == 1 {
+		updatedContainer = containers[0].Name
 	}
 
-	if !updated {
+	if updatedContainer == "" {
 		if containerName == "" {
 			return nil, fmt.Errorf("unable to determine which container to update (provide container_name or old_image)")
 		}
 		return nil, fmt.Errorf("container %s not found in deployment", containerName)
 	}
 
-	_, err = clientset.AppsV1().Deployments(namespace).Update(
-		context.Background(),
-		deployment,
-		metav1.UpdateOptions{},
-	)
+	annotations, conflict, err := checkGitOpsConflict(config, deployment, p.Force)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update deployment: %v", err)
+		return nil, err
 	}
 
-	return map[string]interface{}{
+	// Containers merge by name, so only this container's image changes
+	patch := podTemplateContainerPatch(map[string]interface{}{
+		"name":  updatedContainer,
+		"image": newImage,
+	})
+	if err := patchDeployment(clientset, namespace, deploymentName, withAnnotations(patch, annotations)); err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
 		"action":     "deployment_image_updated",
 		"deployment": deploymentName,
 		"namespace":  namespace,
@@ -1941,78 +2363,140 @@ func updateDeploymentImage(clientset *kubernetes.Clientset, params map[string]in
 		"new_image":  newImage,
 		"old_image":  oldImage,
 		"message":    "Deployment image updated successfully. Kubernetes will roll out the new pods.",
-	}, nil
+	}
+	if conflict != nil {
+		result["gitops_conflict"] = conflict
+	}
+	return result, nil
 }
 
+func updateDeploymentResources(clientset kubernetes.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p UpdateResourcesParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	deploymentName, namespace, containerName := p.DeploymentName, p.Namespace, p.ContainerName
 
-func updateDeploymentResources(clientset *kubernetes.Clientset, params map[string]interface{}) (map[string]interface{}, error) {
-	deploymentName := params["deployment_name"].(string)
-	namespace := params["namespace"].(string)
-	containerName := params["container_name"].(string)
-
+	ctx, cancel := apiContext()
 	deployment, err := clientset.AppsV1().Deployments(namespace).Get(
-		context.Background(),
+		ctx,
 		deploymentName,
 		metav1.GetOptions{},
 	)
+	cancel()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get deployment: %v", err)
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
 
-	// Find and update the container resources
-	updated := false
-	for i, container := range deployment.Spec.Template.Spec.Containers {
+	// A strategic patch for an unknown name would add a new container instead
+	found := false
+	for _, container := range deployment.Spec.Template.Spec.Containers {
 		if container.Name == containerName {
-			if cpuRequest, ok := params["cpu_request"].(string); ok {
-				if deployment.Spec.Template.Spec.Containers[i].Resources.Requests == nil {
-					deployment.Spec.Template.Spec.Containers[i].Resources.Requests = corev1.ResourceList{}
-				}
-				deployment.Spec.Template.Spec.Containers[i].Resources.Requests[corev1.ResourceCPU] = resource.MustParse(cpuRequest)
-			}
-			if memRequest, ok := params["memory_request"].(string); ok {
-				if deployment.Spec.Template.Spec.Containers[i].Resources.Requests == nil {
-					deployment.Spec.Template.Spec.Containers[i].Resources.Requests = corev1.ResourceList{}
-				}
-				deployment.Spec.Template.Spec.Containers[i].Resources.Requests[corev1.ResourceMemory] = resource.MustParse(memRequest)
-			}
-			if cpuLimit, ok := params["cpu_limit"].(string); ok {
-				if deployment.Spec.Template.Spec.Containers[i].Resources.Limits == nil {
-					deployment.Spec.Template.Spec.Containers[i].Resources.Limits = corev1.ResourceList{}
-				}
-				deployment.Spec.Template.Spec.Containers[i].Resources.Limits[corev1.ResourceCPU] = resource.MustParse(cpuLimit)
-			}
-			if memLimit, ok := params["memory_limit"].(string); ok {
-				if deployment.Spec.Template.Spec.Containers[i].Resources.Limits == nil {
-					deployment.Spec.Template.Spec.Containers[i].Resources.Limits = corev1.ResourceList{}
-				}
-				deployment.Spec.Template.Spec.Containers[i].Resources.Limits[corev1.ResourceMemory] = resource.MustParse(memLimit)
-			}
-			updated = true
+			found = true
 			break
 		}
 	}
-
-	if !updated {
+	if !found {
 		return nil, fmt.Errorf("container %s not found in deployment", containerName)
 	}
+	annotations, conflict, err := checkGitOpsConflict(config, deployment, p.Force)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err = clientset.AppsV1().Deployments(namespace).Update(
-		context.Background(),
-		deployment,
-		metav1.UpdateOptions{},
-	)
+	requests := map[string]interface{}{}
+	limits := map[string]interface{}{}
+	if p.CPURequest != nil {
+		requests[string(corev1.ResourceCPU)] = *p.CPURequest
+	}
+	if p.MemoryRequest != nil {
+		requests[string(corev1.ResourceMemory)] = *p.MemoryRequest
+	}
+	if p.CPULimit != nil {
+		limits[string(corev1.ResourceCPU)] = *p.CPULimit
+	}
+	if p.MemoryLimit != nil {
+		limits[string(corev1.ResourceMemory)] = *p.MemoryLimit
+	}
+	resources := map[string]interface{}{}
+	if len(requests) > 0 {
+		resources["requests"] = requests
+	}
+	if len(limits) > 0 {
+		resources["limits"] = limits
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to update deployment resources: %v", err)
+	patch := podTemplateContainerPatch(map[string]interface{}{
+		"name":      containerName,
+		"resources": resources,
+	})
+	if err := patchDeployment(clientset, namespace, deploymentName, withAnnotations(patch, annotations)); err != nil {
+		return nil, err
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"action":     "deployment_resources_updated",
 		"deployment": deploymentName,
 		"namespace":  namespace,
 		"container":  containerName,
 		"message":    "Deployment resources updated successfully. Kubernetes will roll out the new pods.",
-	}, nil
+	}
+	if conflict != nil {
+		result["gitops_conflict"] = conflict
+	}
+	return result, nil
+}
+
+// podTemplateContainerPatch wraps a container patch in a deployment strategic merge patch
+func podTemplateContainerPatch(container map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{container},
+				},
+			},
+		},
+	}
+}
+
+// patchDeployment applies a strategic merge patch, which only touches the given
+// fields and so does not race other controllers the way Get+Update does
+func patchDeployment(clientset kubernetes.Interface, namespace, name string, patch map[string]interface{}) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	_, err = clientset.AppsV1().Deployments(namespace).Patch(
+		ctx,
+		name,
+		types.StrategicMergePatchType,
+		body,
+		metav1.PatchOptions{FieldManager: fieldManager},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to patch deployment %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// commandErrorType classifies a command error for the backend
+func commandErrorType(err error) string {
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return "validation"
+	case apierrors.IsConflict(err):
+		return "conflict"
+	case apierrors.IsNotFound(err):
+		return "not_found"
+	case apierrors.IsForbidden(err):
+		return "forbidden"
+	}
+	return ""
 }
 
 func updateCommandStatus(config AgentConfig, commandID string, result map[string]interface{}, err error) {
@@ -2020,6 +2504,16 @@ func updateCommandStatus(config AgentConfig, commandID string, result map[string
 	if err != nil {
 		status = "failed"
 		result = map[string]interface{}{"error": err.Error()}
+		if errorType := commandErrorType(err); errorType != "" {
+			result["error_type"] = errorType
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			result["validation_errors"] = validationErr.Fields
+		}
+	} else {
+		// Large outputs go out as an artifact; the update keeps a truncated copy
+		result = limitCommandResult(config, commandID, result)
 	}
 
 	payload := map[string]interface{}{
@@ -2028,47 +2522,106 @@ func updateCommandStatus(config AgentConfig, commandID string, result map[string
 		"result":     result,
 	}
 
-	body, _ := json.Marshal(payload)
+	body, marshalErr := marshalRedacted(payload, "command_result")
+	if marshalErr != nil {
+		log.Printf("❌ Error encoding status for command %s: %v", commandID, marshalErr)
+		return
+	}
 	url := fmt.Sprintf("%s/agent-update-command", config.APIEndpoint)
+	wire, gzipped, gzipSize := encodeForWire(body)
 
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, reqErr := http.NewRequest("POST", url, bytes.NewBuffer(wire))
+	if reqErr != nil {
+		log.Printf("❌ Error creating status request for command %s: %v", commandID, reqErr)
+		return
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-agent-key", config.APIKey)
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("x-agent-version", AgentVersion)
+	if authErr := authenticateRequest(config, req, agentAPIKey(config), wire); authErr != nil {
+		log.Printf("❌ Error authenticating status request for command %s: %v", commandID, authErr)
+		return
+	}
 
-	client := &http.Client{}
-	resp, _ := client.Do(req)
-	if resp != nil {
-		defer resp.Body.Close()
+	client := backendClient(config)
+	start := time.Now()
+	resp, sendErr := client.Do(req)
+	diagnostics.recordBackendCall("agent-update-command", start, sendErr)
+	if sendErr != nil {
+		log.Printf("❌ Error updating status for command %s: %v", commandID, sendErr)
+		return
 	}
+	bandwidth.recordSend("agent-update-command", len(body), gzipSize, len(wire), time.Since(start))
+	defer resp.Body.Close()
+	// Drain the body so the connection goes back to the pool
+	io.Copy(io.Discard, resp.Body)
 
 	log.Printf("✅ Command %s status updated: %s", commandID, status)
 }
 
+// collectNow runs an immediate collection outside the normal schedule. The
+// optional "collectors" param restricts it to the listed metric types.
+func collectNow(clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p CollectNowParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	var only map[string]bool
+	if len(p.Collectors) > 0 {
+		only = make(map[string]bool)
+		for _, name := range p.Collectors {
+			only[name] = true
+		}
+	}
+
+	start := time.Now()
+	sent, err := collectAndSendMetrics(clientset, metricsClient, dynamicClient, config, only)
+	if err != nil {
+		return nil, fmt.Errorf("on-demand collection failed: %v", err)
+	}
+
+	return map[string]interface{}{
+		"action":      "collect_now",
+		"collectors":  sent,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"message":     "Collection completed and sent to the backend.",
+	}, nil
+}
+
 // ---------------------------------------------
 // SELF UPDATE
 // Performs a rollout restart of the agent deployment
 // ---------------------------------------------
-func selfUpdate(clientset *kubernetes.Clientset, params map[string]interface{}) (map[string]interface{}, error) {
+func selfUpdate(clientset kubernetes.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p SelfUpdateParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
 	// Get namespace and deployment name from params or use defaults
 	namespace := "kodo"
 	deploymentName := "kodo-agent"
 
-	if ns, ok := params["namespace"].(string); ok && ns != "" {
-		namespace = ns
+	if p.Namespace != "" {
+		namespace = p.Namespace
 	}
-	if dn, ok := params["deployment_name"].(string); ok && dn != "" {
-		deploymentName = dn
+	if p.DeploymentName != "" {
+		deploymentName = p.DeploymentName
 	}
 
 	// Optional: new image tag
-	newImage, hasNewImage := params["new_image"].(string)
+	newImage := p.NewImage
 
 	log.Printf("🔄 Starting self-update for %s/%s (current version: %s)", namespace, deploymentName, AgentVersion)
 
+	ctx, cancel := apiContext()
+	defer cancel()
+
 	// Get the deployment
 	deployment, err := clientset.AppsV1().Deployments(namespace).Get(
-		context.Background(),
+		ctx,
 		deploymentName,
 		metav1.GetOptions{},
 	)
@@ -2077,7 +2630,7 @@ func selfUpdate(clientset *kubernetes.Clientset, params map[string]interface{})
 	}
 
 	// If new image provided, update it
-	if hasNewImage && newImage != "" {
+	if newImage != "" {
 		log.Printf("📦 Updating image to: %s", newImage)
 		for i := range deployment.Spec.Template.Spec.Containers {
 			if deployment.Spec.Template.Spec.Containers[i].Name == "agent" {
@@ -2095,7 +2648,7 @@ func selfUpdate(clientset *kubernetes.Clientset, params map[string]interface{})
 
 	// Update the deployment
 	_, err = clientset.AppsV1().Deployments(namespace).Update(
-		context.Background(),
+		ctx,
 		deployment,
 		metav1.UpdateOptions{},
 	)
@@ -2119,8 +2672,8 @@ func selfUpdate(clientset *kubernetes.Clientset, params map[string]interface{})
 // SECURITY THREATS DATA COLLECTION
 // Coleta dados para detecção de DDoS, hackers, atividades suspeitas
 // ---------------------------------------------
-func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]interface{} {
-	ctx := context.Background()
+func collectSecurityThreatsData(clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("security_threats", time.Now())
 
 	securityThreatsData := map[string]interface{}{
 		"suspicious_pods":       []map[string]interface{}{},
@@ -2131,29 +2684,53 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 		"privileged_containers": []map[string]interface{}{},
 		"host_network_pods":     []map[string]interface{}{},
 		"host_pid_pods":         []map[string]interface{}{},
+		"host_socket_mounts":    []map[string]interface{}{},
+		"privileged_daemonsets": map[string]interface{}{},
 	}
 
 	// 1. Collect pods with suspicious configurations
 	log.Printf("🔒 Collecting security threats data...")
-	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error listing pods for security analysis: %v", err)
-		return securityThreatsData
-	}
 
 	var suspiciousPods []map[string]interface{}
 	var privilegedContainers []map[string]interface{}
 	var hostNetworkPods []map[string]interface{}
 	var hostPidPods []map[string]interface{}
+	var hostSocketPods []map[string]interface{}
 	var resourceAnomalies []map[string]interface{}
 
-	for _, pod := range pods.Items {
+	// Linux-only checks (privileged, capabilities, hostPID, runAsNonRoot) do not apply to Windows pods
+	windowsNodes := windowsNodeNames(snap)
+
+	// Host access of DaemonSet pods is reported once per DaemonSet, not per pod
+	suppressions := newSuppressionIndex(snap)
+	daemonSets, dsErr := privilegedDaemonSets(clientset, status, suppressions)
+
+	for _, pod := range snap.Pods {
 		// Skip system namespaces for certain checks
 		isSystemNS := pod.Namespace == "kube-system" || pod.Namespace == "kube-public" || pod.Namespace == "kube-node-lease"
+		isWindows := isWindowsPod(pod, windowsNodes)
+		inventoried := false
+		approved := false
+		if ds, ok := daemonSets[daemonSetOf(pod)]; ok {
+			inventoried = true
+			approved = ds["approved"].(bool)
+		}
+
+		// HostProcess is the Windows equivalent of privileged mode
+		if isWindows && !inventoried && isHostProcessPod(pod) {
+			privilegedContainers = append(privilegedContainers, map[string]interface{}{
+				"pod_name":     pod.Name,
+				"namespace":    pod.Namespace,
+				"node":         pod.Spec.NodeName,
+				"os":           osWindows,
+				"threat_level": "high",
+				"reason":       "Windows HostProcess container with full host access",
+			})
+		}
 
 		// Check for privileged containers
 		for _, container := range pod.Spec.Containers {
-			if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			if !isWindows && !inventoried && container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
 				privilegedContainers = append(privilegedContainers, map[string]interface{}{
 					"pod_name":       pod.Name,
 					"namespace":      pod.Namespace,
@@ -2166,7 +2743,7 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 			}
 
 			// Check for containers with dangerous capabilities
-			if container.SecurityContext != nil && container.SecurityContext.Capabilities != nil {
+			if !isWindows && !inventoried && container.SecurityContext != nil && container.SecurityContext.Capabilities != nil {
 				for _, cap := range container.SecurityContext.Capabilities.Add {
 					if isDangerousCapability(string(cap)) {
 						privilegedContainers = append(privilegedContainers, map[string]interface{}{
@@ -2210,7 +2787,7 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 		}
 
 		// Check for host network access
-		if pod.Spec.HostNetwork && !isSystemNS {
+		if pod.Spec.HostNetwork && !isSystemNS && !inventoried {
 			hostNetworkPods = append(hostNetworkPods, map[string]interface{}{
 				"pod_name":     pod.Name,
 				"namespace":    pod.Namespace,
@@ -2221,7 +2798,7 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 		}
 
 		// Check for host PID access
-		if pod.Spec.HostPID && !isSystemNS {
+		if pod.Spec.HostPID && !isSystemNS && !isWindows && !inventoried {
 			hostPidPods = append(hostPidPods, map[string]interface{}{
 				"pod_name":     pod.Name,
 				"namespace":    pod.Namespace,
@@ -2231,6 +2808,11 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 			})
 		}
 
+		// Check for runtime sockets and kubelet directories mounted from the host
+		if !inventoried {
+			hostSocketPods = append(hostSocketPods, hostSocketMounts(pod)...)
+		}
+
 		// Check for suspicious image patterns
 		for _, container := range pod.Spec.Containers {
 			if isSuspiciousImage(container.Image) {
@@ -2246,9 +2828,10 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 			}
 		}
 
-		// Check for pods running as root
-		if pod.Spec.SecurityContext == nil ||
-		   (pod.Spec.SecurityContext.RunAsNonRoot == nil || !*pod.Spec.SecurityContext.RunAsNonRoot) {
+		// Check for pods running as root (runAsNonRoot does not apply to Windows pods);
+		// an approved DaemonSet's justification covers it
+		if !isWindows && !approved && (pod.Spec.SecurityContext == nil ||
+		   (pod.Spec.SecurityContext.RunAsNonRoot == nil || !*pod.Spec.SecurityContext.RunAsNonRoot)) {
 			for _, container := range pod.Spec.Containers {
 				if container.SecurityContext == nil ||
 				   (container.SecurityContext.RunAsNonRoot == nil || !*container.SecurityContext.RunAsNonRoot) {
@@ -2269,86 +2852,102 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 	}
 
 	// 2. Collect suspicious Kubernetes events
-	events, err := clientset.CoreV1().Events("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error listing events for security analysis: %v", err)
-	} else {
-		var suspiciousEvents []map[string]interface{}
-		tenMinutesAgo := time.Now().Add(-10 * time.Minute)
-
-		for _, event := range events.Items {
-			if event.LastTimestamp.Time.Before(tenMinutesAgo) {
-				continue
-			}
+	var suspiciousEvents []map[string]interface{}
+	tenMinutesAgo := time.Now().Add(-10 * time.Minute)
 
-			// Check for security-related events
-			if isSecurityEvent(event.Reason, event.Message) {
-				threatLevel := "medium"
-				if strings.Contains(strings.ToLower(event.Message), "unauthorized") ||
-				   strings.Contains(strings.ToLower(event.Message), "forbidden") ||
-				   strings.Contains(strings.ToLower(event.Message), "denied") {
-					threatLevel = "high"
-				}
+	for _, event := range snap.Events {
+		if event.LastTimestamp.Time.Before(tenMinutesAgo) {
+			continue
+		}
 
-				suspiciousEvents = append(suspiciousEvents, map[string]interface{}{
-					"type":       event.Type,
-					"reason":     event.Reason,
-					"message":    event.Message,
-					"namespace":  event.InvolvedObject.Namespace,
-					"object":     event.InvolvedObject.Name,
-					"kind":       event.InvolvedObject.Kind,
-					"count":      event.Count,
-					"last_time":  event.LastTimestamp.Time,
-					"threat_level": threatLevel,
-				})
+		// Check for security-related events
+		if isSecurityEvent(event.Reason, event.Message) {
+			threatLevel := "medium"
+			if strings.Contains(strings.ToLower(event.Message), "unauthorized") ||
+			   strings.Contains(strings.ToLower(event.Message), "forbidden") ||
+			   strings.Contains(strings.ToLower(event.Message), "denied") {
+				threatLevel = "high"
 			}
+
+			suspiciousEvents = append(suspiciousEvents, map[string]interface{}{
+				"type":       event.Type,
+				"reason":     event.Reason,
+				"message":    event.Message,
+				"namespace":  event.InvolvedObject.Namespace,
+				"object":     event.InvolvedObject.Name,
+				"kind":       event.InvolvedObject.Kind,
+				"count":      event.Count,
+				"last_time":  event.LastTimestamp.Time,
+				"threat_level": threatLevel,
+			})
 		}
-		securityThreatsData["suspicious_events"] = suspiciousEvents
 	}
+	securityThreatsData["suspicious_events"] = suspiciousEvents
 
 	// 3. Check for potential network anomalies via Service configurations
-	services, err := clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("⚠️  Error listing services for security analysis: %v", err)
-	} else {
-		var networkAnomalies []map[string]interface{}
+	var networkAnomalies []map[string]interface{}
 
-		for _, svc := range services.Items {
-			// Skip system namespaces
-			if svc.Namespace == "kube-system" || svc.Namespace == "kube-public" {
-				continue
-			}
+	for _, svc := range snap.Services {
+		// Skip system namespaces
+		if svc.Namespace == "kube-system" || svc.Namespace == "kube-public" {
+			continue
+		}
 
-			// Check for LoadBalancer or NodePort services (potential attack surface)
-			if svc.Spec.Type == corev1.ServiceTypeLoadBalancer || svc.Spec.Type == corev1.ServiceTypeNodePort {
-				for _, port := range svc.Spec.Ports {
-					// Common ports that shouldn't be exposed
-					if isDangerousPort(int(port.Port)) {
-						networkAnomalies = append(networkAnomalies, map[string]interface{}{
-							"service_name": svc.Name,
-							"namespace":    svc.Namespace,
-							"service_type": string(svc.Spec.Type),
-							"port":         port.Port,
-							"target_port":  port.TargetPort.String(),
-							"node_port":    port.NodePort,
-							"threat_level": "high",
-							"reason":       fmt.Sprintf("Dangerous port %d exposed via %s service", port.Port, svc.Spec.Type),
-						})
-					}
+		// Check for LoadBalancer or NodePort services (potential attack surface)
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer || svc.Spec.Type == corev1.ServiceTypeNodePort {
+			ipFamilies := serviceIPFamilies(svc)
+			// IPv6 addresses are often globally routable and bypass IPv4-only firewall rules
+			if serviceExposedOnIPv6(svc) {
+				networkAnomalies = append(networkAnomalies, map[string]interface{}{
+					"service_name": svc.Name,
+					"namespace":    svc.Namespace,
+					"service_type": string(svc.Spec.Type),
+					"ip_families":  ipFamilies,
+					"threat_level": "medium",
+					"reason":       fmt.Sprintf("%s service is exposed on IPv6", svc.Spec.Type),
+				})
+			}
+			for _, port := range svc.Spec.Ports {
+				// Common ports that shouldn't be exposed
+				if isDangerousPort(int(port.Port)) {
+					networkAnomalies = append(networkAnomalies, map[string]interface{}{
+						"service_name": svc.Name,
+						"namespace":    svc.Namespace,
+						"service_type": string(svc.Spec.Type),
+						"port":         port.Port,
+						"target_port":  port.TargetPort.String(),
+						"node_port":    port.NodePort,
+						"ip_families":  ipFamilies,
+						"threat_level": "high",
+						"reason":       fmt.Sprintf("Dangerous port %d exposed via %s service", port.Port, svc.Spec.Type),
+					})
 				}
 			}
 		}
-		securityThreatsData["network_anomalies"] = networkAnomalies
 	}
+	securityThreatsData["network_anomalies"] = networkAnomalies
 
 	securityThreatsData["suspicious_pods"] = suspiciousPods
 	securityThreatsData["privileged_containers"] = privilegedContainers
 	securityThreatsData["host_network_pods"] = hostNetworkPods
 	securityThreatsData["host_pid_pods"] = hostPidPods
+	securityThreatsData["host_socket_mounts"] = hostSocketPods
+	if dsErr == nil {
+		securityThreatsData["privileged_daemonsets"] = summarizePrivilegedDaemonSets(daemonSets)
+	}
 	securityThreatsData["resource_anomalies"] = resourceAnomalies
 
+	// Stable IDs and first/last seen for every entry, plus what went away
+	stampPodOwners(securityThreatsData, snap.Pods)
+	resolvedFindings := threatFindings.track(securityThreatsData, snap.Errors, snap.TakenAt)
+	securityThreatsData["resolved_findings"] = resolvedFindings
+	// Accepted risks keep their IDs but leave the threat lists
+	suppressedFindings := applySuppressions(securityThreatsData, suppressions, getSettings().Suppressions)
+	securityThreatsData["suppressed_findings"] = suppressedFindings
+	threatFindings.markSuppressed(suppressedFindings)
+
 	// Log summary
-	totalThreats := len(suspiciousPods) + len(privilegedContainers) + len(hostNetworkPods) + len(hostPidPods) + len(resourceAnomalies)
+	totalThreats := len(suspiciousPods) + len(privilegedContainers) + len(hostNetworkPods) + len(hostPidPods) + len(hostSocketPods) + len(resourceAnomalies)
 	log.Printf("🔒 Security threats scan complete: %d potential threats detected", totalThreats)
 
 	if totalThreats > 0 {
@@ -2356,8 +2955,16 @@ func collectSecurityThreatsData(clientset *kubernetes.Clientset) map[string]inte
 		log.Printf("   - Privileged containers: %d", len(privilegedContainers))
 		log.Printf("   - Host network pods: %d", len(hostNetworkPods))
 		log.Printf("   - Host PID pods: %d", len(hostPidPods))
+		log.Printf("   - Host socket mounts: %d", len(hostSocketPods))
+		log.Printf("   - Privileged DaemonSets: %d", len(daemonSets))
 		log.Printf("   - Resource anomalies: %d", len(resourceAnomalies))
 	}
+	if len(suppressedFindings) > 0 {
+		log.Printf("   - Suppressed findings: %d", len(suppressedFindings))
+	}
+	if len(resolvedFindings) > 0 {
+		log.Printf("   - Resolved findings (last %s): %d", resolvedFindingRetention, len(resolvedFindings))
+	}
 
 	return securityThreatsData
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// ---------------------------------------------
+// GET MANIFEST COMMAND
+// Returns the sanitized YAML of a resource for the "view YAML" panel
+// ---------------------------------------------
+
+// redactedValue replaces any value the agent must not send to the backend
+const redactedValue = "<redacted>"
+
+// sensitiveEnvMarkers flag env vars whose literal values are likely secrets
+var sensitiveEnvMarkers = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "APIKEY", "API_KEY", "PRIVATE", "CREDENTIAL"}
+
+func getManifest(clientset kubernetes.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p ManifestParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	kind, name, namespace := p.Kind, p.Name, p.Namespace
+
+	ctx, cancel := apiContext()
+	defer cancel()
+
+	var obj runtime.Object
+	var apiVersion, kindName string
+	var err error
+
+	switch strings.ToLower(kind) {
+	case "pod":
+		pod, getErr := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			sanitizePodSpec(&pod.Spec)
+			sanitizeObjectMeta(&pod.ObjectMeta)
+			obj = pod
+		}
+		apiVersion, kindName = "v1", "Pod"
+	case "deployment":
+		deployment, getErr := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			sanitizePodSpec(&deployment.Spec.Template.Spec)
+			sanitizeObjectMeta(&deployment.ObjectMeta)
+			obj = deployment
+		}
+		apiVersion, kindName = "apps/v1", "Deployment"
+	case "statefulset":
+		statefulSet, getErr := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			sanitizePodSpec(&statefulSet.Spec.Template.Spec)
+			sanitizeObjectMeta(&statefulSet.ObjectMeta)
+			obj = statefulSet
+		}
+		apiVersion, kindName = "apps/v1", "StatefulSet"
+	case "daemonset":
+		daemonSet, getErr := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			sanitizePodSpec(&daemonSet.Spec.Template.Spec)
+			sanitizeObjectMeta(&daemonSet.ObjectMeta)
+			obj = daemonSet
+		}
+		apiVersion, kindName = "apps/v1", "DaemonSet"
+	case "service":
+		service, getErr := clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			sanitizeObjectMeta(&service.ObjectMeta)
+			obj = service
+		}
+		apiVersion, kindName = "v1", "Service"
+	case "configmap":
+		configMap, getErr := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			sanitizeObjectMeta(&configMap.ObjectMeta)
+			obj = configMap
+		}
+		apiVersion, kindName = "v1", "ConfigMap"
+	case "secret":
+		secret, getErr := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		err = getErr
+		if err == nil {
+			// Keep the keys so the UI can show the shape, never the values
+			for key := range secret.Data {
+				secret.Data[key] = []byte(redactedValue)
+			}
+			for key := range secret.StringData {
+				secret.StringData[key] = redactedValue
+			}
+			sanitizeObjectMeta(&secret.ObjectMeta)
+			obj = secret
+		}
+		apiVersion, kindName = "v1", "Secret"
+	default:
+		return nil, fmt.Errorf("unsupported kind: %s (supported: pod, deployment, statefulset, daemonset, service, configmap, secret)", kind)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s/%s: %v", kind, namespace, name, err)
+	}
+
+	// Objects returned by typed clients have an empty TypeMeta
+	obj.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(apiVersion, kindName))
+
+	manifest, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s %s/%s as YAML: %v", kind, namespace, name, err)
+	}
+
+	log.Printf("📄 Fetched manifest for %s %s/%s (%d bytes)", kindName, namespace, name, len(manifest))
+
+	return map[string]interface{}{
+		"action":    "get_manifest",
+		"kind":      kindName,
+		"name":      name,
+		"namespace": namespace,
+		"yaml":      string(manifest),
+	}, nil
+}
+
+// sanitizeObjectMeta drops server-side noise and annotations that may embed full specs
+func sanitizeObjectMeta(meta *metav1.ObjectMeta) {
+	meta.ManagedFields = nil
+	delete(meta.Annotations, "kubectl.kubernetes.io/last-applied-configuration")
+}
+
+// sanitizePodSpec redacts literal env values whose names look like credentials
+func sanitizePodSpec(spec *corev1.PodSpec) {
+	redact := func(vars []corev1.EnvVar) {
+		for i := range vars {
+			if vars[i].Value != "" && isSensitiveName(vars[i].Name) {
+				vars[i].Value = redactedValue
+			}
+		}
+	}
+	for i := range spec.InitContainers {
+		redact(spec.InitContainers[i].Env)
+	}
+	for i := range spec.Containers {
+		redact(spec.Containers[i].Env)
+	}
+	for i := range spec.EphemeralContainers {
+		redact(spec.EphemeralContainers[i].Env)
+	}
+}
+
+// isSensitiveName reports whether a variable or key name looks like it holds a secret
+func isSensitiveName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, marker := range sensitiveEnvMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
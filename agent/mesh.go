@@ -0,0 +1,233 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// SERVICE MESH (Istio + Linkerd)
+// Detects installed meshes, reports sidecar coverage per namespace and,
+// when enabled, success rate / latency from the mesh's Prometheus metrics
+// ---------------------------------------------
+
+const (
+	meshIstio   = "istio"
+	meshLinkerd = "linkerd"
+)
+
+// meshTelemetryQueries are per-namespace success rate and p95 latency (ms) queries
+var meshTelemetryQueries = map[string][]PromQuery{
+	meshIstio: {
+		{Name: "success_rate", Query: `sum by (destination_workload_namespace) (rate(istio_requests_total{reporter="destination",response_code!~"5.."}[5m])) / sum by (destination_workload_namespace) (rate(istio_requests_total{reporter="destination"}[5m]))`},
+		{Name: "p95_latency_ms", Query: `histogram_quantile(0.95, sum by (le, destination_workload_namespace) (rate(istio_request_duration_milliseconds_bucket{reporter="destination"}[5m])))`},
+	},
+	meshLinkerd: {
+		{Name: "success_rate", Query: `sum by (namespace) (rate(response_total{direction="inbound",classification="success"}[5m])) / sum by (namespace) (rate(response_total{direction="inbound"}[5m]))`},
+		{Name: "p95_latency_ms", Query: `histogram_quantile(0.95, sum by (le, namespace) (rate(response_latency_ms_bucket{direction="inbound"}[5m])))`},
+	},
+}
+
+// meshTelemetryNamespaceLabel is the label carrying the namespace in each mesh's series
+var meshTelemetryNamespaceLabel = map[string]string{
+	meshIstio:   "destination_workload_namespace",
+	meshLinkerd: "namespace",
+}
+
+// collectServiceMesh builds the "mesh" metric from the snapshot
+func collectServiceMesh(snap *ClusterSnapshot, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("mesh", time.Now())
+
+	meshes := detectMeshes(snap)
+
+	type coverage struct {
+		pods, meshed   int
+		injection      string
+		meshesInjected map[string]bool
+	}
+	byNamespace := map[string]*coverage{}
+	for _, ns := range snap.Namespaces {
+		byNamespace[ns.Name] = &coverage{injection: namespaceInjection(ns), meshesInjected: map[string]bool{}}
+	}
+
+	for _, pod := range snap.Pods {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		c, ok := byNamespace[pod.Namespace]
+		if !ok {
+			c = &coverage{meshesInjected: map[string]bool{}}
+			byNamespace[pod.Namespace] = c
+		}
+		c.pods++
+		if mesh := podSidecarMesh(pod); mesh != "" {
+			c.meshed++
+			c.meshesInjected[mesh] = true
+		}
+	}
+
+	var namespaces []map[string]interface{}
+	totalPods, totalMeshed := 0, 0
+	for name, c := range byNamespace {
+		if c.pods == 0 && c.injection == "" {
+			continue
+		}
+		var injected []string
+		for mesh := range c.meshesInjected {
+			injected = append(injected, mesh)
+		}
+		sort.Strings(injected)
+
+		coveragePercent := float64(0)
+		if c.pods > 0 {
+			coveragePercent = float64(c.meshed) / float64(c.pods) * 100
+		}
+		namespaces = append(namespaces, map[string]interface{}{
+			"namespace":        name,
+			"injection":        c.injection,
+			"pods":             c.pods,
+			"meshed_pods":      c.meshed,
+			"coverage_percent": coveragePercent,
+			"meshes":           injected,
+		})
+		totalPods += c.pods
+		totalMeshed += c.meshed
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i]["namespace"].(string) < namespaces[j]["namespace"].(string)
+	})
+
+	result := map[string]interface{}{
+		"meshes":      meshes,
+		"namespaces":  namespaces,
+		"pods":        totalPods,
+		"meshed_pods": totalMeshed,
+	}
+
+	if settings.MeshTelemetry && len(meshes) > 0 {
+		if settings.CustomMetrics.PrometheusURL == "" {
+			log.Printf("⚠️  Mesh telemetry enabled but no Prometheus URL is configured")
+		} else {
+			result["telemetry"] = collectMeshTelemetry(settings.CustomMetrics.PrometheusURL, meshes, status)
+		}
+	}
+
+	return result
+}
+
+// detectMeshes finds mesh control planes among the snapshot pods
+func detectMeshes(snap *ClusterSnapshot) []map[string]interface{} {
+	found := map[string]map[string]interface{}{}
+	for _, pod := range snap.Pods {
+		var mesh string
+		switch {
+		case pod.Labels["app"] == "istiod" || pod.Labels["istio"] == "pilot":
+			mesh = meshIstio
+		case pod.Labels["linkerd.io/control-plane-component"] == "destination":
+			mesh = meshLinkerd
+		default:
+			continue
+		}
+
+		info, ok := found[mesh]
+		if !ok {
+			info = map[string]interface{}{
+				"name":          mesh,
+				"namespace":     pod.Namespace,
+				"version":       meshVersion(pod),
+				"control_plane": 0,
+				"ready":         0,
+			}
+			found[mesh] = info
+		}
+		info["control_plane"] = info["control_plane"].(int) + 1
+		if isPodReady(pod) {
+			info["ready"] = info["ready"].(int) + 1
+		}
+	}
+
+	var meshes []map[string]interface{}
+	for _, info := range found {
+		meshes = append(meshes, info)
+	}
+	sort.Slice(meshes, func(i, j int) bool { return meshes[i]["name"].(string) < meshes[j]["name"].(string) })
+	return meshes
+}
+
+// meshVersion reads the control plane version from labels, falling back to the image tag
+func meshVersion(pod corev1.Pod) string {
+	for _, key := range []string{"istio.io/rev", "linkerd.io/control-plane-version", "app.kubernetes.io/version"} {
+		if v := pod.Labels[key]; v != "" && v != "default" {
+			return v
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		if i := strings.LastIndex(c.Image, ":"); i > 0 && !strings.Contains(c.Image[i:], "/") {
+			return c.Image[i+1:]
+		}
+	}
+	return ""
+}
+
+// namespaceInjection reports which mesh auto-injects sidecars into a namespace
+func namespaceInjection(ns corev1.Namespace) string {
+	if ns.Labels["istio-injection"] == "enabled" || ns.Labels["istio.io/rev"] != "" {
+		return meshIstio
+	}
+	if ns.Annotations["linkerd.io/inject"] == "enabled" {
+		return meshLinkerd
+	}
+	return ""
+}
+
+// podSidecarMesh returns the mesh whose proxy runs in the pod, or ""
+func podSidecarMesh(pod corev1.Pod) string {
+	// Native sidecars (restartable init containers) count as well
+	containers := append(append([]corev1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...)
+	for _, c := range containers {
+		switch c.Name {
+		case "istio-proxy":
+			return meshIstio
+		case "linkerd-proxy":
+			return meshLinkerd
+		}
+	}
+	return ""
+}
+
+// collectMeshTelemetry queries per-namespace success rate and latency for each mesh
+func collectMeshTelemetry(prometheusURL string, meshes []map[string]interface{}, status *CollectorStatus) map[string]interface{} {
+	client := &http.Client{Timeout: scrapeTimeout}
+	telemetry := map[string]interface{}{}
+
+	for _, m := range meshes {
+		mesh := m["name"].(string)
+		nsLabel := meshTelemetryNamespaceLabel[mesh]
+		perNamespace := map[string]map[string]float64{}
+
+		for _, q := range meshTelemetryQueries[mesh] {
+			series, err := queryPrometheus(client, prometheusURL, q)
+			if err != nil {
+				status.Partial(mesh+"_"+q.Name, err)
+				continue
+			}
+			for _, s := range series {
+				ns := s.Labels[nsLabel]
+				if ns == "" {
+					continue
+				}
+				if perNamespace[ns] == nil {
+					perNamespace[ns] = map[string]float64{}
+				}
+				perNamespace[ns][q.Name] = s.Value
+			}
+		}
+		telemetry[mesh] = perNamespace
+	}
+	return telemetry
+}
@@ -0,0 +1,34 @@
+package main
+
+import "strings"
+
+// ---------------------------------------------
+// METADATA SELECTION
+// Labels and annotations are only forwarded when their key is listed, so
+// payloads stay small and values nobody asked for never leave the cluster.
+// A key ending in "*" selects every key with that prefix
+// ("team.example.com/*").
+// ---------------------------------------------
+
+// selectMetadata returns the entries of values whose key is selected, or nil
+func selectMetadata(values map[string]string, keys []string) map[string]string {
+	if len(values) == 0 || len(keys) == 0 {
+		return nil
+	}
+	selected := map[string]string{}
+	for _, key := range keys {
+		if prefix, ok := strings.CutSuffix(key, "*"); ok {
+			for k, v := range values {
+				if strings.HasPrefix(k, prefix) {
+					selected[k] = v
+				}
+			}
+		} else if v, ok := values[key]; ok {
+			selected[key] = v
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return selected
+}
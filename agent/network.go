@@ -0,0 +1,367 @@
+package main
+
+import (
+	"log"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CNI DETECTION AND NETWORK HEALTH
+// Identifies the network plugin, its DaemonSet health, IP exhaustion and
+// nodes whose network is not ready, correlated with the CNI pod on the node
+// ---------------------------------------------
+
+// cniDaemonSets maps known CNI DaemonSet names to the plugin they belong to
+var cniDaemonSets = map[string]string{
+	"calico-node":     "calico",
+	"cilium":          "cilium",
+	"kube-flannel-ds": "flannel",
+	"kube-flannel":    "flannel",
+	"aws-node":        "aws-vpc-cni",
+}
+
+var (
+	calicoIPPoolsGVR    = schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1", Resource: "ippools"}
+	calicoIPAMBlocksGVR = schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1", Resource: "ipamblocks"}
+	ciliumNodesGVR      = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnodes"}
+)
+
+// ipExhaustionPercent is the usage above which an IP pool is flagged
+const ipExhaustionPercent = 85
+
+// ipAllocationFailureMarkers match sandbox events caused by IP exhaustion
+var ipAllocationFailureMarkers = []string{
+	"failed to assign an ip address",
+	"no ip addresses available",
+	"failed to allocate for range",
+	"insufficientcidrblocks",
+	"ipam: no more free ips",
+}
+
+// collectNetworkHealth builds the "network" metric
+func collectNetworkHealth(clientset kubernetes.Interface, dynamicClient dynamic.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("network", time.Now())
+
+	ctx, cancel := apiContext()
+	daemonSets, err := clientset.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing DaemonSets: %v", err)
+		status.Partial("daemonsets", err)
+	}
+
+	var plugins []map[string]interface{}
+	cniPodsByNode := map[string]map[string]bool{} // node -> cni name -> ready
+	if daemonSets != nil {
+		for _, ds := range daemonSets.Items {
+			plugin, ok := cniDaemonSets[ds.Name]
+			if !ok {
+				continue
+			}
+			plugins = append(plugins, describeCNIDaemonSet(plugin, ds))
+			for _, pod := range snap.Pods {
+				if pod.Namespace != ds.Namespace || !podOwnedBy(pod, "DaemonSet", ds.Name) {
+					continue
+				}
+				if cniPodsByNode[pod.Spec.NodeName] == nil {
+					cniPodsByNode[pod.Spec.NodeName] = map[string]bool{}
+				}
+				cniPodsByNode[pod.Spec.NodeName][plugin] = isPodReady(pod)
+			}
+		}
+	}
+
+	result := map[string]interface{}{
+		"plugins":        plugins,
+		"node_ip_usage":  nodePodCIDRUsage(snap),
+		"network_issues": nodeNetworkIssues(snap, cniPodsByNode),
+		"ip_families":    collectIPFamilies(snap),
+	}
+
+	for _, p := range plugins {
+		switch p["name"] {
+		case "calico":
+			result["calico_ip_pools"] = calicoIPPoolUsage(dynamicClient, status)
+		case "cilium":
+			result["cilium_ipam"] = ciliumIPAMUsage(dynamicClient, status)
+		}
+	}
+
+	log.Printf("🌐 Network: %d CNI plugins detected", len(plugins))
+	return result
+}
+
+func describeCNIDaemonSet(plugin string, ds appsv1.DaemonSet) map[string]interface{} {
+	version := ""
+	if len(ds.Spec.Template.Spec.Containers) > 0 {
+		image := ds.Spec.Template.Spec.Containers[0].Image
+		if i := strings.LastIndex(image, ":"); i > 0 && !strings.Contains(image[i:], "/") {
+			version = image[i+1:]
+		}
+	}
+	return map[string]interface{}{
+		"name":          plugin,
+		"daemonset":     ds.Name,
+		"namespace":     ds.Namespace,
+		"version":       version,
+		"desired":       ds.Status.DesiredNumberScheduled,
+		"ready":         ds.Status.NumberReady,
+		"available":     ds.Status.NumberAvailable,
+		"updated":       ds.Status.UpdatedNumberScheduled,
+		"unavailable":   ds.Status.NumberUnavailable,
+		"misscheduled":  ds.Status.NumberMisscheduled,
+		"healthy":       ds.Status.NumberReady == ds.Status.DesiredNumberScheduled && ds.Status.NumberUnavailable == 0,
+		"rollout_stale": ds.Status.ObservedGeneration < ds.Generation,
+	}
+}
+
+// podOwnedBy reports whether the pod's controller is the given kind/name
+func podOwnedBy(pod corev1.Pod, kind, name string) bool {
+	owner := metav1.GetControllerOf(&pod)
+	return owner != nil && owner.Kind == kind && owner.Name == name
+}
+
+// nodePodCIDRUsage compares pods on each node with the size of its podCIDR
+// (host-local IPAM used by Flannel and many Calico installs)
+func nodePodCIDRUsage(snap *ClusterSnapshot) []map[string]interface{} {
+	podIPsByNode := map[string]int{}
+	for _, pod := range snap.Pods {
+		if pod.Spec.HostNetwork || pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		podIPsByNode[pod.Spec.NodeName]++
+	}
+
+	var usage []map[string]interface{}
+	for _, node := range snap.Nodes {
+		if node.Spec.PodCIDR == "" {
+			continue
+		}
+		size := cidrUsableAddresses(node.Spec.PodCIDR)
+		if size <= 0 {
+			continue
+		}
+		used := podIPsByNode[node.Name]
+		percent := float64(used) / float64(size) * 100
+		usage = append(usage, map[string]interface{}{
+			"node":          node.Name,
+			"pod_cidr":      node.Spec.PodCIDR,
+			"capacity":      size,
+			"used":          used,
+			"usage_percent": percent,
+			"exhausted":     percent >= ipExhaustionPercent,
+		})
+	}
+	return usage
+}
+
+// cidrUsableAddresses returns the host addresses in a CIDR, capped for IPv6
+func cidrUsableAddresses(cidr string) int64 {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0
+	}
+	ones, bits := network.Mask.Size()
+	hostBits := bits - ones
+	if hostBits >= 62 {
+		return 1 << 62
+	}
+	size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits)).Int64()
+	if size > 2 {
+		size -= 2 // network and broadcast/gateway
+	}
+	return size
+}
+
+// nodeNetworkIssues lists nodes whose network is not ready or whose pods
+// failed to get an IP, with the state of the CNI pod on that node
+func nodeNetworkIssues(snap *ClusterSnapshot, cniPodsByNode map[string]map[string]bool) []map[string]interface{} {
+	ipFailures := map[string]int{}
+	notReadyEvents := map[string]int{}
+	for _, event := range snap.Events {
+		message := strings.ToLower(event.Message)
+		node := event.Source.Host
+		switch {
+		case event.Reason == "NetworkNotReady" || strings.Contains(message, "network is not ready"):
+			notReadyEvents[node] += int(maxInt32(event.Count, 1))
+		case event.Reason == "FailedCreatePodSandBox" && containsAny(message, ipAllocationFailureMarkers):
+			ipFailures[node] += int(maxInt32(event.Count, 1))
+		}
+	}
+
+	var issues []map[string]interface{}
+	for _, node := range snap.Nodes {
+		var reasons []string
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == corev1.NodeNetworkUnavailable && cond.Status == corev1.ConditionTrue {
+				reasons = append(reasons, "NetworkUnavailable: "+cond.Message)
+			}
+			if cond.Type == corev1.NodeReady && cond.Status != corev1.ConditionTrue {
+				msg := strings.ToLower(cond.Message)
+				if strings.Contains(msg, "network plugin") || strings.Contains(msg, "networkpluginnotready") || strings.Contains(msg, "cni") {
+					reasons = append(reasons, "NotReady: "+cond.Message)
+				}
+			}
+		}
+		if notReadyEvents[node.Name] > 0 {
+			reasons = append(reasons, "NetworkNotReady events")
+		}
+		if ipFailures[node.Name] > 0 {
+			reasons = append(reasons, "IP allocation failures")
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		cniPods := cniPodsByNode[node.Name]
+		likelyCause := "unknown"
+		if len(cniPods) == 0 {
+			likelyCause = "no CNI pod running on node"
+		} else {
+			for plugin, ready := range cniPods {
+				if !ready {
+					likelyCause = plugin + " pod not ready"
+				}
+			}
+			if likelyCause == "unknown" && ipFailures[node.Name] > 0 {
+				likelyCause = "IP pool exhausted"
+			}
+		}
+
+		issues = append(issues, map[string]interface{}{
+			"node":                   node.Name,
+			"reasons":                reasons,
+			"network_not_ready":      notReadyEvents[node.Name],
+			"ip_allocation_failures": ipFailures[node.Name],
+			"cni_pods_ready":         cniPods,
+			"likely_cause":           likelyCause,
+		})
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i]["node"].(string) < issues[j]["node"].(string) })
+	return issues
+}
+
+// calicoIPPoolUsage sums Calico IPAM block allocations per IP pool
+func calicoIPPoolUsage(dynamicClient dynamic.Interface, status *CollectorStatus) []map[string]interface{} {
+	pools, ok := listNetworkCRD(dynamicClient, calicoIPPoolsGVR, status)
+	if !ok {
+		return nil
+	}
+	blocks, _ := listNetworkCRD(dynamicClient, calicoIPAMBlocksGVR, status)
+
+	var result []map[string]interface{}
+	for _, pool := range pools {
+		cidr, _, _ := unstructured.NestedString(pool.Object, "spec", "cidr")
+		disabled, _, _ := unstructured.NestedBool(pool.Object, "spec", "disabled")
+		_, poolNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+
+		var allocated int64
+		for _, block := range blocks {
+			blockCIDR, _, _ := unstructured.NestedString(block.Object, "spec", "cidr")
+			blockIP, _, err := net.ParseCIDR(blockCIDR)
+			if err != nil || !poolNet.Contains(blockIP) {
+				continue
+			}
+			allocations, _, _ := unstructured.NestedSlice(block.Object, "spec", "allocations")
+			for _, a := range allocations {
+				if a != nil {
+					allocated++
+				}
+			}
+		}
+
+		capacity := cidrUsableAddresses(cidr)
+		percent := float64(0)
+		if capacity > 0 {
+			percent = float64(allocated) / float64(capacity) * 100
+		}
+		result = append(result, map[string]interface{}{
+			"name":          pool.GetName(),
+			"cidr":          cidr,
+			"disabled":      disabled,
+			"capacity":      capacity,
+			"allocated":     allocated,
+			"usage_percent": percent,
+			"exhausted":     percent >= ipExhaustionPercent,
+		})
+	}
+	return result
+}
+
+// ciliumIPAMUsage reports per-node IPAM pool usage from CiliumNode objects
+func ciliumIPAMUsage(dynamicClient dynamic.Interface, status *CollectorStatus) []map[string]interface{} {
+	nodes, ok := listNetworkCRD(dynamicClient, ciliumNodesGVR, status)
+	if !ok {
+		return nil
+	}
+
+	var result []map[string]interface{}
+	for _, node := range nodes {
+		pool, _, _ := unstructured.NestedMap(node.Object, "spec", "ipam", "pool")
+		used, _, _ := unstructured.NestedMap(node.Object, "status", "ipam", "used")
+		if len(pool) == 0 {
+			// cluster-pool mode only publishes podCIDRs; covered by node_ip_usage
+			continue
+		}
+		percent := float64(len(used)) / float64(len(pool)) * 100
+		result = append(result, map[string]interface{}{
+			"node":          node.GetName(),
+			"capacity":      len(pool),
+			"used":          len(used),
+			"usage_percent": percent,
+			"exhausted":     percent >= ipExhaustionPercent,
+		})
+	}
+	return result
+}
+
+// listNetworkCRD lists a CNI custom resource; a missing CRD is not an error
+func listNetworkCRD(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, status *CollectorStatus) ([]unstructured.Unstructured, bool) {
+	if dynamicClient == nil {
+		return nil, false
+	}
+	ctx, cancel := apiContext()
+	list, err := dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+	cancel()
+	if apierrors.IsNotFound(err) {
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("⚠️  Error listing %s: %v", gvr.Resource, err)
+		status.Partial(gvr.Resource, err)
+		return nil, false
+	}
+	return list.Items, true
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
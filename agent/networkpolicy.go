@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// NETWORK POLICY TEMPLATES COMMAND
+// Remediates "no NetworkPolicy" findings by applying a default-deny or a
+// templated allow policy to a namespace (server-side apply, so re-running
+// the command updates the policy instead of failing)
+// ---------------------------------------------
+
+// networkPolicyTemplates build the spec for each template; p has been validated
+var networkPolicyTemplates = map[string]func(p NetworkPolicyParams) networkingv1.NetworkPolicySpec{
+	"default-deny-ingress": func(p NetworkPolicyParams) networkingv1.NetworkPolicySpec {
+		return networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: p.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		}
+	},
+	"default-deny-all": func(p NetworkPolicyParams) networkingv1.NetworkPolicySpec {
+		return networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: p.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		}
+	},
+	"allow-same-namespace": func(p NetworkPolicyParams) networkingv1.NetworkPolicySpec {
+		return networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: p.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+				Ports: networkPolicyPorts(p.Ports, corev1.ProtocolTCP),
+			}},
+		}
+	},
+	"allow-from-namespace": func(p NetworkPolicyParams) networkingv1.NetworkPolicySpec {
+		return networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: p.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"kubernetes.io/metadata.name": p.FromNamespace},
+					},
+				}},
+				Ports: networkPolicyPorts(p.Ports, corev1.ProtocolTCP),
+			}},
+		}
+	},
+	// Companion to default-deny-all so pods can still resolve names
+	"allow-dns-egress": func(p NetworkPolicyParams) networkingv1.NetworkPolicySpec {
+		return networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: p.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"kubernetes.io/metadata.name": "kube-system"},
+					},
+					PodSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"k8s-app": "kube-dns"},
+					},
+				}},
+				Ports: append(networkPolicyPorts([]int32{53}, corev1.ProtocolUDP), networkPolicyPorts([]int32{53}, corev1.ProtocolTCP)...),
+			}},
+		}
+	},
+}
+
+func networkPolicyTemplateNames() map[string]bool {
+	names := map[string]bool{}
+	for name := range networkPolicyTemplates {
+		names[name] = true
+	}
+	return names
+}
+
+func networkPolicyPorts(ports []int32, protocol corev1.Protocol) []networkingv1.NetworkPolicyPort {
+	var result []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		port := intstr.FromInt32(port)
+		proto := protocol
+		result = append(result, networkingv1.NetworkPolicyPort{Protocol: &proto, Port: &port})
+	}
+	return result
+}
+
+// applyNetworkPolicy implements the "apply_network_policy" command
+func applyNetworkPolicy(clientset kubernetes.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p NetworkPolicyParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	name := p.PolicyName
+	if name == "" {
+		name = p.Template
+	}
+
+	policy := networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": fieldManager},
+			Annotations: map[string]string{
+				"kuberpulse.io/template": p.Template,
+			},
+		},
+		Spec: networkPolicyTemplates[p.Template](p),
+	}
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	force := true
+	options := metav1.PatchOptions{FieldManager: fieldManager, Force: &force}
+	if p.DryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	log.Printf("🧱 [audit] Applying NetworkPolicy %s/%s from template %s (dry_run=%v)", p.Namespace, name, p.Template, p.DryRun)
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	applied, err := clientset.NetworkingV1().NetworkPolicies(p.Namespace).Patch(ctx, name, types.ApplyPatchType, body, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply NetworkPolicy %s/%s: %w", p.Namespace, name, err)
+	}
+
+	return map[string]interface{}{
+		"action":           "network_policy_applied",
+		"policy":           name,
+		"namespace":        p.Namespace,
+		"template":         p.Template,
+		"dry_run":          p.DryRun,
+		"policy_types":     applied.Spec.PolicyTypes,
+		"resource_version": applied.ResourceVersion,
+		"uid":              string(applied.UID),
+		"message":          "NetworkPolicy applied. Enforcement requires a CNI that supports NetworkPolicy.",
+	}, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// NODE CONDITION DURATIONS
+// How long each node has been NotReady or under pressure, plus the
+// transitions the agent itself observed, so a flapping node can be told
+// apart from a hard failure
+// ---------------------------------------------
+
+const (
+	// nodeFlapWindow is how far back observed transitions are kept
+	nodeFlapWindow = time.Hour
+	// nodeFlapThreshold is the number of Ready transitions within the window that counts as flapping
+	nodeFlapThreshold = 3
+	// nodeHardFailureAfter is how long a node must stay NotReady to be a hard failure
+	nodeHardFailureAfter = 15 * time.Minute
+)
+
+// trackedNodeConditions are the conditions whose durations are reported
+var trackedNodeConditions = []corev1.NodeConditionType{
+	corev1.NodeReady,
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+}
+
+// nodeConditionHistory remembers the last observed condition statuses and the
+// Ready transitions seen between cycles (lost on restart; the API's
+// lastTransitionTime still gives the current duration)
+type nodeConditionHistory struct {
+	mu          sync.Mutex
+	lastStatus  map[string]map[corev1.NodeConditionType]corev1.ConditionStatus
+	transitions map[string][]time.Time
+}
+
+var nodeHistory = &nodeConditionHistory{
+	lastStatus:  map[string]map[corev1.NodeConditionType]corev1.ConditionStatus{},
+	transitions: map[string][]time.Time{},
+}
+
+// observe records Ready transitions and returns each node's transitions within the window
+func (h *nodeConditionHistory) observe(nodes []corev1.Node, now time.Time) map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := map[string]int{}
+	seen := map[string]bool{}
+	for _, node := range nodes {
+		seen[node.Name] = true
+		previous := h.lastStatus[node.Name]
+		current := map[corev1.NodeConditionType]corev1.ConditionStatus{}
+		for _, cond := range node.Status.Conditions {
+			current[cond.Type] = cond.Status
+		}
+		if previous != nil && previous[corev1.NodeReady] != current[corev1.NodeReady] {
+			h.transitions[node.Name] = append(h.transitions[node.Name], now)
+		}
+		h.lastStatus[node.Name] = current
+
+		kept := h.transitions[node.Name][:0]
+		for _, t := range h.transitions[node.Name] {
+			if now.Sub(t) <= nodeFlapWindow {
+				kept = append(kept, t)
+			}
+		}
+		h.transitions[node.Name] = kept
+		counts[node.Name] = len(kept)
+	}
+
+	// Forget nodes that left the cluster
+	for name := range h.lastStatus {
+		if !seen[name] {
+			delete(h.lastStatus, name)
+			delete(h.transitions, name)
+		}
+	}
+	return counts
+}
+
+// collectNodeConditions builds the "node_conditions" metric
+func collectNodeConditions(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("node_conditions", time.Now())
+
+	now := snap.TakenAt
+	transitions := nodeHistory.observe(snap.Nodes, now)
+
+	var nodes []map[string]interface{}
+	summary := map[string]int{"healthy": 0, "under_pressure": 0, "flapping": 0, "not_ready": 0, "hard_failure": 0}
+	for _, node := range snap.Nodes {
+		conditions := map[string]interface{}{}
+		ready := false
+		var notReadyFor time.Duration
+		var pressure []string
+
+		for _, cond := range node.Status.Conditions {
+			if !isTrackedNodeCondition(cond.Type) {
+				continue
+			}
+			since := now.Sub(cond.LastTransitionTime.Time)
+			conditions[string(cond.Type)] = map[string]interface{}{
+				"status":           string(cond.Status),
+				"reason":           cond.Reason,
+				"since":            cond.LastTransitionTime.Time,
+				"duration_seconds": int64(since.Seconds()),
+				"last_heartbeat":   cond.LastHeartbeatTime.Time,
+			}
+			if cond.Type == corev1.NodeReady {
+				ready = cond.Status == corev1.ConditionTrue
+				if !ready {
+					notReadyFor = since
+				}
+			} else if cond.Status == corev1.ConditionTrue {
+				pressure = append(pressure, string(cond.Type))
+			}
+		}
+
+		state := "healthy"
+		switch {
+		case !ready && notReadyFor >= nodeHardFailureAfter:
+			state = "hard_failure"
+		case transitions[node.Name] >= nodeFlapThreshold:
+			state = "flapping"
+		case !ready:
+			state = "not_ready"
+		case len(pressure) > 0:
+			state = "under_pressure"
+		}
+		summary[state]++
+
+		nodes = append(nodes, map[string]interface{}{
+			"node":                     node.Name,
+			"state":                    state,
+			"ready":                    ready,
+			"not_ready_seconds":        int64(notReadyFor.Seconds()),
+			"pressure":                 pressure,
+			"unschedulable":            node.Spec.Unschedulable,
+			"ready_transitions_window": transitions[node.Name],
+			"conditions":               conditions,
+		})
+	}
+
+	return map[string]interface{}{
+		"nodes":               nodes,
+		"summary":             summary,
+		"flap_window_seconds": int64(nodeFlapWindow.Seconds()),
+	}
+}
+
+func isTrackedNodeCondition(t corev1.NodeConditionType) bool {
+	for _, tracked := range trackedNodeConditions {
+		if t == tracked {
+			return true
+		}
+	}
+	return false
+}
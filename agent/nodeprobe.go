@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------
+// NODE PROBE
+// `kodo-agent node-probe` runs as an opt-in privileged DaemonSet
+// (kubernetes/node-probe.yaml, hostPID) and samples the TCP sockets of every
+// pod network namespace on its node from /proc. It only serves aggregates:
+// per pod, how many outbound connections it has and to how many distinct
+// remote IPs and ports, never payloads. The agent fetches them through the
+// API server pod proxy (see connections.go), presenting the shared token of
+// the kodo-node-probe Secret; the probe refuses to start without it and
+// listens only on its pod IP.
+// ---------------------------------------------
+
+const (
+	// nodeProbePort is where the probe serves /connections
+	nodeProbePort = 9465
+	// nodeProbeTokenHeader carries the shared token; the API server proxy
+	// strips Authorization after authenticating the agent, so it is custom
+	nodeProbeTokenHeader = "X-Kodo-Probe-Token"
+	// probeTopRemotes is how many remote IPs are listed per pod
+	probeTopRemotes = 5
+)
+
+// TCP states in /proc/net/tcp
+const (
+	tcpEstablished = "01"
+	tcpSynSent     = "02"
+	tcpListen      = "0A"
+)
+
+// podUIDRe finds the pod UID in a cgroup path, for both the cgroupfs
+// ("pod1b2c...-...") and systemd ("kubepods-pod1b2c..._....slice") drivers
+var podUIDRe = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+// remoteCount is one remote IP a pod talks to
+type remoteCount struct {
+	IP          string `json:"ip"`
+	Connections int    `json:"connections"`
+	Ports       int    `json:"ports"`
+}
+
+// podConnections is the outbound TCP picture of one pod network namespace
+type podConnections struct {
+	PodUID        string        `json:"pod_uid"`
+	Established   int           `json:"established"`
+	SynSent       int           `json:"syn_sent"`
+	RemoteIPs     int           `json:"remote_ips"`
+	RemotePorts   int           `json:"remote_ports"`
+	MaxPortsPerIP int           `json:"max_ports_per_ip"`
+	TopRemoteIPs  []remoteCount `json:"top_remote_ips,omitempty"`
+}
+
+// probeReport is the /connections response
+type probeReport struct {
+	Node      string           `json:"node"`
+	SampledAt time.Time        `json:"sampled_at"`
+	Pods      []podConnections `json:"pods"`
+	// Unreadable counts processes whose sockets could not be read
+	Unreadable int `json:"unreadable"`
+}
+
+// runNodeProbe serves connection samples until killed
+func runNodeProbe(args []string) error {
+	fs := flag.NewFlagSet("node-probe", flag.ExitOnError)
+	listen := fs.String("listen", net.JoinHostPort(os.Getenv("POD_IP"), strconv.Itoa(nodeProbePort)), "address /connections is served on")
+	procRoot := fs.String("proc", "/proc", "host /proc (the pod needs hostPID)")
+	fs.Parse(args)
+
+	token := os.Getenv("PROBE_TOKEN")
+	if token == "" {
+		return fmt.Errorf("PROBE_TOKEN is required (token key of the kodo-node-probe Secret)")
+	}
+	node := os.Getenv("NODE_NAME")
+	http.HandleFunc("/connections", func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(nodeProbeTokenHeader)), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		report := sampleConnections(*procRoot, node)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	log.Printf("🔎 Node probe on %s serving connection samples on %s", node, *listen)
+	return http.ListenAndServe(*listen, nil)
+}
+
+// sampleConnections reads the sockets of every pod network namespace once
+func sampleConnections(procRoot, node string) probeReport {
+	report := probeReport{Node: node, SampledAt: time.Now().UTC(), Pods: []podConnections{}}
+	hostNetNS, _ := os.Readlink(filepath.Join(procRoot, "1", "ns", "net"))
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		log.Printf("⚠️  Reading %s: %v", procRoot, err)
+		return report
+	}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		netNS, err := os.Readlink(filepath.Join(procRoot, pid, "ns", "net"))
+		if err != nil || netNS == hostNetNS || seen[netNS] {
+			continue
+		}
+		cgroup, err := os.ReadFile(filepath.Join(procRoot, pid, "cgroup"))
+		if err != nil {
+			continue
+		}
+		m := podUIDRe.FindSubmatch(cgroup)
+		if m == nil {
+			continue
+		}
+		seen[netNS] = true
+
+		conns, ok := readPodSockets(filepath.Join(procRoot, pid, "net"))
+		if !ok {
+			report.Unreadable++
+			continue
+		}
+		conns.PodUID = strings.ReplaceAll(string(m[1]), "_", "-")
+		report.Pods = append(report.Pods, conns)
+	}
+	return report
+}
+
+// procSocket is one line of /proc/net/tcp{,6}
+type procSocket struct {
+	localPort  string
+	remoteIP   string
+	remotePort string
+	state      string
+}
+
+// readPodSockets aggregates the outbound TCP connections of one network
+// namespace; connections to a local listening port are inbound and skipped
+func readPodSockets(netDir string) (podConnections, bool) {
+	var sockets []procSocket
+	readable := false
+	for _, file := range []string{"tcp", "tcp6"} {
+		s, err := readProcNetTCP(filepath.Join(netDir, file))
+		if err != nil {
+			continue
+		}
+		readable = true
+		sockets = append(sockets, s...)
+	}
+	if !readable {
+		return podConnections{}, false
+	}
+
+	listening := map[string]bool{}
+	for _, s := range sockets {
+		if s.state == tcpListen {
+			listening[s.localPort] = true
+		}
+	}
+
+	var c podConnections
+	ports := map[string]bool{}
+	byIP := map[string]*remoteCount{}
+	portsByIP := map[string]map[string]bool{}
+	for _, s := range sockets {
+		if (s.state != tcpEstablished && s.state != tcpSynSent) || listening[s.localPort] {
+			continue
+		}
+		if s.state == tcpSynSent {
+			c.SynSent++
+		} else {
+			c.Established++
+		}
+		ports[s.remotePort] = true
+		if byIP[s.remoteIP] == nil {
+			byIP[s.remoteIP] = &remoteCount{IP: s.remoteIP}
+			portsByIP[s.remoteIP] = map[string]bool{}
+		}
+		byIP[s.remoteIP].Connections++
+		portsByIP[s.remoteIP][s.remotePort] = true
+	}
+
+	remotes := make([]remoteCount, 0, len(byIP))
+	for ip, r := range byIP {
+		r.Ports = len(portsByIP[ip])
+		if r.Ports > c.MaxPortsPerIP {
+			c.MaxPortsPerIP = r.Ports
+		}
+		remotes = append(remotes, *r)
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		if remotes[i].Connections != remotes[j].Connections {
+			return remotes[i].Connections > remotes[j].Connections
+		}
+		return remotes[i].IP < remotes[j].IP
+	})
+	if len(remotes) > probeTopRemotes {
+		remotes = remotes[:probeTopRemotes]
+	}
+	c.RemoteIPs = len(byIP)
+	c.RemotePorts = len(ports)
+	c.TopRemoteIPs = remotes
+	return c, true
+}
+
+// readProcNetTCP parses /proc/<pid>/net/tcp or tcp6
+func readProcNetTCP(path string) ([]procSocket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sockets []procSocket
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		_, localPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		remoteAddr, remotePort, ok := strings.Cut(fields[2], ":")
+		if !ok {
+			continue
+		}
+		sockets = append(sockets, procSocket{
+			localPort:  localPort,
+			remoteIP:   decodeProcIP(remoteAddr),
+			remotePort: remotePort,
+			state:      fields[3],
+		})
+	}
+	return sockets, scanner.Err()
+}
+
+// decodeProcIP turns the kernel's hex address (host-order 32-bit words) into
+// an IP string; undecodable input is returned as is
+func decodeProcIP(h string) string {
+	raw, err := hex.DecodeString(h)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return h
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip.String()
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// OFFLINE BUFFER
+// Cycles that could not be sent (network errors, 429 and 5xx answers) are
+// kept in memory and replayed oldest first once the backend answers again,
+// a few cycles per collection so reconnecting does not flood it. Cycles
+// older than OFFLINE_DOWNSAMPLE_AFTER_MINUTES are downsampled: only every
+// OFFLINE_KEEP_EVERY-th cycle keeps all its metrics, the others keep just
+// the event and alert types, which cannot be recomputed later. In tenant
+// split mode only the batches of the tenants whose send failed are kept, and
+// they are replayed to those tenants as they are, not split again. Metrics are
+// kept JSON-encoded, so the limit measures what the buffer really holds;
+// when it still exceeds the limit the oldest cycles are dropped. The limit
+// is OFFLINE_BUFFER_MB, or by default an eighth of the container memory
+// limit (no buffering when the limit is unknown).
+// ---------------------------------------------
+
+const (
+	// offlineReplayPerCycle bounds the buffered cycles replayed after one successful send
+	offlineReplayPerCycle = 5
+	// offlineMemoryShare is the fraction (1/N) of AGENT_MEMORY_LIMIT_MB the
+	// buffer gets when OFFLINE_BUFFER_MB is not set
+	offlineMemoryShare = 8
+)
+
+// offlineBufferMB is the buffer limit in MB; 0 disables buffering
+func (c AgentConfig) offlineBufferMB() int {
+	if c.OfflineBufferMB >= 0 {
+		return c.OfflineBufferMB
+	}
+	return c.MemoryLimitMB / offlineMemoryShare
+}
+
+// offlineEventTypes are never downsampled: each cycle reports different
+// occurrences, while snapshots like cpu or pods supersede each other
+var offlineEventTypes = map[string]bool{
+	"events":               true,
+	"oom_events":           true,
+	"evictions":            true,
+	"security_threats":     true,
+	"rbac_changes":         true,
+	"connection_anomalies": true,
+}
+
+// backendStatusError is a non-200 answer from the backend
+type backendStatusError struct {
+	code int
+	body string
+}
+
+func (e *backendStatusError) Error() string {
+	return fmt.Sprintf("backend returned %d: %s", e.code, e.body)
+}
+
+// transientSendError reports whether a failed send is worth buffering:
+// network errors, rate limiting and server errors, but not rejections
+func transientSendError(err error) bool {
+	var status *backendStatusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	return err != nil
+}
+
+// offlineMetric is one buffered metric envelope, encoded; tenant is set for
+// an already split batch and empty for a whole cycle
+type offlineMetric struct {
+	tenant     string
+	metricType string
+	data       []byte
+}
+
+// offlineCycle is one collection cycle waiting to be sent
+type offlineCycle struct {
+	seq     int
+	at      time.Time
+	metrics []offlineMetric
+	bytes   int
+	thinned bool
+}
+
+// offlineBuffer holds the unsent cycles, oldest first
+type offlineBuffer struct {
+	mu      sync.Mutex
+	cycles  []*offlineCycle
+	seq     int
+	bytes   int
+	thinned int
+	dropped int
+	since   time.Time
+}
+
+var offline = &offlineBuffer{}
+
+// push buffers a cycle whose send failed, then downsamples and trims
+func (b *offlineBuffer) push(config AgentConfig, metrics []map[string]interface{}, at time.Time, err error) {
+	if config.offlineBufferMB() <= 0 || config.dryRun() {
+		return
+	}
+	// Encoded outside the lock; the live maps are released with the cycle
+	var encoded []offlineMetric
+	size := 0
+	encode := func(tenant string, metrics []map[string]interface{}) {
+		for _, m := range metrics {
+			data, err := json.Marshal(m)
+			if err != nil {
+				continue
+			}
+			t, _ := m["type"].(string)
+			encoded = append(encoded, offlineMetric{tenant: tenant, metricType: t, data: data})
+			size += len(data)
+		}
+	}
+	var split *tenantSendError
+	if errors.As(err, &split) {
+		// Tenants that got their batch must not get it again on replay
+		tenants := make([]string, 0, len(split.failed))
+		for tenant := range split.failed {
+			tenants = append(tenants, tenant)
+		}
+		sort.Strings(tenants)
+		for _, tenant := range tenants {
+			if f := split.failed[tenant]; transientSendError(f.err) {
+				encode(tenant, f.metrics)
+			}
+		}
+	} else if transientSendError(err) {
+		encode("", metrics)
+	}
+	if len(encoded) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.cycles) == 0 {
+		b.since = at
+		log.Printf("📦 Backend unreachable, buffering cycles (up to %d MB)", config.offlineBufferMB())
+	}
+	b.seq++
+	c := &offlineCycle{seq: b.seq, at: at, metrics: encoded, bytes: size}
+	b.cycles = append(b.cycles, c)
+	b.bytes += c.bytes
+	b.compact(config, at)
+}
+
+// compact thins cycles past the downsampling age and drops the oldest
+// cycles while the buffer is over its limit; the caller holds b.mu
+func (b *offlineBuffer) compact(config AgentConfig, now time.Time) {
+	keepEvery := config.OfflineKeepEvery
+	if keepEvery < 1 {
+		keepEvery = 1
+	}
+	cutoff := now.Add(-time.Duration(config.OfflineDownsampleAfterMinutes) * time.Minute)
+
+	kept := b.cycles[:0]
+	for _, c := range b.cycles {
+		if !c.thinned && c.at.Before(cutoff) && c.seq%keepEvery != 0 {
+			var events []offlineMetric
+			size := 0
+			for _, m := range c.metrics {
+				if offlineEventTypes[m.metricType] {
+					events = append(events, m)
+					size += len(m.data)
+				}
+			}
+			b.bytes -= c.bytes
+			c.metrics, c.bytes, c.thinned = events, size, true
+			b.bytes += c.bytes
+			b.thinned++
+		}
+		if len(c.metrics) > 0 {
+			kept = append(kept, c)
+		}
+	}
+	b.cycles = kept
+
+	limit := config.offlineBufferMB() * 1024 * 1024
+	for b.bytes > limit && len(b.cycles) > 0 {
+		b.bytes -= b.cycles[0].bytes
+		b.cycles = b.cycles[1:]
+		b.dropped++
+	}
+}
+
+// replay sends up to offlineReplayPerCycle buffered cycles, oldest first,
+// stopping at the first failure
+func (b *offlineBuffer) replay(config AgentConfig) {
+	b.mu.Lock()
+	n := min(len(b.cycles), offlineReplayPerCycle)
+	batch := append([]*offlineCycle(nil), b.cycles[:n]...)
+	b.mu.Unlock()
+
+	sent := 0
+	for _, c := range batch {
+		if err := b.replayCycle(config, c); err != nil {
+			log.Printf("⚠️  Replaying buffered cycle from %s failed: %v", c.at.Format(time.RFC3339), err)
+			break
+		}
+		sent++
+	}
+	if sent == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Only the replayed prefix is removed; push may have appended meanwhile
+	for _, c := range batch[:sent] {
+		if len(b.cycles) > 0 && b.cycles[0] == c {
+			b.bytes -= c.bytes
+			b.cycles = b.cycles[1:]
+		}
+	}
+	if len(b.cycles) == 0 {
+		log.Printf("📦 Offline buffer drained (%d cycles thinned, %d dropped since %s)", b.thinned, b.dropped, b.since.Format(time.RFC3339))
+		b.thinned, b.dropped = 0, 0
+	} else {
+		log.Printf("📦 Replayed %d buffered cycles, %d left", sent, len(b.cycles))
+	}
+}
+
+// report describes the buffer for the metrics payload; nil when empty
+func (b *offlineBuffer) report() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.cycles) == 0 && b.dropped == 0 {
+		return nil
+	}
+	report := map[string]interface{}{
+		"cycles":         len(b.cycles),
+		"bytes":          b.bytes,
+		"thinned_cycles": b.thinned,
+		"dropped_cycles": b.dropped,
+	}
+	if !b.since.IsZero() {
+		report["since"] = b.since.UTC()
+	}
+	return report
+}
+
+// replayCycle sends one buffered cycle: a whole cycle through postMetrics,
+// split batches straight to their tenant. Each part that goes through is
+// removed from the cycle, so a retry after a partial failure does not send
+// it twice.
+func (b *offlineBuffer) replayCycle(config AgentConfig, c *offlineCycle) error {
+	b.mu.Lock()
+	pending := append([]offlineMetric(nil), c.metrics...)
+	b.mu.Unlock()
+
+	var tenants []string
+	byTenant := map[string][]map[string]interface{}{}
+	for _, m := range pending {
+		envelope, err := decodeOfflineMetric(m)
+		if err != nil {
+			return err
+		}
+		if _, ok := byTenant[m.tenant]; !ok {
+			tenants = append(tenants, m.tenant)
+		}
+		byTenant[m.tenant] = append(byTenant[m.tenant], envelope)
+	}
+
+	for _, tenant := range tenants {
+		var err error
+		if tenant == "" {
+			err = postMetrics(config, byTenant[tenant])
+		} else {
+			err = postMetricsWithKey(config, tenantAPIKey(config, tenant), byTenant[tenant])
+		}
+		if err != nil {
+			return err
+		}
+		b.mu.Lock()
+		kept := c.metrics[:0]
+		for _, m := range c.metrics {
+			if m.tenant == tenant {
+				c.bytes -= len(m.data)
+				b.bytes -= len(m.data)
+				continue
+			}
+			kept = append(kept, m)
+		}
+		c.metrics = kept
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// decodeOfflineMetric rebuilds an envelope for sending, marked as buffered;
+// numbers stay json.Number so large integers survive the round trip
+func decodeOfflineMetric(m offlineMetric) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(m.data))
+	decoder.UseNumber()
+	var envelope map[string]interface{}
+	if err := decoder.Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("buffered %s metric: %v", m.metricType, err)
+	}
+	envelope["buffered"] = true
+	return envelope, nil
+}
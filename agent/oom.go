@@ -0,0 +1,255 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// OOMKILL HISTORY
+// OOMKilled terminations only show up in a container's last state until the
+// next restart; the agent remembers them, correlates each kill with the
+// container's memory limit and its node's memory pressure, and suggests a
+// limit adjustment per container
+// ---------------------------------------------
+
+const (
+	// oomHistoryWindow is how long observed OOM kills are remembered
+	oomHistoryWindow = 24 * time.Hour
+	// oomLimitHeadroom multiplies the limit (or current usage) for the recommended limit
+	oomLimitHeadroom = 1.5
+	// nodeOOMCorrelationWindow matches a kill to a node-level OOM event
+	nodeOOMCorrelationWindow = 5 * time.Minute
+)
+
+// oomEvent is one OOMKilled termination of a container
+type oomEvent struct {
+	Namespace    string    `json:"namespace"`
+	Pod          string    `json:"pod"`
+	Container    string    `json:"container"`
+	Node         string    `json:"node"`
+	WorkloadKind string    `json:"workload_kind"`
+	Workload     string    `json:"workload"`
+	FinishedAt   time.Time `json:"finished_at"`
+	LimitBytes   int64     `json:"memory_limit_bytes"`
+	RequestBytes int64     `json:"memory_request_bytes"`
+	// Cause is "container_limit", "node_pressure" or "no_limit"
+	Cause string `json:"cause"`
+}
+
+// oomHistoryStore remembers OOM kills between cycles (lost on restart)
+type oomHistoryStore struct {
+	mu     sync.Mutex
+	events map[string]oomEvent
+}
+
+var oomHistory = &oomHistoryStore{events: map[string]oomEvent{}}
+
+// record adds newly observed kills, drops those older than the window and
+// returns the remaining ones, oldest first
+func (h *oomHistoryStore) record(observed []oomEvent, now time.Time) []oomEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range observed {
+		key := e.Namespace + "/" + e.Pod + "/" + e.Container + "/" + e.FinishedAt.UTC().Format(time.RFC3339)
+		if _, ok := h.events[key]; !ok {
+			h.events[key] = e
+		}
+	}
+
+	events := make([]oomEvent, 0, len(h.events))
+	for key, e := range h.events {
+		if now.Sub(e.FinishedAt) > oomHistoryWindow {
+			delete(h.events, key)
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].FinishedAt.Before(events[j].FinishedAt) })
+	return events
+}
+
+// collectOOMEvents builds the "oom_events" metric
+func collectOOMEvents(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("oom_events", time.Now())
+
+	nodes := map[string]corev1.Node{}
+	for _, node := range snap.Nodes {
+		nodes[node.Name] = node
+	}
+
+	// Kernel OOM kills outside any cgroup limit are reported on the Node
+	systemOOMs := map[string][]time.Time{}
+	for _, e := range snap.Events {
+		if e.InvolvedObject.Kind == "Node" && e.Reason == "OOMKilling" {
+			systemOOMs[e.InvolvedObject.Name] = append(systemOOMs[e.InvolvedObject.Name], eventTime(e))
+		}
+	}
+
+	var observed []oomEvent
+	for _, pod := range snap.Pods {
+		kind, name := podWorkload(pod)
+		specs := map[string]corev1.Container{}
+		for _, c := range pod.Spec.InitContainers {
+			specs[c.Name] = c
+		}
+		for _, c := range pod.Spec.Containers {
+			specs[c.Name] = c
+		}
+
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			for _, terminated := range []*corev1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+				if terminated == nil || terminated.Reason != "OOMKilled" {
+					continue
+				}
+				spec := specs[cs.Name]
+				e := oomEvent{
+					Namespace:    pod.Namespace,
+					Pod:          pod.Name,
+					Container:    cs.Name,
+					Node:         pod.Spec.NodeName,
+					WorkloadKind: kind,
+					Workload:     name,
+					FinishedAt:   terminated.FinishedAt.Time,
+					LimitBytes:   spec.Resources.Limits.Memory().Value(),
+					RequestBytes: spec.Resources.Requests.Memory().Value(),
+				}
+				e.Cause = oomCause(e, nodes[e.Node], systemOOMs[e.Node])
+				observed = append(observed, e)
+			}
+		}
+	}
+
+	events := oomHistory.record(observed, snap.TakenAt)
+
+	// Current working set per container, to size the recommendation
+	usage := map[string]uint64{}
+	for key, stats := range podStatsByKey(snap) {
+		for _, cs := range stats.Containers {
+			if cs.Memory != nil && cs.Memory.WorkingSetBytes != nil {
+				usage[key+"/"+cs.Name] = *cs.Memory.WorkingSetBytes
+			}
+		}
+	}
+
+	type containerOOMs struct {
+		namespace, kind, workload, container string
+		kills                                int
+		lastKill                             time.Time
+		limit, workingSet                    int64
+		causes                               map[string]int
+	}
+	byContainer := map[string]*containerOOMs{}
+	var order []string
+	summary := map[string]int{"container_limit": 0, "node_pressure": 0, "no_limit": 0}
+	for _, e := range events {
+		summary[e.Cause]++
+		key := e.Namespace + "/" + e.WorkloadKind + "/" + e.Workload + "/" + e.Container
+		c, ok := byContainer[key]
+		if !ok {
+			c = &containerOOMs{namespace: e.Namespace, kind: e.WorkloadKind, workload: e.Workload, container: e.Container, causes: map[string]int{}}
+			byContainer[key] = c
+			order = append(order, key)
+		}
+		c.kills++
+		c.causes[e.Cause]++
+		if e.FinishedAt.After(c.lastKill) {
+			c.lastKill = e.FinishedAt
+			c.limit = e.LimitBytes
+		}
+		if used := int64(usage[e.Namespace+"/"+e.Pod+"/"+e.Container]); used > c.workingSet {
+			c.workingSet = used
+		}
+	}
+	sort.Strings(order)
+
+	var containers []map[string]interface{}
+	for _, key := range order {
+		c := byContainer[key]
+		entry := map[string]interface{}{
+			"namespace":                c.namespace,
+			"workload_kind":            c.kind,
+			"workload":                 c.workload,
+			"container":                c.container,
+			"kills":                    c.kills,
+			"last_kill":                c.lastKill,
+			"causes":                   c.causes,
+			"memory_limit_bytes":       c.limit,
+			"memory_working_set_bytes": c.workingSet,
+			"recommended_limit_bytes":  recommendedMemoryLimit(c.limit, c.workingSet),
+		}
+		switch {
+		case c.causes["node_pressure"] > 0 && c.causes["container_limit"] == 0:
+			entry["recommendation"] = "node was under memory pressure; raise the memory request so the pod is scheduled where memory is available"
+		case c.limit == 0:
+			entry["recommendation"] = "set a memory limit and request sized to the recommended value"
+		default:
+			entry["recommendation"] = "raise the memory limit to the recommended value"
+		}
+		containers = append(containers, entry)
+	}
+
+	systemOOMCount := 0
+	for _, times := range systemOOMs {
+		systemOOMCount += len(times)
+	}
+
+	return map[string]interface{}{
+		"events":           events,
+		"total":            len(events),
+		"containers":       containers,
+		"by_cause":         summary,
+		"node_system_ooms": systemOOMCount,
+		"window_seconds":   int64(oomHistoryWindow.Seconds()),
+	}
+}
+
+// oomCause tells a kill at the container's own limit from one driven by node
+// memory pressure (or by the kernel OOM killer for a container without limit)
+func oomCause(e oomEvent, node corev1.Node, systemOOMs []time.Time) string {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeMemoryPressure && cond.Status == corev1.ConditionTrue && !cond.LastTransitionTime.After(e.FinishedAt) {
+			return "node_pressure"
+		}
+	}
+	for _, t := range systemOOMs {
+		if d := e.FinishedAt.Sub(t); d > -nodeOOMCorrelationWindow && d < nodeOOMCorrelationWindow {
+			return "node_pressure"
+		}
+	}
+	if e.LimitBytes == 0 {
+		return "no_limit"
+	}
+	return "container_limit"
+}
+
+// recommendedMemoryLimit sizes a limit above both the current limit and the
+// observed usage, rounded up to a whole MiB (0 when nothing is known)
+func recommendedMemoryLimit(limit, usage int64) int64 {
+	base := limit
+	if usage > base {
+		base = usage
+	}
+	if base == 0 {
+		return 0
+	}
+	const mib = 1 << 20
+	recommended := int64(float64(base) * oomLimitHeadroom)
+	return (recommended + mib - 1) / mib * mib
+}
+
+// eventTime returns the most precise timestamp an Event carries
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// DRY-RUN OUTPUT
+// With OUTPUT=stdout or OUTPUT=file the agent collects as usual but writes
+// the exact (redacted) payloads it would POST, pretty-printed, instead of
+// contacting the backend. Useful to review what leaves the cluster before
+// granting egress. Commands, enrollment and the capability handshake need
+// the backend and are disabled.
+// ---------------------------------------------
+
+const (
+	outputBackend = "backend"
+	outputStdout  = "stdout"
+	outputFile    = "file"
+)
+
+// outputMu serializes writes so concurrent payloads do not interleave
+var outputMu sync.Mutex
+
+// dryRun reports whether payloads are written locally instead of POSTed
+func (c AgentConfig) dryRun() bool {
+	return c.Output == outputStdout || c.Output == outputFile || c.Output == outputRecord
+}
+
+// writePayload pretty-prints one payload to the configured output, preceded
+// by a comment-style header naming the endpoint it was meant for
+func writePayload(config AgentConfig, endpoint string, body []byte) error {
+	if config.Output == outputRecord {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		f, err := os.OpenFile(config.OutputPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", config.OutputPath, err)
+		}
+		defer f.Close()
+		return writeRecord(f, endpoint, body)
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, body, "", "  "); err != nil {
+		return fmt.Errorf("failed to format %s payload: %v", endpoint, err)
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	var w io.Writer = os.Stdout
+	if config.Output == outputFile {
+		f, err := os.OpenFile(config.OutputPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", config.OutputPath, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err := fmt.Fprintf(w, "# %s %s (%d bytes)\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), endpoint, len(body), pretty.String())
+	return err
+}
+
+// dryRunTarget describes where dry-run payloads go, for logs
+func dryRunTarget(config AgentConfig) string {
+	if config.Output == outputFile || config.Output == outputRecord {
+		return config.OutputPath
+	}
+	return "stdout"
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ---------------------------------------------
+// SYSTEM OVERHEAD
+// The Kubelet summary reports the kubelet, container runtime, misc and
+// "pods" cgroups next to the node totals. Splitting node usage into pods,
+// system daemons and the unaccounted rest (kernel, page cache, processes
+// outside any cgroup) explains nodes that look full while their pods are small.
+// ---------------------------------------------
+
+// highOverheadPercent flags nodes where non-pod memory exceeds this share of allocatable
+const highOverheadPercent = 25
+
+// usageOf returns the working set and CPU (millicores) of a stats entry
+func usageOf(cpu *CPUStats, mem *MemoryStats) (uint64, int64) {
+	var workingSet uint64
+	var millicores int64
+	if mem != nil && mem.WorkingSetBytes != nil {
+		workingSet = *mem.WorkingSetBytes
+	}
+	if cpu != nil && cpu.UsageNanoCores != nil {
+		millicores = int64(*cpu.UsageNanoCores / 1e6)
+	}
+	return workingSet, millicores
+}
+
+// collectSystemOverhead builds the "system_overhead" metric
+func collectSystemOverhead(snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("system_overhead", time.Now())
+
+	if len(snap.NodeStats) == 0 {
+		status.Fail("kubelet_stats", fmt.Errorf("no node returned stats/summary"))
+		return map[string]interface{}{}
+	}
+
+	var nodes []map[string]interface{}
+	var highOverhead []string
+	for _, node := range snap.Nodes {
+		summary, ok := snap.NodeStats[node.Name]
+		if !ok {
+			continue
+		}
+		nodeMemory, nodeCPU := usageOf(summary.Node.CPU, summary.Node.Memory)
+
+		system := map[string]interface{}{}
+		var systemMemory uint64
+		var systemCPU, podsCgroupCPU int64
+		var podsCgroupMemory uint64
+		for _, sc := range summary.Node.SystemContainers {
+			memory, cpu := usageOf(sc.CPU, sc.Memory)
+			if sc.Name == "pods" {
+				podsCgroupMemory, podsCgroupCPU = memory, cpu
+				continue
+			}
+			system[sc.Name] = map[string]interface{}{
+				"memory_working_set_bytes": memory,
+				"cpu_millicores":           cpu,
+			}
+			systemMemory += memory
+			systemCPU += cpu
+		}
+
+		// Sum of the individual pods, to compare with the "pods" cgroup
+		var podsMemory uint64
+		var podsCPU int64
+		for _, ps := range summary.Pods {
+			memory, cpu := usageOf(ps.CPU, ps.Memory)
+			podsMemory += memory
+			podsCPU += cpu
+		}
+		if podsCgroupMemory == 0 {
+			podsCgroupMemory, podsCgroupCPU = podsMemory, podsCPU
+		}
+
+		var unaccountedMemory uint64
+		if nodeMemory > podsCgroupMemory+systemMemory {
+			unaccountedMemory = nodeMemory - podsCgroupMemory - systemMemory
+		}
+		unaccountedCPU := nodeCPU - podsCgroupCPU - systemCPU
+		if unaccountedCPU < 0 {
+			unaccountedCPU = 0
+		}
+
+		entry := map[string]interface{}{
+			"node":                        node.Name,
+			"node_memory_working_set":     nodeMemory,
+			"node_cpu_millicores":         nodeCPU,
+			"pods_memory_working_set":     podsCgroupMemory,
+			"pods_cpu_millicores":         podsCgroupCPU,
+			"pods_sum_memory_working_set": podsMemory,
+			"system_memory_working_set":   systemMemory,
+			"system_cpu_millicores":       systemCPU,
+			"unaccounted_memory":          unaccountedMemory,
+			"unaccounted_cpu_millicores":  unaccountedCPU,
+			"system_containers":           system,
+		}
+		if allocatable := node.Status.Allocatable.Memory().Value(); allocatable > 0 {
+			overhead := float64(systemMemory+unaccountedMemory) / float64(allocatable) * 100
+			entry["overhead_memory_percent"] = overhead
+			if overhead >= highOverheadPercent {
+				highOverhead = append(highOverhead, node.Name)
+			}
+		}
+		// Kube/system reserved are carved out of capacity before allocatable
+		reservedMemory := node.Status.Capacity.Memory().Value() - node.Status.Allocatable.Memory().Value()
+		entry["reserved_memory_bytes"] = reservedMemory
+		nodes = append(nodes, entry)
+	}
+
+	sort.Strings(highOverhead)
+	return map[string]interface{}{
+		"nodes":                 nodes,
+		"high_overhead_nodes":   highOverhead,
+		"high_overhead_percent": highOverheadPercent,
+	}
+}
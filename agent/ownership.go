@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ---------------------------------------------
+// OWNERSHIP ANNOTATIONS
+// Objects a command changed are stamped with the last command, when it ran
+// and the backend user behind it, so GitOps tools and humans can trace a
+// change back to the platform. Teams that do not want their objects touched
+// beyond the command itself set COMMAND_ANNOTATIONS=false. Pods are never
+// stamped: they are deleted or only get an ephemeral container.
+// ---------------------------------------------
+
+const (
+	annotationLastCommand    = "kuberpulse.io/last-command-id"
+	annotationLastModifiedAt = "kuberpulse.io/last-modified-at"
+	annotationLastModifiedBy = "kuberpulse.io/last-modified-by"
+)
+
+// stampOwnership annotates the object a successful command changed. Failing
+// to annotate never fails the command.
+func stampOwnership(dynamicClient dynamic.Interface, config AgentConfig, cmd Command, result map[string]interface{}) {
+	if !config.CommandAnnotations || config.dryRun() || dynamicClient == nil {
+		return
+	}
+	target, ok := commandActionTarget(result)
+	if !ok || target.ref.Name == "" || target.resource == "" || target.resource == "pods" {
+		return
+	}
+	gv, err := schema.ParseGroupVersion(target.ref.APIVersion)
+	if err != nil {
+		return
+	}
+
+	annotations := map[string]interface{}{
+		annotationLastCommand:    cmd.ID,
+		annotationLastModifiedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	// An unknown user leaves the annotation out instead of erasing it
+	if requester := cmd.requester(); requester != "" {
+		annotations[annotationLastModifiedBy] = requester
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	_, err = dynamicClient.Resource(gv.WithResource(target.resource)).Namespace(target.ref.Namespace).
+		Patch(ctx, target.ref.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager})
+	if err != nil {
+		log.Printf("⚠️  Could not annotate %s %s/%s for command %s: %v", target.ref.Kind, target.ref.Namespace, target.ref.Name, cmd.ID, err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+)
+
+// ---------------------------------------------
+// PATCH RESOURCE COMMAND
+// Generic JSON / merge / strategic-merge patch of any resource type,
+// restricted to an allowlist of kinds ("Kind.group", "Kind" for core)
+// ---------------------------------------------
+
+// fieldManager identifies the agent's writes in managedFields
+const fieldManager = "kodo-agent"
+
+// defaultPatchableKinds is used when neither PATCH_ALLOWED_KINDS nor the CRD set one.
+// Extending it also requires granting "patch" on the kind in the ClusterRole.
+var defaultPatchableKinds = []string{"Deployment.apps", "StatefulSet.apps", "DaemonSet.apps"}
+
+// unpatchableGroups and unpatchableKinds can never be patched, whatever the allowlist says
+var (
+	unpatchableGroups = map[string]bool{
+		"rbac.authorization.k8s.io":    true,
+		"admissionregistration.k8s.io": true,
+		"certificates.k8s.io":          true,
+		"kuberpulse.io":                true,
+	}
+	unpatchableKinds = map[string]bool{"Secret": true, "ServiceAccount": true}
+)
+
+var patchTypes = map[string]types.PatchType{
+	"strategic": types.StrategicMergePatchType,
+	"merge":     types.MergePatchType,
+	"json":      types.JSONPatchType,
+}
+
+var (
+	restMapperMu sync.Mutex
+	restMapper   *restmapper.DeferredDiscoveryRESTMapper
+)
+
+// kindMapping resolves a GVK to its resource and scope, refreshing discovery
+// once when the kind is unknown (e.g. a CRD installed after startup)
+func kindMapping(clientset kubernetes.Interface, gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	restMapperMu.Lock()
+	if restMapper == nil {
+		restMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	}
+	mapper := restMapper
+	restMapperMu.Unlock()
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		mapper.Reset()
+		mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
+
+// patchableKindName formats a GroupKind the way the allowlist spells it
+func patchableKindName(gk schema.GroupKind) string {
+	if gk.Group == "" {
+		return gk.Kind
+	}
+	return gk.Kind + "." + gk.Group
+}
+
+// PatchAllowed reports whether the patch policy permits patching a kind
+func (s *AgentSettings) PatchAllowed(gk schema.GroupKind) bool {
+	if unpatchableGroups[gk.Group] || (gk.Group == "" && unpatchableKinds[gk.Kind]) {
+		return false
+	}
+	return s.PatchableKinds[patchableKindName(gk)]
+}
+
+// patchResource implements the "patch_resource" command
+func patchResource(clientset kubernetes.Interface, dynamicClient dynamic.Interface, params map[string]interface{}) (map[string]interface{}, error) {
+	var p PatchResourceParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("dynamic client unavailable")
+	}
+
+	gvk := schema.GroupVersionKind{Group: p.Group, Version: p.Version, Kind: p.Kind}
+	if !getSettings().PatchAllowed(gvk.GroupKind()) {
+		return nil, fmt.Errorf("patching %s is not allowed by the agent patch policy", patchableKindName(gvk.GroupKind()))
+	}
+
+	mapping, err := kindMapping(clientset, gvk)
+	if err != nil {
+		return nil, fmt.Errorf("unknown kind %s: %v", gvk.String(), err)
+	}
+
+	var resourceClient dynamic.ResourceInterface = dynamicClient.Resource(mapping.Resource)
+	namespace := ""
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if p.Namespace == "" {
+			return nil, &ValidationError{Fields: []FieldError{{Field: "namespace", Message: "is required for namespaced kinds"}}}
+		}
+		namespace = p.Namespace
+		resourceClient = dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	options := metav1.PatchOptions{FieldManager: fieldManager}
+	if p.DryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	log.Printf("🩹 [audit] Patching %s %s/%s (type=%s, dry_run=%v): %s",
+		patchableKindName(gvk.GroupKind()), namespace, p.Name, p.PatchType, p.DryRun, string(p.Patch))
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	patched, err := resourceClient.Patch(ctx, p.Name, patchTypes[p.PatchType], p.Patch, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch %s %s: %v", strings.ToLower(gvk.Kind), p.Name, err)
+	}
+
+	return map[string]interface{}{
+		"action":           "resource_patched",
+		"kind":             gvk.Kind,
+		"api_version":      gvk.GroupVersion().String(),
+		"resource":         mapping.Resource.Resource,
+		"name":             p.Name,
+		"namespace":        namespace,
+		"patch_type":       p.PatchType,
+		"dry_run":          p.DryRun,
+		"resource_version": patched.GetResourceVersion(),
+		"uid":              string(patched.GetUID()),
+		"generation":       patched.GetGeneration(),
+	}, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// WORKLOAD PLACEMENT (affinity, anti-affinity, topology spread)
+// Scheduling constraints of replicated workloads and where their replicas
+// actually landed, flagging replicas concentrated on one node or one zone
+// ---------------------------------------------
+
+// replicatedKinds are the workload kinds whose replicas are expected to spread
+var replicatedKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "ReplicaSet": true}
+
+// nodeZone returns the node's failure-domain zone, or "" when unlabeled
+func nodeZone(node corev1.Node) string {
+	if zone := node.Labels[corev1.LabelTopologyZone]; zone != "" {
+		return zone
+	}
+	return node.Labels[corev1.LabelFailureDomainBetaZone]
+}
+
+// replicatedWorkload groups the running, scheduled pods of one workload
+type replicatedWorkload struct {
+	namespace, kind, name string
+	pods                  []corev1.Pod
+}
+
+// replicatedWorkloads groups running pods by their Deployment/StatefulSet/ReplicaSet, sorted by key
+func replicatedWorkloads(snap *ClusterSnapshot) []*replicatedWorkload {
+	var order []string
+	byWorkload := map[string]*replicatedWorkload{}
+	for _, pod := range snap.Pods {
+		if pod.Spec.NodeName == "" || pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		kind, name := podWorkload(pod)
+		if !replicatedKinds[kind] {
+			continue
+		}
+		key := pod.Namespace + "/" + kind + "/" + name
+		if _, ok := byWorkload[key]; !ok {
+			byWorkload[key] = &replicatedWorkload{namespace: pod.Namespace, kind: kind, name: name}
+			order = append(order, key)
+		}
+		byWorkload[key].pods = append(byWorkload[key].pods, pod)
+	}
+	sort.Strings(order)
+
+	workloads := make([]*replicatedWorkload, len(order))
+	for i, key := range order {
+		workloads[i] = byWorkload[key]
+	}
+	return workloads
+}
+
+// collectWorkloadPlacement builds the "workload_placement" metric
+func collectWorkloadPlacement(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("workload_placement", time.Now())
+
+	zoneByNode := map[string]string{}
+	clusterZones := map[string]bool{}
+	for _, node := range snap.Nodes {
+		zone := nodeZone(node)
+		zoneByNode[node.Name] = zone
+		if zone != "" {
+			clusterZones[zone] = true
+		}
+	}
+
+	var workloads []map[string]interface{}
+	singleNode, singleZone, unconstrained := 0, 0, 0
+	for _, w := range replicatedWorkloads(snap) {
+		nodes := map[string]int{}
+		zones := map[string]int{}
+		for _, pod := range w.pods {
+			nodes[pod.Spec.NodeName]++
+			if zone := zoneByNode[pod.Spec.NodeName]; zone != "" {
+				zones[zone]++
+			}
+		}
+
+		// Replicas share a template, so the first pod describes the constraints
+		spec := w.pods[0].Spec
+		constraints := describePlacementConstraints(spec)
+
+		replicas := len(w.pods)
+		onSingleNode := replicas > 1 && len(nodes) == 1
+		onSingleZone := replicas > 1 && len(clusterZones) > 1 && len(zones) == 1
+		spreadConfigured := constraints["pod_anti_affinity"] != "none" || len(spec.TopologySpreadConstraints) > 0
+
+		if onSingleNode {
+			singleNode++
+		}
+		if onSingleZone {
+			singleZone++
+		}
+		if replicas > 1 && !spreadConfigured {
+			unconstrained++
+		}
+
+		workloads = append(workloads, map[string]interface{}{
+			"namespace":         w.namespace,
+			"kind":              w.kind,
+			"name":              w.name,
+			"running_replicas":  replicas,
+			"nodes":             nodes,
+			"zones":             zones,
+			"constraints":       constraints,
+			"spread_configured": spreadConfigured,
+			"single_node":       onSingleNode,
+			"single_zone":       onSingleZone,
+		})
+	}
+
+	return map[string]interface{}{
+		"workloads":               workloads,
+		"cluster_zones":           len(clusterZones),
+		"single_node_workloads":   singleNode,
+		"single_zone_workloads":   singleZone,
+		"unconstrained_workloads": unconstrained,
+	}
+}
+
+// describePlacementConstraints summarizes a pod spec's affinity rules and topology spread constraints
+func describePlacementConstraints(spec corev1.PodSpec) map[string]interface{} {
+	result := map[string]interface{}{
+		"node_affinity":     "none",
+		"pod_affinity":      "none",
+		"pod_anti_affinity": "none",
+		"node_selector":     spec.NodeSelector,
+	}
+
+	if a := spec.Affinity; a != nil {
+		if na := a.NodeAffinity; na != nil {
+			result["node_affinity"] = affinityStrength(na.RequiredDuringSchedulingIgnoredDuringExecution != nil, len(na.PreferredDuringSchedulingIgnoredDuringExecution) > 0)
+		}
+		if pa := a.PodAffinity; pa != nil {
+			result["pod_affinity"] = affinityStrength(len(pa.RequiredDuringSchedulingIgnoredDuringExecution) > 0, len(pa.PreferredDuringSchedulingIgnoredDuringExecution) > 0)
+		}
+		if paa := a.PodAntiAffinity; paa != nil {
+			result["pod_anti_affinity"] = affinityStrength(len(paa.RequiredDuringSchedulingIgnoredDuringExecution) > 0, len(paa.PreferredDuringSchedulingIgnoredDuringExecution) > 0)
+			var keys []string
+			for _, term := range paa.RequiredDuringSchedulingIgnoredDuringExecution {
+				keys = append(keys, term.TopologyKey)
+			}
+			for _, term := range paa.PreferredDuringSchedulingIgnoredDuringExecution {
+				keys = append(keys, term.PodAffinityTerm.TopologyKey)
+			}
+			result["anti_affinity_topology_keys"] = keys
+		}
+	}
+
+	var spread []map[string]interface{}
+	for _, tsc := range spec.TopologySpreadConstraints {
+		spread = append(spread, map[string]interface{}{
+			"topology_key":       tsc.TopologyKey,
+			"max_skew":           tsc.MaxSkew,
+			"when_unsatisfiable": string(tsc.WhenUnsatisfiable),
+			"min_domains":        tsc.MinDomains,
+		})
+	}
+	result["topology_spread"] = spread
+	return result
+}
+
+// affinityStrength reports "required", "preferred", "both" or "none"
+func affinityStrength(required, preferred bool) string {
+	switch {
+	case required && preferred:
+		return "both"
+	case required:
+		return "required"
+	case preferred:
+		return "preferred"
+	}
+	return "none"
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// COLLECTION POLICY
+// The backend can push a policy through the set_collection_policy command
+// to manage data volume fleet-wide: collectors to turn off, minimum
+// intervals for expensive collectors and a cap on events per cycle. The
+// policy is stored in a ConfigMap (COLLECTION_POLICY_CONFIGMAP) so it
+// survives restarts, and is applied on top of the env/KuberPulseConfig
+// settings: it can only reduce what is collected.
+// ---------------------------------------------
+
+// collectionPolicyKey is the ConfigMap key holding the policy JSON
+const collectionPolicyKey = "policy.json"
+
+// maxPolicyIntervalSeconds bounds per-collector intervals to a day
+const maxPolicyIntervalSeconds = 86400
+
+// CollectionPolicy is the backend-managed collection policy; the zero value
+// collects everything as configured
+type CollectionPolicy struct {
+	// Version is the backend's identifier, echoed in every payload
+	Version            string   `json:"version,omitempty"`
+	DisabledCollectors []string `json:"disabled_collectors,omitempty"`
+	// IntervalSeconds is the minimum time between two collections of a type
+	IntervalSeconds map[string]int `json:"interval_seconds,omitempty"`
+	// MaxEvents caps the events sent per cycle, newest first (0 = no cap)
+	MaxEvents int `json:"max_events,omitempty"`
+	// WarningEventsOnly drops Normal events
+	WarningEventsOnly bool `json:"warning_events_only,omitempty"`
+}
+
+func (p *CollectionPolicy) Validate() error {
+	var c fieldChecks
+	for i, t := range p.DisabledCollectors {
+		if _, ok := metricSchemaVersions[t]; !ok {
+			c.add(fmt.Sprintf("disabled_collectors[%d]", i), "unknown metric type %q", t)
+		}
+	}
+	for t, seconds := range p.IntervalSeconds {
+		if _, ok := metricSchemaVersions[t]; !ok {
+			c.add("interval_seconds", "unknown metric type %q", t)
+		}
+		if seconds < 0 || seconds > maxPolicyIntervalSeconds {
+			c.add("interval_seconds."+t, "must be between 0 and %d", maxPolicyIntervalSeconds)
+		}
+	}
+	if p.MaxEvents < 0 {
+		c.add("max_events", "must not be negative")
+	}
+	return c.err()
+}
+
+// collectionPolicyState is the policy in effect and when each throttled
+// type was last collected
+type collectionPolicyState struct {
+	mu        sync.Mutex
+	policy    CollectionPolicy
+	disabled  map[string]bool
+	lastRun   map[string]time.Time
+	appliedAt time.Time
+}
+
+var collectionPolicy = &collectionPolicyState{lastRun: map[string]time.Time{}}
+
+// set replaces the policy in effect
+func (s *collectionPolicyState) set(p CollectionPolicy, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+	s.disabled = stringSet(p.DisabledCollectors)
+	s.appliedAt = at
+}
+
+// skipReason returns why the policy skips a metric type this cycle, or ""
+// when it is collected; a collected throttled type restarts its interval
+func (s *collectionPolicyState) skipReason(metricType string, now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disabled[metricType] {
+		return "disabled by backend policy"
+	}
+	seconds := s.policy.IntervalSeconds[metricType]
+	if seconds <= 0 {
+		return ""
+	}
+	if last, ok := s.lastRun[metricType]; ok && now.Sub(last) < time.Duration(seconds)*time.Second {
+		return fmt.Sprintf("throttled by backend policy (every %ds)", seconds)
+	}
+	s.lastRun[metricType] = now
+	return ""
+}
+
+// trimEvents applies the event limits of the policy, keeping the newest
+func (s *collectionPolicyState) trimEvents(events []map[string]interface{}) []map[string]interface{} {
+	s.mu.Lock()
+	maxEvents, warningOnly := s.policy.MaxEvents, s.policy.WarningEventsOnly
+	s.mu.Unlock()
+
+	if warningOnly {
+		kept := events[:0]
+		for _, e := range events {
+			if e["type"] == corev1.EventTypeWarning {
+				kept = append(kept, e)
+			}
+		}
+		events = kept
+	}
+	if maxEvents > 0 && len(events) > maxEvents {
+		sort.SliceStable(events, func(i, j int) bool {
+			a, _ := events[i]["last_time"].(time.Time)
+			b, _ := events[j]["last_time"].(time.Time)
+			return a.After(b)
+		})
+		events = events[:maxEvents]
+	}
+	return events
+}
+
+// report describes the policy for the metrics payload; nil when none is set
+func (s *collectionPolicyState) report() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.appliedAt.IsZero() {
+		return nil
+	}
+	return map[string]interface{}{
+		"version":    s.policy.Version,
+		"applied_at": s.appliedAt.UTC(),
+	}
+}
+
+// loadCollectionPolicy restores the persisted policy at startup
+func loadCollectionPolicy(clientset kubernetes.Interface, config AgentConfig) {
+	if config.CollectionPolicyConfigMap == "" {
+		return
+	}
+	ctx, cancel := apiContext()
+	defer cancel()
+	cm, err := clientset.CoreV1().ConfigMaps(config.Namespace).Get(ctx, config.CollectionPolicyConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	var p CollectionPolicy
+	if err == nil {
+		err = json.Unmarshal([]byte(cm.Data[collectionPolicyKey]), &p)
+	}
+	if err == nil {
+		err = p.Validate()
+	}
+	if err != nil {
+		log.Printf("⚠️  Could not restore collection policy from %s/%s: %v", config.Namespace, config.CollectionPolicyConfigMap, err)
+		return
+	}
+	collectionPolicy.set(p, time.Now())
+	log.Printf("🎚️  Restored collection policy %q", p.Version)
+}
+
+// setCollectionPolicy handles set_collection_policy: validate, persist, apply.
+// Empty params reset the policy.
+func setCollectionPolicy(clientset kubernetes.Interface, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p CollectionPolicy
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	persisted := false
+	if config.CollectionPolicyConfigMap != "" {
+		if err := saveCollectionPolicy(clientset, config, p); err != nil {
+			// Still applied, just not across restarts
+			log.Printf("⚠️  Could not persist collection policy: %v", err)
+		} else {
+			persisted = true
+		}
+	}
+	collectionPolicy.set(p, time.Now())
+	log.Printf("🎚️  Collection policy %q applied (disabled: %s)", p.Version, strings.Join(p.DisabledCollectors, ", "))
+
+	return map[string]interface{}{
+		"version":   p.Version,
+		"persisted": persisted,
+	}, nil
+}
+
+// saveCollectionPolicy creates or updates the policy ConfigMap
+func saveCollectionPolicy(clientset kubernetes.Interface, config AgentConfig, p CollectionPolicy) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	configMaps := clientset.CoreV1().ConfigMaps(config.Namespace)
+
+	ctx, cancel := apiContext()
+	defer cancel()
+	existing, err := configMaps.Get(ctx, config.CollectionPolicyConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      config.CollectionPolicyConfigMap,
+				Namespace: config.Namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": fieldManager},
+			},
+			Data: map[string]string{collectionPolicyKey: string(data)},
+		}, metav1.CreateOptions{FieldManager: fieldManager})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	existing.Data = map[string]string{collectionPolicyKey: string(data)}
+	_, err = configMaps.Update(ctx, existing, metav1.UpdateOptions{FieldManager: fieldManager})
+	return err
+}
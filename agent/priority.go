@@ -0,0 +1,313 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// PRIORITY CLASSES AND PREEMPTION
+// Inventory of PriorityClasses, which workloads lose pods to preemption and
+// how many pods per namespace are first in line for eviction (QoS class)
+// ---------------------------------------------
+
+// collectPriorityData builds the "priority" metric
+func collectPriorityData(clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("priority", time.Now())
+
+	podsByClass := map[string]int{}
+	withoutClass := 0
+	for _, pod := range snap.Pods {
+		if pod.Spec.PriorityClassName == "" {
+			withoutClass++
+			continue
+		}
+		podsByClass[pod.Spec.PriorityClassName]++
+	}
+
+	ctx, cancel := apiContext()
+	priorityClasses, err := clientset.SchedulingV1().PriorityClasses().List(ctx, metav1.ListOptions{})
+	cancel()
+
+	var classes []map[string]interface{}
+	if err != nil {
+		log.Printf("⚠️  Error listing PriorityClasses: %v", err)
+		status.Partial("priorityclasses", err)
+	} else {
+		for _, pc := range priorityClasses.Items {
+			preemptionPolicy := string(corev1.PreemptLowerPriority)
+			if pc.PreemptionPolicy != nil {
+				preemptionPolicy = string(*pc.PreemptionPolicy)
+			}
+			classes = append(classes, map[string]interface{}{
+				"name":              pc.Name,
+				"value":             pc.Value,
+				"global_default":    pc.GlobalDefault,
+				"preemption_policy": preemptionPolicy,
+				"description":       pc.Description,
+				"system":            strings.HasPrefix(pc.Name, "system-"),
+				"pods":              podsByClass[pc.Name],
+			})
+		}
+		sort.Slice(classes, func(i, j int) bool { return classes[i]["value"].(int32) > classes[j]["value"].(int32) })
+	}
+
+	preemptions := collectPreemptions(snap)
+	total := 0
+	for _, p := range preemptions {
+		total += p["count"].(int)
+	}
+
+	return map[string]interface{}{
+		"classes":                classes,
+		"pods_without_class":     withoutClass,
+		"preemptions":            preemptions,
+		"preemptions_total":      total,
+		"preempted_pods_pending": countPreemptedPods(snap),
+		"qos_by_namespace":       collectQoSByNamespace(snap),
+	}
+}
+
+// collectQoSByNamespace counts active pods per QoS class and namespace.
+// Under node pressure the kubelet evicts BestEffort pods first, then
+// Burstable ones above their requests; at_risk counts BestEffort pods that
+// also have no positive priority, so preemption picks them first as well.
+func collectQoSByNamespace(snap *ClusterSnapshot) map[string]map[string]int {
+	byNamespace := map[string]map[string]int{}
+	for _, pod := range snap.Pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		counts, ok := byNamespace[pod.Namespace]
+		if !ok {
+			counts = map[string]int{string(corev1.PodQOSGuaranteed): 0, string(corev1.PodQOSBurstable): 0, string(corev1.PodQOSBestEffort): 0, "at_risk": 0}
+			byNamespace[pod.Namespace] = counts
+		}
+		qos := podQOSClass(pod)
+		counts[string(qos)]++
+		if qos == corev1.PodQOSBestEffort && (pod.Spec.Priority == nil || *pod.Spec.Priority <= 0) {
+			counts["at_risk"]++
+		}
+	}
+	return byNamespace
+}
+
+// podQOSClass returns the pod's QoS class, deriving it from the container
+// resources while the kubelet has not set it yet
+func podQOSClass(pod corev1.Pod) corev1.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	anySet, guaranteed := false, true
+	for _, c := range containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, hasRequest := c.Resources.Requests[name]
+			limit, hasLimit := c.Resources.Limits[name]
+			if hasRequest || hasLimit {
+				anySet = true
+			}
+			// A missing request defaults to the limit
+			if !hasLimit || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case !anySet:
+		return corev1.PodQOSBestEffort
+	case guaranteed:
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
+
+// collectPreemptions groups "Preempted" events by the victim's workload
+func collectPreemptions(snap *ClusterSnapshot) []map[string]interface{} {
+	podsByKey := make(map[string]corev1.Pod, len(snap.Pods))
+	// Live siblings name the workload of victims that are already gone
+	byGenerateName := map[string]corev1.Pod{}
+	for _, pod := range snap.Pods {
+		podsByKey[pod.Namespace+"/"+pod.Name] = pod
+		if pod.GenerateName != "" {
+			byGenerateName[pod.Namespace+"/"+pod.GenerateName] = pod
+		}
+	}
+
+	type workloadPreemptions struct {
+		namespace, kind, name string
+		priorityClass         string
+		priority              int32
+		count                 int
+		lastTime              time.Time
+		preemptors            map[string]bool
+		nodes                 map[string]bool
+	}
+	byWorkload := map[string]*workloadPreemptions{}
+
+	for _, event := range snap.Events {
+		if event.Reason != "Preempted" || event.InvolvedObject.Kind != "Pod" {
+			continue
+		}
+
+		namespace, podName := event.InvolvedObject.Namespace, event.InvolvedObject.Name
+		var kind, name, priorityClass string
+		var priority int32
+		if pod, ok := podsByKey[namespace+"/"+podName]; ok {
+			kind, name = podWorkload(pod)
+			priorityClass = pod.Spec.PriorityClassName
+			if pod.Spec.Priority != nil {
+				priority = *pod.Spec.Priority
+			}
+		} else {
+			kind, name = goneWorkload(namespace, podName, byGenerateName)
+		}
+
+		key := namespace + "/" + kind + "/" + name
+		wp, ok := byWorkload[key]
+		if !ok {
+			wp = &workloadPreemptions{
+				namespace: namespace, kind: kind, name: name,
+				priorityClass: priorityClass, priority: priority,
+				preemptors: map[string]bool{}, nodes: map[string]bool{},
+			}
+			byWorkload[key] = wp
+		}
+		wp.count += int(maxInt32(event.Count, 1))
+		if t := eventLastTime(event); t.After(wp.lastTime) {
+			wp.lastTime = t
+		}
+		// Message: "Preempted by pod <uid> on node <node>" or "Preempted by <ns>/<pod> on node <node>"
+		if rest, found := strings.CutPrefix(event.Message, "Preempted by "); found {
+			preemptor, node, _ := strings.Cut(rest, " on node ")
+			wp.preemptors[strings.TrimPrefix(preemptor, "pod ")] = true
+			if node != "" {
+				wp.nodes[node] = true
+			}
+		}
+	}
+
+	var result []map[string]interface{}
+	for _, wp := range byWorkload {
+		result = append(result, map[string]interface{}{
+			"namespace":      wp.namespace,
+			"kind":           wp.kind,
+			"name":           wp.name,
+			"priority_class": wp.priorityClass,
+			"priority":       wp.priority,
+			"count":          wp.count,
+			"last_time":      wp.lastTime,
+			"preemptors":     sortedKeys(wp.preemptors),
+			"nodes":          sortedKeys(wp.nodes),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i]["count"].(int) > result[j]["count"].(int) })
+	return result
+}
+
+// countPreemptedPods counts pods still carrying the scheduler's preemption condition
+func countPreemptedPods(snap *ClusterSnapshot) int {
+	count := 0
+	for _, pod := range snap.Pods {
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.DisruptionTarget && cond.Status == corev1.ConditionTrue && cond.Reason == "PreemptionByScheduler" {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// podWorkload returns the pod's top-level controller without extra API calls,
+// mapping a ReplicaSet to its Deployment through the pod-template-hash suffix
+func podWorkload(pod corev1.Pod) (string, string) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return "Pod", pod.Name
+	}
+	if owner.Kind == "ReplicaSet" {
+		if hash := pod.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return owner.Kind, owner.Name
+}
+
+// podNameAlphabet is the alphabet of generated pod name suffixes and
+// pod-template-hash values
+const podNameAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// goneWorkload derives the workload of a pod that no longer exists from its
+// name: a live pod with the same generateName has the real owner; otherwise
+// "<name>-<template-hash>-<suffix>" is a Deployment and "<name>-<ordinal>" a
+// StatefulSet. Names that fit neither stay kind "Pod".
+func goneWorkload(namespace, podName string, byGenerateName map[string]corev1.Pod) (string, string) {
+	i := strings.LastIndexByte(podName, '-')
+	if i <= 0 {
+		return "Pod", podName
+	}
+	prefix, suffix := podName[:i], podName[i+1:]
+	if sibling, ok := byGenerateName[namespace+"/"+prefix+"-"]; ok {
+		return podWorkload(sibling)
+	}
+	if isOrdinal(suffix) {
+		return "StatefulSet", prefix
+	}
+	if len(suffix) == 5 && inPodNameAlphabet(suffix) {
+		if j := strings.LastIndexByte(prefix, '-'); j > 0 {
+			if hash := prefix[j+1:]; len(hash) >= 6 && len(hash) <= 10 && inPodNameAlphabet(hash) {
+				return "Deployment", prefix[:j]
+			}
+		}
+	}
+	return "Pod", podName
+}
+
+// isOrdinal reports a StatefulSet ordinal ("0", "12", never "01")
+func isOrdinal(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func inPodNameAlphabet(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(podNameAlphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// eventLastTime returns the most recent timestamp an event carries
+func eventLastTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.FirstTimestamp.Time
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
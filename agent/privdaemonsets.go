@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// PRIVILEGED DAEMONSETS
+// CNI plugins, CSI drivers, log shippers and node exporters legitimately run
+// privileged on every node, and reported pod by pod they drown the real
+// findings. Host-access DaemonSets are inventoried once per DaemonSet instead;
+// an operator marks the known-good ones with kuberpulse.io/approved, whose
+// value is the justification ("cni", "csi", ...).
+// ---------------------------------------------
+
+// approvedAnnotation marks a DaemonSet whose host access has been reviewed
+const approvedAnnotation = "kuberpulse.io/approved"
+
+// daemonSetApproval returns the justification of an approved DaemonSet, read
+// from the DaemonSet or its pod template
+func daemonSetApproval(ds appsv1.DaemonSet) (string, bool) {
+	for _, annotations := range []map[string]string{ds.Annotations, ds.Spec.Template.Annotations} {
+		value := strings.TrimSpace(annotations[approvedAnnotation])
+		if value != "" && value != "false" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// podSpecPrivileges lists the host access a pod spec grants; Linux-only
+// settings are ignored for Windows pods, where HostProcess is the equivalent
+func podSpecPrivileges(spec corev1.PodSpec, windows bool) (privileges []string, hostPaths []string) {
+	found := map[string]bool{}
+	if spec.HostNetwork {
+		found["host_network"] = true
+	}
+	if windows {
+		if isHostProcessPod(corev1.Pod{Spec: spec}) {
+			found["host_process"] = true
+		}
+	} else {
+		if spec.HostPID {
+			found["host_pid"] = true
+		}
+		if spec.HostIPC {
+			found["host_ipc"] = true
+		}
+		containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+		for _, c := range containers {
+			sc := c.SecurityContext
+			if sc == nil {
+				continue
+			}
+			if sc.Privileged != nil && *sc.Privileged {
+				found["privileged"] = true
+			}
+			if sc.Capabilities != nil {
+				for _, cap := range sc.Capabilities.Add {
+					if isDangerousCapability(string(cap)) {
+						found["cap_"+strings.ToLower(string(cap))] = true
+					}
+				}
+			}
+		}
+	}
+	for _, v := range spec.Volumes {
+		if v.HostPath == nil {
+			continue
+		}
+		found["host_path"] = true
+		hostPaths = append(hostPaths, v.HostPath.Path)
+		for _, m := range matchSensitiveHostPath(v.HostPath.Path) {
+			found[m.Kind] = true
+		}
+	}
+	sort.Strings(hostPaths)
+	return sortedKeys(found), hostPaths
+}
+
+// privilegedDaemonSets lists the cluster's DaemonSets and returns the
+// inventory of those with host access, keyed namespace/name; their
+// annotations are added to idx for suppressions
+func privilegedDaemonSets(clientset kubernetes.Interface, status *CollectorStatus, idx *suppressionIndex) (map[string]map[string]interface{}, error) {
+	ctx, cancel := apiContext()
+	list, err := clientset.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing DaemonSets: %v", err)
+		status.Partial("daemonsets", err)
+		return nil, err
+	}
+
+	settings := getSettings()
+	daemonSets := filterByNamespace(list.Items, func(ds appsv1.DaemonSet) string { return ds.Namespace }, settings)
+	inventory := map[string]map[string]interface{}{}
+	for _, ds := range daemonSets {
+		spec := ds.Spec.Template.Spec
+		windows := (spec.OS != nil && spec.OS.Name == corev1.Windows) || spec.NodeSelector["kubernetes.io/os"] == osWindows
+		privileges, hostPaths := podSpecPrivileges(spec, windows)
+		if len(privileges) == 0 {
+			continue
+		}
+		var images []string
+		for _, c := range spec.Containers {
+			images = append(images, c.Image)
+		}
+		idx.objects["DaemonSet/"+ds.Namespace+"/"+ds.Name] = ds.Annotations
+		justification, approved := daemonSetApproval(ds)
+		threatLevel := "high"
+		if approved {
+			threatLevel = "info"
+		}
+		inventory[ds.Namespace+"/"+ds.Name] = map[string]interface{}{
+			"name":          ds.Name,
+			"namespace":     ds.Namespace,
+			"privileges":    privileges,
+			"host_paths":    hostPaths,
+			"images":        images,
+			"desired":       ds.Status.DesiredNumberScheduled,
+			"ready":         ds.Status.NumberReady,
+			"approved":      approved,
+			"justification": justification,
+			"threat_level":  threatLevel,
+			"reason":        fmt.Sprintf("DaemonSet with host access on every node: %s", strings.Join(privileges, ", ")),
+		}
+	}
+	return inventory, nil
+}
+
+// summarizePrivilegedDaemonSets orders the inventory (unapproved first) for the payload
+func summarizePrivilegedDaemonSets(inventory map[string]map[string]interface{}) map[string]interface{} {
+	entries := make([]map[string]interface{}, 0, len(inventory))
+	approved := 0
+	for _, e := range inventory {
+		if e["approved"].(bool) {
+			approved++
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a["approved"] != b["approved"] {
+			return !a["approved"].(bool)
+		}
+		return a["namespace"].(string)+"/"+a["name"].(string) < b["namespace"].(string)+"/"+b["name"].(string)
+	})
+	return map[string]interface{}{
+		"daemonsets":          entries,
+		"total":               len(entries),
+		"approved":            approved,
+		"unapproved":          len(entries) - approved,
+		"approval_annotation": approvedAnnotation,
+	}
+}
+
+// daemonSetOf returns the namespace/name key of the pod's DaemonSet, or ""
+func daemonSetOf(pod corev1.Pod) string {
+	if owner := metav1.GetControllerOf(&pod); owner != nil && owner.Kind == "DaemonSet" {
+		return pod.Namespace + "/" + owner.Name
+	}
+	return ""
+}
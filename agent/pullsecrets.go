@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// REGISTRY CREDENTIAL HEALTH
+// With REGISTRY_CREDENTIAL_CHECKS the agent periodically proves that every
+// imagePullSecret referenced by a pod still authenticates: for each
+// (secret, registry) pair it sends a HEAD for one manifest the workloads use,
+// with that secret's credentials only. An expired token then shows up as
+// auth_failed before the next rollout hits ImagePullBackOff.
+// ---------------------------------------------
+
+const (
+	// credentialChecksPerRound bounds the registry traffic of one round
+	credentialChecksPerRound = 100
+	// maxCredentialWorkloads bounds the workloads listed per check
+	maxCredentialWorkloads = 20
+)
+
+// credentialCheck is the outcome for one pull secret against one registry
+type credentialCheck struct {
+	Namespace string `json:"namespace"`
+	Secret    string `json:"secret"`
+	Registry  string `json:"registry,omitempty"`
+	// Status is "ok", "auth_failed", "not_found" (authenticated, but the
+	// probed manifest is gone or hidden), "missing_secret", "invalid_secret"
+	// or "error" (registry unreachable)
+	Status    string              `json:"status"`
+	Image     string              `json:"image,omitempty"`
+	Reason    string              `json:"reason,omitempty"`
+	Workloads []map[string]string `json:"workloads"`
+	CheckedAt time.Time           `json:"checked_at"`
+	LastOKAt  *time.Time          `json:"last_ok_at,omitempty"`
+}
+
+var credentialState = struct {
+	mu        sync.Mutex
+	checks    map[string]*credentialCheck
+	lastRound time.Time
+	skipped   int
+}{checks: map[string]*credentialCheck{}}
+
+// credentialTarget groups what one (secret, registry) pair is used for
+type credentialTarget struct {
+	namespace, secret, registry string
+	image                       imageRef
+	workloads                   map[[2]string]bool
+}
+
+// runCredentialChecks checks the pull secrets every interval; it never returns
+func runCredentialChecks(clientset kubernetes.Interface, config AgentConfig) {
+	interval := time.Duration(config.RegistryCheckIntervalHours) * time.Hour
+	log.Printf("🔑 Registry credential checks every %v", interval)
+	time.Sleep(splayDelay(5 * time.Minute))
+	for {
+		checkCredentialsRound(clientset)
+		time.Sleep(interval)
+	}
+}
+
+// checkCredentialsRound probes every referenced pull secret once
+func checkCredentialsRound(clientset kubernetes.Interface) {
+	now := time.Now()
+	ctx, cancel := apiContext()
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Registry credential checks: listing pods: %v", err)
+		return
+	}
+
+	settings := getSettings()
+	targets := map[string]*credentialTarget{}
+	results := map[string]*credentialCheck{}
+	secrets := map[string]map[string]registryAuth{}
+	for _, pod := range pods.Items {
+		if !settings.NamespaceAllowed(pod.Namespace) || len(pod.Spec.ImagePullSecrets) == 0 {
+			continue
+		}
+		kind, name := podWorkload(pod)
+		workload := [2]string{kind, name}
+		for _, ref := range pod.Spec.ImagePullSecrets {
+			secretKey := pod.Namespace + "/" + ref.Name
+			auths, ok := secrets[secretKey]
+			if !ok {
+				auths, err = readPullSecret(clientset, pod.Namespace, ref.Name)
+				if err != nil {
+					status := "invalid_secret"
+					if apierrors.IsNotFound(err) {
+						status = "missing_secret"
+					} else if !errors.Is(err, errNoRegistryAuths) {
+						status = "error"
+					}
+					results[secretKey] = &credentialCheck{
+						Namespace: pod.Namespace, Secret: ref.Name, Status: status,
+						Reason: err.Error(), Workloads: []map[string]string{}, CheckedAt: now.UTC(),
+					}
+				}
+				secrets[secretKey] = auths
+			}
+			if check, failed := results[secretKey]; failed {
+				addCredentialWorkload(check, pod.Namespace, workload)
+				continue
+			}
+			for _, image := range podImages(pod) {
+				img, err := parseImageRef(image)
+				if err != nil {
+					continue
+				}
+				if _, ok := auths[img.Registry]; !ok {
+					continue
+				}
+				key := secretKey + "@" + img.Registry
+				t, ok := targets[key]
+				if !ok {
+					t = &credentialTarget{namespace: pod.Namespace, secret: ref.Name, registry: img.Registry, image: img, workloads: map[[2]string]bool{}}
+					targets[key] = t
+				}
+				t.workloads[workload] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(targets))
+	for key := range targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	skipped := 0
+	if len(keys) > credentialChecksPerRound {
+		skipped = len(keys) - credentialChecksPerRound
+		keys = keys[:credentialChecksPerRound]
+	}
+	counts := map[string]int{}
+	for _, key := range keys {
+		t := targets[key]
+		auth := secrets[t.namespace+"/"+t.secret][t.registry]
+		check := probeCredential(t, auth, now)
+		for w := range t.workloads {
+			addCredentialWorkload(check, t.namespace, w)
+		}
+		results[key] = check
+	}
+
+	credentialState.mu.Lock()
+	for key, check := range results {
+		sort.Slice(check.Workloads, func(i, j int) bool {
+			return check.Workloads[i]["kind"]+"/"+check.Workloads[i]["name"] < check.Workloads[j]["kind"]+"/"+check.Workloads[j]["name"]
+		})
+		if check.Status == "ok" {
+			t := check.CheckedAt
+			check.LastOKAt = &t
+		} else if prev, ok := credentialState.checks[key]; ok {
+			check.LastOKAt = prev.LastOKAt
+		}
+		counts[check.Status]++
+	}
+	credentialState.checks = results
+	credentialState.lastRound = now
+	credentialState.skipped = skipped
+	credentialState.mu.Unlock()
+	log.Printf("🔑 Registry credential round: %d pull secret checks %v", len(results), counts)
+}
+
+// errNoRegistryAuths marks a pull secret without any registry entry
+var errNoRegistryAuths = errors.New("no registry credentials in secret (expected a dockerconfigjson or dockercfg)")
+
+// readPullSecret returns the registry credentials of a pull secret
+func readPullSecret(clientset kubernetes.Interface, namespace, name string) (map[string]registryAuth, error) {
+	ctx, cancel := apiContext()
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	auths := parseDockerConfig(*secret)
+	if len(auths) == 0 {
+		return nil, errNoRegistryAuths
+	}
+	return auths, nil
+}
+
+// probeCredential sends a HEAD for the target's image with auth only
+func probeCredential(t *credentialTarget, auth registryAuth, now time.Time) *credentialCheck {
+	defer diagnostics.recordDuration("registry_credential_check", time.Now())
+
+	check := &credentialCheck{
+		Namespace: t.namespace, Secret: t.secret, Registry: t.registry,
+		Image: t.image.String(), Workloads: []map[string]string{}, CheckedAt: now.UTC(),
+	}
+	client := newRegistryClient(t.image, &auth)
+	_, err := client.headManifest(t.image.reference())
+	switch {
+	case err == nil:
+		check.Status = "ok"
+	case errors.Is(err, errRegistryUnauthorized):
+		check.Status = "auth_failed"
+	case errors.Is(err, errRegistryNotFound):
+		check.Status = "not_found"
+	default:
+		check.Status = "error"
+	}
+	if err != nil {
+		check.Reason = err.Error()
+	}
+	return check
+}
+
+// podImages returns the images of a pod's init and regular containers
+func podImages(pod corev1.Pod) []string {
+	var images []string
+	for _, c := range pod.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
+
+// addCredentialWorkload lists a workload on a check, up to maxCredentialWorkloads
+func addCredentialWorkload(check *credentialCheck, namespace string, w [2]string) {
+	for _, existing := range check.Workloads {
+		if existing["kind"] == w[0] && existing["name"] == w[1] {
+			return
+		}
+	}
+	if len(check.Workloads) < maxCredentialWorkloads {
+		check.Workloads = append(check.Workloads, map[string]string{"namespace": namespace, "kind": w[0], "name": w[1]})
+	}
+}
+
+// collectRegistryCredentials builds the "registry_credentials" metric from the last round
+func collectRegistryCredentials(config AgentConfig) map[string]interface{} {
+	defer diagnostics.recordDuration("registry_credentials", time.Now())
+
+	credentialState.mu.Lock()
+	defer credentialState.mu.Unlock()
+
+	counts := map[string]int{"ok": 0, "auth_failed": 0, "not_found": 0, "missing_secret": 0, "invalid_secret": 0, "error": 0}
+	checks := make([]credentialCheck, 0, len(credentialState.checks))
+	failing := 0
+	for _, c := range credentialState.checks {
+		check := *c
+		counts[check.Status]++
+		if check.Status != "ok" {
+			failing++
+		}
+		checks = append(checks, check)
+	}
+	// Failures first, then by secret and registry
+	sort.Slice(checks, func(i, j int) bool {
+		a, b := checks[i], checks[j]
+		if (a.Status == "ok") != (b.Status == "ok") {
+			return b.Status == "ok"
+		}
+		return a.Namespace+"/"+a.Secret+"@"+a.Registry < b.Namespace+"/"+b.Secret+"@"+b.Registry
+	})
+
+	result := map[string]interface{}{
+		"checks":         checks,
+		"by_status":      counts,
+		"failing":        failing,
+		"skipped":        credentialState.skipped,
+		"interval_hours": config.RegistryCheckIntervalHours,
+	}
+	if !credentialState.lastRound.IsZero() {
+		result["last_round_at"] = credentialState.lastRound.UTC()
+	}
+	return result
+}
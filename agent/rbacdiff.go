@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// RBAC CHANGES
+// Roles and bindings are fingerprinted on a slow schedule (see
+// slowCollectorIntervals) and compared with the previous run, so the backend
+// receives explicit change events ("new binding to cluster-admin",
+// "ClusterRole deleted") to alert on instead of counts it would have to diff
+// itself. The first run only records a baseline.
+// ---------------------------------------------
+
+// rbacKinds are diffed independently: a failed list keeps that kind's
+// baseline instead of reporting everything in it as deleted
+var rbacKinds = []string{"ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding"}
+
+// rbacObject is what the diff compares for one Role or binding
+type rbacObject struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	Fingerprint string
+	RoleRef     string
+	Subjects    []string
+	Wildcard    bool
+}
+
+func (o rbacObject) key() string {
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
+var rbacBaseline = struct {
+	mu      sync.Mutex
+	objects map[string]map[string]rbacObject // kind → key → object
+}{objects: map[string]map[string]rbacObject{}}
+
+// resetRBACBaseline drops the baseline (memory watchdog); the next run
+// baselines again instead of diffing
+func resetRBACBaseline() {
+	rbacBaseline.mu.Lock()
+	rbacBaseline.objects = map[string]map[string]rbacObject{}
+	rbacBaseline.mu.Unlock()
+}
+
+// rulesFingerprint hashes rules (and aggregation) so any change shows
+func rulesFingerprint(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return sha256Hex(data)
+}
+
+// rulesHaveWildcard reports rules granting "*" verbs or resources
+func rulesHaveWildcard(rules []rbacv1.PolicyRule) bool {
+	for _, r := range rules {
+		if containsString(r.Verbs, "*") || containsString(r.Resources, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectNames renders binding subjects as "Kind:namespace/name"
+func subjectNames(subjects []rbacv1.Subject) []string {
+	names := make([]string, 0, len(subjects))
+	for _, s := range subjects {
+		name := s.Name
+		if s.Namespace != "" {
+			name = s.Namespace + "/" + s.Name
+		}
+		names = append(names, s.Kind+":"+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listRBACObjects lists one kind cluster-wide and fingerprints every object
+func listRBACObjects(clientset kubernetes.Interface, kind string, settings *AgentSettings) (map[string]rbacObject, error) {
+	ctx, cancel := apiContext()
+	defer cancel()
+	objects := map[string]rbacObject{}
+	add := func(o rbacObject) {
+		if settings.NamespaceAllowed(o.Namespace) {
+			objects[o.key()] = o
+		}
+	}
+
+	switch kind {
+	case "ClusterRole":
+		list, err := clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list.Items {
+			add(rbacObject{Kind: kind, Name: r.Name, Fingerprint: rulesFingerprint([]interface{}{r.Rules, r.AggregationRule}), Wildcard: rulesHaveWildcard(r.Rules)})
+		}
+	case "Role":
+		list, err := clientset.RbacV1().Roles("").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list.Items {
+			add(rbacObject{Kind: kind, Namespace: r.Namespace, Name: r.Name, Fingerprint: rulesFingerprint(r.Rules), Wildcard: rulesHaveWildcard(r.Rules)})
+		}
+	case "ClusterRoleBinding":
+		list, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range list.Items {
+			roleRef, subjects := b.RoleRef.Kind+"/"+b.RoleRef.Name, subjectNames(b.Subjects)
+			add(rbacObject{Kind: kind, Name: b.Name, RoleRef: roleRef, Subjects: subjects, Fingerprint: rulesFingerprint([]interface{}{roleRef, subjects})})
+		}
+	case "RoleBinding":
+		list, err := clientset.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range list.Items {
+			roleRef, subjects := b.RoleRef.Kind+"/"+b.RoleRef.Name, subjectNames(b.Subjects)
+			add(rbacObject{Kind: kind, Namespace: b.Namespace, Name: b.Name, RoleRef: roleRef, Subjects: subjects, Fingerprint: rulesFingerprint([]interface{}{roleRef, subjects})})
+		}
+	}
+	return objects, nil
+}
+
+// rbacChangeSeverity ranks a change: new access to cluster-admin or to a
+// wildcard role is what security teams page on
+func rbacChangeSeverity(change string, o rbacObject, addedSubjects []string) string {
+	grantsAccess := change == "created" || len(addedSubjects) > 0
+	switch {
+	case o.RoleRef == "ClusterRole/cluster-admin" && grantsAccess:
+		return "critical"
+	case o.Wildcard && change != "deleted":
+		return "high"
+	case o.RoleRef != "" && grantsAccess:
+		return "medium"
+	case change == "deleted":
+		return "low"
+	}
+	return "info"
+}
+
+// diffStrings returns the elements only in a and only in b
+func diffStrings(a, b []string) (onlyA, onlyB []string) {
+	inA, inB := stringSet(a), stringSet(b)
+	for _, s := range a {
+		if !inB[s] {
+			onlyA = append(onlyA, s)
+		}
+	}
+	for _, s := range b {
+		if !inA[s] {
+			onlyB = append(onlyB, s)
+		}
+	}
+	return onlyA, onlyB
+}
+
+// collectRBACChanges builds the "rbac_changes" metric
+func collectRBACChanges(clientset kubernetes.Interface, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("rbac_changes", time.Now())
+
+	var changes []map[string]interface{}
+	event := func(change string, o rbacObject, detail string, added, removed []string) {
+		entry := map[string]interface{}{
+			"change":   change,
+			"kind":     o.Kind,
+			"name":     o.Name,
+			"severity": rbacChangeSeverity(change, o, added),
+			"detail":   detail,
+		}
+		if o.Namespace != "" {
+			entry["namespace"] = o.Namespace
+		}
+		if o.RoleRef != "" {
+			entry["role_ref"] = o.RoleRef
+			entry["subjects"] = o.Subjects
+		}
+		if len(added) > 0 {
+			entry["added_subjects"] = added
+		}
+		if len(removed) > 0 {
+			entry["removed_subjects"] = removed
+		}
+		changes = append(changes, entry)
+	}
+
+	rbacBaseline.mu.Lock()
+	defer rbacBaseline.mu.Unlock()
+
+	baselined := false
+	counts := map[string]int{}
+	for _, kind := range rbacKinds {
+		current, err := listRBACObjects(clientset, kind, settings)
+		if err != nil {
+			log.Printf("⚠️  Error listing %ss for RBAC diff: %v", kind, err)
+			status.Partial(kind, err)
+			continue
+		}
+		counts[kind] = len(current)
+
+		previous, ok := rbacBaseline.objects[kind]
+		rbacBaseline.objects[kind] = current
+		if !ok {
+			baselined = true
+			continue
+		}
+		for key, o := range current {
+			before, existed := previous[key]
+			switch {
+			case !existed:
+				detail := fmt.Sprintf("%s %s created", kind, o.Name)
+				if o.RoleRef != "" {
+					detail = fmt.Sprintf("%s %s created, binding %s to %d subject(s)", kind, o.Name, o.RoleRef, len(o.Subjects))
+				}
+				event("created", o, detail, o.Subjects, nil)
+			case before.Fingerprint != o.Fingerprint:
+				if o.RoleRef == "" {
+					event("modified", o, fmt.Sprintf("%s %s rules changed", kind, o.Name), nil, nil)
+					continue
+				}
+				added, removed := diffStrings(o.Subjects, before.Subjects)
+				event("modified", o, fmt.Sprintf("%s %s subjects changed (+%d/-%d)", kind, o.Name, len(added), len(removed)), added, removed)
+			}
+		}
+		for key, o := range previous {
+			if _, ok := current[key]; !ok {
+				event("deleted", o, fmt.Sprintf("%s %s deleted", kind, o.Name), nil, nil)
+			}
+		}
+	}
+
+	if len(counts) == 0 {
+		status.Fail("rbac", fmt.Errorf("no RBAC kind could be listed"))
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a["kind"] != b["kind"] {
+			return a["kind"].(string) < b["kind"].(string)
+		}
+		return fmt.Sprint(a["namespace"], "/", a["name"]) < fmt.Sprint(b["namespace"], "/", b["name"])
+	})
+	if len(changes) > 0 {
+		log.Printf("🔐 %d RBAC change(s) since the previous run", len(changes))
+	}
+
+	return map[string]interface{}{
+		"baseline": baselined,
+		"changes":  changes,
+		"counts":   counts,
+	}
+}
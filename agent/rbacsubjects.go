@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// SUBJECT-CENTRIC RBAC (opt-in, RBAC_SUBJECTS / spec.rbac.subjects)
+// Bindings and roles resolved into the effective rules of every
+// ServiceAccount, User and Group, so the backend can answer "who can delete
+// pods in prod" without re-implementing RBAC. Large clusters have thousands
+// of subjects: rules are capped per subject and subjects are sent one page
+// per cycle, tagged with a fingerprint of the RBAC state they came from.
+// ---------------------------------------------
+
+const (
+	// rbacSubjectsPerPage is how many subjects one cycle sends
+	rbacSubjectsPerPage = 100
+	// rbacRulesPerSubject caps the rules exported for one subject
+	rbacRulesPerSubject = 200
+)
+
+// subjectPermission is one rule granted to a subject, with where it applies
+type subjectPermission struct {
+	// Scope is "cluster" or the namespace the rule applies in
+	Scope           string   `json:"scope"`
+	Verbs           []string `json:"verbs"`
+	APIGroups       []string `json:"api_groups,omitempty"`
+	Resources       []string `json:"resources,omitempty"`
+	ResourceNames   []string `json:"resource_names,omitempty"`
+	NonResourceURLs []string `json:"non_resource_urls,omitempty"`
+	Via             string   `json:"via"`
+}
+
+// subjectPermissions is the export entry of one subject
+type subjectPermissions struct {
+	Kind        string              `json:"kind"`
+	Namespace   string              `json:"namespace,omitempty"`
+	Name        string              `json:"name"`
+	Bindings    []string            `json:"bindings"`
+	Rules       []subjectPermission `json:"rules"`
+	RuleCount   int                 `json:"rule_count"`
+	Truncated   bool                `json:"truncated"`
+	ClusterWide bool                `json:"cluster_wide"`
+}
+
+// rbacSubjectsCursor is the page sent next
+var rbacSubjectsCursor = struct {
+	mu   sync.Mutex
+	page int
+}{}
+
+// resolveSubjectPermissions lists roles and bindings and inverts them per subject
+func resolveSubjectPermissions(clientset kubernetes.Interface, settings *AgentSettings) (map[string]*subjectPermissions, string, error) {
+	ctx, cancel := apiContext()
+	clusterRoles, err := clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, "", fmt.Errorf("clusterroles: %v", err)
+	}
+	ctx, cancel = apiContext()
+	roles, err := clientset.RbacV1().Roles("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, "", fmt.Errorf("roles: %v", err)
+	}
+	ctx, cancel = apiContext()
+	clusterBindings, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, "", fmt.Errorf("clusterrolebindings: %v", err)
+	}
+	ctx, cancel = apiContext()
+	bindings, err := clientset.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, "", fmt.Errorf("rolebindings: %v", err)
+	}
+
+	clusterRoleRules := map[string][]rbacv1.PolicyRule{}
+	for _, r := range clusterRoles.Items {
+		clusterRoleRules[r.Name] = r.Rules
+	}
+	roleRules := map[string][]rbacv1.PolicyRule{}
+	for _, r := range roles.Items {
+		roleRules[r.Namespace+"/"+r.Name] = r.Rules
+	}
+
+	subjects := map[string]*subjectPermissions{}
+	grant := func(subject rbacv1.Subject, bindingNamespace, scope, via string, rules []rbacv1.PolicyRule) {
+		namespace := ""
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			namespace = subject.Namespace
+			if namespace == "" {
+				namespace = bindingNamespace
+			}
+		}
+		key := subject.Kind + ":" + namespace + "/" + subject.Name
+		s, ok := subjects[key]
+		if !ok {
+			s = &subjectPermissions{Kind: subject.Kind, Namespace: namespace, Name: subject.Name}
+			subjects[key] = s
+		}
+		s.Bindings = append(s.Bindings, via)
+		if scope == "cluster" {
+			s.ClusterWide = true
+		}
+		for _, rule := range rules {
+			s.RuleCount++
+			if len(s.Rules) >= rbacRulesPerSubject {
+				s.Truncated = true
+				continue
+			}
+			s.Rules = append(s.Rules, subjectPermission{
+				Scope:           scope,
+				Verbs:           rule.Verbs,
+				APIGroups:       rule.APIGroups,
+				Resources:       rule.Resources,
+				ResourceNames:   rule.ResourceNames,
+				NonResourceURLs: rule.NonResourceURLs,
+				Via:             via,
+			})
+		}
+	}
+
+	var fingerprint strings.Builder
+	for _, b := range clusterBindings.Items {
+		via := "ClusterRoleBinding/" + b.Name + " → ClusterRole/" + b.RoleRef.Name
+		fingerprint.WriteString(b.Name + b.ResourceVersion)
+		for _, subject := range b.Subjects {
+			grant(subject, "", "cluster", via, clusterRoleRules[b.RoleRef.Name])
+		}
+	}
+	for _, b := range bindings.Items {
+		if !settings.NamespaceAllowed(b.Namespace) {
+			continue
+		}
+		rules := roleRules[b.Namespace+"/"+b.RoleRef.Name]
+		if b.RoleRef.Kind == "ClusterRole" {
+			rules = clusterRoleRules[b.RoleRef.Name]
+		}
+		via := "RoleBinding/" + b.Namespace + "/" + b.Name + " → " + b.RoleRef.Kind + "/" + b.RoleRef.Name
+		fingerprint.WriteString(b.Namespace + b.Name + b.ResourceVersion)
+		for _, subject := range b.Subjects {
+			grant(subject, b.Namespace, b.Namespace, via, rules)
+		}
+	}
+	// Role content changes without touching the bindings
+	for _, r := range clusterRoles.Items {
+		fingerprint.WriteString(r.Name + r.ResourceVersion)
+	}
+	for _, r := range roles.Items {
+		fingerprint.WriteString(r.Namespace + r.Name + r.ResourceVersion)
+	}
+
+	return subjects, sha256Hex([]byte(fingerprint.String()))[:16], nil
+}
+
+// collectRBACSubjects builds the "rbac_subjects" metric: one page per cycle
+func collectRBACSubjects(clientset kubernetes.Interface, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("rbac_subjects", time.Now())
+
+	subjects, fingerprint, err := resolveSubjectPermissions(clientset, settings)
+	if err != nil {
+		log.Printf("⚠️  Error resolving RBAC subjects: %v", err)
+		status.Fail("rbac", err)
+		return map[string]interface{}{}
+	}
+
+	keys := make([]string, 0, len(subjects))
+	for key := range subjects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pages := (len(keys) + rbacSubjectsPerPage - 1) / rbacSubjectsPerPage
+	if pages == 0 {
+		pages = 1
+	}
+	rbacSubjectsCursor.mu.Lock()
+	page := rbacSubjectsCursor.page % pages
+	rbacSubjectsCursor.page = page + 1
+	rbacSubjectsCursor.mu.Unlock()
+
+	start := page * rbacSubjectsPerPage
+	end := start + rbacSubjectsPerPage
+	if end > len(keys) {
+		end = len(keys)
+	}
+	entries := make([]*subjectPermissions, 0, end-start)
+	truncated := 0
+	for _, key := range keys[start:end] {
+		s := subjects[key]
+		sort.Strings(s.Bindings)
+		if s.Truncated {
+			truncated++
+		}
+		entries = append(entries, s)
+	}
+
+	return map[string]interface{}{
+		"fingerprint":         fingerprint,
+		"page":                page + 1,
+		"pages":               pages,
+		"page_size":           rbacSubjectsPerPage,
+		"total_subjects":      len(keys),
+		"max_rules_per_entry": rbacRulesPerSubject,
+		"truncated_subjects":  truncated,
+		"subjects":            entries,
+	}
+}
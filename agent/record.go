@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// ---------------------------------------------
+// RECORD AND REPLAY
+// `kodo-agent record` runs a few collection cycles and appends every payload
+// (as it would have been POSTed) to a JSON Lines file; `kodo-agent replay`
+// sends a recorded file to the backend, preserving the original spacing
+// scaled by --speed. Used for backend load tests and to reproduce support
+// cases without access to the customer's cluster.
+// ---------------------------------------------
+
+// outputRecord makes writePayload append JSON Lines records instead of pretty JSON
+const outputRecord = "record"
+
+// recordedPayload is one line of a recording
+type recordedPayload struct {
+	RecordedAt time.Time       `json:"recorded_at"`
+	Endpoint   string          `json:"endpoint"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// runSubcommand dispatches the one-shot subcommands
+func runSubcommand(name string, args []string) {
+	config := loadConfig()
+	applySettings(defaultSettings(config))
+
+	var err error
+	switch name {
+	case "record":
+		err = runRecord(config, args)
+	case "replay":
+		err = runReplay(config, args)
+	case "node-probe":
+		err = runNodeProbe(args)
+	case "benchmark":
+		err = runBenchmark(args)
+	default:
+		err = fmt.Errorf("unknown subcommand %q (expected record, replay, node-probe or benchmark)", name)
+	}
+	if err != nil {
+		log.Fatalf("❌ %s: %v", name, err)
+	}
+}
+
+// runRecord collects --cycles times, --interval apart, into --out
+func runRecord(config AgentConfig, args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	out := fs.String("out", "kodo-recording.jsonl", "file the payloads are appended to")
+	cycles := fs.Int("cycles", 1, "collection cycles to record")
+	interval := fs.Duration("interval", 15*time.Second, "time between cycles")
+	simulate := fs.Bool("simulate", false, "record from a fake cluster seeded from --fixtures")
+	fixtures := fs.String("fixtures", "", "fixture YAML file or directory used by --simulate")
+	fs.Parse(args)
+
+	var clientset kubernetes.Interface
+	var metricsClient metricsv.Interface
+	var dynamicClient dynamic.Interface
+	if *simulate {
+		var err error
+		if clientset, metricsClient, err = simulatedClients(*fixtures); err != nil {
+			return err
+		}
+	} else {
+		client, kubeconfig, err := connectKubernetes()
+		if err != nil {
+			return err
+		}
+		clientset = client
+		metricsClient, dynamicClient = newAuxiliaryClients(kubeconfig)
+	}
+	return recordCycles(config, clientset, metricsClient, dynamicClient, *out, *cycles, *interval)
+}
+
+func recordCycles(config AgentConfig, clientset kubernetes.Interface, metricsClient metricsv.Interface, dynamicClient dynamic.Interface, out string, cycles int, interval time.Duration) error {
+	config.Output, config.OutputPath = outputRecord, out
+	for cycle := 1; cycle <= cycles; cycle++ {
+		if cycle > 1 {
+			time.Sleep(interval)
+		}
+		sent, err := collectAndSendMetrics(clientset, metricsClient, dynamicClient, config, nil)
+		if err != nil {
+			return fmt.Errorf("cycle %d: %v", cycle, err)
+		}
+		log.Printf("⏺️  Recorded cycle %d/%d (%d metric types) to %s", cycle, cycles, len(sent), out)
+	}
+	return nil
+}
+
+// writeRecord appends one payload to a recording
+func writeRecord(w io.Writer, endpoint string, body []byte) error {
+	line, err := json.Marshal(recordedPayload{
+		RecordedAt: time.Now().UTC(),
+		Endpoint:   endpoint,
+		Payload:    body,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", line)
+	return err
+}
+
+// runReplay sends a recording to the backend
+func runReplay(config AgentConfig, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "replay speed multiplier (0 sends without waiting)")
+	loop := fs.Int("loop", 1, "times to replay the file (0 loops forever)")
+	retime := fs.Bool("retime", false, "shift collected_at timestamps to the replay time")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kodo-agent replay [--speed N] [--loop N] [--retime] <recording.jsonl>")
+	}
+
+	records, err := readRecording(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s has no payloads", fs.Arg(0))
+	}
+	log.Printf("▶️  Replaying %d payloads from %s to %s at %gx", len(records), fs.Arg(0), config.APIEndpoint, *speed)
+
+	for round := 1; *loop == 0 || round <= *loop; round++ {
+		for i, rec := range records {
+			if i > 0 && *speed > 0 {
+				gap := rec.RecordedAt.Sub(records[i-1].RecordedAt)
+				time.Sleep(time.Duration(float64(gap) / *speed))
+			}
+			body := []byte(rec.Payload)
+			if *retime {
+				if body, err = retimePayload(body, time.Since(rec.RecordedAt)); err != nil {
+					return fmt.Errorf("payload %d: %v", i+1, err)
+				}
+			}
+			if err := replayPayload(config, rec.Endpoint, body); err != nil {
+				log.Printf("⚠️  Payload %d (%s) failed: %v", i+1, rec.Endpoint, err)
+				continue
+			}
+			log.Printf("✅ Replayed payload %d/%d (round %d, %d bytes)", i+1, len(records), round, len(body))
+		}
+	}
+	return nil
+}
+
+// readRecording parses a JSON Lines recording
+func readRecording(path string) ([]recordedPayload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []recordedPayload
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec recordedPayload
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// retimePayload moves every metric's collected_at forward by offset
+func retimePayload(body []byte, offset time.Duration) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	metrics, _ := payload["metrics"].([]interface{})
+	for _, m := range metrics {
+		metric, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		collectedAt, _ := metric["collected_at"].(string)
+		if t, err := time.Parse(time.RFC3339, collectedAt); err == nil {
+			metric["collected_at"] = t.Add(offset).UTC().Format(time.RFC3339)
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// replayPayload posts a recorded body to its endpoint with the current credentials
+func replayPayload(config AgentConfig, endpoint string, body []byte) (err error) {
+	start := time.Now()
+	defer func() {
+		diagnostics.recordBackendCall(endpoint, start, err)
+	}()
+
+	wire, gzipped, gzipSize := encodeForWire(body)
+	url := fmt.Sprintf("%s/%s", config.APIEndpoint, endpoint)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(wire))
+	if err != nil {
+		return fmt.Errorf("failed to create replay request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("x-agent-version", AgentVersion)
+	req.Header.Set("x-agent-replay", "true")
+	if err := authenticateRequest(config, req, agentAPIKey(config), wire); err != nil {
+		return err
+	}
+
+	client := backendClient(config)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bandwidth.recordSend(endpoint, len(body), gzipSize, len(wire), time.Since(start))
+
+	responseBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("backend returned %d: %s", resp.StatusCode, string(responseBody))
+	}
+	return nil
+}
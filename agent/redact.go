@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ---------------------------------------------
+// PAYLOAD REDACTION
+// Last line of defense: every payload sent to the backend (metrics and command
+// results) is re-encoded through this layer, which scrubs anything that looks
+// like secret material even if a collector forgot to sanitize it.
+// ---------------------------------------------
+
+// secretValuePatterns match secret material inside arbitrary strings
+var secretValuePatterns = []*regexp.Regexp{
+	// PEM certificates and keys
+	regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----[\s\S]*?-----END [A-Z0-9 ]+-----`),
+	// JWTs (service account tokens, OIDC tokens)
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+	// Authorization headers
+	regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+[A-Za-z0-9._~+/-]{16,}=*`),
+	// Well-known credential formats: AWS access keys, GitHub, GitLab and Slack tokens
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`),
+}
+
+// urlCredentialsPattern matches the password of user:password@host URLs
+var urlCredentialsPattern = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+
+// inlineAssignmentPattern matches "password: x" / "API_TOKEN=x" inside text such as YAML or command lines
+var inlineAssignmentPattern = regexp.MustCompile(`(?i)([A-Za-z0-9_.-]*(?:password|passwd|secret|token|apikey|api_key|private|credential)[A-Za-z0-9_.-]*)(["']?\s*[:=]\s*["']?)([^\s"',;}]+)`)
+
+// yamlEnvPattern matches "name: X\n  value: Y" env entries inside YAML text
+var yamlEnvPattern = regexp.MustCompile(`(name:[ \t]*["']?([A-Za-z0-9_.-]+)["']?[ \t]*\n[ \t]*value:[ \t]*)([^\n]+)`)
+
+// nonSecretKeySuffixes mark keys that reference a secret without containing it
+// (e.g. "secret_name", "token_type", "secrets_count")
+var nonSecretKeySuffixes = []string{
+	"NAME", "NAMES", "TYPE", "TYPES", "KIND", "COUNT", "TOTAL", "NAMESPACE", "REF", "PATH",
+	"REASON", "MOUNT", "MOUNTS", "_AT", "TIME", "EXPIRY", "EXPIRES", "AGE", "STATUS",
+}
+
+// isSecretKey reports whether a field name implies its value is secret material
+func isSecretKey(key string) bool {
+	if !isSensitiveName(key) {
+		return false
+	}
+	upper := strings.ToUpper(key)
+	for _, suffix := range nonSecretKeySuffixes {
+		if strings.HasSuffix(upper, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// payloadRedactor walks a decoded JSON document and counts what it scrubs
+type payloadRedactor struct {
+	redacted int
+}
+
+// marshalRedacted encodes v as JSON with secret-looking values redacted
+func marshalRedacted(v interface{}, payloadName string) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	body, redacted, err := redactJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	if redacted > 0 {
+		log.Printf("🛡️  Redacted %d secret-looking values from %s payload", redacted, payloadName)
+		diagnostics.recordRedactions(payloadName, redacted)
+	}
+	return body, nil
+}
+
+// redactForLog renders a JSON document for the agent's own log output with
+// the same scrubbing as outgoing payloads; command params carry API keys and
+// tunnel tokens that must not reach stdout either
+func redactForLog(raw []byte) string {
+	body, _, err := redactJSON(raw)
+	if err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON>", len(raw))
+	}
+	return string(body)
+}
+
+// redactJSON scrubs a JSON document and returns it with the number of values
+// replaced; the input is returned untouched when nothing was redacted
+func redactJSON(raw []byte) ([]byte, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, 0, err
+	}
+
+	r := &payloadRedactor{}
+	doc = r.value("", doc)
+	if r.redacted == 0 {
+		return raw, 0, nil
+	}
+	body, err := json.Marshal(doc)
+	return body, r.redacted, err
+}
+
+func (r *payloadRedactor) value(key string, v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		return r.object(typed)
+	case []interface{}:
+		for i := range typed {
+			typed[i] = r.value(key, typed[i])
+		}
+		return typed
+	case string:
+		if typed == "" || typed == redactedValue {
+			return typed
+		}
+		if key != "" && isSecretKey(key) && !isMaskedKey(typed) {
+			r.redacted++
+			return redactedValue
+		}
+		return r.text(typed)
+	}
+	return v
+}
+
+// isMaskedKey reports maskAPIKey output ("abcd1234...wxyz" or "***"), which
+// is already safe to send; anything else merely containing "..." is not
+func isMaskedKey(s string) bool {
+	if s == "***" {
+		return true
+	}
+	return len(s) == 15 && s[8:11] == "..." && !strings.Contains(s[:8]+s[11:], ".")
+}
+
+func (r *payloadRedactor) object(obj map[string]interface{}) map[string]interface{} {
+	// Kubernetes Secrets: keep the keys, never the values
+	if kind, _ := obj["kind"].(string); kind == "Secret" {
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := obj[field].(map[string]interface{}); ok {
+				for k, v := range data {
+					if v != redactedValue {
+						data[k] = redactedValue
+						r.redacted++
+					}
+				}
+			}
+		}
+	}
+
+	// Env-style {"name": "DB_PASSWORD", "value": "..."} pairs
+	if name, ok := obj["name"].(string); ok && isSensitiveName(name) {
+		if value, ok := obj["value"].(string); ok && value != "" && value != redactedValue {
+			obj["value"] = redactedValue
+			r.redacted++
+		}
+	}
+
+	for k, v := range obj {
+		obj[k] = r.value(k, v)
+	}
+	return obj
+}
+
+// text scrubs secret material embedded in a free-form string
+func (r *payloadRedactor) text(s string) string {
+	for _, pattern := range secretValuePatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(string) string {
+			r.redacted++
+			return redactedValue
+		})
+	}
+	s = urlCredentialsPattern.ReplaceAllStringFunc(s, func(match string) string {
+		r.redacted++
+		return urlCredentialsPattern.ReplaceAllString(match, "${1}"+redactedValue+"@")
+	})
+	s = yamlEnvPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := yamlEnvPattern.FindStringSubmatch(match)
+		if !isSensitiveName(parts[2]) || strings.Trim(parts[3], `"' `) == redactedValue {
+			return match
+		}
+		r.redacted++
+		return parts[1] + redactedValue
+	})
+	s = inlineAssignmentPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := inlineAssignmentPattern.FindStringSubmatch(match)
+		if !isSecretKey(parts[1]) || parts[3] == redactedValue {
+			return match
+		}
+		r.redacted++
+		return parts[1] + parts[2] + redactedValue
+	})
+	return s
+}
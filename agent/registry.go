@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// REGISTRY CLIENT
+// A minimal OCI distribution client for the image scanners' small reads
+// (manifests, signature blobs, SBOM size checks; Syft pulls the layers
+// itself): resolves a reference to its platform manifest, authenticating
+// anonymously or with the pod's pull secrets (Basic or the Bearer token
+// flow). Cloud registries that rely on node IAM credentials (ECR, GCR with
+// workload identity) are reached only if a pull secret is present.
+// ---------------------------------------------
+
+const (
+	mediaOCIIndex          = "application/vnd.oci.image.index.v1+json"
+	mediaOCIManifest       = "application/vnd.oci.image.manifest.v1+json"
+	mediaDockerManifestV2  = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaDockerManifestSet = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// registryTimeout bounds a single registry request (blobs stream under it)
+	registryTimeout = 5 * time.Minute
+	// maxManifestBytes guards against non-manifest responses
+	maxManifestBytes = 4 * 1024 * 1024
+)
+
+// errRegistryNotFound is wrapped by requests answered with 404 (e.g. no
+// signature tag for an image)
+var errRegistryNotFound = errors.New("not found")
+
+// errRegistryUnauthorized is wrapped when the registry or its token endpoint
+// rejects the credentials (401/403 after answering the challenge)
+var errRegistryUnauthorized = errors.New("unauthorized")
+
+// imageRef is a parsed image reference
+type imageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImageRef parses "registry/repo:tag@sha256:..." (Docker Hub defaults
+// applied); a "docker-pullable://" runtime prefix is ignored
+func parseImageRef(image string) (imageRef, error) {
+	for _, prefix := range []string{"docker-pullable://", "docker://"} {
+		image = strings.TrimPrefix(image, prefix)
+	}
+	var ref imageRef
+	if name, digest, ok := strings.Cut(image, "@"); ok {
+		image, ref.Digest = name, digest
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.Tag = image[:i], image[i+1:]
+	}
+	ref.Registry = imageRegistry(image)
+	ref.Repository = strings.TrimPrefix(image, ref.Registry+"/")
+	if ref.Registry == "docker.io" && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" {
+		return ref, fmt.Errorf("invalid image reference %q", image)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// String returns the canonical reference
+func (r imageRef) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// host returns the registry's API host
+func (r imageRef) host() string {
+	if r.Registry == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return r.Registry
+}
+
+// reference returns the digest, or the tag when there is none
+func (r imageRef) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// registryAuth is one registry's entry of a dockerconfigjson
+type registryAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// basic returns the username and password, decoding "auth" when needed
+func (a registryAuth) basic() (string, string) {
+	if a.Username == "" && a.Auth != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(a.Auth); err == nil {
+			user, pass, _ := strings.Cut(string(decoded), ":")
+			return user, pass
+		}
+	}
+	return a.Username, a.Password
+}
+
+// parseDockerConfig reads a kubernetes.io/dockerconfigjson or dockercfg Secret
+func parseDockerConfig(secret corev1.Secret) map[string]registryAuth {
+	auths := map[string]registryAuth{}
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		var cfg struct {
+			Auths map[string]registryAuth `json:"auths"`
+		}
+		if json.Unmarshal(data, &cfg) == nil {
+			auths = cfg.Auths
+		}
+	} else if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		json.Unmarshal(data, &auths)
+	}
+	// Keys may be URLs ("https://index.docker.io/v1/"); index them by host
+	normalized := map[string]registryAuth{}
+	for key, auth := range auths {
+		host := key
+		if u, err := url.Parse(key); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if host == "index.docker.io" || host == "registry-1.docker.io" {
+			host = "docker.io"
+		}
+		normalized[host] = auth
+	}
+	return normalized
+}
+
+// pullSecretCache reads each pull secret once per scan round
+type pullSecretCache struct {
+	clientset kubernetes.Interface
+	secrets   map[string]map[string]registryAuth
+}
+
+func newPullSecretCache(clientset kubernetes.Interface) *pullSecretCache {
+	return &pullSecretCache{clientset: clientset, secrets: map[string]map[string]registryAuth{}}
+}
+
+// credentials returns the auth for registry from the pod's pull secrets
+func (c *pullSecretCache) credentials(pod corev1.Pod, registry string) (registryAuth, bool) {
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		key := pod.Namespace + "/" + ref.Name
+		auths, ok := c.secrets[key]
+		if !ok {
+			ctx, cancel := apiContext()
+			secret, err := c.clientset.CoreV1().Secrets(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			cancel()
+			if err == nil {
+				auths = parseDockerConfig(*secret)
+			}
+			c.secrets[key] = auths
+		}
+		if auth, ok := auths[registry]; ok {
+			return auth, true
+		}
+	}
+	return registryAuth{}, false
+}
+
+// registryClient talks to one repository
+type registryClient struct {
+	ref   imageRef
+	auth  *registryAuth
+	http  *http.Client
+	mu    sync.Mutex
+	token string
+}
+
+var registryHTTP = &http.Client{Timeout: registryTimeout}
+
+func newRegistryClient(ref imageRef, auth *registryAuth) *registryClient {
+	return &registryClient{ref: ref, auth: auth, http: registryHTTP}
+}
+
+// get requests /v2/<repo>/<path>, answering one authentication challenge
+func (c *registryClient) get(path string, accept ...string) (*http.Response, error) {
+	return c.do("GET", path, accept...)
+}
+
+// do sends method to /v2/<repo>/<path>, answering one authentication challenge
+func (c *registryClient) do(method, path string, accept ...string) (*http.Response, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", c.ref.host(), c.ref.Repository, path)
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		c.mu.Lock()
+		token := c.token
+		c.mu.Unlock()
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			if resp.StatusCode == http.StatusNotFound {
+				resp.Body.Close()
+				return nil, fmt.Errorf("%s: %w", path, errRegistryNotFound)
+			}
+			if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+				resp.Body.Close()
+				return nil, fmt.Errorf("%s: registry returned %d: %w", path, resp.StatusCode, errRegistryUnauthorized)
+			}
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+				resp.Body.Close()
+				return nil, fmt.Errorf("%s: registry returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+			}
+			return resp, nil
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(challenge); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", path, errRegistryUnauthorized)
+}
+
+// authenticate answers a Basic or Bearer challenge
+func (c *registryClient) authenticate(challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.auth == nil {
+			return fmt.Errorf("registry %s requires credentials and the pod has no pull secret for it", c.ref.Registry)
+		}
+		user, pass := c.auth.basic()
+		c.mu.Lock()
+		c.token = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+		c.mu.Unlock()
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+
+	values := parseChallengeParams(params)
+	tokenURL, err := url.Parse(values["realm"])
+	if err != nil || tokenURL.Scheme != "https" {
+		return fmt.Errorf("invalid token realm %q", values["realm"])
+	}
+	q := tokenURL.Query()
+	if values["service"] != "" {
+		q.Set("service", values["service"])
+	}
+	q.Set("scope", "repository:"+c.ref.Repository+":pull")
+	tokenURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", tokenURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.auth != nil {
+		req.SetBasicAuth(c.auth.basic())
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("token endpoint returned %d: %w", resp.StatusCode, errRegistryUnauthorized)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return err
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	c.mu.Lock()
+	c.token = "Bearer " + token
+	c.mu.Unlock()
+	return nil
+}
+
+// parseChallengeParams splits `realm="...",service="..."`
+func parseChallengeParams(s string) map[string]string {
+	values := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			values[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return values
+}
+
+// ociDescriptor points at a blob or manifest
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	// Annotations carry e.g. cosign's signature and certificate
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant,omitempty"`
+	} `json:"platform,omitempty"`
+}
+
+// ociManifest covers image manifests and indexes (both OCI and Docker)
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// manifest fetches reference; an index is resolved to the linux/arch entry.
+// It returns the manifest and its digest.
+func (c *registryClient) manifest(reference, arch string) (ociManifest, string, error) {
+	for depth := 0; depth < 2; depth++ {
+		resp, err := c.get("manifests/"+reference, mediaOCIIndex, mediaDockerManifestSet, mediaOCIManifest, mediaDockerManifestV2)
+		if err != nil {
+			return ociManifest{}, "", err
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestBytes))
+		resp.Body.Close()
+		if err != nil {
+			return ociManifest{}, "", err
+		}
+		digest := resp.Header.Get("Docker-Content-Digest")
+		if digest == "" {
+			digest = "sha256:" + sha256Hex(data)
+		}
+		var m ociManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return ociManifest{}, "", fmt.Errorf("invalid manifest: %v", err)
+		}
+		if len(m.Manifests) == 0 {
+			return m, digest, nil
+		}
+		reference = ""
+		for _, d := range m.Manifests {
+			if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == arch {
+				reference = d.Digest
+				break
+			}
+		}
+		if reference == "" {
+			return ociManifest{}, "", fmt.Errorf("no linux/%s image in index %s", arch, digest)
+		}
+	}
+	return ociManifest{}, "", fmt.Errorf("nested image index")
+}
+
+// headManifest checks that reference resolves without downloading it and
+// returns its digest
+func (c *registryClient) headManifest(reference string) (string, error) {
+	resp, err := c.do("HEAD", "manifests/"+reference, mediaOCIIndex, mediaDockerManifestSet, mediaOCIManifest, mediaDockerManifestV2)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// blob streams a blob; the caller closes it
+func (c *registryClient) blob(digest string) (io.ReadCloser, error) {
+	resp, err := c.get("blobs/" + digest)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// REPLICASET HISTORY
+// ReplicaSets per Deployment with their revisions, and ReplicaSets that are
+// orphaned or kept beyond the revision history limit (they bloat etcd and
+// make `kubectl rollout undo` pick unexpected revisions)
+// ---------------------------------------------
+
+// revisionAnnotation is set by the deployment controller on each ReplicaSet
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// defaultRevisionHistoryLimit is the API default for spec.revisionHistoryLimit
+const defaultRevisionHistoryLimit = 10
+
+// collectReplicaSets builds the "replicasets" metric
+func collectReplicaSets(clientset kubernetes.Interface, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("replicasets", time.Now())
+
+	ctx, cancel := apiContext()
+	rsList, err := clientset.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing ReplicaSets: %v", err)
+		status.Fail("replicasets", err)
+		return map[string]interface{}{}
+	}
+
+	ctx, cancel = apiContext()
+	deployList, err := clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing Deployments: %v", err)
+		status.Partial("deployments", err)
+	}
+
+	deployments := map[string]appsv1.Deployment{}
+	if deployList != nil {
+		for _, d := range deployList.Items {
+			deployments[d.Namespace+"/"+d.Name] = d
+		}
+	}
+
+	replicaSets := filterByNamespace(rsList.Items, func(rs appsv1.ReplicaSet) string { return rs.Namespace }, settings)
+	byDeployment := map[string][]appsv1.ReplicaSet{}
+	var orphans []map[string]interface{}
+	for _, rs := range replicaSets {
+		owner := metav1.GetControllerOf(&rs)
+		if owner == nil {
+			// A bare ReplicaSet is legitimate only while it still runs pods
+			if rs.Status.Replicas == 0 {
+				orphans = append(orphans, describeOrphanReplicaSet(rs, "no owner and no replicas"))
+			}
+			continue
+		}
+		if owner.Kind != "Deployment" {
+			continue
+		}
+		key := rs.Namespace + "/" + owner.Name
+		if d, ok := deployments[key]; deployList != nil && (!ok || d.UID != owner.UID) {
+			orphans = append(orphans, describeOrphanReplicaSet(rs, "owning Deployment "+owner.Name+" no longer exists"))
+			continue
+		}
+		byDeployment[key] = append(byDeployment[key], rs)
+	}
+
+	var histories []map[string]interface{}
+	staleTotal := 0
+	for key, sets := range byDeployment {
+		sort.Slice(sets, func(i, j int) bool { return replicaSetRevision(sets[i]) > replicaSetRevision(sets[j]) })
+
+		limit := int32(defaultRevisionHistoryLimit)
+		currentRevision := ""
+		if d, ok := deployments[key]; ok {
+			if d.Spec.RevisionHistoryLimit != nil {
+				limit = *d.Spec.RevisionHistoryLimit
+			}
+			currentRevision = d.Annotations[revisionAnnotation]
+		}
+
+		var revisions []map[string]interface{}
+		old, stale := 0, 0
+		for _, rs := range sets {
+			revision := rs.Annotations[revisionAnnotation]
+			isCurrent := revision == currentRevision
+			if !isCurrent && rs.Status.Replicas == 0 {
+				old++
+				// The controller prunes beyond the limit; leftovers mean pruning is stuck
+				if int32(old) > limit {
+					stale++
+				}
+			}
+			revisions = append(revisions, map[string]interface{}{
+				"name":               rs.Name,
+				"revision":           revision,
+				"current":            isCurrent,
+				"desired_replicas":   rs.Spec.Replicas,
+				"replicas":           rs.Status.Replicas,
+				"ready_replicas":     rs.Status.ReadyReplicas,
+				"available_replicas": rs.Status.AvailableReplicas,
+				"images":             containerImages(rs.Spec.Template.Spec.Containers),
+				"created_at":         rs.CreationTimestamp.Time,
+			})
+		}
+		staleTotal += stale
+
+		namespace, name, _ := strings.Cut(key, "/")
+		histories = append(histories, map[string]interface{}{
+			"namespace":              namespace,
+			"deployment":             name,
+			"current_revision":       currentRevision,
+			"revision_history_limit": limit,
+			"replicasets":            len(sets),
+			"old_replicasets":        old,
+			"stale_replicasets":      stale,
+			"revisions":              revisions,
+		})
+	}
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i]["namespace"].(string)+"/"+histories[i]["deployment"].(string) <
+			histories[j]["namespace"].(string)+"/"+histories[j]["deployment"].(string)
+	})
+
+	return map[string]interface{}{
+		"total":                   len(replicaSets),
+		"deployments":             histories,
+		"orphaned":                orphans,
+		"orphaned_count":          len(orphans),
+		"stale_replicasets_count": staleTotal,
+	}
+}
+
+func describeOrphanReplicaSet(rs appsv1.ReplicaSet, reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"namespace":  rs.Namespace,
+		"name":       rs.Name,
+		"replicas":   rs.Status.Replicas,
+		"revision":   rs.Annotations[revisionAnnotation],
+		"created_at": rs.CreationTimestamp.Time,
+		"reason":     reason,
+	}
+}
+
+// replicaSetRevision parses the revision annotation, 0 when missing
+func replicaSetRevision(rs appsv1.ReplicaSet) int64 {
+	revision, _ := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+	return revision
+}
+
+// containerImages lists the images of a pod template's containers
+func containerImages(containers []corev1.Container) []string {
+	images := make([]string, 0, len(containers))
+	for _, c := range containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// SCHEDULED REPORTS
+// With REPORT_SCHEDULE=daily|weekly every collection cycle is folded into
+// running aggregates for the current period (UTC days, weeks starting on
+// Monday) instead of being kept as samples. When a period closes the agent
+// sends one "report" envelope with capacity, estimated cost, security score
+// and top issues, so the backend can email summaries without storing the
+// live stream. Unsent reports are retried on the next cycles; the running
+// period survives restarts when REPORT_STATE_PATH is set.
+// ---------------------------------------------
+
+const (
+	// maxReportGap caps the time one cycle accounts for, so agent downtime
+	// is not counted as observed capacity
+	maxReportGap = 5 * time.Minute
+	// maxReportPods bounds the pods whose restarts are tracked per period
+	maxReportPods = 5000
+	// maxPendingReports bounds reports kept while the backend rejects them
+	maxPendingReports = 4
+	// reportTopIssues is how many findings and restarting pods a report lists
+	reportTopIssues = 10
+)
+
+// threatLevelWeight is what one open finding costs the security score
+var threatLevelWeight = map[string]float64{"critical": 15, "high": 8, "medium": 3, "low": 1}
+
+// restartSpan is a pod's restart count at the first and last cycle of a period
+type restartSpan struct {
+	First int32 `json:"first"`
+	Last  int32 `json:"last"`
+}
+
+// reportPeriod holds the running aggregates of one period
+type reportPeriod struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Cycles    int       `json:"cycles"`
+	LastCycle time.Time `json:"last_cycle"`
+	// Observed is the time covered by cycles (gaps capped at maxReportGap)
+	ObservedSeconds float64 `json:"observed_seconds"`
+
+	CoreHours          float64 `json:"core_hours"`
+	UsedCoreHours      float64 `json:"used_core_hours"`
+	MemoryGiBHours     float64 `json:"memory_gib_hours"`
+	UsedMemoryGiBHours float64 `json:"used_memory_gib_hours"`
+	PeakCPUPercent     float64 `json:"peak_cpu_percent"`
+	PeakMemoryPercent  float64 `json:"peak_memory_percent"`
+	NodesMin           int     `json:"nodes_min"`
+	NodesMax           int     `json:"nodes_max"`
+	PeakRunningPods    int     `json:"peak_running_pods"`
+
+	ScoreSum float64 `json:"score_sum"`
+	ScoreMin float64 `json:"score_min"`
+
+	Restarts map[string]restartSpan `json:"restarts"`
+}
+
+// reportBuilder accumulates the current period and the reports not yet sent
+type reportBuilder struct {
+	mu      sync.Mutex
+	path    string
+	Current *reportPeriod            `json:"current"`
+	Pending []map[string]interface{} `json:"pending"`
+}
+
+var reports = &reportBuilder{}
+
+// reportCycle is what one collection cycle contributes to the report
+type reportCycle struct {
+	at                        time.Time
+	cpuMillis, usedCPUMillis  int64
+	memoryBytes, usedMemBytes int64
+	nodes, runningPods        int
+	pods                      []corev1.Pod
+}
+
+// reportPeriodBounds returns the UTC period containing t
+func reportPeriodBounds(schedule string, t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if schedule == "weekly" {
+		// Weeks start on Monday
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	}
+	return day, day.AddDate(0, 0, 1)
+}
+
+// reportSchedule returns "daily", "weekly" or "" (reports off)
+func reportSchedule(config AgentConfig) string {
+	switch s := strings.ToLower(config.ReportSchedule); s {
+	case "daily", "weekly":
+		return s
+	}
+	return ""
+}
+
+// load restores the running period and pending reports from path
+func (r *reportBuilder) load(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.path = path
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, r)
+	}
+	if err != nil {
+		log.Printf("⚠️  Could not restore report state from %s: %v", path, err)
+		r.Current, r.Pending = nil, nil
+		return
+	}
+	log.Printf("🗞️  Restored report state from %s (%d pending)", path, len(r.Pending))
+}
+
+// save writes the state atomically; the caller holds r.mu
+func (r *reportBuilder) save() {
+	if r.path == "" {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(r.path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(r.path+".tmp", data, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(r.path+".tmp", r.path)
+	}
+	if err != nil {
+		log.Printf("⚠️  Could not persist report state to %s: %v", r.path, err)
+	}
+}
+
+// observe folds one cycle into the current period, closing it into a
+// pending report when the cycle falls past its end
+func (r *reportBuilder) observe(config AgentConfig, c reportCycle) {
+	schedule := reportSchedule(config)
+	if schedule == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer r.save()
+
+	if r.Current != nil && !c.at.Before(r.Current.End) {
+		if r.Current.Cycles > 0 {
+			r.Pending = append(r.Pending, buildReport(config, schedule, r.Current, c.at))
+			if len(r.Pending) > maxPendingReports {
+				r.Pending = r.Pending[len(r.Pending)-maxPendingReports:]
+			}
+		}
+		r.Current = nil
+	}
+	if r.Current == nil {
+		start, end := reportPeriodBounds(schedule, c.at)
+		r.Current = &reportPeriod{Start: start, End: end, ScoreMin: 100, Restarts: map[string]restartSpan{}}
+	}
+	p := r.Current
+
+	if !p.LastCycle.IsZero() {
+		dt := c.at.Sub(p.LastCycle)
+		if dt > maxReportGap {
+			dt = maxReportGap
+		}
+		if dt > 0 {
+			hours := dt.Hours()
+			p.ObservedSeconds += dt.Seconds()
+			p.CoreHours += float64(c.cpuMillis) / 1000 * hours
+			p.UsedCoreHours += float64(c.usedCPUMillis) / 1000 * hours
+			p.MemoryGiBHours += float64(c.memoryBytes) / (1 << 30) * hours
+			p.UsedMemoryGiBHours += float64(c.usedMemBytes) / (1 << 30) * hours
+		}
+	}
+	p.LastCycle = c.at
+	p.Cycles++
+
+	if c.cpuMillis > 0 {
+		p.PeakCPUPercent = max(p.PeakCPUPercent, float64(c.usedCPUMillis)/float64(c.cpuMillis)*100)
+	}
+	if c.memoryBytes > 0 {
+		p.PeakMemoryPercent = max(p.PeakMemoryPercent, float64(c.usedMemBytes)/float64(c.memoryBytes)*100)
+	}
+	if p.Cycles == 1 || c.nodes < p.NodesMin {
+		p.NodesMin = c.nodes
+	}
+	if c.nodes > p.NodesMax {
+		p.NodesMax = c.nodes
+	}
+	if c.runningPods > p.PeakRunningPods {
+		p.PeakRunningPods = c.runningPods
+	}
+
+	score := securityScore(threatFindings.open())
+	p.ScoreSum += score
+	if score < p.ScoreMin {
+		p.ScoreMin = score
+	}
+
+	for _, pod := range c.pods {
+		var restarts int32
+		for _, cs := range pod.Status.ContainerStatuses {
+			restarts += cs.RestartCount
+		}
+		key := pod.Namespace + "/" + pod.Name
+		span, ok := p.Restarts[key]
+		if !ok {
+			if len(p.Restarts) >= maxReportPods {
+				continue
+			}
+			span.First = restarts
+		}
+		span.Last = restarts
+		p.Restarts[key] = span
+	}
+}
+
+// securityScore is 100 minus the weight of every open finding, floored at 0
+func securityScore(open []threatFinding) float64 {
+	score := 100.0
+	for _, f := range open {
+		score -= threatLevelWeight[f.ThreatLevel]
+	}
+	return max(score, 0)
+}
+
+// buildReport turns a closed period into the "report" payload
+func buildReport(config AgentConfig, schedule string, p *reportPeriod, now time.Time) map[string]interface{} {
+	observedHours := p.ObservedSeconds / 3600
+	avg := func(total float64) float64 {
+		if observedHours == 0 {
+			return 0
+		}
+		return round2(total / observedHours)
+	}
+	percent := func(used, total float64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return round2(used / total * 100)
+	}
+
+	report := map[string]interface{}{
+		"schedule":     schedule,
+		"period_start": p.Start,
+		"period_end":   p.End,
+		"generated_at": now.UTC(),
+		"cycles":       p.Cycles,
+		// Share of the period the agent actually observed
+		"coverage": round2(p.ObservedSeconds / p.End.Sub(p.Start).Seconds()),
+		"capacity": map[string]interface{}{
+			"avg_cpu_cores":                  avg(p.CoreHours),
+			"avg_memory_gib":                 avg(p.MemoryGiBHours),
+			"avg_cpu_utilization_percent":    percent(p.UsedCoreHours, p.CoreHours),
+			"avg_memory_utilization_percent": percent(p.UsedMemoryGiBHours, p.MemoryGiBHours),
+			"peak_cpu_percent":               round2(p.PeakCPUPercent),
+			"peak_memory_percent":            round2(p.PeakMemoryPercent),
+			"core_hours":                     round2(p.CoreHours),
+			"memory_gib_hours":               round2(p.MemoryGiBHours),
+			"nodes_min":                      p.NodesMin,
+			"nodes_max":                      p.NodesMax,
+			"peak_running_pods":              p.PeakRunningPods,
+		},
+	}
+
+	if config.ReportCPUCoreHourPrice > 0 || config.ReportMemoryGiBHourPrice > 0 {
+		provisioned := p.CoreHours*config.ReportCPUCoreHourPrice + p.MemoryGiBHours*config.ReportMemoryGiBHourPrice
+		used := p.UsedCoreHours*config.ReportCPUCoreHourPrice + p.UsedMemoryGiBHours*config.ReportMemoryGiBHourPrice
+		report["cost"] = map[string]interface{}{
+			"currency":              config.ReportCurrency,
+			"cpu_core_hour_price":   config.ReportCPUCoreHourPrice,
+			"memory_gib_hour_price": config.ReportMemoryGiBHourPrice,
+			"provisioned":           round2(provisioned),
+			"used":                  round2(used),
+			"idle":                  round2(provisioned - used),
+		}
+	}
+
+	open := threatFindings.open()
+	byLevel := map[string]int{}
+	newFindings := 0
+	for _, f := range open {
+		byLevel[f.ThreatLevel]++
+		if !f.FirstSeen.Before(p.Start) {
+			newFindings++
+		}
+	}
+	report["security"] = map[string]interface{}{
+		"score":                  securityScore(open),
+		"average_score":          round2(p.ScoreSum / float64(p.Cycles)),
+		"min_score":              p.ScoreMin,
+		"open_findings":          len(open),
+		"open_findings_by_level": byLevel,
+		"new_findings":           newFindings,
+	}
+	report["top_issues"] = reportTopFindings(open, p.Restarts)
+	return report
+}
+
+// reportTopFindings lists the worst open findings, then the pods that
+// restarted most during the period
+func reportTopFindings(open []threatFinding, restarts map[string]restartSpan) []map[string]interface{} {
+	sort.Slice(open, func(i, j int) bool {
+		a, b := threatLevelWeight[open[i].ThreatLevel], threatLevelWeight[open[j].ThreatLevel]
+		if a != b {
+			return a > b
+		}
+		return open[i].FirstSeen.Before(open[j].FirstSeen)
+	})
+	issues := []map[string]interface{}{}
+	for i, f := range open {
+		if i == reportTopIssues {
+			break
+		}
+		issues = append(issues, map[string]interface{}{
+			"kind":         "threat",
+			"finding_id":   f.ID,
+			"category":     f.Category,
+			"namespace":    f.Namespace,
+			"object":       f.Object,
+			"threat_level": f.ThreatLevel,
+			"reason":       f.Reason,
+			"first_seen":   f.FirstSeen.UTC(),
+		})
+	}
+
+	type restarting struct {
+		pod   string
+		count int32
+	}
+	var pods []restarting
+	for pod, span := range restarts {
+		if n := span.Last - span.First; n > 0 {
+			pods = append(pods, restarting{pod, n})
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].count != pods[j].count {
+			return pods[i].count > pods[j].count
+		}
+		return pods[i].pod < pods[j].pod
+	})
+	for i, p := range pods {
+		if i == reportTopIssues {
+			break
+		}
+		namespace, name, _ := strings.Cut(p.pod, "/")
+		issues = append(issues, map[string]interface{}{
+			"kind":      "restarts",
+			"namespace": namespace,
+			"pod":       name,
+			"restarts":  p.count,
+		})
+	}
+	return issues
+}
+
+// sendPending posts the closed reports, oldest first, keeping the ones that fail
+func (r *reportBuilder) sendPending(config AgentConfig) {
+	r.mu.Lock()
+	pending := append([]map[string]interface{}(nil), r.Pending...)
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	sent := 0
+	for _, report := range pending {
+		version, ok := capabilities.payloadVersion("report")
+		if !ok {
+			log.Printf("⚠️  Backend does not accept report payloads; dropping the %s report", report["schedule"])
+			sent++
+			continue
+		}
+		metric := buildMetric("report", report, &CollectorStatus{})
+		metric["schema_version"] = version
+		if err := postMetrics(config, []map[string]interface{}{metric}); err != nil {
+			log.Printf("⚠️  Sending %s report failed, retrying next cycle: %v", report["schedule"], err)
+			break
+		}
+		log.Printf("🗞️  Sent %s report for %v", report["schedule"], report["period_start"])
+		sent++
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Reports may have been closed while sending; drop only the ones sent
+	if sent > len(r.Pending) {
+		sent = len(r.Pending)
+	}
+	r.Pending = r.Pending[sent:]
+	r.save()
+}
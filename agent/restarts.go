@@ -0,0 +1,233 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// RESTART REASONS
+// Every container restart leaves its cause in the container's last
+// termination state until the next one; the agent remembers each observed
+// restart, classifies it and counts the causes per workload container, so
+// "why is this restarting" is answered without reading logs:
+//   oom_killed      killed at its memory limit (details in oom_events)
+//   liveness_probe  killed by the kubelet after failing its liveness probe
+//   startup_probe   killed after failing its startup probe
+//   completed       exited with code 0 and was restarted by the policy
+//   error           exited with a non-zero code (see exit_codes)
+// Probe kills look like plain exits (137/143) in the status, so they are
+// told apart by the kubelet's "Killing" Events for the container. Restarts
+// without a termination state (e.g. after a node reboot) are only counted.
+// ---------------------------------------------
+
+const (
+	// restartHistoryWindow is how long observed restarts are remembered
+	restartHistoryWindow = 24 * time.Hour
+	// probeKillCorrelationWindow matches a termination to a probe kill Event
+	probeKillCorrelationWindow = 2 * time.Minute
+)
+
+// containerRestart is one observed restart of a container
+type containerRestart struct {
+	Namespace    string
+	Pod          string
+	Container    string
+	WorkloadKind string
+	Workload     string
+	FinishedAt   time.Time
+	ExitCode     int32
+	// Category is one of the classes listed above
+	Category string
+}
+
+// restartHistoryStore remembers restarts between cycles (lost on restart)
+type restartHistoryStore struct {
+	mu       sync.Mutex
+	restarts map[string]containerRestart
+}
+
+var restartHistory = &restartHistoryStore{restarts: map[string]containerRestart{}}
+
+// record adds newly observed restarts, drops those older than the window and
+// returns the remaining ones
+func (h *restartHistoryStore) record(observed []containerRestart, now time.Time) []containerRestart {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, r := range observed {
+		key := r.Namespace + "/" + r.Pod + "/" + r.Container + "/" + r.FinishedAt.UTC().Format(time.RFC3339)
+		if _, ok := h.restarts[key]; !ok {
+			h.restarts[key] = r
+		}
+	}
+
+	restarts := make([]containerRestart, 0, len(h.restarts))
+	for key, r := range h.restarts {
+		if now.Sub(r.FinishedAt) > restartHistoryWindow {
+			delete(h.restarts, key)
+			continue
+		}
+		restarts = append(restarts, r)
+	}
+	return restarts
+}
+
+// probeKill is a kubelet "Killing" Event caused by a failed probe
+type probeKill struct {
+	category    string
+	first, last time.Time
+}
+
+// probeKillsByContainer indexes probe kill Events by namespace/pod/container
+func probeKillsByContainer(events []corev1.Event) map[string][]probeKill {
+	kills := map[string][]probeKill{}
+	for _, e := range events {
+		if e.Reason != "Killing" || e.InvolvedObject.Kind != "Pod" {
+			continue
+		}
+		var category string
+		switch {
+		case strings.Contains(e.Message, "failed liveness probe"):
+			category = "liveness_probe"
+		case strings.Contains(e.Message, "failed startup probe"):
+			category = "startup_probe"
+		default:
+			continue
+		}
+		// The field path is spec.containers{name}
+		container := e.InvolvedObject.FieldPath
+		if _, rest, ok := strings.Cut(container, "{"); ok {
+			container = strings.TrimSuffix(rest, "}")
+		}
+		first := e.FirstTimestamp.Time
+		if first.IsZero() {
+			first = eventTime(e)
+		}
+		key := e.InvolvedObject.Namespace + "/" + e.InvolvedObject.Name + "/" + container
+		kills[key] = append(kills[key], probeKill{category, first, eventTime(e)})
+	}
+	return kills
+}
+
+// classifyRestart returns the category of a container's last termination
+func classifyRestart(terminated *corev1.ContainerStateTerminated, kills []probeKill) string {
+	if terminated.Reason == "OOMKilled" {
+		return "oom_killed"
+	}
+	finished := terminated.FinishedAt.Time
+	for _, k := range kills {
+		if !finished.Before(k.first.Add(-probeKillCorrelationWindow)) && !finished.After(k.last.Add(probeKillCorrelationWindow)) {
+			return k.category
+		}
+	}
+	if terminated.ExitCode == 0 {
+		return "completed"
+	}
+	return "error"
+}
+
+// collectRestartReasons builds the "restart_reasons" metric
+func collectRestartReasons(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("restart_reasons", time.Now())
+
+	kills := probeKillsByContainer(snap.Events)
+	var observed []containerRestart
+	for _, pod := range snap.Pods {
+		kind, name := podWorkload(pod)
+		for _, cs := range pod.Status.ContainerStatuses {
+			terminated := cs.LastTerminationState.Terminated
+			if cs.RestartCount == 0 || terminated == nil {
+				continue
+			}
+			observed = append(observed, containerRestart{
+				Namespace:    pod.Namespace,
+				Pod:          pod.Name,
+				Container:    cs.Name,
+				WorkloadKind: kind,
+				Workload:     name,
+				FinishedAt:   terminated.FinishedAt.Time,
+				ExitCode:     terminated.ExitCode,
+				Category:     classifyRestart(terminated, kills[pod.Namespace+"/"+pod.Name+"/"+cs.Name]),
+			})
+		}
+	}
+
+	restarts := restartHistory.record(observed, snap.TakenAt)
+
+	// Restart counts in the status cover the pod's whole life; the history
+	// only what the agent saw, so both are reported
+	restartCounts := map[string]int32{}
+	for _, pod := range snap.Pods {
+		kind, name := podWorkload(pod)
+		for _, cs := range pod.Status.ContainerStatuses {
+			restartCounts[pod.Namespace+"/"+kind+"/"+name+"/"+cs.Name] += cs.RestartCount
+		}
+	}
+
+	type workloadRestarts struct {
+		namespace, kind, workload, container string
+		observed                             int
+		lastRestart                          time.Time
+		lastCategory                         string
+		categories                           map[string]int
+		exitCodes                            map[int32]int
+	}
+	byContainer := map[string]*workloadRestarts{}
+	summary := map[string]int{"oom_killed": 0, "liveness_probe": 0, "startup_probe": 0, "completed": 0, "error": 0}
+	for _, r := range restarts {
+		summary[r.Category]++
+		key := r.Namespace + "/" + r.WorkloadKind + "/" + r.Workload + "/" + r.Container
+		w, ok := byContainer[key]
+		if !ok {
+			w = &workloadRestarts{namespace: r.Namespace, kind: r.WorkloadKind, workload: r.Workload, container: r.Container,
+				categories: map[string]int{}, exitCodes: map[int32]int{}}
+			byContainer[key] = w
+		}
+		w.observed++
+		w.categories[r.Category]++
+		if r.Category == "error" {
+			w.exitCodes[r.ExitCode]++
+		}
+		if r.FinishedAt.After(w.lastRestart) {
+			w.lastRestart, w.lastCategory = r.FinishedAt, r.Category
+		}
+	}
+
+	keys := make([]string, 0, len(byContainer))
+	for key := range byContainer {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var containers []map[string]interface{}
+	for _, key := range keys {
+		w := byContainer[key]
+		entry := map[string]interface{}{
+			"namespace":         w.namespace,
+			"workload_kind":     w.kind,
+			"workload":          w.workload,
+			"container":         w.container,
+			"restarts":          restartCounts[key],
+			"observed_restarts": w.observed,
+			"by_category":       w.categories,
+			"last_category":     w.lastCategory,
+			"last_restart":      w.lastRestart,
+		}
+		if len(w.exitCodes) > 0 {
+			entry["exit_codes"] = w.exitCodes
+		}
+		containers = append(containers, entry)
+	}
+
+	return map[string]interface{}{
+		"containers":     containers,
+		"total":          len(restarts),
+		"by_category":    summary,
+		"window_seconds": int64(restartHistoryWindow.Seconds()),
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// SBOM SCANNER
+// With SBOM_ENABLED the agent generates an SBOM (CycloneDX or SPDX, by Syft
+// in a scan pod, see sbomcatalog.go) for every unique running image digest
+// on a slow schedule and uploads it as an artifact. Each digest is scanned once per agent
+// lifetime; failures are retried after sbomRetryAfter. The "sbom" metric
+// reports what was scanned so the backend can link artifacts to workloads.
+// ---------------------------------------------
+
+const (
+	// sbomImagesPerRound bounds the registry traffic of one round
+	sbomImagesPerRound = 25
+	// sbomRetryAfter is how long a failed image waits before the next attempt
+	sbomRetryAfter = 24 * time.Hour
+	// maxSBOMImageBytes skips images whose compressed layers exceed it; the
+	// unpacked image has to fit the scan pod's sbomScanDisk
+	maxSBOMImageBytes = 1024 * 1024 * 1024
+	// maxSBOMRecords bounds the "sbom" metric
+	maxSBOMRecords = 500
+)
+
+// sbomRecord is the outcome of one image's scan
+type sbomRecord struct {
+	Image      string    `json:"image"`
+	Digest     string    `json:"digest"`
+	Format     string    `json:"format"`
+	OS         string    `json:"os,omitempty"`
+	Packages   int       `json:"packages"`
+	ArtifactID string    `json:"artifact_id,omitempty"`
+	SizeBytes  int       `json:"size_bytes,omitempty"`
+	ScannedAt  time.Time `json:"scanned_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var sbomState = struct {
+	mu        sync.Mutex
+	records   map[string]*sbomRecord
+	lastRound time.Time
+	nextRound time.Time
+	pending   int
+}{records: map[string]*sbomRecord{}}
+
+// resetSBOMRecords forgets every scanned image (memory watchdog); the
+// scanner catalogs the running images again over its next rounds
+func resetSBOMRecords() {
+	sbomState.mu.Lock()
+	sbomState.records = map[string]*sbomRecord{}
+	sbomState.mu.Unlock()
+}
+
+// runningImage is one unique image digest and a pod that runs it
+type runningImage struct {
+	ref  imageRef
+	pod  corev1.Pod
+	arch string
+}
+
+// runSBOMScanner scans new images every interval; it never returns
+func runSBOMScanner(clientset kubernetes.Interface, config AgentConfig) {
+	interval := time.Duration(config.SBOMIntervalHours) * time.Hour
+	format := sbomFormat(config)
+	log.Printf("📜 SBOM scanner: %s every %v, up to %d new images per round", format, interval, sbomImagesPerRound)
+
+	// Let the first collection cycles go out before hitting registries
+	time.Sleep(splayDelay(10 * time.Minute))
+	for {
+		scanSBOMRound(clientset, config, format)
+		sbomState.mu.Lock()
+		sbomState.nextRound = time.Now().Add(interval)
+		sbomState.mu.Unlock()
+		time.Sleep(interval)
+	}
+}
+
+// sbomFormat returns the configured format, "cyclonedx" unless "spdx"
+func sbomFormat(config AgentConfig) string {
+	if strings.EqualFold(config.SBOMFormat, "spdx") {
+		return "spdx"
+	}
+	return "cyclonedx"
+}
+
+// runningImages returns the unique running image digests of the allowed namespaces
+func runningImages(clientset kubernetes.Interface) ([]runningImage, error) {
+	ctx, cancel := apiContext()
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel = apiContext()
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	arch := map[string]string{}
+	for _, node := range nodes.Items {
+		arch[node.Name] = node.Status.NodeInfo.Architecture
+	}
+
+	settings := getSettings()
+	seen := map[string]bool{}
+	var images []runningImage
+	for _, pod := range pods.Items {
+		if !settings.NamespaceAllowed(pod.Namespace) || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			digest := imageIDDigest(cs.ImageID)
+			if digest == "" || seen[digest] {
+				continue
+			}
+			seen[digest] = true
+			ref, err := parseImageRef(cs.Image)
+			if err != nil {
+				continue
+			}
+			ref.Digest = digest
+			nodeArch := arch[pod.Spec.NodeName]
+			if nodeArch == "" {
+				nodeArch = "amd64"
+			}
+			images = append(images, runningImage{ref: ref, pod: pod, arch: nodeArch})
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].ref.String() < images[j].ref.String() })
+	return images, nil
+}
+
+// imageIDDigest extracts the digest of a container status imageID
+// ("docker-pullable://repo@sha256:...", "repo@sha256:..." or "sha256:...")
+func imageIDDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		imageID = imageID[i+1:]
+	}
+	if strings.HasPrefix(imageID, "sha256:") && len(imageID) == len("sha256:")+64 {
+		return imageID
+	}
+	return ""
+}
+
+// scanSBOMRound scans up to sbomImagesPerRound new images
+func scanSBOMRound(clientset kubernetes.Interface, config AgentConfig, format string) {
+	now := time.Now()
+	running, err := runningImages(clientset)
+	if err != nil {
+		log.Printf("⚠️  SBOM scanner: listing running images: %v", err)
+		return
+	}
+	var images []runningImage
+	sbomState.mu.Lock()
+	for _, img := range running {
+		if r, ok := sbomState.records[img.ref.Digest]; ok && (r.Error == "" || now.Sub(r.ScannedAt) < sbomRetryAfter) {
+			continue
+		}
+		images = append(images, img)
+	}
+	sbomState.mu.Unlock()
+	pending := len(images)
+	if len(images) > sbomImagesPerRound {
+		images = images[:sbomImagesPerRound]
+	}
+	secrets := newPullSecretCache(clientset)
+
+	scanned := 0
+	for _, img := range images {
+		record := scanImageSBOM(clientset, config, img, secrets, format)
+		if record.Error == "" {
+			scanned++
+		} else {
+			log.Printf("⚠️  SBOM for %s failed: %s", record.Image, record.Error)
+		}
+		sbomState.mu.Lock()
+		sbomState.records[record.Digest] = record
+		sbomState.mu.Unlock()
+	}
+
+	sbomState.mu.Lock()
+	sbomState.lastRound = now
+	sbomState.pending = pending - len(images)
+	sbomState.mu.Unlock()
+	log.Printf("📜 SBOM round: %d/%d images scanned, %d left for later rounds", scanned, len(images), pending-len(images))
+}
+
+// scanImageSBOM catalogs one image and uploads its SBOM; the manifest is
+// read first so oversized images are skipped before a scan pod is started
+func scanImageSBOM(clientset kubernetes.Interface, config AgentConfig, img runningImage, secrets *pullSecretCache, format string) *sbomRecord {
+	defer diagnostics.recordDuration("sbom_scan", time.Now())
+
+	record := &sbomRecord{Image: img.ref.String(), Digest: img.ref.Digest, Format: format, ScannedAt: time.Now().UTC()}
+	fail := func(err error) *sbomRecord {
+		record.Error = err.Error()
+		return record
+	}
+
+	var auth *registryAuth
+	if a, ok := secrets.credentials(img.pod, img.ref.Registry); ok {
+		auth = &a
+	}
+	client := newRegistryClient(img.ref, auth)
+	manifest, _, err := client.manifest(img.ref.Digest, img.arch)
+	if err != nil {
+		return fail(err)
+	}
+	var total int64
+	for _, l := range manifest.Layers {
+		total += l.Size
+	}
+	if total > maxSBOMImageBytes {
+		return fail(fmt.Errorf("image layers total %d bytes, above the %d byte scan limit", total, int64(maxSBOMImageBytes)))
+	}
+
+	catalog, err := catalogImage(clientset, config, img.ref, img.arch, auth, format)
+	if err != nil {
+		return fail(err)
+	}
+	record.Packages = catalog.Packages
+	if catalog.OSID != "" {
+		record.OS = strings.TrimSpace(catalog.OSID + " " + catalog.OSVersion)
+	}
+
+	data := catalog.Document
+	contentType := "application/vnd.cyclonedx+json"
+	if format == "spdx" {
+		contentType = "application/spdx+json"
+	}
+	record.SizeBytes = len(data)
+	if len(data) > artifactMaxBytes {
+		return fail(fmt.Errorf("SBOM of %d bytes exceeds the %d byte artifact limit", len(data), artifactMaxBytes))
+	}
+
+	artifact, err := uploadArtifact(config, map[string]interface{}{
+		"kind":         "sbom",
+		"image":        record.Image,
+		"image_digest": record.Digest,
+		"format":       format,
+	}, data, contentType)
+	if err != nil {
+		return fail(fmt.Errorf("upload: %w", err))
+	}
+	record.ArtifactID, _ = artifact["id"].(string)
+	return record
+}
+
+// collectSBOMStatus builds the "sbom" metric from the scanner's state
+func collectSBOMStatus(config AgentConfig) map[string]interface{} {
+	defer diagnostics.recordDuration("sbom", time.Now())
+
+	sbomState.mu.Lock()
+	defer sbomState.mu.Unlock()
+
+	records := make([]sbomRecord, 0, len(sbomState.records))
+	failed := 0
+	for _, r := range sbomState.records {
+		if r.Error != "" {
+			failed++
+		}
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ScannedAt.After(records[j].ScannedAt) })
+	truncated := len(records) > maxSBOMRecords
+	if truncated {
+		records = records[:maxSBOMRecords]
+	}
+
+	result := map[string]interface{}{
+		"format":         sbomFormat(config),
+		"interval_hours": config.SBOMIntervalHours,
+		"images":         records,
+		"scanned":        len(sbomState.records) - failed,
+		"failed":         failed,
+		"pending":        sbomState.pending,
+		"truncated":      truncated,
+	}
+	if !sbomState.lastRound.IsZero() {
+		result["last_round_at"] = sbomState.lastRound.UTC()
+	}
+	if !sbomState.nextRound.IsZero() {
+		result["next_round_at"] = sbomState.nextRound.UTC()
+	}
+	return result
+}
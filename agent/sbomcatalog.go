@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// SBOM CATALOGING
+// Images are cataloged by Syft in a short-lived pod of the agent namespace
+// (kubernetes/sbom-scanner.yaml), never inside the agent: the scan pulls and
+// squashes the whole image, which needs far more memory and disk than the
+// agent's own limits. The pod runs the SBOM_SYFT_IMAGE image against the
+// registry digest (with the pod's pull secret, passed through a temporary
+// Secret), writes CycloneDX 1.5 or SPDX 2.3 JSON to stdout and is deleted
+// once the agent has read its log.
+// ---------------------------------------------
+
+const (
+	// defaultSyftImage runs the scans unless SBOM_SYFT_IMAGE is set
+	defaultSyftImage = "anchore/syft:v1.4.1"
+	// sbomScanTimeout covers scheduling, the Syft and image pulls and the scan
+	sbomScanTimeout = 30 * time.Minute
+	// sbomScanMemory and sbomScanDisk are the scan pod's limits
+	sbomScanMemory = "2Gi"
+	sbomScanDisk   = "4Gi"
+)
+
+// imageCatalog is Syft's SBOM of one image, encoded
+type imageCatalog struct {
+	OSID      string
+	OSVersion string
+	Packages  int
+	Document  []byte
+}
+
+// catalogImage runs Syft on ref for linux/arch in a scan pod and returns its
+// SBOM as docFormat
+func catalogImage(clientset kubernetes.Interface, config AgentConfig, ref imageRef, arch string, auth *registryAuth, docFormat string) (*imageCatalog, error) {
+	name := "kodo-sbom-" + rand.String(5)
+	namespace := config.Namespace
+	cleanup := func(kind string, del func(context.Context) error) {
+		ctx, cancel := apiContext()
+		defer cancel()
+		if err := del(ctx); err != nil {
+			log.Printf("⚠️  Failed to delete SBOM scan %s %s/%s: %v", kind, namespace, name, err)
+		}
+	}
+
+	if auth != nil {
+		user, pass := auth.basic()
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"app": "kodo-sbom", "app.kubernetes.io/managed-by": fieldManager},
+			},
+			StringData: map[string]string{"authority": syftAuthority(ref), "username": user, "password": pass},
+		}
+		ctx, cancel := apiContext()
+		_, err := clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{FieldManager: fieldManager})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create scan credentials: %w", err)
+		}
+		defer cleanup("secret", func(ctx context.Context) error {
+			return clientset.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		})
+	}
+
+	pod := sbomScanPod(namespace, name, config.SBOMSyftImage, ref, arch, auth != nil, docFormat)
+	ctx, cancel := apiContext()
+	_, err := clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{FieldManager: fieldManager})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan pod: %w", err)
+	}
+	defer cleanup("pod", func(ctx context.Context) error {
+		return clientset.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	})
+
+	terminated, err := waitForPodExit(clientset, namespace, name, "SBOM scan", sbomScanTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// One byte over the artifact limit is enough to reject the document
+	limit := int64(artifactMaxBytes + 1)
+	ctx, cancel = apiContext()
+	doc, err := clientset.CoreV1().Pods(namespace).GetLogs(name, &corev1.PodLogOptions{Container: "syft", LimitBytes: &limit}).DoRaw(ctx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the SBOM: %w", err)
+	}
+	if terminated.ExitCode != 0 {
+		msg := strings.TrimSpace(string(doc))
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return nil, fmt.Errorf("syft exited with code %d: %s", terminated.ExitCode, msg)
+	}
+	if int64(len(doc)) >= limit {
+		return nil, fmt.Errorf("SBOM exceeds the %d byte artifact limit", artifactMaxBytes)
+	}
+	return summarizeSBOM(doc, docFormat)
+}
+
+// sbomScanPod builds the pod that runs Syft against ref; credentials come
+// from the Secret of the same name when withAuth is set
+func sbomScanPod(namespace, name, image string, ref imageRef, arch string, withAuth bool, docFormat string) *corev1.Pod {
+	output := "cyclonedx-json@1.5"
+	if docFormat == "spdx" {
+		output = "spdx-json@2.3"
+	}
+	env := []corev1.EnvVar{
+		{Name: "TMPDIR", Value: "/tmp"},
+		{Name: "SYFT_CHECK_FOR_APP_UPDATE", Value: "false"},
+	}
+	if withAuth {
+		for _, key := range []string{"authority", "username", "password"} {
+			env = append(env, corev1.EnvVar{
+				Name: "SYFT_REGISTRY_AUTH_" + strings.ToUpper(key),
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+					Key:                  key,
+				}},
+			})
+		}
+	}
+	deadline := int64(sbomScanTimeout.Seconds())
+	diskLimit := resource.MustParse(sbomScanDisk)
+	yes, no := true, false
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "kodo-sbom", "app.kubernetes.io/managed-by": fieldManager},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds:        &deadline,
+			AutomountServiceAccountToken: &no,
+			NodeSelector:                 map[string]string{corev1.LabelOSStable: "linux"},
+			Containers: []corev1.Container{{
+				Name:  "syft",
+				Image: image,
+				Args: []string{
+					"scan", "registry:" + ref.Registry + "/" + ref.Repository + "@" + ref.Digest,
+					"--platform", "linux/" + arch, "-o", output, "-q",
+				},
+				Env: env,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:              resource.MustParse("100m"),
+						corev1.ResourceMemory:           resource.MustParse("256Mi"),
+						corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceMemory:           resource.MustParse(sbomScanMemory),
+						corev1.ResourceEphemeralStorage: diskLimit,
+					},
+				},
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &no,
+					ReadOnlyRootFilesystem:   &yes,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "tmp", MountPath: "/tmp"}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "tmp",
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &diskLimit}},
+			}},
+		},
+	}
+}
+
+// summarizeSBOM counts the packages and reads the distro of a Syft document
+func summarizeSBOM(doc []byte, docFormat string) (*imageCatalog, error) {
+	catalog := &imageCatalog{Document: doc}
+	if docFormat == "spdx" {
+		var spdx struct {
+			Packages []struct {
+				Name    string `json:"name"`
+				Version string `json:"versionInfo"`
+				Purpose string `json:"primaryPackagePurpose"`
+			} `json:"packages"`
+		}
+		if err := json.Unmarshal(doc, &spdx); err != nil {
+			return nil, fmt.Errorf("syft output is not SPDX JSON: %v", err)
+		}
+		for _, p := range spdx.Packages {
+			switch p.Purpose {
+			case "OPERATING-SYSTEM":
+				catalog.OSID, catalog.OSVersion = p.Name, p.Version
+			case "CONTAINER":
+			default:
+				catalog.Packages++
+			}
+		}
+		return catalog, nil
+	}
+
+	var cdx struct {
+		Components []struct {
+			Type    string `json:"type"`
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(doc, &cdx); err != nil {
+		return nil, fmt.Errorf("syft output is not CycloneDX JSON: %v", err)
+	}
+	for _, c := range cdx.Components {
+		if c.Type == "operating-system" {
+			catalog.OSID, catalog.OSVersion = c.Name, c.Version
+			continue
+		}
+		catalog.Packages++
+	}
+	return catalog, nil
+}
+
+// syftAuthority is the registry name Syft matches credentials against
+// (Docker Hub images resolve to index.docker.io)
+func syftAuthority(ref imageRef) string {
+	if ref.Registry == "docker.io" {
+		return "index.docker.io"
+	}
+	return strings.ToLower(ref.Registry)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// SCHEDULING (splay + jitter)
+// Avoids synchronized load spikes when many agents start together
+// ---------------------------------------------
+
+// splayDelay returns a random delay in [0, max) used once before the first cycle
+func splayDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// jitteredInterval returns base shifted randomly by up to ±percent% of base
+func jitteredInterval(base time.Duration, percent int) time.Duration {
+	if percent <= 0 || base <= 0 {
+		return base
+	}
+	spread := int64(base) * int64(percent) / 100
+	if spread <= 0 {
+		return base
+	}
+	return base + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
+
+// runJittered waits a random splay, then calls fn forever, sleeping a
+// jittered interval between runs. The interval is re-read before every sleep
+// so runtime settings changes apply from the next cycle. When the memory
+// watchdog fires, the loop drops the state it keeps between cycles and
+// starts over with a fresh splay. It never returns.
+func runJittered(name string, interval func() time.Duration, maxSplay time.Duration, jitterPercent int, fn func()) {
+	splay := splayDelay(maxSplay)
+	log.Printf("⏱️  %s loop: first run in %v, then every %v ±%d%%", name, splay.Round(time.Millisecond), interval(), jitterPercent)
+	time.Sleep(splay)
+
+	for {
+		fn()
+		time.Sleep(jitteredInterval(interval(), jitterPercent))
+		if watchdog.restartLoop(name) {
+			splay := splayDelay(maxSplay)
+			log.Printf("🐕 Dropped %s loop state, restarting in %v", name, splay.Round(time.Millisecond))
+			time.Sleep(splay)
+		}
+	}
+}
+
+// slowCollectorIntervals are the collectors whose cluster-wide lists cover
+// data that rarely changes. They run on their own jittered interval instead
+// of every cycle; in between the backend keeps showing their last data.
+var slowCollectorIntervals = map[string]time.Duration{
+	"rbac_changes":  5 * time.Minute,
+	"replicasets":   5 * time.Minute,
+	"topology":      5 * time.Minute,
+	"configmaps":    10 * time.Minute,
+	"leases":        2 * time.Minute,
+	"control_plane": 2 * time.Minute,
+}
+
+// slowCollectorJitterPercent spreads the slow runs of agents started together
+const slowCollectorJitterPercent = 20
+
+// slowCollectorSchedule remembers when each slow collector is due next
+type slowCollectorSchedule struct {
+	mu      sync.Mutex
+	nextRun map[string]time.Time
+}
+
+var slowCollectors = &slowCollectorSchedule{nextRun: map[string]time.Time{}}
+
+// skipReason returns why a slow collector does not run this cycle, or ""
+// when it is due (or not a slow collector); a due collector is rescheduled
+func (s *slowCollectorSchedule) skipReason(metricType string, now time.Time) string {
+	interval, ok := slowCollectorIntervals[metricType]
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if next, ok := s.nextRun[metricType]; ok && now.Before(next) {
+		return fmt.Sprintf("slow schedule (every %v)", interval)
+	}
+	s.nextRun[metricType] = now.Add(jitteredInterval(interval, slowCollectorJitterPercent))
+	return ""
+}
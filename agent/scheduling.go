@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// UNSATISFIABLE SCHEDULING CONSTRAINTS
+// Pods whose nodeSelector, required node affinity or tolerations no current
+// node satisfies stay Pending forever (a selector for a removed pool, a new
+// taint nobody tolerates). The constraints of every unschedulable pod are
+// checked against the nodes and the workloads that can never be placed are
+// reported as findings. Resource shortage is not judged here: a node that
+// matches but is full can still free up. Pods are only checked after
+// unschedulablePendingGrace, so a pool the autoscaler is scaling up from
+// zero is not reported while its first node starts.
+// ---------------------------------------------
+
+// unschedulablePendingGrace is how long a pod must be unschedulable before it is checked
+const unschedulablePendingGrace = 10 * time.Minute
+
+// unschedulableSince returns when the scheduler marked the pod unschedulable
+func unschedulableSince(pod corev1.Pod) (time.Time, bool) {
+	if pod.Spec.NodeName != "" || pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodPending {
+		return time.Time{}, false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable {
+			return cond.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nodeSelectorMatches checks the pod's nodeSelector and required node affinity
+func nodeSelectorMatches(spec corev1.PodSpec, node corev1.Node) bool {
+	for key, value := range spec.NodeSelector {
+		if v, ok := node.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// Terms are ORed, the requirements within a term ANDed; an empty term matches nothing
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		matches := true
+		for _, req := range term.MatchExpressions {
+			matches = matches && nodeRequirementMatches(req, node.Labels)
+		}
+		for _, req := range term.MatchFields {
+			// metadata.name is the only field the scheduler supports
+			matches = matches && req.Key == "metadata.name" && nodeRequirementMatches(req, map[string]string{req.Key: node.Name})
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRequirementMatches evaluates one node selector requirement against labels
+func nodeRequirementMatches(req corev1.NodeSelectorRequirement, labels map[string]string) bool {
+	value, present := labels[req.Key]
+	switch req.Operator {
+	case corev1.NodeSelectorOpIn:
+		return present && containsString(req.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !present || !containsString(req.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return present
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !present
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !present || len(req.Values) != 1 {
+			return false
+		}
+		have, err1 := strconv.ParseInt(value, 10, 64)
+		want, err2 := strconv.ParseInt(req.Values[0], 10, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if req.Operator == corev1.NodeSelectorOpGt {
+			return have > want
+		}
+		return have < want
+	}
+	return false
+}
+
+// untoleratedTaints returns the node's scheduling taints the pod does not
+// tolerate; a cordoned node counts as tainted unschedulable
+func untoleratedTaints(spec corev1.PodSpec, node corev1.Node) []corev1.Taint {
+	taints := append([]corev1.Taint{}, node.Spec.Taints...)
+	if node.Spec.Unschedulable {
+		taints = append(taints, corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule})
+	}
+	var untolerated []corev1.Taint
+	for i := range taints {
+		if taints[i].Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range spec.Tolerations {
+			if spec.Tolerations[j].ToleratesTaint(&taints[i]) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			untolerated = append(untolerated, taints[i])
+		}
+	}
+	return untolerated
+}
+
+// formatTaint renders a taint the way kubectl does (key=value:Effect)
+func formatTaint(t corev1.Taint) string {
+	if t.Value == "" {
+		return t.Key + ":" + string(t.Effect)
+	}
+	return t.Key + "=" + t.Value + ":" + string(t.Effect)
+}
+
+// collectSchedulingConstraints builds the "scheduling_constraints" metric
+func collectSchedulingConstraints(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("scheduling_constraints", time.Now())
+
+	type pendingWorkload struct {
+		namespace, kind, name string
+		spec                  corev1.PodSpec
+		pods                  int
+		since                 time.Time
+	}
+	byWorkload := map[string]*pendingWorkload{}
+	checked := 0
+	for _, pod := range snap.Pods {
+		since, ok := unschedulableSince(pod)
+		if !ok || snap.TakenAt.Sub(since) < unschedulablePendingGrace {
+			continue
+		}
+		checked++
+		kind, name := podWorkload(pod)
+		key := pod.Namespace + "/" + kind + "/" + name
+		w, ok := byWorkload[key]
+		if !ok {
+			// Replicas share a template, so the first pod describes the constraints
+			w = &pendingWorkload{namespace: pod.Namespace, kind: kind, name: name, spec: pod.Spec, since: since}
+			byWorkload[key] = w
+		}
+		w.pods++
+		if since.Before(w.since) {
+			w.since = since
+		}
+	}
+
+	keys := make([]string, 0, len(byWorkload))
+	for key := range byWorkload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []map[string]interface{}
+	for _, key := range keys {
+		// Without nodes nothing can be judged
+		if len(snap.Nodes) == 0 {
+			break
+		}
+		w := byWorkload[key]
+
+		selected := 0
+		placeable := false
+		taints := map[string]bool{}
+		for _, node := range snap.Nodes {
+			if !nodeSelectorMatches(w.spec, node) {
+				continue
+			}
+			selected++
+			blocking := untoleratedTaints(w.spec, node)
+			if len(blocking) == 0 {
+				placeable = true
+				break
+			}
+			for _, t := range blocking {
+				taints[formatTaint(t)] = true
+			}
+		}
+		if placeable {
+			continue
+		}
+
+		finding := map[string]interface{}{
+			"namespace":               w.namespace,
+			"kind":                    w.kind,
+			"name":                    w.name,
+			"severity":                "high",
+			"pending_pods":            w.pods,
+			"unschedulable_since":     w.since,
+			"node_selector":           w.spec.NodeSelector,
+			"required_node_affinity":  describeRequiredNodeAffinity(w.spec),
+			"tolerations":             describeTolerations(w.spec.Tolerations),
+			"nodes_matching_selector": selected,
+		}
+		if selected == 0 {
+			finding["id"] = "no_matching_nodes"
+			finding["detail"] = "no node has the labels required by the nodeSelector/node affinity; the pool may have been removed or relabeled"
+		} else {
+			finding["id"] = "taints_not_tolerated"
+			finding["untolerated_taints"] = sortedKeys(taints)
+			finding["detail"] = fmt.Sprintf("every node matching the selector (%d) has taints the pods do not tolerate", selected)
+		}
+		findings = append(findings, finding)
+	}
+
+	return map[string]interface{}{
+		"findings":                findings,
+		"unsatisfiable_workloads": len(findings),
+		"unschedulable_pods":      checked,
+		"grace_seconds":           int64(unschedulablePendingGrace.Seconds()),
+	}
+}
+
+// describeRequiredNodeAffinity renders the required node affinity terms
+// ("zone In [a b]"), one string per ORed term
+func describeRequiredNodeAffinity(spec corev1.PodSpec) []string {
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	var terms []string
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		var parts []string
+		for _, req := range append(append([]corev1.NodeSelectorRequirement{}, term.MatchExpressions...), term.MatchFields...) {
+			part := req.Key + " " + string(req.Operator)
+			if len(req.Values) > 0 {
+				part += " [" + strings.Join(req.Values, " ") + "]"
+			}
+			parts = append(parts, part)
+		}
+		terms = append(terms, strings.Join(parts, ", "))
+	}
+	return terms
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// SCHEMA VERSIONING AND CAPABILITY NEGOTIATION
+// Every metric envelope carries the schema_version of its data. On startup
+// (and hourly) the agent asks the backend which metric types and versions it
+// accepts; types the backend ignores are not collected at all, and a type
+// whose current version is too new is downgraded when a converter exists.
+// Backends without the handshake endpoint keep receiving everything.
+// ---------------------------------------------
+
+const (
+	// capabilitiesRefresh is how often the accepted types are renegotiated
+	capabilitiesRefresh = time.Hour
+	// capabilitiesRetry is the wait after a failed handshake
+	capabilitiesRetry = 5 * time.Minute
+)
+
+// metricSchemaVersions is the current data version per metric type. Bump a
+// type's version on any breaking change to its data (renamed or retyped
+// fields) and add a converter to schemaDowngrades for backends still on the
+// previous version. Additive fields do not need a bump.
+var metricSchemaVersions = map[string]int{
+	"agent_status":                1,
+	"agent_info":                  1,
+	"cpu":                         1,
+	"memory":                      1,
+	"pods":                        1,
+	"nodes":                       1,
+	"pod_details":                 1,
+	"events":                      1,
+	"pvcs":                        1,
+	"standalone_pvs":              1,
+	"storage":                     1,
+	"node_storage":                1,
+	"security":                    1,
+	"security_rbac":               1,
+	"security_default_hygiene":    1,
+	"security_network_policies":   1,
+	"security_secrets":            1,
+	"security_resource_quotas":    1,
+	"security_limit_ranges":       1,
+	"security_pod_security":       1,
+	"security_ingress_controller": 1,
+	"security_ingress_backends":   1,
+	"security_kubelet":            1,
+	"security_threats":            1,
+	"network":                     1,
+	"coredns":                     1,
+	"priority":                    1,
+	"stuck_deletions":             1,
+	"kubelet_config":              1,
+	"workload_placement":          1,
+	"zone_topology":               1,
+	"node_conditions":             1,
+	"replicasets":                 1,
+	"service_routing":             1,
+	"oom_events":                  1,
+	"timeseries":                  1,
+	"network_top_talkers":         1,
+	"system_overhead":             1,
+	"image_pulls":                 1,
+	"pod_lifecycle":               1,
+	"topology":                    1,
+	"configmaps":                  1,
+	"leases":                      1,
+	"control_plane":               1,
+	"rbac_changes":                1,
+	"rbac_subjects":               1,
+	"upgrade_readiness":           1,
+	"evictions":                   1,
+	"agent_footprint":             1,
+	"connection_anomalies":        1,
+	"sbom":                        1,
+	"image_signatures":            1,
+	"registry_credentials":        1,
+	"report":                      1,
+	"changes":                     1,
+	"mesh":                        1,
+	"gitops":                      1,
+	"custom_metrics":              1,
+	"restart_reasons":             1,
+	"scheduling_constraints":      1,
+}
+
+// schemaDowngrades converts a metric's current data to an older version,
+// keyed by metric type and target version
+var schemaDowngrades = map[string]map[int]func(map[string]interface{}) map[string]interface{}{}
+
+// metricSchemaVersion returns the current data version of a metric type
+func metricSchemaVersion(metricType string) int {
+	if v, ok := metricSchemaVersions[metricType]; ok {
+		return v
+	}
+	return 1
+}
+
+// backendCapabilities is what the backend declared it accepts
+type backendCapabilities struct {
+	mu sync.RWMutex
+	// accepted is nil when the backend has no handshake (accept everything)
+	accepted  map[string][]int
+	gzip      bool
+	nextCheck time.Time
+}
+
+var capabilities = &backendCapabilities{}
+
+// capabilitiesResponse is the body returned by agent-capabilities
+type capabilitiesResponse struct {
+	MetricTypes      map[string][]int `json:"metric_types"`
+	ContentEncodings []string         `json:"content_encodings"`
+}
+
+// payloadVersion returns the schema version to send a metric type in, and
+// false when the backend does not want the type (or no compatible version exists)
+func (c *backendCapabilities) payloadVersion(metricType string) (int, bool) {
+	current := metricSchemaVersion(metricType)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.accepted == nil {
+		return current, true
+	}
+	best := 0
+	for _, v := range c.accepted[metricType] {
+		if v > best && (v == current || (v < current && schemaDowngrades[metricType][v] != nil)) {
+			best = v
+		}
+	}
+	return best, best > 0
+}
+
+// declares reports whether the backend's handshake explicitly listed a metric
+// type, as opposed to accepting everything for lack of a handshake
+func (c *backendCapabilities) declares(metricType string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.accepted[metricType]
+	return ok
+}
+
+// acceptsGzip reports whether payloads may be sent with Content-Encoding: gzip
+func (c *backendCapabilities) acceptsGzip() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.gzip
+}
+
+// adaptMetricData converts data to the requested version (no-op for the current one)
+func adaptMetricData(metricType string, version int, data map[string]interface{}) map[string]interface{} {
+	if version == metricSchemaVersion(metricType) {
+		return data
+	}
+	return schemaDowngrades[metricType][version](data)
+}
+
+// refreshCapabilities runs the handshake when it is due. Errors keep the
+// previous answer and retry later.
+func refreshCapabilities(config AgentConfig) {
+	if config.dryRun() {
+		return
+	}
+	c := capabilities
+	c.mu.RLock()
+	due := !time.Now().Before(c.nextCheck)
+	c.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	accepted, encodings, err := negotiateCapabilities(config)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		log.Printf("⚠️  Capability handshake failed, keeping previous capabilities: %v", err)
+		c.nextCheck = time.Now().Add(capabilitiesRetry)
+		return
+	}
+	c.accepted = accepted
+	c.gzip = containsString(encodings, "gzip")
+	c.nextCheck = time.Now().Add(capabilitiesRefresh)
+	if accepted == nil {
+		log.Printf("🤝 Backend has no capability handshake, sending every metric type")
+		return
+	}
+	var skipped []string
+	for metricType := range metricSchemaVersions {
+		if _, ok := accepted[metricType]; !ok {
+			skipped = append(skipped, metricType)
+		}
+	}
+	log.Printf("🤝 Backend accepts %d metric types (gzip: %v); not collecting %v", len(accepted), c.gzip, sortedKeys(stringSet(skipped)))
+}
+
+// negotiateCapabilities posts the agent's metric types and versions and
+// returns the backend's accepted versions per type (nil on a legacy backend)
+// and the content encodings it accepts for payloads
+func negotiateCapabilities(config AgentConfig) (accepted map[string][]int, encodings []string, err error) {
+	defer func(start time.Time) {
+		diagnostics.recordBackendCall("agent-capabilities", start, err)
+	}(time.Now())
+
+	body, err := json.Marshal(map[string]interface{}{
+		"agent_version": AgentVersion,
+		"metric_types":  metricSchemaVersions,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	url := fmt.Sprintf("%s/agent-capabilities", config.APIEndpoint)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create capabilities request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-agent-version", AgentVersion)
+	if err := authenticateRequest(config, req, agentAPIKey(config), body); err != nil {
+		return nil, nil, err
+	}
+
+	client := backendClient(config)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, fmt.Errorf("backend returned %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	var parsed capabilitiesResponse
+	if err := json.Unmarshal(responseBody, &parsed); err != nil {
+		return nil, nil, fmt.Errorf("invalid capabilities response: %v", err)
+	}
+	return parsed.MetricTypes, parsed.ContentEncodings, nil
+}
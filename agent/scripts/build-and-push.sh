@@ -14,6 +14,8 @@ docker buildx create --use --name kodo-builder 2>/dev/null || docker buildx use
 
 docker buildx build \
   --platform linux/amd64,linux/arm64 \
+  --build-arg GIT_COMMIT="$(git rev-parse --short HEAD 2>/dev/null)" \
+  --build-arg BUILD_DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)" \
   -t ${FULL_IMAGE} \
   -t ${IMAGE_NAME}:latest \
   --push \
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// SECURITY DATA COLLECTION
+// Security data is collected in independent areas (RBAC, network policies,
+// secrets, quotas...), each sent as its own "security_<area>" metric with its
+// own status. Each area has its own interval and timeout and runs in its own
+// goroutine; a slow or failing area keeps its last good result (marked stale)
+// without touching the others, and a timed-out run is cancelled.
+// ---------------------------------------------
+
+// securityArea is one independently scheduled security metric
+type securityArea struct {
+	name string
+	// interval between collections; 0 collects every cycle
+	interval time.Duration
+	// timeout bounds a run: the cycle stops waiting and the run's API calls
+	// are cancelled
+	timeout time.Duration
+	collect func(ctx context.Context, clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{}
+}
+
+// metricType is the metric the area is reported under
+func (a securityArea) metricType() string {
+	return "security_" + a.name
+}
+
+// securityAreas lists the areas in payload order. RBAC and secrets are the
+// largest lists and run less often.
+var securityAreas = []securityArea{
+	{name: "rbac", interval: 5 * time.Minute, timeout: 2 * time.Minute, collect: collectRBACSummary},
+	{name: "default_hygiene", interval: 5 * time.Minute, timeout: time.Minute, collect: collectDefaultHygiene},
+	{name: "network_policies", timeout: time.Minute, collect: collectNetworkPolicySummary},
+	{name: "secrets", interval: 5 * time.Minute, timeout: 2 * time.Minute, collect: collectSecretsSummary},
+	{name: "resource_quotas", timeout: time.Minute, collect: collectResourceQuotaSummary},
+	{name: "limit_ranges", timeout: time.Minute, collect: collectLimitRangeSummary},
+	{name: "pod_security", timeout: 30 * time.Second, collect: collectPodSecuritySummary},
+	// Detected on its own slow loop; this only reads the cached result
+	{name: "ingress_controller", timeout: 10 * time.Second, collect: func(context.Context, kubernetes.Interface, *ClusterSnapshot, *CollectorStatus) map[string]interface{} {
+		return ingressDetection.current()
+	}},
+	{name: "ingress_backends", timeout: time.Minute, collect: checkIngressBackends},
+	// Kubelet settings from /configz (anonymous auth, read-only port...)
+	{name: "kubelet", timeout: 10 * time.Second, collect: func(_ context.Context, _ kubernetes.Interface, snap *ClusterSnapshot, _ *CollectorStatus) map[string]interface{} {
+		return summarizeKubeletSecurity(snap)
+	}},
+}
+
+// securityAreaState keeps an area's last result between cycles
+type securityAreaState struct {
+	mu          sync.Mutex
+	data        map[string]interface{}
+	state       string
+	err         string
+	collectedAt time.Time
+	running     bool
+}
+
+var securityAreaStates = func() map[string]*securityAreaState {
+	states := make(map[string]*securityAreaState, len(securityAreas))
+	for _, area := range securityAreas {
+		states[area.name] = &securityAreaState{}
+	}
+	return states
+}()
+
+// start launches the area unless it is still running or not yet due; the
+// returned channel is closed when the run finishes
+func (s *securityAreaState) start(area securityArea, clientset kubernetes.Interface, snap *ClusterSnapshot) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running || (!s.collectedAt.IsZero() && snap.TakenAt.Sub(s.collectedAt) < area.interval) {
+		return nil
+	}
+	s.running = true
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer diagnostics.recordDuration("security/"+area.name, time.Now())
+		ctx, cancel := context.WithTimeout(context.Background(), area.timeout)
+		defer cancel()
+		status := &CollectorStatus{}
+		data := area.collect(ctx, clientset, snap, status)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.running = false
+		s.state, s.err = status.State(), status.Error()
+		// A failed run keeps the previous data, which is still the best we know
+		if status.State() != StatusFailed || s.data == nil {
+			s.data = data
+			s.collectedAt = snap.TakenAt
+		}
+	}()
+	return done
+}
+
+// securityCycle starts the due areas once per collection cycle and hands
+// each area's result to its metric
+type securityCycle struct {
+	clientset kubernetes.Interface
+	snap      *ClusterSnapshot
+
+	once    sync.Once
+	started time.Time
+	runs    map[string]<-chan struct{}
+
+	mu      sync.Mutex
+	results map[string]securityAreaResult
+}
+
+// securityAreaResult is what a cycle reports for one area
+type securityAreaResult struct {
+	data   map[string]interface{}
+	failed bool
+	err    error
+}
+
+func newSecurityCycle(clientset kubernetes.Interface, snap *ClusterSnapshot) *securityCycle {
+	return &securityCycle{clientset: clientset, snap: snap, results: map[string]securityAreaResult{}}
+}
+
+// start launches every due area, so their timeouts run concurrently
+func (c *securityCycle) start() {
+	c.once.Do(func() {
+		c.started = time.Now()
+		c.runs = make(map[string]<-chan struct{}, len(securityAreas))
+		for _, area := range securityAreas {
+			if done := securityAreaStates[area.name].start(area, c.clientset, c.snap); done != nil {
+				c.runs[area.name] = done
+			}
+		}
+	})
+}
+
+// collect fills the area's metric status and returns its data
+func (c *securityCycle) collect(area securityArea, status *CollectorStatus) map[string]interface{} {
+	result := c.result(area)
+	switch {
+	case result.failed:
+		status.Fail(area.name, result.err)
+	case result.err != nil:
+		status.Partial(area.name, result.err)
+	}
+	return result.data
+}
+
+// result waits for the area, up to its timeout counted from the cycle's
+// start, and returns its latest data with when it was collected
+func (c *securityCycle) result(area securityArea) securityAreaResult {
+	c.start()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result, ok := c.results[area.name]; ok {
+		return result
+	}
+
+	timedOut := false
+	if done, ok := c.runs[area.name]; ok {
+		select {
+		case <-done:
+		case <-time.After(time.Until(c.started.Add(area.timeout))):
+			timedOut = true
+		}
+	}
+
+	state := securityAreaStates[area.name]
+	state.mu.Lock()
+	areaData, areaState, areaErr, collectedAt, running := state.data, state.state, state.err, state.collectedAt, state.running
+	state.mu.Unlock()
+
+	data := make(map[string]interface{}, len(areaData)+3)
+	for k, v := range areaData {
+		data[k] = v
+	}
+	data["stale"] = collectedAt.Before(c.snap.TakenAt)
+	data["interval_seconds"] = area.interval.Seconds()
+	if !collectedAt.IsZero() {
+		data["collected_at"] = collectedAt.UTC().Format(time.RFC3339)
+	}
+
+	result := securityAreaResult{data: data}
+	switch {
+	case timedOut:
+		result.err = fmt.Errorf("timed out after %s, run cancelled", area.timeout)
+		log.Printf("⚠️  Security area %s %v, sending previous result", area.name, result.err)
+	case running:
+		result.err = errors.New("previous run still in progress")
+	case areaErr != "":
+		result.err = errors.New(areaErr)
+	}
+	result.failed = areaState == StatusFailed && collectedAt.IsZero()
+	c.results[area.name] = result
+	return result
+}
+
+// legacy assembles the combined "security" metric from the same area results
+// for backends that have not declared the per-area metric types
+func (c *securityCycle) legacy(status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("security", time.Now())
+
+	securityData := map[string]interface{}{}
+	failed := 0
+	for _, area := range securityAreas {
+		result := c.result(area)
+		securityData[area.name] = result.data
+		if result.failed {
+			failed++
+		}
+	}
+	if failed == len(securityAreas) {
+		status.Fail("security", fmt.Errorf("every security area failed"))
+	}
+	return securityData
+}
+
+// collectRBACSummary counts ClusterRoles, ClusterRoleBindings, Roles and RoleBindings
+func collectRBACSummary(parent context.Context, clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	rbacData := map[string]interface{}{
+		"cluster_roles_count":         0,
+		"cluster_role_bindings_count": 0,
+		"roles_count":                 0,
+		"role_bindings_count":         0,
+		"has_rbac":                    false,
+		"cluster_roles":               []string{},
+	}
+
+	clusterRolesCount := 0
+	clusterRoleBindingsCount := 0
+
+	ctx, cancel := apiContextWithin(parent)
+	clusterRoles, err := clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("❌ ERROR listing ClusterRoles: %v", err)
+		status.Partial("clusterroles", err)
+	} else {
+		clusterRolesCount = len(clusterRoles.Items)
+		// Only store first 50 names to avoid huge payloads
+		maxRolesToStore := 50
+		if clusterRolesCount < maxRolesToStore {
+			maxRolesToStore = clusterRolesCount
+		}
+		roleNames := make([]string, 0, maxRolesToStore)
+		for i, cr := range clusterRoles.Items {
+			if i < maxRolesToStore {
+				roleNames = append(roleNames, cr.Name)
+			}
+		}
+		rbacData["cluster_roles_count"] = clusterRolesCount
+		rbacData["cluster_roles"] = roleNames
+	}
+
+	ctx, cancel = apiContextWithin(parent)
+	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("❌ ERROR listing ClusterRoleBindings: %v", err)
+		status.Partial("clusterrolebindings", err)
+	} else {
+		clusterRoleBindingsCount = len(clusterRoleBindings.Items)
+		rbacData["cluster_role_bindings_count"] = clusterRoleBindingsCount
+	}
+
+	// Count roles and rolebindings across namespaces, one cluster-wide list each
+	settings := getSettings()
+	totalRoles := 0
+	totalRoleBindings := 0
+	rolesByNamespace := make(map[string]int)
+	ctx, cancel = apiContextWithin(parent)
+	roles, err := clientset.RbacV1().Roles("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing Roles: %v", err)
+		status.Partial("roles", err)
+	} else {
+		for _, r := range filterByNamespace(roles.Items, func(r rbacv1.Role) string { return r.Namespace }, settings) {
+			totalRoles++
+			rolesByNamespace[r.Namespace]++
+		}
+	}
+	ctx, cancel = apiContextWithin(parent)
+	roleBindings, err := clientset.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing RoleBindings: %v", err)
+		status.Partial("rolebindings", err)
+	} else {
+		totalRoleBindings = len(filterByNamespace(roleBindings.Items, func(rb rbacv1.RoleBinding) string { return rb.Namespace }, settings))
+	}
+
+	hasRbac := clusterRolesCount > 0 || clusterRoleBindingsCount > 0 || totalRoles > 0 || totalRoleBindings > 0
+	log.Printf("📊 RBAC scan complete: %d ClusterRoles, %d ClusterRoleBindings, %d Roles, %d RoleBindings, has_rbac=%v",
+		clusterRolesCount, clusterRoleBindingsCount, totalRoles, totalRoleBindings, hasRbac)
+
+	rbacData["roles_count"] = totalRoles
+	rbacData["role_bindings_count"] = totalRoleBindings
+	rbacData["roles_by_namespace"] = rolesByNamespace
+	rbacData["has_rbac"] = hasRbac
+	return rbacData
+}
+
+// collectDefaultHygiene lists the bindings checkDefaultHygiene needs
+func collectDefaultHygiene(parent context.Context, clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	status.Uses(snap, "pods")
+
+	ctx, cancel := apiContextWithin(parent)
+	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		status.Fail("clusterrolebindings", err)
+		return map[string]interface{}{}
+	}
+	ctx, cancel = apiContextWithin(parent)
+	roleBindings, err := clientset.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		status.Fail("rolebindings", err)
+		return map[string]interface{}{}
+	}
+	bindings := filterByNamespace(roleBindings.Items, func(rb rbacv1.RoleBinding) string { return rb.Namespace }, getSettings())
+	return checkDefaultHygiene(snap, clusterRoleBindings.Items, bindings)
+}
+
+// collectNetworkPolicySummary lists NetworkPolicies cluster-wide
+func collectNetworkPolicySummary(parent context.Context, clientset kubernetes.Interface, _ *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	ctx, cancel := apiContextWithin(parent)
+	netPolicies, err := clientset.NetworkingV1().NetworkPolicies("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing NetworkPolicies: %v", err)
+		status.Fail("networkpolicies", err)
+		return map[string]interface{}{}
+	}
+
+	policies := filterByNamespace(netPolicies.Items, func(np networkingv1.NetworkPolicy) string { return np.Namespace }, getSettings())
+	totalNetworkPolicies := len(policies)
+	withPolicies := map[string]bool{}
+	networkPolicyDetails := []map[string]interface{}{}
+	for _, np := range policies {
+		withPolicies[np.Namespace] = true
+		networkPolicyDetails = append(networkPolicyDetails, map[string]interface{}{
+			"name":      np.Name,
+			"namespace": np.Namespace,
+		})
+	}
+	namespacesWithPolicies := len(withPolicies)
+	log.Printf("📊 NetworkPolicies scan complete: found %d policies in %d namespaces", totalNetworkPolicies, namespacesWithPolicies)
+
+	return map[string]interface{}{
+		"total_count":              totalNetworkPolicies,
+		"namespaces_with_policies": namespacesWithPolicies,
+		"has_network_policies":     totalNetworkPolicies > 0,
+		"policies":                 networkPolicyDetails,
+	}
+}
+
+// secretsPageSize bounds how many Secrets (with their data) are held at once
+const secretsPageSize = 500
+
+// collectSecretsSummary counts Secrets per namespace and type (never their content)
+func collectSecretsSummary(parent context.Context, clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	settings := getSettings()
+	totalSecrets := 0
+	helmReleases := 0
+	secretTypes := make(map[string]int)
+	secretsByNamespace := make(map[string]int)
+	var certificates []certManagerCertificate
+	// Cluster-wide, paginated: Secrets are the largest objects the agent lists
+	opts := metav1.ListOptions{Limit: secretsPageSize}
+	for {
+		ctx, cancel := apiContextWithin(parent)
+		secrets, err := clientset.CoreV1().Secrets("").List(ctx, opts)
+		cancel()
+		if err != nil {
+			log.Printf("❌ ERROR listing Secrets: %v", err)
+			if totalSecrets == 0 {
+				status.Fail("secrets", err)
+				return map[string]interface{}{}
+			}
+			status.Partial("secrets", err)
+			break
+		}
+		for _, s := range secrets.Items {
+			if !settings.NamespaceAllowed(s.Namespace) {
+				continue
+			}
+			if cert, ok := certManagerCertificateOf(s, snap.TakenAt); ok {
+				certificates = append(certificates, cert)
+			}
+			// Helm keeps one Secret per release revision, which drowns the real ones
+			if s.Type == helmReleaseSecretType {
+				helmReleases++
+				if settings.ExcludeHelmSecrets {
+					continue
+				}
+			}
+			totalSecrets++
+			secretsByNamespace[s.Namespace]++
+			secretTypes[string(s.Type)]++
+		}
+		if secrets.Continue == "" {
+			break
+		}
+		opts.Continue = secrets.Continue
+	}
+	log.Printf("✅ Secrets scan complete: found %d secrets across namespaces", totalSecrets)
+
+	return map[string]interface{}{
+		"total_count":            totalSecrets,
+		"types":                  secretTypes,
+		"has_secrets":            totalSecrets > 0,
+		"by_namespace":           secretsByNamespace,
+		"helm_release_count":     helmReleases,
+		"helm_releases_excluded": settings.ExcludeHelmSecrets,
+		"cert_manager":           summarizeCertManager(certificates),
+	}
+}
+
+// collectResourceQuotaSummary counts ResourceQuotas
+func collectResourceQuotaSummary(parent context.Context, clientset kubernetes.Interface, _ *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	ctx, cancel := apiContextWithin(parent)
+	quotas, err := clientset.CoreV1().ResourceQuotas("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing ResourceQuotas: %v", err)
+		status.Fail("resourcequotas", err)
+		return map[string]interface{}{}
+	}
+	totalQuotas := len(filterByNamespace(quotas.Items, func(q corev1.ResourceQuota) string { return q.Namespace }, getSettings()))
+
+	return map[string]interface{}{
+		"total_count": totalQuotas,
+		"has_quotas":  totalQuotas > 0,
+	}
+}
+
+// collectLimitRangeSummary counts LimitRanges
+func collectLimitRangeSummary(parent context.Context, clientset kubernetes.Interface, _ *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	ctx, cancel := apiContextWithin(parent)
+	limitRanges, err := clientset.CoreV1().LimitRanges("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing LimitRanges: %v", err)
+		status.Fail("limitranges", err)
+		return map[string]interface{}{}
+	}
+	totalLimitRanges := len(filterByNamespace(limitRanges.Items, func(lr corev1.LimitRange) string { return lr.Namespace }, getSettings()))
+
+	return map[string]interface{}{
+		"total_count":      totalLimitRanges,
+		"has_limit_ranges": totalLimitRanges > 0,
+	}
+}
+
+// collectPodSecuritySummary counts pods running as root, privileged, without limits...
+func collectPodSecuritySummary(_ context.Context, _ kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	status.Requires(snap, "pods")
+	podsWithSecurityContext := 0
+	podsRunningAsNonRoot := 0
+	podsWithResourceLimits := 0
+	privilegedContainers := 0
+
+	for _, pod := range snap.Pods {
+		hasSecurityContext := false
+		isNonRoot := false
+		hasLimits := false
+
+		// Check pod-level security context
+		if pod.Spec.SecurityContext != nil {
+			hasSecurityContext = true
+			if pod.Spec.SecurityContext.RunAsNonRoot != nil && *pod.Spec.SecurityContext.RunAsNonRoot {
+				isNonRoot = true
+			}
+		}
+
+		// Check container-level settings
+		for _, container := range pod.Spec.Containers {
+			if container.SecurityContext != nil {
+				hasSecurityContext = true
+				if container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+					privilegedContainers++
+				}
+				if container.SecurityContext.RunAsNonRoot != nil && *container.SecurityContext.RunAsNonRoot {
+					isNonRoot = true
+				}
+			}
+			if len(container.Resources.Limits) > 0 {
+				hasLimits = true
+			}
+		}
+
+		if hasSecurityContext {
+			podsWithSecurityContext++
+		}
+		if isNonRoot {
+			podsRunningAsNonRoot++
+		}
+		if hasLimits {
+			podsWithResourceLimits++
+		}
+	}
+
+	totalPods := len(snap.Pods)
+	podSecurityData := map[string]interface{}{
+		"total_pods":                  totalPods,
+		"pods_with_security_context":  podsWithSecurityContext,
+		"pods_running_as_non_root":    podsRunningAsNonRoot,
+		"pods_with_resource_limits":   podsWithResourceLimits,
+		"privileged_containers":       privilegedContainers,
+		"has_pod_security":            podsWithSecurityContext > 0,
+		"security_context_percentage": float64(0),
+		"resource_limits_percentage":  float64(0),
+	}
+	if totalPods > 0 {
+		podSecurityData["security_context_percentage"] = float64(podsWithSecurityContext) / float64(totalPods) * 100
+		podSecurityData["resource_limits_percentage"] = float64(podsWithResourceLimits) / float64(totalPods) * 100
+	}
+	return podSecurityData
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// RUNTIME SETTINGS
+// Behavior that can change without a restart (see KuberPulseConfig CRD).
+// Defaults come from env vars; a KuberPulseConfig overrides them.
+// ---------------------------------------------
+
+// AgentSettings is an immutable set of runtime knobs; replace it, never mutate it
+type AgentSettings struct {
+	Interval        time.Duration
+	CommandInterval time.Duration
+	// CommandConcurrency limits how many commands of a batch run at once
+	CommandConcurrency int
+
+	// nil means "all"; the Disabled/Excluded/Denied sets always win
+	EnabledCollectors  map[string]bool
+	DisabledCollectors map[string]bool
+	IncludeNamespaces  map[string]bool
+	ExcludeNamespaces  map[string]bool
+	AllowedCommands    map[string]bool
+	DeniedCommands     map[string]bool
+	// PatchableKinds lists the "Kind.group" values patch_resource may touch
+	PatchableKinds map[string]bool
+
+	CustomMetrics CustomMetricsConfig
+	// MeshTelemetry queries mesh success rate/latency from CustomMetrics.PrometheusURL
+	MeshTelemetry bool
+	// RBACSubjects exports the resolved permissions of every RBAC subject
+	RBACSubjects bool
+	// ExcludeHelmSecrets leaves Helm release Secrets out of the secrets counts
+	ExcludeHelmSecrets bool
+	// Suppressions silence accepted threat findings (see suppressions.go)
+	Suppressions []FindingSuppression
+	// ConnectionSampling reads per-pod connection samples from the node probes
+	ConnectionSampling bool
+	// Tenants maps namespaces to tenants for tagged or split payloads (see tenants.go)
+	Tenants TenantPolicy
+
+	// Source describes where the settings came from, e.g. "env" or "crd:kodo/kodo-agent@3"
+	Source string
+}
+
+var (
+	settingsMu      sync.RWMutex
+	currentSettings *AgentSettings
+)
+
+// defaultSettings derives the settings used when no KuberPulseConfig exists
+func defaultSettings(config AgentConfig) *AgentSettings {
+	interval := time.Duration(config.Interval) * time.Second
+	return &AgentSettings{
+		Interval:           interval,
+		CommandInterval:    interval,
+		CommandConcurrency: clampCommandConcurrency(config.CommandConcurrency),
+		PatchableKinds:     patchableKinds(strings.Split(config.PatchAllowedKinds, ",")),
+		CustomMetrics: CustomMetricsConfig{
+			PrometheusURL: config.PrometheusURL,
+			Queries:       parsePrometheusQueries(config.PrometheusQueries),
+		},
+		MeshTelemetry:      config.MeshTelemetry,
+		RBACSubjects:       config.RBACSubjects,
+		ExcludeHelmSecrets: config.ExcludeHelmSecrets,
+		Suppressions:       parseSuppressions(config.ThreatSuppressions),
+		ConnectionSampling: config.ConnectionSampling,
+		Tenants:            tenantPolicyFromEnv(config),
+		Source:             "env",
+	}
+}
+
+// clampCommandConcurrency keeps the command concurrency within [1, maxCommandConcurrency]
+func clampCommandConcurrency(n int) int {
+	if n < 1 {
+		return 1
+	}
+	if n > maxCommandConcurrency {
+		return maxCommandConcurrency
+	}
+	return n
+}
+
+// patchableKinds builds the patch allowlist, falling back to defaultPatchableKinds
+func patchableKinds(kinds []string) map[string]bool {
+	set := map[string]bool{}
+	for _, k := range kinds {
+		if k = strings.TrimSpace(k); k != "" {
+			set[k] = true
+		}
+	}
+	if len(set) == 0 {
+		return stringSet(defaultPatchableKinds)
+	}
+	return set
+}
+
+// getSettings returns the settings in effect
+func getSettings() *AgentSettings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return currentSettings
+}
+
+// applySettings atomically replaces the settings in effect
+func applySettings(s *AgentSettings) {
+	settingsMu.Lock()
+	currentSettings = s
+	settingsMu.Unlock()
+}
+
+// CollectorEnabled reports whether a metric type should be collected
+func (s *AgentSettings) CollectorEnabled(metricType string) bool {
+	if s.DisabledCollectors[metricType] {
+		return false
+	}
+	return s.EnabledCollectors == nil || s.EnabledCollectors[metricType]
+}
+
+// NamespaceAllowed reports whether resources in a namespace should be collected.
+// Cluster-scoped resources (empty namespace) are always allowed.
+func (s *AgentSettings) NamespaceAllowed(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	if s.ExcludeNamespaces[namespace] {
+		return false
+	}
+	return s.IncludeNamespaces == nil || s.IncludeNamespaces[namespace]
+}
+
+// CommandAllowed reports whether the command policy permits a command type
+func (s *AgentSettings) CommandAllowed(commandType string) bool {
+	if s.DeniedCommands[commandType] {
+		return false
+	}
+	return s.AllowedCommands == nil || s.AllowedCommands[commandType]
+}
+
+// filterByNamespace keeps the items whose namespace is allowed by the settings
+func filterByNamespace[T any](items []T, namespaceOf func(T) string, s *AgentSettings) []T {
+	if s.IncludeNamespaces == nil && len(s.ExcludeNamespaces) == 0 {
+		return items
+	}
+	filtered := items[:0]
+	for _, item := range items {
+		if s.NamespaceAllowed(namespaceOf(item)) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
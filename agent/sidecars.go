@@ -0,0 +1,106 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// INJECTED SIDECARS
+// Mesh proxies and secret agents are injected into application pods by
+// webhooks. Their requests and usage are reported apart from the
+// application's so rightsizing and cost do not charge the proxy's memory to
+// the app (or suggest shrinking the app because the proxy is idle).
+// ---------------------------------------------
+
+// injectedSidecars maps the container names injectors use to the injector
+var injectedSidecars = map[string]string{
+	"istio-proxy":               "istio",
+	"istio-init":                "istio",
+	"istio-validation":          "istio",
+	"linkerd-proxy":             "linkerd",
+	"linkerd-init":              "linkerd",
+	"linkerd-network-validator": "linkerd",
+	"vault-agent":               "vault",
+	"vault-agent-init":          "vault",
+}
+
+// sidecarInjector returns who injected the container, or "" for the app's own
+func sidecarInjector(name string) string {
+	return injectedSidecars[name]
+}
+
+// resourceSplit sums requests and observed usage for one side of a pod
+type resourceSplit struct {
+	requestsCPU    int64
+	requestsMemory int64
+	usageCPU       int64
+	usageMemory    uint64
+	hasUsage       bool
+}
+
+func (r *resourceSplit) add(c corev1.Container, stats *ContainerStats) {
+	r.requestsCPU += c.Resources.Requests.Cpu().MilliValue()
+	r.requestsMemory += c.Resources.Requests.Memory().Value()
+	if stats == nil {
+		return
+	}
+	if stats.CPU != nil && stats.CPU.UsageNanoCores != nil {
+		r.usageCPU += int64(*stats.CPU.UsageNanoCores / 1e6)
+		r.hasUsage = true
+	}
+	if stats.Memory != nil && stats.Memory.WorkingSetBytes != nil {
+		r.usageMemory += *stats.Memory.WorkingSetBytes
+		r.hasUsage = true
+	}
+}
+
+func (r *resourceSplit) report() map[string]interface{} {
+	out := map[string]interface{}{
+		"requests_cpu_millicores": r.requestsCPU,
+		"requests_memory_bytes":   r.requestsMemory,
+	}
+	if r.hasUsage {
+		out["usage_cpu_millicores"] = r.usageCPU
+		out["usage_memory_working_set_bytes"] = r.usageMemory
+	}
+	return out
+}
+
+// podResourceSplit separates the app containers' requests and usage from
+// the injected sidecars'. It returns nil for pods without injected sidecars.
+// Native sidecars (restartable init containers) run alongside the app and
+// are counted; regular init containers have finished and are not.
+func podResourceSplit(pod corev1.Pod, stats *PodStats) map[string]interface{} {
+	statsByName := map[string]*ContainerStats{}
+	if stats != nil {
+		for i := range stats.Containers {
+			statsByName[stats.Containers[i].Name] = &stats.Containers[i]
+		}
+	}
+
+	running := append([]corev1.Container{}, pod.Spec.Containers...)
+	for _, c := range pod.Spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			running = append(running, c)
+		}
+	}
+
+	var app, sidecars resourceSplit
+	byInjector := map[string]bool{}
+	for _, c := range running {
+		if injector := sidecarInjector(c.Name); injector != "" {
+			sidecars.add(c, statsByName[c.Name])
+			byInjector[injector] = true
+		} else {
+			app.add(c, statsByName[c.Name])
+		}
+	}
+	if len(byInjector) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"app":       app.report(),
+		"sidecars":  sidecars.report(),
+		"injectors": sortedKeys(byInjector),
+	}
+}
@@ -0,0 +1,762 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// IMAGE SIGNATURES AND PROVENANCE
+// Running image digests are checked against cosign signatures (the
+// "sha256-<hex>.sig" tag) and attestations (".att") with the configured
+// public keys (IMAGE_VERIFY_KEYS) or keyless identities (IMAGE_VERIFY_IDENTITIES,
+// certificates chained to IMAGE_VERIFY_ROOTS). A keyless signature only counts
+// with the Rekor bundle cosign attaches: its signed entry timestamp, checked
+// against IMAGE_VERIFY_REKOR_KEYS, proves the signature was logged while the
+// short-lived certificate was valid, as cosign's offline verification does.
+// ---------------------------------------------
+
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+
+	// verifyImagesPerRound bounds the registry traffic of one round
+	verifyImagesPerRound = 50
+	// verifyRecheckAfter re-checks images that were not verified (a signature
+	// may have been pushed since)
+	verifyRecheckAfter = 6 * time.Hour
+	// maxSignatureBlobBytes bounds signature payloads and attestations
+	maxSignatureBlobBytes = 4 * 1024 * 1024
+)
+
+// Fulcio certificate extensions naming the OIDC issuer (legacy raw string, and DER)
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// keylessIdentity accepts certificates from Issuer whose subject matches Subject
+type keylessIdentity struct {
+	Issuer  string
+	Subject *regexp.Regexp
+}
+
+// imageVerifier holds the trust configuration loaded at startup
+type imageVerifier struct {
+	keys       []crypto.PublicKey
+	identities []keylessIdentity
+	roots      *x509.CertPool
+	// rekorKeys are the transparency log keys, by log ID (SHA-256 of the key)
+	rekorKeys map[string]crypto.PublicKey
+}
+
+// signatureCheck is the verification outcome of one image digest
+type signatureCheck struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+	// Status is "verified", "unsigned", "unverified" (signed, but not by a
+	// trusted key/identity) or "error" (registry unreachable)
+	Status       string              `json:"status"`
+	Method       string              `json:"method,omitempty"`
+	Signer       string              `json:"signer,omitempty"`
+	Signatures   int                 `json:"signatures"`
+	Attestations []attestationCheck  `json:"attestations,omitempty"`
+	Reason       string              `json:"reason,omitempty"`
+	CheckedAt    time.Time           `json:"checked_at"`
+	Workloads    []map[string]string `json:"workloads,omitempty"`
+}
+
+// attestationCheck is one in-toto attestation attached to the image
+type attestationCheck struct {
+	PredicateType string `json:"predicate_type"`
+	Verified      bool   `json:"verified"`
+	Signer        string `json:"signer,omitempty"`
+}
+
+var signatureState = struct {
+	mu sync.Mutex
+	// verifier is set once the trust configuration loaded; nil means the
+	// image_signatures metric reports the load error instead
+	verifier  *imageVerifier
+	loadErr   error
+	checks    map[string]*signatureCheck
+	lastRound time.Time
+	pending   int
+}{checks: map[string]*signatureCheck{}}
+
+// loadImageVerifier reads keys, identities and roots; nil when nothing is configured
+func loadImageVerifier(config AgentConfig) (*imageVerifier, error) {
+	if config.ImageVerifyKeys == "" && config.ImageVerifyIdentities == "" {
+		return nil, nil
+	}
+	v := &imageVerifier{}
+	if config.ImageVerifyKeys != "" {
+		blocks, err := readPEMBlocks(config.ImageVerifyKeys)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range blocks {
+			key, err := x509.ParsePKIXPublicKey(b.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("IMAGE_VERIFY_KEYS: %v", err)
+			}
+			v.keys = append(v.keys, key)
+		}
+	}
+	for _, entry := range strings.Split(config.ImageVerifyIdentities, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		issuer, subject, ok := strings.Cut(entry, "=")
+		re, err := regexp.Compile(subject)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("IMAGE_VERIFY_IDENTITIES entry %q: expected issuer=subject-regexp", entry)
+		}
+		v.identities = append(v.identities, keylessIdentity{Issuer: issuer, Subject: re})
+	}
+	if len(v.identities) > 0 {
+		if config.ImageVerifyRoots == "" {
+			return nil, fmt.Errorf("IMAGE_VERIFY_IDENTITIES needs IMAGE_VERIFY_ROOTS (Fulcio root and intermediate certificates)")
+		}
+		blocks, err := readPEMBlocks(config.ImageVerifyRoots)
+		if err != nil {
+			return nil, err
+		}
+		v.roots = x509.NewCertPool()
+		for _, b := range blocks {
+			cert, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("IMAGE_VERIFY_ROOTS: %v", err)
+			}
+			v.roots.AddCert(cert)
+		}
+
+		if config.ImageVerifyRekorKeys == "" {
+			return nil, fmt.Errorf("IMAGE_VERIFY_IDENTITIES needs IMAGE_VERIFY_REKOR_KEYS (Rekor public key)")
+		}
+		blocks, err = readPEMBlocks(config.ImageVerifyRekorKeys)
+		if err != nil {
+			return nil, err
+		}
+		v.rekorKeys = map[string]crypto.PublicKey{}
+		for _, b := range blocks {
+			key, err := x509.ParsePKIXPublicKey(b.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("IMAGE_VERIFY_REKOR_KEYS: %v", err)
+			}
+			v.rekorKeys[sha256Hex(b.Bytes)] = key
+		}
+	}
+	return v, nil
+}
+
+// readPEMBlocks reads every PEM block of a file, or of all files in a directory
+func readPEMBlocks(path string) ([]*pem.Block, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		entries, _ := os.ReadDir(path)
+		files = nil
+		for _, e := range entries {
+			if !e.IsDir() && !strings.HasPrefix(e.Name(), "..") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	var blocks []*pem.Block
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var b *pem.Block
+			b, data = pem.Decode(data)
+			if b == nil {
+				break
+			}
+			blocks = append(blocks, b)
+		}
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no PEM blocks in %s", path)
+	}
+	return blocks, nil
+}
+
+// verifyWithKey checks sig over payload with one public key
+func verifyWithKey(key crypto.PublicKey, payload, sig []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, sig)
+	}
+	return false
+}
+
+// keyFingerprint names a key in the payload without exporting it
+func keyFingerprint(key crypto.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	sum := sha256.Sum256(der)
+	return fmt.Sprintf("key:sha256:%x", sum[:8])
+}
+
+// certificateIdentity returns the subject (email or URI SAN) and OIDC issuer
+func certificateIdentity(cert *x509.Certificate) (subject, issuer string) {
+	if len(cert.EmailAddresses) > 0 {
+		subject = cert.EmailAddresses[0]
+	} else if len(cert.URIs) > 0 {
+		subject = cert.URIs[0].String()
+	}
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var s string
+			if _, err := asn1.Unmarshal(ext.Value, &s); err == nil {
+				issuer = s
+			}
+		case ext.Id.Equal(oidFulcioIssuer) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	return subject, issuer
+}
+
+// verifySigner checks sig over signed with the trusted keys, then with the
+// layer's certificate (keyless); logged is the payload the Rekor entry must
+// cover. It returns the method and signer on success.
+func (v *imageVerifier) verifySigner(signed, logged, sig []byte, annotations map[string]string) (method, signer string, err error) {
+	for _, key := range v.keys {
+		if verifyWithKey(key, signed, sig) {
+			return "key", keyFingerprint(key), nil
+		}
+	}
+	certPEM := annotations[cosignCertificateAnnotation]
+	if certPEM == "" {
+		return "", "", errors.New("signature does not match any trusted key")
+	}
+	if len(v.identities) == 0 {
+		return "", "", errors.New("keyless signature, but no identities are trusted")
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return "", "", errors.New("invalid signing certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", "", err
+	}
+	if !verifyWithKey(cert.PublicKey, signed, sig) {
+		return "", "", errors.New("signature does not match its certificate")
+	}
+
+	// Fulcio certificates live minutes; only the transparency log proves the
+	// signature was made while it was valid and not with a leaked key later
+	bundle := annotations[cosignBundleAnnotation]
+	if bundle == "" {
+		return "", "", errors.New("keyless signature has no Rekor bundle")
+	}
+	signedAt, err := v.verifyRekorBundle(bundle, logged, sig, cert)
+	if err != nil {
+		return "", "", fmt.Errorf("rekor bundle: %v", err)
+	}
+	intermediates := x509.NewCertPool()
+	for rest := []byte(annotations[cosignChainAnnotation]); ; {
+		var b *pem.Block
+		if b, rest = pem.Decode(rest); b == nil {
+			break
+		}
+		if c, err := x509.ParseCertificate(b.Bytes); err == nil {
+			intermediates.AddCert(c)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return "", "", fmt.Errorf("signing certificate not trusted when logged at %s: %v", signedAt.UTC().Format(time.RFC3339), err)
+	}
+
+	subject, issuer := certificateIdentity(cert)
+	for _, id := range v.identities {
+		if id.Issuer == issuer && id.Subject.MatchString(subject) {
+			return "keyless", subject + " (" + issuer + ")", nil
+		}
+	}
+	return "", "", fmt.Errorf("signer %s (%s) is not a trusted identity", subject, issuer)
+}
+
+// rekorBundle is cosign's "dev.sigstore.cosign/bundle" annotation
+type rekorBundle struct {
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// verifyRekorBundle checks the signed entry timestamp with the trusted Rekor
+// key and that the logged entry is this signature, its payload and its
+// certificate. It returns when the entry was integrated into the log.
+func (v *imageVerifier) verifyRekorBundle(bundleJSON string, logged, sig []byte, cert *x509.Certificate) (time.Time, error) {
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(bundleJSON), &bundle); err != nil {
+		return time.Time{}, fmt.Errorf("invalid bundle: %v", err)
+	}
+	key, ok := v.rekorKeys[bundle.Payload.LogID]
+	if !ok {
+		return time.Time{}, fmt.Errorf("log %s is not a trusted Rekor instance", bundle.Payload.LogID)
+	}
+
+	// The SET signs the canonical JSON of the payload: sorted keys, no spaces
+	var canonical bytes.Buffer
+	encoder := json.NewEncoder(&canonical)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(map[string]interface{}{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logIndex":       bundle.Payload.LogIndex,
+		"logID":          bundle.Payload.LogID,
+	}); err != nil {
+		return time.Time{}, err
+	}
+	if !verifyWithKey(key, bytes.TrimSuffix(canonical.Bytes(), []byte("\n")), bundle.SignedEntryTimestamp) {
+		return time.Time{}, errors.New("signed entry timestamp does not verify")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid entry body: %v", err)
+	}
+	if err := rekorEntryCovers(body, logged, sig, cert); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// rekorEntryCovers checks that a hashedrekord (signatures) or intoto
+// (attestations) entry records this payload and certificate
+func rekorEntryCovers(body, logged, sig []byte, cert *x509.Certificate) error {
+	var entry struct {
+		Kind string          `json:"kind"`
+		Spec json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return fmt.Errorf("invalid entry: %v", err)
+	}
+	type hash struct {
+		Algorithm string `json:"algorithm"`
+		Value     string `json:"value"`
+	}
+	payloadHash := sha256Hex(logged)
+	sameCert := func(encoded string) bool {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return false
+		}
+		block, _ := pem.Decode(data)
+		return block != nil && bytes.Equal(block.Bytes, cert.Raw)
+	}
+
+	switch entry.Kind {
+	case "hashedrekord":
+		var spec struct {
+			Data struct {
+				Hash hash `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content   string `json:"content"`
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+		}
+		if err := json.Unmarshal(entry.Spec, &spec); err != nil {
+			return fmt.Errorf("invalid hashedrekord entry: %v", err)
+		}
+		if spec.Data.Hash.Algorithm != "sha256" || spec.Data.Hash.Value != payloadHash {
+			return errors.New("logged entry is for another payload")
+		}
+		if spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+			return errors.New("logged entry is for another signature")
+		}
+		if !sameCert(spec.Signature.PublicKey.Content) {
+			return errors.New("logged entry is for another certificate")
+		}
+		return nil
+	case "intoto":
+		var spec struct {
+			Content struct {
+				PayloadHash hash            `json:"payloadHash"`
+				Envelope    json.RawMessage `json:"envelope"`
+			} `json:"content"`
+			// v0.0.1 keeps the certificate here, v0.0.2 in the envelope signatures
+			PublicKey string `json:"publicKey"`
+		}
+		if err := json.Unmarshal(entry.Spec, &spec); err != nil {
+			return fmt.Errorf("invalid intoto entry: %v", err)
+		}
+		if spec.Content.PayloadHash.Algorithm != "sha256" || spec.Content.PayloadHash.Value != payloadHash {
+			return errors.New("logged entry is for another attestation")
+		}
+		var envelope struct {
+			Signatures []struct {
+				PublicKey string `json:"publicKey"`
+			} `json:"signatures"`
+		}
+		_ = json.Unmarshal(spec.Content.Envelope, &envelope)
+		certs := []string{spec.PublicKey}
+		for _, s := range envelope.Signatures {
+			certs = append(certs, s.PublicKey)
+		}
+		for _, c := range certs {
+			if sameCert(c) {
+				return nil
+			}
+		}
+		return errors.New("logged entry is for another certificate")
+	}
+	return fmt.Errorf("unsupported entry kind %q", entry.Kind)
+}
+
+// sidecarManifest fetches the cosign ".sig" or ".att" manifest of a digest;
+// ok is false when there is none
+func sidecarManifest(client *registryClient, digest, suffix string) (ociManifest, bool, error) {
+	tag := strings.Replace(digest, ":", "-", 1) + "." + suffix
+	m, _, err := client.manifest(tag, "")
+	if errors.Is(err, errRegistryNotFound) {
+		return ociManifest{}, false, nil
+	}
+	return m, err == nil, err
+}
+
+// readSmallBlob fetches a blob and checks it against its digest
+func readSmallBlob(client *registryClient, d ociDescriptor) ([]byte, error) {
+	rc, err := client.blob(d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxSignatureBlobBytes))
+	if err != nil {
+		return nil, err
+	}
+	if "sha256:"+sha256Hex(data) != d.Digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", d.Digest)
+	}
+	return data, nil
+}
+
+// checkImageSignature verifies the signatures and attestations of one digest
+func (v *imageVerifier) checkImageSignature(client *registryClient, ref imageRef) *signatureCheck {
+	check := &signatureCheck{Image: ref.String(), Digest: ref.Digest, CheckedAt: time.Now().UTC()}
+
+	sigs, found, err := sidecarManifest(client, ref.Digest, "sig")
+	if err != nil {
+		check.Status, check.Reason = "error", err.Error()
+		return check
+	}
+	check.Status = "unsigned"
+	if found {
+		check.Status = "unverified"
+		for _, layer := range sigs.Layers {
+			sig, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+			if err != nil || len(sig) == 0 {
+				continue
+			}
+			check.Signatures++
+			payload, err := readSmallBlob(client, layer)
+			if err != nil {
+				check.Reason = err.Error()
+				continue
+			}
+			var simple struct {
+				Critical struct {
+					Image struct {
+						Digest string `json:"docker-manifest-digest"`
+					} `json:"image"`
+				} `json:"critical"`
+			}
+			if json.Unmarshal(payload, &simple) != nil || simple.Critical.Image.Digest != ref.Digest {
+				check.Reason = "signature payload is for another digest"
+				continue
+			}
+			method, signer, err := v.verifySigner(payload, payload, sig, layer.Annotations)
+			if err != nil {
+				check.Reason = err.Error()
+				continue
+			}
+			check.Status, check.Method, check.Signer, check.Reason = "verified", method, signer, ""
+			break
+		}
+	}
+
+	check.Attestations = v.checkAttestations(client, ref.Digest)
+	return check
+}
+
+// checkAttestations lists the DSSE attestations of a digest and verifies them
+func (v *imageVerifier) checkAttestations(client *registryClient, digest string) []attestationCheck {
+	atts, found, err := sidecarManifest(client, digest, "att")
+	if err != nil || !found {
+		return nil
+	}
+	var checks []attestationCheck
+	for _, layer := range atts.Layers {
+		data, err := readSmallBlob(client, layer)
+		if err != nil {
+			continue
+		}
+		var envelope struct {
+			PayloadType string `json:"payloadType"`
+			Payload     string `json:"payload"`
+			Signatures  []struct {
+				Sig string `json:"sig"`
+			} `json:"signatures"`
+		}
+		if json.Unmarshal(data, &envelope) != nil {
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			continue
+		}
+		var statement struct {
+			PredicateType string `json:"predicateType"`
+			Subject       []struct {
+				Digest map[string]string `json:"digest"`
+			} `json:"subject"`
+		}
+		if json.Unmarshal(payload, &statement) != nil {
+			continue
+		}
+		covers := false
+		for _, s := range statement.Subject {
+			if "sha256:"+s.Digest["sha256"] == digest {
+				covers = true
+			}
+		}
+		att := attestationCheck{PredicateType: statement.PredicateType}
+		// DSSE signs the pre-authentication encoding, not the payload itself
+		pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d ", len(envelope.PayloadType), envelope.PayloadType, len(payload)))
+		pae = append(pae, payload...)
+		for _, s := range envelope.Signatures {
+			sig, err := base64.StdEncoding.DecodeString(s.Sig)
+			if err != nil || !covers {
+				continue
+			}
+			if _, signer, err := v.verifySigner(pae, payload, sig, layer.Annotations); err == nil {
+				att.Verified, att.Signer = true, signer
+				break
+			}
+		}
+		checks = append(checks, att)
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].PredicateType < checks[j].PredicateType })
+	return checks
+}
+
+// imageVerification reports whether any trusted key or identity is configured
+func (c AgentConfig) imageVerification() bool {
+	return c.ImageVerifyKeys != "" || c.ImageVerifyIdentities != ""
+}
+
+// startImageVerifier loads the trust configuration and starts the
+// verification loop; a bad configuration is logged and surfaced by the metric
+func startImageVerifier(clientset kubernetes.Interface, config AgentConfig) {
+	v, err := loadImageVerifier(config)
+	if err != nil {
+		log.Printf("⚠️  Image verification disabled: %v", err)
+		signatureState.mu.Lock()
+		signatureState.loadErr = err
+		signatureState.mu.Unlock()
+		return
+	}
+	if v != nil {
+		go runImageVerifier(clientset, config, v)
+	}
+}
+
+// runImageVerifier verifies new running digests every interval; it never returns
+func runImageVerifier(clientset kubernetes.Interface, config AgentConfig, v *imageVerifier) {
+	signatureState.mu.Lock()
+	signatureState.verifier = v
+	signatureState.mu.Unlock()
+
+	interval := time.Duration(config.ImageVerifyIntervalMinutes) * time.Minute
+	log.Printf("🔏 Image verification: %d keys, %d keyless identities, every %v", len(v.keys), len(v.identities), interval)
+	time.Sleep(splayDelay(5 * time.Minute))
+	for {
+		verifyImagesRound(clientset, v)
+		time.Sleep(interval)
+	}
+}
+
+// verifyImagesRound checks up to verifyImagesPerRound digests that are new or due
+func verifyImagesRound(clientset kubernetes.Interface, v *imageVerifier) {
+	now := time.Now()
+	running, err := runningImages(clientset)
+	if err != nil {
+		log.Printf("⚠️  Image verification: listing running images: %v", err)
+		return
+	}
+	var due []runningImage
+	signatureState.mu.Lock()
+	for _, img := range running {
+		if c, ok := signatureState.checks[img.ref.Digest]; ok && (c.Status == "verified" || now.Sub(c.CheckedAt) < verifyRecheckAfter) {
+			continue
+		}
+		due = append(due, img)
+	}
+	signatureState.mu.Unlock()
+	pending := len(due)
+	if len(due) > verifyImagesPerRound {
+		due = due[:verifyImagesPerRound]
+	}
+
+	secrets := newPullSecretCache(clientset)
+	counts := map[string]int{}
+	for _, img := range due {
+		var auth *registryAuth
+		if a, ok := secrets.credentials(img.pod, img.ref.Registry); ok {
+			auth = &a
+		}
+		check := v.checkImageSignature(newRegistryClient(img.ref, auth), img.ref)
+		counts[check.Status]++
+		signatureState.mu.Lock()
+		signatureState.checks[check.Digest] = check
+		signatureState.mu.Unlock()
+	}
+
+	signatureState.mu.Lock()
+	signatureState.lastRound = now
+	signatureState.pending = pending - len(due)
+	signatureState.mu.Unlock()
+	log.Printf("🔏 Image verification round: %d checked %v, %d left for later rounds", len(due), counts, pending-len(due))
+}
+
+// collectImageSignatures builds the "image_signatures" metric, attributing
+// each checked digest to the workloads running it
+func collectImageSignatures(snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("image_signatures", time.Now())
+
+	signatureState.mu.Lock()
+	v, loadErr := signatureState.verifier, signatureState.loadErr
+	signatureState.mu.Unlock()
+	if loadErr != nil {
+		status.Fail("trust_config", loadErr)
+		return map[string]interface{}{}
+	}
+	if v == nil {
+		// Not started yet (or dry run): nothing checked so far
+		v = &imageVerifier{}
+	}
+
+	type workloadKey struct{ namespace, kind, name string }
+	workloadsByDigest := map[string]map[workloadKey]bool{}
+	for _, pod := range snap.Pods {
+		kind, name := podWorkload(pod)
+		for _, cs := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+			digest := imageIDDigest(cs.ImageID)
+			if digest == "" {
+				continue
+			}
+			if workloadsByDigest[digest] == nil {
+				workloadsByDigest[digest] = map[workloadKey]bool{}
+			}
+			workloadsByDigest[digest][workloadKey{pod.Namespace, kind, name}] = true
+		}
+	}
+
+	signatureState.mu.Lock()
+	defer signatureState.mu.Unlock()
+
+	counts := map[string]int{"verified": 0, "unsigned": 0, "unverified": 0, "error": 0}
+	var images []signatureCheck
+	flagged := map[workloadKey][]string{}
+	for digest, workloads := range workloadsByDigest {
+		c, ok := signatureState.checks[digest]
+		if !ok {
+			continue
+		}
+		check := *c
+		check.Workloads = nil
+		for w := range workloads {
+			check.Workloads = append(check.Workloads, map[string]string{"namespace": w.namespace, "kind": w.kind, "name": w.name})
+			if check.Status == "unsigned" || check.Status == "unverified" {
+				flagged[w] = append(flagged[w], check.Image)
+			}
+		}
+		sort.Slice(check.Workloads, func(i, j int) bool {
+			a, b := check.Workloads[i], check.Workloads[j]
+			return a["namespace"]+"/"+a["name"] < b["namespace"]+"/"+b["name"]
+		})
+		counts[check.Status]++
+		images = append(images, check)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Image < images[j].Image })
+
+	workloads := []map[string]interface{}{}
+	for w, imgs := range flagged {
+		sort.Strings(imgs)
+		workloads = append(workloads, map[string]interface{}{
+			"namespace": w.namespace, "kind": w.kind, "name": w.name, "images": imgs,
+		})
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		return a["namespace"].(string)+"/"+a["name"].(string) < b["namespace"].(string)+"/"+b["name"].(string)
+	})
+
+	// Keyless signatures are only accepted with a verified Rekor bundle (the
+	// signed entry timestamp, checked offline); key signatures skip the log
+	transparencyLog := "none"
+	if len(v.identities) > 0 {
+		transparencyLog = "rekor_bundle"
+	}
+	result := map[string]interface{}{
+		"images":               images,
+		"by_status":            counts,
+		"unverified_workloads": workloads,
+		"pending":              signatureState.pending,
+		"trusted_keys":         len(v.keys),
+		"trusted_identities":   len(v.identities),
+		"transparency_log":     transparencyLog,
+	}
+	if !signatureState.lastRound.IsZero() {
+		result["last_round_at"] = signatureState.lastRound.UTC()
+	}
+	return result
+}
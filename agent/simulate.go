@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	fakemetrics "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+	metricsscheme "k8s.io/metrics/pkg/client/clientset/versioned/scheme"
+)
+
+// ---------------------------------------------
+// SIMULATION (--simulate)
+// Runs the normal metrics loop against fake clientsets seeded from fixture
+// YAML, so backend developers get realistic payload streams without a live
+// cluster. Fixtures are plain manifests or `kubectl get -o yaml` output
+// (kind: List); metrics.k8s.io NodeMetrics/PodMetrics seed the Metrics API.
+// Kubelet proxy data, CRDs (dynamic client), the KuberPulseConfig watch and
+// commands are not simulated.
+// ---------------------------------------------
+
+//go:embed fixtures/cluster.yaml
+var defaultFixtures []byte
+
+// fixtureDecoder understands core Kubernetes types and metrics.k8s.io
+var fixtureDecoder = func() runtime.Decoder {
+	scheme := runtime.NewScheme()
+	if err := kubescheme.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	if err := metricsscheme.AddToScheme(scheme); err != nil {
+		panic(err)
+	}
+	return serializer.NewCodecFactory(scheme).UniversalDeserializer()
+}()
+
+// runSimulation collects and sends metrics forever from a fake cluster
+func runSimulation(config AgentConfig, fixturesPath string) {
+	clientset, metricsClient, err := simulatedClients(fixturesPath)
+	if err != nil {
+		log.Fatalf("❌ Could not load simulation fixtures: %v", err)
+	}
+	if config.ClusterID == "" {
+		config.ClusterID = "simulated"
+	}
+
+	target := config.APIEndpoint
+	if config.dryRun() {
+		target = dryRunTarget(config)
+	}
+	log.Printf("🧪 Simulation mode: fake cluster, payloads go to %s", target)
+
+	go runIngressDetection(clientset, newInformerFactory(clientset), config)
+	runJittered("metrics", func() time.Duration { return getSettings().Interval }, 0, config.JitterPercent, func() {
+		sendMetrics(clientset, metricsClient, nil, config)
+	})
+}
+
+// simulatedClients builds fake clientsets seeded from fixturesPath (a file
+// or a directory of .yaml/.yml/.json files); empty uses the built-in fixture
+func simulatedClients(fixturesPath string) (kubernetes.Interface, metricsv.Interface, error) {
+	var objects []runtime.Object
+	if fixturesPath == "" {
+		decoded, err := decodeFixtures(bytes.NewReader(defaultFixtures))
+		if err != nil {
+			return nil, nil, fmt.Errorf("built-in fixtures: %v", err)
+		}
+		objects = decoded
+	} else {
+		files, err := fixtureFiles(fixturesPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			decoded, err := decodeFixtures(f)
+			f.Close()
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
+			}
+			objects = append(objects, decoded...)
+		}
+	}
+
+	var kubeObjects, metricsObjects []runtime.Object
+	for _, obj := range objects {
+		if metricsscheme.Scheme.Recognizes(obj.GetObjectKind().GroupVersionKind()) {
+			metricsObjects = append(metricsObjects, obj)
+		} else {
+			kubeObjects = append(kubeObjects, obj)
+		}
+	}
+	log.Printf("🧪 Loaded %d Kubernetes objects and %d metrics objects", len(kubeObjects), len(metricsObjects))
+
+	var metricsClient metricsv.Interface
+	if len(metricsObjects) > 0 {
+		client, err := fakeMetricsClient(metricsObjects)
+		if err != nil {
+			return nil, nil, err
+		}
+		metricsClient = client
+	}
+	return fakekube.NewSimpleClientset(kubeObjects...), metricsClient, nil
+}
+
+// fakeMetricsClient seeds a fake Metrics API. The metrics.k8s.io resources
+// are "nodes" and "pods", which the tracker cannot guess from the kinds, so
+// objects are added under their explicit resource.
+func fakeMetricsClient(objects []runtime.Object) (metricsv.Interface, error) {
+	client := fakemetrics.NewSimpleClientset()
+	for _, obj := range objects {
+		var err error
+		switch m := obj.(type) {
+		case *metricsv1beta1.NodeMetrics:
+			err = client.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("nodes"), m, "")
+		case *metricsv1beta1.PodMetrics:
+			err = client.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("pods"), m, m.Namespace)
+		default:
+			err = fmt.Errorf("unsupported metrics fixture %s", obj.GetObjectKind().GroupVersionKind().Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// fixtureFiles lists the fixture files under path, sorted by name
+func fixtureFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixture files in %s", path)
+	}
+	return sortedKeys(stringSet(files)), nil
+}
+
+// decodeFixtures decodes every document of a multi-document YAML (or JSON)
+// stream, expanding List objects into their items
+func decodeFixtures(r io.Reader) ([]runtime.Object, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	var objects []runtime.Object
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(strings.TrimSpace(string(doc))) == 0 {
+			continue
+		}
+		decoded, err := decodeFixture(doc)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+func decodeFixture(doc []byte) ([]runtime.Object, error) {
+	obj, _, err := fixtureDecoder.Decode(doc, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var items []runtime.RawExtension
+	switch list := obj.(type) {
+	case *corev1.List:
+		items = list.Items
+	case *metav1.List:
+		items = list.Items
+	default:
+		return []runtime.Object{obj}, nil
+	}
+	var objects []runtime.Object
+	for _, item := range items {
+		decoded, err := decodeFixture(item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// CLUSTER SNAPSHOT (shared by all collectors in a cycle)
+// ---------------------------------------------
+
+// ClusterSnapshot holds the cluster-wide lists fetched once per collection
+// cycle. Collectors read from it instead of listing the same resources again.
+type ClusterSnapshot struct {
+	Nodes      []corev1.Node
+	Pods       []corev1.Pod
+	Namespaces []corev1.Namespace
+	Events     []corev1.Event
+	PVCs       []corev1.PersistentVolumeClaim
+	PVs        []corev1.PersistentVolume
+	Services   []corev1.Service
+	// NodeStats holds the parsed Kubelet stats/summary per node name
+	NodeStats map[string]*StatsSummary
+	// NodeConfigs holds the Kubelet /configz per node name (cached between cycles)
+	NodeConfigs map[string]*KubeletConfig
+	// Errors holds the list failure per resource ("nodes", "pods", ...)
+	Errors  map[string]error
+	TakenAt time.Time
+}
+
+// buildClusterSnapshot lists the shared resources once. A failed list is
+// logged, recorded in Errors and leaves the corresponding slice empty so the
+// cycle can continue. Namespaced resources outside the configured namespace
+// filters are dropped here, so no collector ever sees them.
+func buildClusterSnapshot(clientset kubernetes.Interface, config AgentConfig, settings *AgentSettings) *ClusterSnapshot {
+	defer diagnostics.recordDuration("snapshot", time.Now())
+	start := time.Now()
+	snap := &ClusterSnapshot{TakenAt: start, Errors: map[string]error{}}
+
+	ctx, cancel := apiContext()
+	if nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{}); err != nil {
+		log.Printf("⚠️  Snapshot: error listing nodes: %v", err)
+		snap.Errors["nodes"] = err
+	} else {
+		snap.Nodes = nodes.Items
+		kubeletConfigs.prune(snap.Nodes)
+	}
+	cancel()
+
+	var failedNodes, failedConfigz int
+	snap.NodeStats, snap.NodeConfigs, failedNodes, failedConfigz = fetchNodeStatsSummaries(clientset, snap.Nodes)
+	if failedNodes > 0 {
+		snap.Errors["kubelet_stats"] = fmt.Errorf("stats/summary unavailable on %d of %d nodes", failedNodes, len(snap.Nodes))
+	}
+	if failedConfigz > 0 {
+		snap.Errors["kubelet_configz"] = fmt.Errorf("configz unavailable on %d of %d nodes", failedConfigz, len(snap.Nodes))
+	}
+
+	ctx, cancel = apiContext()
+	if pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{}); err != nil {
+		log.Printf("⚠️  Snapshot: error listing pods: %v", err)
+		snap.Errors["pods"] = err
+	} else {
+		snap.Pods = pods.Items
+	}
+	cancel()
+
+	ctx, cancel = apiContext()
+	if namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err != nil {
+		log.Printf("⚠️  Snapshot: error listing namespaces: %v", err)
+		snap.Errors["namespaces"] = err
+	} else {
+		snap.Namespaces = namespaces.Items
+	}
+	cancel()
+
+	// Events come from the cache, which only fetches what changed; on errors
+	// the last known events are still used
+	events, err := eventStore.refresh(clientset, config, start)
+	if err != nil {
+		log.Printf("⚠️  Snapshot: error refreshing events: %v", err)
+		snap.Errors["events"] = err
+	}
+	snap.Events = events
+
+	ctx, cancel = apiContext()
+	if pvcs, err := clientset.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{}); err != nil {
+		log.Printf("⚠️  Snapshot: error listing PVCs: %v", err)
+		snap.Errors["pvcs"] = err
+	} else {
+		snap.PVCs = pvcs.Items
+	}
+	cancel()
+
+	ctx, cancel = apiContext()
+	if pvs, err := clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{}); err != nil {
+		log.Printf("⚠️  Snapshot: error listing PVs: %v", err)
+		snap.Errors["pvs"] = err
+	} else {
+		snap.PVs = pvs.Items
+	}
+	cancel()
+
+	ctx, cancel = apiContext()
+	if services, err := clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{}); err != nil {
+		log.Printf("⚠️  Snapshot: error listing services: %v", err)
+		snap.Errors["services"] = err
+	} else {
+		snap.Services = services.Items
+	}
+	cancel()
+
+	snap.Pods = filterByNamespace(snap.Pods, func(p corev1.Pod) string { return p.Namespace }, settings)
+	snap.Namespaces = filterByNamespace(snap.Namespaces, func(ns corev1.Namespace) string { return ns.Name }, settings)
+	snap.Events = filterByNamespace(snap.Events, func(e corev1.Event) string { return e.Namespace }, settings)
+	snap.PVCs = filterByNamespace(snap.PVCs, func(pvc corev1.PersistentVolumeClaim) string { return pvc.Namespace }, settings)
+	snap.Services = filterByNamespace(snap.Services, func(svc corev1.Service) string { return svc.Namespace }, settings)
+
+	log.Printf("📸 Snapshot built in %v: %d nodes (%d with kubelet stats), %d pods, %d namespaces, %d events, %d PVCs, %d PVs, %d services",
+		time.Since(start).Round(time.Millisecond),
+		len(snap.Nodes), len(snap.NodeStats), len(snap.Pods), len(snap.Namespaces), len(snap.Events),
+		len(snap.PVCs), len(snap.PVs), len(snap.Services))
+
+	return snap
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// ---------------------------------------------
+// STARTUP (retry with backoff, degraded mode)
+// ---------------------------------------------
+
+const (
+	startupInitialBackoff = 2 * time.Second
+	startupMaxBackoff     = 60 * time.Second
+	// After this many failed attempts the agent reports itself as degraded
+	startupQuickAttempts = 5
+)
+
+// connectKubernetes loads the in-cluster config and builds a clientset
+func connectKubernetes() (kubernetes.Interface, *rest.Config, error) {
+	kubeconfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load Kubernetes config: %v", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeconfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+
+	return clientset, kubeconfig, nil
+}
+
+// newAuxiliaryClients builds the Metrics API and dynamic clients. Either is
+// nil (a nil interface, so callers can compare with nil) when unavailable.
+func newAuxiliaryClients(kubeconfig *rest.Config) (metricsv.Interface, dynamic.Interface) {
+	// Metrics client with insecure TLS (common for local clusters)
+	metricsConfig := *kubeconfig
+	metricsConfig.TLSClientConfig.Insecure = true
+	metricsConfig.TLSClientConfig.CAData = nil
+	metricsConfig.TLSClientConfig.CAFile = ""
+
+	var metricsClient metricsv.Interface
+	if client, err := metricsv.NewForConfig(&metricsConfig); err != nil {
+		log.Printf("⚠️  Failed to create Metrics client: %v", err)
+		log.Println("⚠️  Metrics API not available - will use capacity values")
+	} else {
+		metricsClient = client
+		log.Println("✅ Metrics Server client created (TLS verification disabled for local clusters)")
+	}
+
+	// Dynamic client for CRDs the agent has no typed client for (ArgoCD, Flux...)
+	var dynamicClient dynamic.Interface
+	if client, err := dynamic.NewForConfig(kubeconfig); err != nil {
+		log.Printf("⚠️  Failed to create dynamic client: %v", err)
+	} else {
+		dynamicClient = client
+	}
+	return metricsClient, dynamicClient
+}
+
+// newInformerFactory builds the one informer factory every watch shares, so
+// a resource watched by several features is listed and cached once. Managed
+// fields are most of an object's size and nothing reads them.
+func newInformerFactory(clientset kubernetes.Interface) informers.SharedInformerFactory {
+	return informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithTransform(func(obj interface{}) (interface{}, error) {
+		if m, err := meta.Accessor(obj); err == nil {
+			m.SetManagedFields(nil)
+		}
+		return obj, nil
+	}))
+}
+
+// errNoRESTClient is returned for raw API calls (Kubelet proxy, port-forward)
+// against a simulated cluster, whose fake clientset has no REST client
+var errNoRESTClient = errors.New("raw API calls are not available against a simulated cluster")
+
+// coreRESTClient returns the core/v1 REST client used for raw API calls
+func coreRESTClient(clientset kubernetes.Interface) (*rest.RESTClient, error) {
+	restClient, ok := clientset.CoreV1().RESTClient().(*rest.RESTClient)
+	if !ok || restClient == nil {
+		return nil, errNoRESTClient
+	}
+	return restClient, nil
+}
+
+// connectKubernetesWithRetry builds the client, then keeps probing the API
+// server with exponential backoff instead of exiting, so a transient outage
+// does not crash-loop the agent. Once the quick attempts are exhausted the
+// agent runs degraded and reports the failure to the backend on every retry.
+// A missing or invalid in-cluster config cannot fix itself, so it fails fast.
+func connectKubernetesWithRetry(config AgentConfig) (kubernetes.Interface, *rest.Config) {
+	clientset, kubeconfig, err := connectKubernetes()
+	if err != nil {
+		reportDegraded(config, err)
+		log.Fatalf("❌ %v", err)
+	}
+
+	backoff := startupInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := probeAPIServer(clientset)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("✅ Kubernetes connection recovered after %d attempts", attempt)
+			}
+			return clientset, kubeconfig
+		}
+
+		log.Printf("⚠️  Kubernetes connection attempt %d failed: %v (retrying in %v)", attempt, err, backoff)
+		if attempt >= startupQuickAttempts {
+			if attempt == startupQuickAttempts {
+				log.Printf("⚠️  Entering degraded mode: metrics collection paused until the cluster is reachable")
+			}
+			reportDegraded(config, err)
+		}
+
+		time.Sleep(jitteredInterval(backoff, config.JitterPercent))
+		backoff *= 2
+		if backoff > startupMaxBackoff {
+			backoff = startupMaxBackoff
+		}
+	}
+}
+
+// probeAPIServer makes one real request (GET /version) to prove the API
+// server is reachable and accepts the agent's credentials
+func probeAPIServer(clientset kubernetes.Interface) error {
+	ctx, cancel := apiContext()
+	defer cancel()
+	if err := clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("API server unreachable: %v", err)
+	}
+	return nil
+}
+
+// reportDegraded tells the backend the agent is running but cannot reach the cluster
+func reportDegraded(config AgentConfig, cause error) {
+	status := &CollectorStatus{}
+	status.Fail("kubernetes", cause)
+
+	metrics := []map[string]interface{}{
+		buildMetric("agent_status", map[string]interface{}{
+			"state":   "degraded",
+			"version": AgentVersion,
+		}, status),
+	}
+
+	if err := postMetrics(config, metrics); err != nil {
+		log.Printf("⚠️  Could not report degraded status: %v", err)
+	}
+}
+
+// maskAPIKey returns a loggable form of the API key
+func maskAPIKey(key string) string {
+	if len(key) < 12 {
+		return "***"
+	}
+	return key[:8] + "..." + key[len(key)-4:]
+}
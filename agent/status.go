@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// API TIMEOUTS AND COLLECTOR STATUS
+// ---------------------------------------------
+
+// apiCallTimeout bounds every individual Kubernetes API call
+const apiCallTimeout = 20 * time.Second
+
+// apiContext returns a context for a single Kubernetes API call
+func apiContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), apiCallTimeout)
+}
+
+// apiContextWithin is apiContext for a call made on behalf of a run that can
+// be cancelled as a whole
+func apiContextWithin(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, apiCallTimeout)
+}
+
+// Collector status values reported next to every payload section
+const (
+	StatusOK      = "ok"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+	// StatusSkipped is only reported in the payload's "collection" summary:
+	// the collector did not run (disabled, opt-in, or not accepted by the backend)
+	StatusSkipped = "skipped"
+)
+
+// collectorErrorBudget is the share of failed or partial sections above
+// which a payload is flagged as degraded
+const collectorErrorBudget = 0.2
+
+// CollectorStatus records API failures seen while building one payload
+// section, so the backend can tell "zero pods" from "collection failed".
+type CollectorStatus struct {
+	failed bool
+	errors []string
+}
+
+// Fail marks the section as failed: its primary data source was unavailable
+func (s *CollectorStatus) Fail(source string, err error) {
+	s.failed = true
+	s.errors = append(s.errors, fmt.Sprintf("%s: %v", source, err))
+}
+
+// Partial records a non-fatal failure: the section is usable but incomplete
+func (s *CollectorStatus) Partial(source string, err error) {
+	s.errors = append(s.errors, fmt.Sprintf("%s: %v", source, err))
+}
+
+// Requires fails the section if any of the given snapshot resources could not be listed
+func (s *CollectorStatus) Requires(snap *ClusterSnapshot, resources ...string) {
+	for _, r := range resources {
+		if err, ok := snap.Errors[r]; ok {
+			s.Fail(r, err)
+		}
+	}
+}
+
+// Uses marks the section partial if any of the given snapshot resources could not be listed
+func (s *CollectorStatus) Uses(snap *ClusterSnapshot, resources ...string) {
+	for _, r := range resources {
+		if err, ok := snap.Errors[r]; ok {
+			s.Partial(r, err)
+		}
+	}
+}
+
+// State returns one of StatusOK, StatusPartial or StatusFailed
+func (s *CollectorStatus) State() string {
+	if s.failed {
+		return StatusFailed
+	}
+	if len(s.errors) > 0 {
+		return StatusPartial
+	}
+	return StatusOK
+}
+
+// Error returns the recorded failures joined into one message, or ""
+func (s *CollectorStatus) Error() string {
+	return strings.Join(s.errors, "; ")
+}
+
+// buildMetric wraps a collector's data in the envelope expected by
+// agent-receive-metrics, adding its status and error message.
+func buildMetric(metricType string, data map[string]interface{}, status *CollectorStatus) map[string]interface{} {
+	metric := map[string]interface{}{
+		"type":           metricType,
+		"schema_version": metricSchemaVersion(metricType),
+		"data":           data,
+		"status":         status.State(),
+		"collected_at":   time.Now().UTC().Format(time.RFC3339),
+	}
+	if msg := status.Error(); msg != "" {
+		metric["error"] = msg
+	}
+	return metric
+}
+
+// collectorRecord is the last known outcome of one metric type
+type collectorRecord struct {
+	state               string
+	err                 string
+	lastAttempt         time.Time
+	lastSuccess         time.Time
+	consecutiveFailures int
+}
+
+// collectorHealthTracker remembers every collector's outcome across cycles,
+// so a failed section can say since when its data is stale
+type collectorHealthTracker struct {
+	mu      sync.Mutex
+	records map[string]*collectorRecord
+}
+
+var collectorHealth = &collectorHealthTracker{records: map[string]*collectorRecord{}}
+
+func (t *collectorHealthTracker) record(metricType string) *collectorRecord {
+	r, ok := t.records[metricType]
+	if !ok {
+		r = &collectorRecord{}
+		t.records[metricType] = r
+	}
+	return r
+}
+
+// observe records a collector run and returns when its data was last good
+// (zero if never). Partial data still counts as a success.
+func (t *collectorHealthTracker) observe(metricType string, status *CollectorStatus, at time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.record(metricType)
+	r.state, r.err, r.lastAttempt = status.State(), status.Error(), at
+	if r.state == StatusFailed {
+		r.consecutiveFailures++
+	} else {
+		r.consecutiveFailures = 0
+		r.lastSuccess = at
+	}
+	return r.lastSuccess
+}
+
+// skip records that a collector did not run this cycle and why
+func (t *collectorHealthTracker) skip(metricType, reason string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.record(metricType)
+	r.state, r.err, r.lastAttempt = StatusSkipped, reason, at
+}
+
+// report summarizes every collector for the payload's "collection" field
+// (nil before the first cycle)
+func (t *collectorHealthTracker) report() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.records) == 0 {
+		return nil
+	}
+
+	collectors := make(map[string]interface{}, len(t.records))
+	counts := map[string]int{StatusOK: 0, StatusPartial: 0, StatusFailed: 0, StatusSkipped: 0}
+	for metricType, r := range t.records {
+		counts[r.state]++
+		entry := map[string]interface{}{"status": r.state}
+		switch {
+		case r.state == StatusSkipped:
+			entry["reason"] = r.err
+		case r.err != "":
+			entry["error"] = r.err
+		}
+		if !r.lastSuccess.IsZero() {
+			entry["last_success_at"] = r.lastSuccess.UTC().Format(time.RFC3339)
+		}
+		if r.state == StatusFailed {
+			entry["consecutive_failures"] = r.consecutiveFailures
+			entry["stale_since"] = nil
+			if !r.lastSuccess.IsZero() {
+				entry["stale_since"] = entry["last_success_at"]
+			}
+		}
+		collectors[metricType] = entry
+	}
+
+	ran := counts[StatusOK] + counts[StatusPartial] + counts[StatusFailed]
+	degraded := ran > 0 && float64(counts[StatusPartial]+counts[StatusFailed]) > collectorErrorBudget*float64(ran)
+	return map[string]interface{}{
+		"collectors":   collectors,
+		"counts":       counts,
+		"error_budget": collectorErrorBudget,
+		"degraded":     degraded,
+	}
+}
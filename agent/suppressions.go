@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------
+// FINDING SUPPRESSIONS
+// Accepted risks are suppressed per rule (threat category) by config entry
+// (THREAT_SUPPRESSIONS or spec.threats.suppressions) or by annotating the
+// object or its namespace with kuberpulse.io/suppress. Suppressed findings
+// keep their finding_id and move to "suppressed_findings", so the backend
+// can still show them without alerting on them.
+// ---------------------------------------------
+
+const (
+	// suppressAnnotation lists the suppressed rules ("host_network_pods,privileged_containers" or "*")
+	suppressAnnotation = "kuberpulse.io/suppress"
+	// suppressReasonAnnotation optionally explains why
+	suppressReasonAnnotation = "kuberpulse.io/suppress-reason"
+)
+
+// FindingSuppression silences one rule, optionally only in matching
+// namespaces/objects (path.Match globs, empty matches everything)
+type FindingSuppression struct {
+	Rule      string `json:"rule"`
+	Namespace string `json:"namespace,omitempty"`
+	Object    string `json:"object,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Validate rejects entries that would not match anything or everything by accident
+func (s FindingSuppression) Validate() error {
+	if s.Rule != "*" && findingIdentity[s.Rule] == nil {
+		return fmt.Errorf("suppression rule %q is not a threat category", s.Rule)
+	}
+	for _, pattern := range []string{s.Namespace, s.Object} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("suppression pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the entry silences a finding of rule on namespace/object
+func (s FindingSuppression) matches(rule, namespace, object string) bool {
+	if s.Rule != "*" && s.Rule != rule {
+		return false
+	}
+	if ok, _ := path.Match(s.Namespace, namespace); s.Namespace != "" && !ok {
+		return false
+	}
+	if ok, _ := path.Match(s.Object, object); s.Object != "" && !ok {
+		return false
+	}
+	return true
+}
+
+// parseSuppressions reads THREAT_SUPPRESSIONS: "rule[:namespace[/object]]" separated by ";"
+func parseSuppressions(value string) []FindingSuppression {
+	var suppressions []FindingSuppression
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		rule, scope, _ := strings.Cut(entry, ":")
+		namespace, object, _ := strings.Cut(scope, "/")
+		s := FindingSuppression{Rule: strings.TrimSpace(rule), Namespace: strings.TrimSpace(namespace), Object: strings.TrimSpace(object)}
+		if err := s.Validate(); err != nil {
+			log.Printf("⚠️  Ignoring invalid THREAT_SUPPRESSIONS entry %q: %v", entry, err)
+			continue
+		}
+		suppressions = append(suppressions, s)
+	}
+	return suppressions
+}
+
+// annotationSuppresses reports whether a kuberpulse.io/suppress value covers rule
+func annotationSuppresses(annotations map[string]string, rule string) bool {
+	for _, r := range strings.Split(annotations[suppressAnnotation], ",") {
+		if r = strings.TrimSpace(r); r == "*" || r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressionIndex holds the annotations of the objects findings are raised on
+type suppressionIndex struct {
+	// objects is keyed "Kind/namespace/name"
+	objects    map[string]map[string]string
+	namespaces map[string]map[string]string
+}
+
+// newSuppressionIndex indexes the snapshot's pods, services and namespaces
+func newSuppressionIndex(snap *ClusterSnapshot) *suppressionIndex {
+	idx := &suppressionIndex{objects: map[string]map[string]string{}, namespaces: map[string]map[string]string{}}
+	for _, pod := range snap.Pods {
+		idx.objects["Pod/"+pod.Namespace+"/"+pod.Name] = pod.Annotations
+	}
+	for _, svc := range snap.Services {
+		idx.objects["Service/"+svc.Namespace+"/"+svc.Name] = svc.Annotations
+	}
+	for _, ns := range snap.Namespaces {
+		idx.namespaces[ns.Name] = ns.Annotations
+	}
+	return idx
+}
+
+// findingKind returns the kind of object a finding of category is raised on
+func findingKind(category string, entry map[string]interface{}) string {
+	switch category {
+	case "network_anomalies":
+		return "Service"
+	case "privileged_daemonsets":
+		return "DaemonSet"
+	case "suspicious_events":
+		kind, _ := entry["kind"].(string)
+		return kind
+	}
+	return "Pod"
+}
+
+// suppression returns who suppressed a finding and why, or "" when it is active
+func (idx *suppressionIndex) suppression(config []FindingSuppression, category, namespace, object, kind string) (by, reason string) {
+	for _, s := range config {
+		if s.matches(category, namespace, object) {
+			return "config", s.Reason
+		}
+	}
+	if annotations := idx.objects[kind+"/"+namespace+"/"+object]; annotationSuppresses(annotations, category) {
+		return "annotation", annotations[suppressReasonAnnotation]
+	}
+	if annotations := idx.namespaces[namespace]; annotationSuppresses(annotations, category) {
+		return "namespace_annotation", annotations[suppressReasonAnnotation]
+	}
+	return "", ""
+}
+
+// applySuppressions moves suppressed entries out of their category into the
+// returned list, tagged with category, suppressed_by and suppression_reason
+func applySuppressions(data map[string]interface{}, idx *suppressionIndex, config []FindingSuppression) []map[string]interface{} {
+	categories := make([]string, 0, len(findingIdentity))
+	for category := range findingIdentity {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	suppressed := []map[string]interface{}{}
+	for _, category := range categories {
+		entries, ok := threatEntries(data, category)
+		if !ok {
+			continue
+		}
+		active := entries[:0]
+		for _, entry := range entries {
+			namespace, _ := entry["namespace"].(string)
+			by, reason := idx.suppression(config, category, namespace, findingObject(entry), findingKind(category, entry))
+			if by == "" {
+				active = append(active, entry)
+				continue
+			}
+			entry["category"] = category
+			entry["suppressed_by"] = by
+			entry["suppression_reason"] = reason
+			suppressed = append(suppressed, entry)
+		}
+		if ds, ok := data[category].(map[string]interface{}); ok {
+			ds["daemonsets"] = active
+		} else {
+			data[category] = active
+		}
+	}
+	return suppressed
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ---------------------------------------------
+// MULTI-TENANT PAYLOADS
+// Namespaces are mapped to tenants by ordered rules (label selector or name
+// globs), then by a namespace label, then to the default tenant. In "tag"
+// mode every payload entry carrying a namespace gets a "tenant" field; in
+// "split" mode each tenant gets its own payload holding only its namespaces'
+// entries, sent with the tenant's API key (TENANT_API_KEYS) so MSPs can route
+// teams to different backend projects. Cluster-scoped data (nodes, totals)
+// only goes to the default tenant.
+// ---------------------------------------------
+
+const (
+	tenantModeTag   = "tag"
+	tenantModeSplit = "split"
+)
+
+// TenantRule assigns the namespaces matching Selector or Namespaces to Tenant
+type TenantRule struct {
+	Tenant string `json:"tenant"`
+	// Selector is a namespace label selector ("team=payments,env!=dev")
+	Selector string `json:"selector,omitempty"`
+	// Namespaces are namespace name globs ("payments-*")
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// Validate checks the tenant name, selector syntax and globs
+func (r TenantRule) Validate() error {
+	if r.Tenant == "" {
+		return fmt.Errorf("tenant rule: tenant is required")
+	}
+	if r.Selector == "" && len(r.Namespaces) == 0 {
+		return fmt.Errorf("tenant rule %q: needs a selector or namespaces", r.Tenant)
+	}
+	if _, err := labels.Parse(r.Selector); err != nil {
+		return fmt.Errorf("tenant rule %q: invalid selector: %v", r.Tenant, err)
+	}
+	for _, glob := range r.Namespaces {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("tenant rule %q: invalid namespace glob %q", r.Tenant, glob)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule selects ns
+func (r TenantRule) matches(ns corev1.Namespace) bool {
+	for _, glob := range r.Namespaces {
+		if ok, _ := path.Match(glob, ns.Name); ok {
+			return true
+		}
+	}
+	if r.Selector == "" {
+		return false
+	}
+	selector, err := labels.Parse(r.Selector)
+	return err == nil && selector.Matches(labels.Set(ns.Labels))
+}
+
+// TenantPolicy is how namespaces map to tenants and what that does to payloads
+type TenantPolicy struct {
+	// Mode is "tag", "split" or "" (tenancy off)
+	Mode  string       `json:"mode,omitempty"`
+	Rules []TenantRule `json:"rules,omitempty"`
+	// Label is a namespace label whose value names the tenant
+	Label string `json:"label,omitempty"`
+	// Default owns unmatched namespaces and cluster-scoped data
+	Default string `json:"default,omitempty"`
+}
+
+// Validate checks the mode and every rule
+func (p TenantPolicy) Validate() error {
+	switch p.Mode {
+	case "", tenantModeTag, tenantModeSplit:
+	default:
+		return fmt.Errorf("tenants.mode must be %q or %q", tenantModeTag, tenantModeSplit)
+	}
+	for _, r := range p.Rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultTenant returns the tenant of unmatched namespaces
+func (p TenantPolicy) defaultTenant() string {
+	if p.Default == "" {
+		return "default"
+	}
+	return p.Default
+}
+
+// tenantOf resolves the tenant of one namespace
+func (p TenantPolicy) tenantOf(ns corev1.Namespace) string {
+	for _, r := range p.Rules {
+		if r.matches(ns) {
+			return r.Tenant
+		}
+	}
+	if p.Label != "" && ns.Labels[p.Label] != "" {
+		return ns.Labels[p.Label]
+	}
+	return p.defaultTenant()
+}
+
+// parseTenantRules reads TENANT_RULES: "tenant=selector" separated by ";",
+// where a selector of "ns:glob,glob" matches namespace names instead of labels
+func parseTenantRules(value string) []TenantRule {
+	var rules []TenantRule
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		tenant, selector, _ := strings.Cut(entry, "=")
+		rule := TenantRule{Tenant: strings.TrimSpace(tenant)}
+		if globs, ok := strings.CutPrefix(strings.TrimSpace(selector), "ns:"); ok {
+			rule.Namespaces = strings.Split(globs, ",")
+		} else {
+			rule.Selector = strings.TrimSpace(selector)
+		}
+		if err := rule.Validate(); err != nil {
+			log.Printf("⚠️  Ignoring TENANT_RULES entry %q: %v", entry, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// tenantPolicyFromEnv builds the policy from TENANT_*; an unknown mode
+// disables tenancy rather than sending unpartitioned data as if it were split
+func tenantPolicyFromEnv(config AgentConfig) TenantPolicy {
+	policy := TenantPolicy{
+		Mode:    config.TenantMode,
+		Rules:   parseTenantRules(config.TenantRules),
+		Label:   config.TenantLabel,
+		Default: config.TenantDefault,
+	}
+	if err := policy.Validate(); err != nil {
+		log.Printf("⚠️  Tenancy disabled: %v", err)
+		policy.Mode = ""
+	}
+	return policy
+}
+
+// parseTenantAPIKeys reads TENANT_API_KEYS: "tenant=key" separated by ";"
+func parseTenantAPIKeys(value string) map[string]string {
+	keys := map[string]string{}
+	for _, entry := range strings.Split(value, ";") {
+		tenant, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && tenant != "" && key != "" {
+			keys[tenant] = key
+		}
+	}
+	return keys
+}
+
+// tenantState is the namespace → tenant map of the last cycle
+var tenantState = struct {
+	mu     sync.RWMutex
+	policy TenantPolicy
+	owners map[string]string
+}{owners: map[string]string{}}
+
+// updateTenants maps this cycle's namespaces to their tenants
+func updateTenants(namespaces []corev1.Namespace, policy TenantPolicy) {
+	owners := make(map[string]string, len(namespaces))
+	if policy.Mode != "" {
+		for _, ns := range namespaces {
+			owners[ns.Name] = policy.tenantOf(ns)
+		}
+	}
+	tenantState.mu.Lock()
+	tenantState.policy, tenantState.owners = policy, owners
+	tenantState.mu.Unlock()
+}
+
+// tenantOwner returns the tenant of a namespace name
+func tenantOwner(policy TenantPolicy, owners map[string]string, namespace string) string {
+	if t, ok := owners[namespace]; ok {
+		return t
+	}
+	return policy.defaultTenant()
+}
+
+// partitionMetrics applies the tenant mode to a batch: the batch tagged in
+// place ("tag"), one batch per tenant ("split"), or the batch as is
+func partitionMetrics(metrics []map[string]interface{}) (map[string][]map[string]interface{}, error) {
+	tenantState.mu.RLock()
+	policy, owners := tenantState.policy, tenantState.owners
+	tenantState.mu.RUnlock()
+	if policy.Mode == "" {
+		return map[string][]map[string]interface{}{"": metrics}, nil
+	}
+
+	generic, err := genericEnvelopes(metrics)
+	if err != nil {
+		return nil, err
+	}
+	owner := func(namespace string) string { return tenantOwner(policy, owners, namespace) }
+
+	if policy.Mode == tenantModeTag {
+		for _, m := range generic {
+			tagTenants(m["data"], owner)
+		}
+		return map[string][]map[string]interface{}{"": generic}, nil
+	}
+
+	tenants := map[string]bool{policy.defaultTenant(): true}
+	for _, t := range owners {
+		tenants[t] = true
+	}
+	batches := map[string][]map[string]interface{}{}
+	for tenant := range tenants {
+		isDefault := tenant == policy.defaultTenant()
+		for _, m := range generic {
+			data, ok := filterTenant(m["data"], tenant, isDefault, owner)
+			if !ok {
+				continue
+			}
+			envelope := make(map[string]interface{}, len(m)+1)
+			for k, v := range m {
+				envelope[k] = v
+			}
+			envelope["data"] = data
+			envelope["tenant"] = tenant
+			batches[tenant] = append(batches[tenant], envelope)
+		}
+	}
+	return batches, nil
+}
+
+// genericEnvelopes re-decodes typed collector output into plain JSON values
+func genericEnvelopes(metrics []map[string]interface{}) ([]map[string]interface{}, error) {
+	raw, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var generic []map[string]interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// tagTenants adds "tenant" next to every "namespace" field in v
+func tagTenants(v interface{}, owner func(string) string) {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if ns, ok := typed["namespace"].(string); ok && ns != "" {
+			if _, exists := typed["tenant"]; !exists {
+				typed["tenant"] = owner(ns)
+			}
+		}
+		for _, child := range typed {
+			tagTenants(child, owner)
+		}
+	case []interface{}:
+		for _, child := range typed {
+			tagTenants(child, owner)
+		}
+	}
+}
+
+// filterTenant returns the part of v that belongs to tenant. Objects naming
+// another tenant's namespace are dropped; the default tenant also keeps
+// cluster-scoped values, the others only their namespaced entries. ok is
+// false when nothing is left for the tenant.
+func filterTenant(v interface{}, tenant string, isDefault bool, owner func(string) string) (interface{}, bool) {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if ns, ok := typed["namespace"].(string); ok && ns != "" {
+			return typed, owner(ns) == tenant
+		}
+		out := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			if filtered, ok := filterTenant(child, tenant, isDefault, owner); ok {
+				out[key] = filtered
+			} else if isDefault {
+				// Emptied lists stay as empty lists for the default tenant
+				if _, isList := child.([]interface{}); isList {
+					out[key] = []interface{}{}
+				}
+			}
+		}
+		return out, len(out) > 0
+	case []interface{}:
+		out := make([]interface{}, 0, len(typed))
+		for _, child := range typed {
+			if filtered, ok := filterTenant(child, tenant, isDefault, owner); ok {
+				out = append(out, filtered)
+			}
+		}
+		return out, len(out) > 0
+	}
+	// Scalars are cluster-scoped
+	return v, isDefault
+}
+
+// tenantAPIKey is the key a tenant's payloads are sent with; tenants
+// without a key of their own use the agent's
+func tenantAPIKey(config AgentConfig, tenant string) string {
+	if key := parseTenantAPIKeys(config.TenantAPIKeys)[tenant]; key != "" {
+		return key
+	}
+	return agentAPIKey(config)
+}
+
+// tenantSendError reports the tenants whose batch could not be sent, with
+// the batch and the error of each, so only those are buffered
+type tenantSendError struct {
+	failed map[string]tenantFailure
+	last   error
+}
+
+// tenantFailure is one tenant's unsent batch
+type tenantFailure struct {
+	metrics []map[string]interface{}
+	err     error
+}
+
+func (e *tenantSendError) Error() string {
+	tenants := make([]string, 0, len(e.failed))
+	for tenant := range e.failed {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return fmt.Sprintf("tenants %s: %v", strings.Join(tenants, ", "), e.last)
+}
+
+func (e *tenantSendError) Unwrap() error { return e.last }
+
+// postTenantBatches sends each tenant's batch with its API key
+func postTenantBatches(config AgentConfig, batches map[string][]map[string]interface{}) error {
+	tenants := make([]string, 0, len(batches))
+	for tenant := range batches {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+
+	var sendErr *tenantSendError
+	for _, tenant := range tenants {
+		if err := postMetricsWithKey(config, tenantAPIKey(config, tenant), batches[tenant]); err != nil {
+			log.Printf("⚠️  Sending tenant %q payload failed: %v", tenant, err)
+			if sendErr == nil {
+				sendErr = &tenantSendError{failed: map[string]tenantFailure{}}
+			}
+			sendErr.failed[tenant] = tenantFailure{metrics: batches[tenant], err: err}
+			sendErr.last = err
+		}
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// SHORT-TERM TIME SERIES
+// A bounded ring of recent samples (cluster/node CPU and memory, pod memory
+// and restarts, PVC usage) kept by the agent itself, so trends, rates and
+// anomalies are computed locally and survive backend outages. Optionally
+// persisted to TIMESERIES_PATH so an agent restart does not lose the window.
+// ---------------------------------------------
+
+const (
+	// timeSeriesRetention is how far back samples are kept
+	timeSeriesRetention = time.Hour
+	// timeSeriesCapacity caps the samples per series (1h at the default 15s interval)
+	timeSeriesCapacity = 240
+	// maxTimeSeries bounds how many series are tracked at once
+	maxTimeSeries = 5000
+	// anomalyMinSamples is the history needed before a sample can be an anomaly
+	anomalyMinSamples = 20
+	// anomalyZScore flags a sample this many standard deviations from the mean
+	anomalyZScore = 3.0
+)
+
+// Series kinds: gauges are compared as-is, counters are turned into rates
+const (
+	seriesGauge   = "gauge"
+	seriesCounter = "counter"
+)
+
+// tsSample is one point; T is a unix timestamp in seconds
+type tsSample struct {
+	T int64   `json:"t"`
+	V float64 `json:"v"`
+}
+
+type timeSeries struct {
+	Kind    string     `json:"kind"`
+	Samples []tsSample `json:"samples"` // oldest first
+}
+
+// timeSeriesStore holds every series by key ("node/<name>/cpu_millicores", ...)
+type timeSeriesStore struct {
+	mu     sync.Mutex
+	path   string
+	series map[string]*timeSeries
+}
+
+var tsStore = &timeSeriesStore{series: map[string]*timeSeries{}}
+
+// load restores persisted series from path; a missing file is not an error
+func (s *timeSeriesStore) load(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &s.series)
+	}
+	if err != nil || s.series == nil {
+		log.Printf("⚠️  Could not restore time series from %s: %v", path, err)
+		s.series = map[string]*timeSeries{}
+		return
+	}
+	log.Printf("📈 Restored %d time series from %s", len(s.series), path)
+}
+
+// save writes the series atomically to the configured path (no-op without one)
+func (s *timeSeriesStore) save() error {
+	s.mu.Lock()
+	data, err := json.Marshal(s.series)
+	path := s.path
+	s.mu.Unlock()
+	if path == "" || err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// add appends a sample, creating the series when there is room for it
+func (s *timeSeriesStore) add(key, kind string, t time.Time, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, ok := s.series[key]
+	if !ok {
+		if len(s.series) >= maxTimeSeries {
+			return
+		}
+		ts = &timeSeries{Kind: kind}
+		s.series[key] = ts
+	}
+	ts.Samples = append(ts.Samples, tsSample{T: t.Unix(), V: v})
+	if len(ts.Samples) > timeSeriesCapacity {
+		ts.Samples = append([]tsSample(nil), ts.Samples[len(ts.Samples)-timeSeriesCapacity:]...)
+	}
+}
+
+// prune drops samples older than the retention and series left empty
+func (s *timeSeriesStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := now.Add(-timeSeriesRetention).Unix()
+	for key, ts := range s.series {
+		i := sort.Search(len(ts.Samples), func(i int) bool { return ts.Samples[i].T >= cutoff })
+		ts.Samples = ts.Samples[i:]
+		if len(ts.Samples) == 0 {
+			delete(s.series, key)
+		}
+	}
+}
+
+// reset drops every series in memory (memory watchdog); the next save
+// overwrites the persisted file
+func (s *timeSeriesStore) reset() {
+	s.mu.Lock()
+	s.series = map[string]*timeSeries{}
+	s.mu.Unlock()
+}
+
+// copySeries returns a snapshot of every series for analysis outside the lock
+func (s *timeSeriesStore) copySeries() map[string]timeSeries {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]timeSeries, len(s.series))
+	for key, ts := range s.series {
+		out[key] = timeSeries{Kind: ts.Kind, Samples: append([]tsSample(nil), ts.Samples...)}
+	}
+	return out
+}
+
+// sampleAt returns the sample of a series closest to t, if one is within tolerance
+func (s *timeSeriesStore) sampleAt(key string, t time.Time, tolerance time.Duration) (tsSample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, ok := s.series[key]
+	if !ok {
+		return tsSample{}, false
+	}
+	var best tsSample
+	found := false
+	for _, sample := range ts.Samples {
+		d := time.Unix(sample.T, 0).Sub(t).Abs()
+		if d <= tolerance && (!found || d < time.Unix(best.T, 0).Sub(t).Abs()) {
+			best, found = sample, true
+		}
+	}
+	return best, found
+}
+
+// seriesStats summarizes one series over the retained window
+type seriesStats struct {
+	Samples       int     `json:"samples"`
+	Last          float64 `json:"last"`
+	Min           float64 `json:"min"`
+	Max           float64 `json:"max"`
+	Mean          float64 `json:"mean"`
+	RatePerMinute float64 `json:"rate_per_minute,omitempty"` // counters only, resets ignored
+	SlopePerHour  float64 `json:"slope_per_hour"`            // least-squares trend
+	ZScore        float64 `json:"zscore"`                    // last sample against the earlier ones
+}
+
+func computeSeriesStats(ts timeSeries) seriesStats {
+	samples := ts.Samples
+	st := seriesStats{Samples: len(samples)}
+	if len(samples) == 0 {
+		return st
+	}
+	st.Last = samples[len(samples)-1].V
+	st.Min, st.Max = samples[0].V, samples[0].V
+	var sum float64
+	for _, p := range samples {
+		sum += p.V
+		st.Min = math.Min(st.Min, p.V)
+		st.Max = math.Max(st.Max, p.V)
+	}
+	st.Mean = sum / float64(len(samples))
+	if len(samples) < 2 {
+		return st
+	}
+
+	// Trend: least-squares slope in units per hour
+	t0 := samples[0].T
+	var sx, sy, sxx, sxy float64
+	for _, p := range samples {
+		x := float64(p.T-t0) / 3600
+		sx += x
+		sy += p.V
+		sxx += x * x
+		sxy += x * p.V
+	}
+	n := float64(len(samples))
+	if d := n*sxx - sx*sx; d != 0 {
+		st.SlopePerHour = (n*sxy - sx*sy) / d
+	}
+
+	if ts.Kind == seriesCounter {
+		var increase float64
+		for i := 1; i < len(samples); i++ {
+			if delta := samples[i].V - samples[i-1].V; delta > 0 {
+				increase += delta
+			}
+		}
+		if minutes := float64(samples[len(samples)-1].T-t0) / 60; minutes > 0 {
+			st.RatePerMinute = increase / minutes
+		}
+	}
+
+	// Anomaly score of the latest sample against the ones before it
+	previous := samples[:len(samples)-1]
+	var mean, variance float64
+	for _, p := range previous {
+		mean += p.V
+	}
+	mean /= float64(len(previous))
+	for _, p := range previous {
+		variance += (p.V - mean) * (p.V - mean)
+	}
+	if stddev := math.Sqrt(variance / float64(len(previous))); stddev > 0 {
+		st.ZScore = (st.Last - mean) / stddev
+	}
+	return st
+}
+
+// recordTimeSeries appends this cycle's samples to the store
+func recordTimeSeries(snap *ClusterSnapshot, cpuPercent, memoryPercent float64) {
+	now := snap.TakenAt
+	tsStore.add("cluster/cpu_percent", seriesGauge, now, cpuPercent)
+	tsStore.add("cluster/memory_percent", seriesGauge, now, memoryPercent)
+
+	for name, summary := range snap.NodeStats {
+		if cpu := summary.Node.CPU; cpu != nil && cpu.UsageNanoCores != nil {
+			tsStore.add("node/"+name+"/cpu_millicores", seriesGauge, now, float64(*cpu.UsageNanoCores/1e6))
+		}
+		if mem := summary.Node.Memory; mem != nil && mem.WorkingSetBytes != nil {
+			tsStore.add("node/"+name+"/memory_working_set_bytes", seriesGauge, now, float64(*mem.WorkingSetBytes))
+		}
+		// Eviction signals, looked up again when an eviction is reported
+		if mem := summary.Node.Memory; mem != nil && mem.AvailableBytes != nil {
+			tsStore.add("node/"+name+"/memory_available_bytes", seriesGauge, now, float64(*mem.AvailableBytes))
+		}
+		if fs := summary.Node.Fs; fs != nil && fs.AvailableBytes != nil {
+			tsStore.add("node/"+name+"/nodefs_available_bytes", seriesGauge, now, float64(*fs.AvailableBytes))
+			if fs.InodesFree != nil {
+				tsStore.add("node/"+name+"/nodefs_inodes_free", seriesGauge, now, float64(*fs.InodesFree))
+			}
+		}
+		if rt := summary.Node.Runtime; rt != nil && rt.ImageFs != nil && rt.ImageFs.AvailableBytes != nil {
+			tsStore.add("node/"+name+"/imagefs_available_bytes", seriesGauge, now, float64(*rt.ImageFs.AvailableBytes))
+		}
+		for _, pod := range summary.Pods {
+			key := pod.PodRef.Namespace + "/" + pod.PodRef.Name
+			if pod.Memory != nil && pod.Memory.WorkingSetBytes != nil {
+				tsStore.add("pod/"+key+"/memory_working_set_bytes", seriesGauge, now, float64(*pod.Memory.WorkingSetBytes))
+			}
+			for _, vol := range pod.VolumeStats {
+				if vol.PVCRef != nil && vol.UsedBytes != nil {
+					tsStore.add("pvc/"+vol.PVCRef.Namespace+"/"+vol.PVCRef.Name+"/used_bytes", seriesGauge, now, float64(*vol.UsedBytes))
+				}
+			}
+		}
+	}
+
+	for _, pod := range snap.Pods {
+		if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		var restarts int32
+		for _, cs := range pod.Status.ContainerStatuses {
+			restarts += cs.RestartCount
+		}
+		tsStore.add("pod/"+pod.Namespace+"/"+pod.Name+"/restarts", seriesCounter, now, float64(restarts))
+	}
+
+	tsStore.prune(now)
+}
+
+// collectTimeSeries builds the "timeseries" metric from the local store
+func collectTimeSeries(snap *ClusterSnapshot, cpuPercent, memoryPercent float64, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("timeseries", time.Now())
+
+	recordTimeSeries(snap, cpuPercent, memoryPercent)
+	if err := tsStore.save(); err != nil {
+		log.Printf("⚠️  Could not persist time series: %v", err)
+		status.Partial("persistence", err)
+	}
+
+	pvcCapacity := map[string]uint64{}
+	for _, summary := range snap.NodeStats {
+		for _, pod := range summary.Pods {
+			for _, vol := range pod.VolumeStats {
+				if vol.PVCRef != nil && vol.CapacityBytes != nil {
+					pvcCapacity[vol.PVCRef.Namespace+"/"+vol.PVCRef.Name] = *vol.CapacityBytes
+				}
+			}
+		}
+	}
+
+	all := tsStore.copySeries()
+	keys := make([]string, 0, len(all))
+	samples := 0
+	for key, ts := range all {
+		keys = append(keys, key)
+		samples += len(ts.Samples)
+	}
+	sort.Strings(keys)
+
+	cluster := map[string]seriesStats{}
+	nodes := map[string]map[string]seriesStats{}
+	var pvcs, restarting, anomalies []map[string]interface{}
+	for _, key := range keys {
+		ts := all[key]
+		st := computeSeriesStats(ts)
+		parts := strings.Split(key, "/")
+		metric := parts[len(parts)-1]
+		subject := strings.Join(parts[1:len(parts)-1], "/")
+
+		switch parts[0] {
+		case "cluster":
+			cluster[metric] = st
+		case "node":
+			if nodes[subject] == nil {
+				nodes[subject] = map[string]seriesStats{}
+			}
+			nodes[subject][metric] = st
+		case "pvc":
+			entry := map[string]interface{}{"pvc": subject, "stats": st}
+			if capacity := pvcCapacity[subject]; capacity > 0 && st.SlopePerHour > 0 {
+				entry["hours_to_full"] = (float64(capacity) - st.Last) / st.SlopePerHour
+			}
+			pvcs = append(pvcs, entry)
+		case "pod":
+			if metric == "restarts" && st.RatePerMinute > 0 {
+				restarting = append(restarting, map[string]interface{}{"pod": subject, "stats": st})
+			}
+		}
+
+		if ts.Kind == seriesGauge && st.Samples >= anomalyMinSamples && math.Abs(st.ZScore) >= anomalyZScore {
+			anomalies = append(anomalies, map[string]interface{}{
+				"series": key,
+				"last":   st.Last,
+				"mean":   st.Mean,
+				"zscore": st.ZScore,
+			})
+		}
+	}
+
+	result := map[string]interface{}{
+		"series_count":      len(all),
+		"sample_count":      samples,
+		"retention_seconds": int64(timeSeriesRetention.Seconds()),
+		"persisted":         tsStore.path != "",
+		"cluster":           cluster,
+		"nodes":             nodes,
+		"pvcs":              pvcs,
+		"restarting_pods":   restarting,
+		"anomalies":         anomalies,
+	}
+
+	// After a backend outage, resend the cluster samples the backend missed
+	if last := diagnostics.lastSuccess("agent-receive-metrics"); !last.IsZero() && snap.TakenAt.Sub(last) > 2*settings.Interval {
+		backfill := map[string][]tsSample{}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, "cluster/") {
+				continue
+			}
+			for _, p := range all[key].Samples {
+				if p.T > last.Unix() {
+					backfill[key] = append(backfill[key], p)
+				}
+			}
+		}
+		result["backfill"] = backfill
+		result["backfill_since"] = last
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// ZONE TOPOLOGY (failure domains)
+// Per-zone node capacity and replica distribution, so a zone imbalance is
+// visible before a zone outage takes a workload down
+// ---------------------------------------------
+
+// unzoned groups nodes without a topology.kubernetes.io/zone label
+const unzoned = "<none>"
+
+// zoneImbalanceRatio flags zones whose allocatable CPU differs by more than this factor
+const zoneImbalanceRatio = 1.5
+
+// nodeRegion returns the node's region, or "" when unlabeled
+func nodeRegion(node corev1.Node) string {
+	if region := node.Labels[corev1.LabelTopologyRegion]; region != "" {
+		return region
+	}
+	return node.Labels[corev1.LabelFailureDomainBetaRegion]
+}
+
+// collectZoneTopology builds the "zone_topology" metric
+func collectZoneTopology(snap *ClusterSnapshot) map[string]interface{} {
+	defer diagnostics.recordDuration("zone_topology", time.Now())
+
+	type zoneInfo struct {
+		region                     string
+		nodes, readyNodes, pods    int
+		allocCPU, allocMem         int64
+		requestedCPU, requestedMem int64
+	}
+	zones := map[string]*zoneInfo{}
+	zoneByNode := map[string]string{}
+	regions := map[string]bool{}
+	var totalCPU int64
+
+	for _, node := range snap.Nodes {
+		zone := nodeZone(node)
+		if zone == "" {
+			zone = unzoned
+		}
+		zoneByNode[node.Name] = zone
+		z, ok := zones[zone]
+		if !ok {
+			z = &zoneInfo{region: nodeRegion(node)}
+			zones[zone] = z
+		}
+		if z.region != "" {
+			regions[z.region] = true
+		}
+
+		z.nodes++
+		if getNodeStatus(node) == "Ready" {
+			z.readyNodes++
+		}
+		z.allocCPU += node.Status.Allocatable.Cpu().MilliValue()
+		z.allocMem += node.Status.Allocatable.Memory().Value()
+		cpu, mem := getPodResourcesOnNode(snap.Pods, node.Name)
+		z.requestedCPU += cpu
+		z.requestedMem += mem
+		totalCPU += node.Status.Allocatable.Cpu().MilliValue()
+	}
+	for _, pod := range snap.Pods {
+		if zone, ok := zoneByNode[pod.Spec.NodeName]; ok && pod.Status.Phase == corev1.PodRunning {
+			zones[zone].pods++
+		}
+	}
+
+	var zoneList []map[string]interface{}
+	var minCPU, maxCPU int64
+	for name, z := range zones {
+		share := float64(0)
+		if totalCPU > 0 {
+			share = float64(z.allocCPU) / float64(totalCPU) * 100
+		}
+		zoneList = append(zoneList, map[string]interface{}{
+			"zone":                     name,
+			"region":                   z.region,
+			"nodes":                    z.nodes,
+			"ready_nodes":              z.readyNodes,
+			"running_pods":             z.pods,
+			"allocatable_cpu_millis":   z.allocCPU,
+			"allocatable_memory_bytes": z.allocMem,
+			"requested_cpu_millis":     z.requestedCPU,
+			"requested_memory_bytes":   z.requestedMem,
+			"cpu_capacity_share":       share,
+		})
+		if name == unzoned {
+			continue
+		}
+		if minCPU == 0 || z.allocCPU < minCPU {
+			minCPU = z.allocCPU
+		}
+		if z.allocCPU > maxCPU {
+			maxCPU = z.allocCPU
+		}
+	}
+	sort.Slice(zoneList, func(i, j int) bool { return zoneList[i]["zone"].(string) < zoneList[j]["zone"].(string) })
+
+	labeledZones, unzonedNodes := len(zones), 0
+	if z, ok := zones[unzoned]; ok {
+		labeledZones--
+		unzonedNodes = z.nodes
+	}
+
+	// Workloads that would lose every replica (or most of them) with a single zone
+	var atRisk []map[string]interface{}
+	if labeledZones > 1 {
+		for _, w := range replicatedWorkloads(snap) {
+			if len(w.pods) < 2 {
+				continue
+			}
+			perZone := map[string]int{}
+			for _, pod := range w.pods {
+				perZone[zoneByNode[pod.Spec.NodeName]]++
+			}
+			worstZone, worst := "", 0
+			for zone, count := range perZone {
+				if count > worst {
+					worstZone, worst = zone, count
+				}
+			}
+			lostPercent := float64(worst) / float64(len(w.pods)) * 100
+			if lostPercent <= 50 {
+				continue
+			}
+			atRisk = append(atRisk, map[string]interface{}{
+				"namespace":            w.namespace,
+				"kind":                 w.kind,
+				"name":                 w.name,
+				"running_replicas":     len(w.pods),
+				"replicas_per_zone":    perZone,
+				"most_exposed_zone":    worstZone,
+				"zone_loss_percent":    lostPercent,
+				"total_outage_on_loss": worst == len(w.pods),
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"zones":              zoneList,
+		"zone_count":         labeledZones,
+		"regions":            sortedKeys(regions),
+		"unzoned_nodes":      unzonedNodes,
+		"capacity_imbalance": minCPU > 0 && float64(maxCPU)/float64(minCPU) > zoneImbalanceRatio,
+		"workloads_at_risk":  atRisk,
+	}
+}
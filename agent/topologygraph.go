@@ -0,0 +1,216 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// TOPOLOGY GRAPH
+// The relationships the backend needs to draw a cluster map, resolved here
+// where the objects already are: Deployment → ReplicaSet → Pod → Node,
+// Service → Pod, Ingress → Service, Pod → PVC → PV → StorageClass. Nodes are
+// identified as "Kind/namespace/name" ("Kind/name" when cluster-scoped).
+// ---------------------------------------------
+
+// topologyNode is one object in the graph
+type topologyNode struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+}
+
+// topologyEdge is a directed relationship between two nodes
+type topologyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// topologyID builds the graph id of an object
+func topologyID(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + "/" + name
+	}
+	return kind + "/" + namespace + "/" + name
+}
+
+// topologyGraph deduplicates nodes and edges while they are added
+type topologyGraph struct {
+	nodes     map[string]*topologyNode
+	edges     []topologyEdge
+	seenEdges map[topologyEdge]bool
+}
+
+func (g *topologyGraph) node(kind, namespace, name, status string) string {
+	id := topologyID(kind, namespace, name)
+	if n, ok := g.nodes[id]; ok {
+		if status != "" {
+			n.Status = status
+		}
+		return id
+	}
+	g.nodes[id] = &topologyNode{ID: id, Kind: kind, Namespace: namespace, Name: name, Status: status}
+	return id
+}
+
+func (g *topologyGraph) edge(from, to, kind string) {
+	e := topologyEdge{From: from, To: to, Type: kind}
+	if g.seenEdges[e] {
+		return
+	}
+	g.seenEdges[e] = true
+	g.edges = append(g.edges, e)
+}
+
+// ownerEdge links an object to its controller, if it has one
+func (g *topologyGraph) ownerEdge(owners []metav1.OwnerReference, namespace, id string) {
+	if ref := metav1.GetControllerOfNoCopy(&metav1.ObjectMeta{OwnerReferences: owners}); ref != nil {
+		g.edge(g.node(ref.Kind, namespace, ref.Name, ""), id, "owns")
+	}
+}
+
+// collectTopologyGraph builds the "topology" metric
+func collectTopologyGraph(clientset kubernetes.Interface, snap *ClusterSnapshot, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("topology", time.Now())
+
+	g := &topologyGraph{nodes: map[string]*topologyNode{}, seenEdges: map[topologyEdge]bool{}}
+
+	for _, node := range snap.Nodes {
+		ready := "NotReady"
+		for _, c := range node.Status.Conditions {
+			if c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue {
+				ready = "Ready"
+			}
+		}
+		g.node("Node", "", node.Name, ready)
+	}
+
+	ctx, cancel := apiContext()
+	deployList, err := clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing Deployments: %v", err)
+		status.Partial("deployments", err)
+	} else {
+		for _, d := range filterByNamespace(deployList.Items, func(d appsv1.Deployment) string { return d.Namespace }, settings) {
+			g.node("Deployment", d.Namespace, d.Name, "")
+		}
+	}
+
+	ctx, cancel = apiContext()
+	rsList, err := clientset.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing ReplicaSets: %v", err)
+		status.Partial("replicasets", err)
+	} else {
+		for _, rs := range filterByNamespace(rsList.Items, func(rs appsv1.ReplicaSet) string { return rs.Namespace }, settings) {
+			// Scaled-down revisions only add noise to a map
+			if rs.Status.Replicas == 0 {
+				continue
+			}
+			id := g.node("ReplicaSet", rs.Namespace, rs.Name, "")
+			g.ownerEdge(rs.OwnerReferences, rs.Namespace, id)
+		}
+	}
+
+	for _, pod := range snap.Pods {
+		id := g.node("Pod", pod.Namespace, pod.Name, string(pod.Status.Phase))
+		g.ownerEdge(pod.OwnerReferences, pod.Namespace, id)
+		if pod.Spec.NodeName != "" {
+			g.edge(id, g.node("Node", "", pod.Spec.NodeName, ""), "scheduled_on")
+		}
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				g.edge(id, g.node("PersistentVolumeClaim", pod.Namespace, v.PersistentVolumeClaim.ClaimName, ""), "mounts")
+			}
+		}
+	}
+
+	for _, svc := range snap.Services {
+		id := g.node("Service", svc.Namespace, svc.Name, string(svc.Spec.Type))
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		for _, pod := range snap.Pods {
+			if pod.Namespace == svc.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				g.edge(id, topologyID("Pod", pod.Namespace, pod.Name), "selects")
+			}
+		}
+	}
+
+	ctx, cancel = apiContext()
+	ingressList, err := clientset.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		log.Printf("⚠️  Error listing Ingresses: %v", err)
+		status.Partial("ingresses", err)
+	} else {
+		for _, ing := range filterByNamespace(ingressList.Items, func(ing networkingv1.Ingress) string { return ing.Namespace }, settings) {
+			id := g.node("Ingress", ing.Namespace, ing.Name, "")
+			route := func(backend *networkingv1.IngressBackend) {
+				if backend != nil && backend.Service != nil {
+					g.edge(id, g.node("Service", ing.Namespace, backend.Service.Name, ""), "routes_to")
+				}
+			}
+			route(ing.Spec.DefaultBackend)
+			for _, rule := range ing.Spec.Rules {
+				if rule.HTTP == nil {
+					continue
+				}
+				for _, p := range rule.HTTP.Paths {
+					backend := p.Backend
+					route(&backend)
+				}
+			}
+		}
+	}
+
+	for _, pvc := range snap.PVCs {
+		id := g.node("PersistentVolumeClaim", pvc.Namespace, pvc.Name, string(pvc.Status.Phase))
+		if pvc.Spec.VolumeName != "" {
+			g.edge(id, g.node("PersistentVolume", "", pvc.Spec.VolumeName, ""), "bound_to")
+		}
+	}
+	for _, pv := range snap.PVs {
+		id := g.node("PersistentVolume", "", pv.Name, string(pv.Status.Phase))
+		if pv.Spec.StorageClassName != "" {
+			g.edge(id, g.node("StorageClass", "", pv.Spec.StorageClassName, ""), "provisioned_by")
+		}
+	}
+
+	nodes := make([]*topologyNode, 0, len(g.nodes))
+	kinds := map[string]int{}
+	for _, id := range sortedNodeIDs(g.nodes) {
+		nodes = append(nodes, g.nodes[id])
+		kinds[g.nodes[id].Kind]++
+	}
+
+	return map[string]interface{}{
+		"nodes":      nodes,
+		"edges":      g.edges,
+		"node_count": len(nodes),
+		"edge_count": len(g.edges),
+		"kinds":      kinds,
+	}
+}
+
+// sortedNodeIDs keeps the node list stable between cycles
+func sortedNodeIDs(nodes map[string]*topologyNode) []string {
+	ids := make(map[string]bool, len(nodes))
+	for id := range nodes {
+		ids[id] = true
+	}
+	return sortedKeys(ids)
+}
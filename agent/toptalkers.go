@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------
+// NETWORK TOP TALKERS
+// The Kubelet reports cumulative rx/tx counters per pod network namespace;
+// the agent keeps the previous sample to turn them into per-cycle bytes and
+// rates, and reports the top-N pods so bandwidth hogs (or a pod suddenly
+// sending far more than it receives) stand out
+// ---------------------------------------------
+
+const (
+	// topTalkersLimit is how many pods each ranking keeps
+	topTalkersLimit = 10
+	// txDominantRatio flags pods sending this many times more than they receive
+	txDominantRatio = 10
+	// txDominantMinBytesPerSec ignores the ratio for quiet pods
+	txDominantMinBytesPerSec = 1 << 20
+)
+
+// networkSample is one pod's counters at a point in time
+type networkSample struct {
+	rx, tx, rxErrors, txErrors uint64
+	at                         time.Time
+}
+
+// networkCounters keeps the previous sample per pod; pods that disappear are dropped
+var networkCounters = struct {
+	mu      sync.Mutex
+	samples map[string]networkSample
+}{samples: map[string]networkSample{}}
+
+// podTraffic is one pod's traffic since the previous cycle
+type podTraffic struct {
+	Namespace   string  `json:"namespace"`
+	Pod         string  `json:"pod"`
+	Node        string  `json:"node"`
+	RxBytes     uint64  `json:"rx_bytes"`
+	TxBytes     uint64  `json:"tx_bytes"`
+	RxPerSec    float64 `json:"rx_bytes_per_sec"`
+	TxPerSec    float64 `json:"tx_bytes_per_sec"`
+	RxErrors    uint64  `json:"rx_errors"`
+	TxErrors    uint64  `json:"tx_errors"`
+	TxDominant  bool    `json:"tx_dominant"`
+	WindowSecs  float64 `json:"window_seconds"`
+	totalPerSec float64
+}
+
+// collectTopTalkers builds the "network_top_talkers" metric
+func collectTopTalkers(snap *ClusterSnapshot, settings *AgentSettings, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("network_top_talkers", time.Now())
+
+	if len(snap.NodeStats) == 0 {
+		status.Fail("kubelet_stats", fmt.Errorf("no node returned stats/summary"))
+		return map[string]interface{}{}
+	}
+
+	// Host-network pods report the node's interfaces, not their own traffic
+	hostNetwork := map[string]bool{}
+	for _, pod := range snap.Pods {
+		if pod.Spec.HostNetwork {
+			hostNetwork[pod.Namespace+"/"+pod.Name] = true
+		}
+	}
+
+	now := snap.TakenAt
+	current := map[string]networkSample{}
+	var traffic []podTraffic
+	var totalRx, totalTx uint64
+
+	networkCounters.mu.Lock()
+	for nodeName, summary := range snap.NodeStats {
+		for _, ps := range summary.Pods {
+			key := ps.PodRef.Namespace + "/" + ps.PodRef.Name
+			n := ps.Network
+			if n == nil || n.RxBytes == nil || n.TxBytes == nil || hostNetwork[key] || !settings.NamespaceAllowed(ps.PodRef.Namespace) {
+				continue
+			}
+			sample := networkSample{rx: *n.RxBytes, tx: *n.TxBytes, at: now}
+			if n.RxErrors != nil {
+				sample.rxErrors = *n.RxErrors
+			}
+			if n.TxErrors != nil {
+				sample.txErrors = *n.TxErrors
+			}
+			current[key] = sample
+
+			prev, ok := networkCounters.samples[key]
+			// Counters reset when the pod sandbox is recreated
+			if !ok || sample.rx < prev.rx || sample.tx < prev.tx {
+				continue
+			}
+			window := sample.at.Sub(prev.at).Seconds()
+			if window <= 0 {
+				continue
+			}
+			t := podTraffic{
+				Namespace:  ps.PodRef.Namespace,
+				Pod:        ps.PodRef.Name,
+				Node:       nodeName,
+				RxBytes:    sample.rx - prev.rx,
+				TxBytes:    sample.tx - prev.tx,
+				WindowSecs: window,
+			}
+			if sample.rxErrors >= prev.rxErrors {
+				t.RxErrors = sample.rxErrors - prev.rxErrors
+			}
+			if sample.txErrors >= prev.txErrors {
+				t.TxErrors = sample.txErrors - prev.txErrors
+			}
+			t.RxPerSec = float64(t.RxBytes) / window
+			t.TxPerSec = float64(t.TxBytes) / window
+			t.totalPerSec = t.RxPerSec + t.TxPerSec
+			t.TxDominant = t.TxPerSec >= txDominantMinBytesPerSec && t.TxPerSec >= txDominantRatio*t.RxPerSec
+			totalRx += t.RxBytes
+			totalTx += t.TxBytes
+			traffic = append(traffic, t)
+		}
+	}
+	networkCounters.samples = current
+	networkCounters.mu.Unlock()
+
+	if len(traffic) == 0 {
+		// First cycle (or no pod network stats): counters are baselined only
+		return map[string]interface{}{
+			"pods_sampled": len(current),
+			"baseline":     true,
+		}
+	}
+
+	rank := func(less func(a, b podTraffic) bool) []podTraffic {
+		sorted := make([]podTraffic, len(traffic))
+		copy(sorted, traffic)
+		sort.Slice(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+		if len(sorted) > topTalkersLimit {
+			sorted = sorted[:topTalkersLimit]
+		}
+		return sorted
+	}
+
+	var txDominant []podTraffic
+	for _, t := range traffic {
+		if t.TxDominant {
+			txDominant = append(txDominant, t)
+		}
+	}
+
+	return map[string]interface{}{
+		"pods_sampled":   len(current),
+		"total_rx_bytes": totalRx,
+		"total_tx_bytes": totalTx,
+		"top_total":      rank(func(a, b podTraffic) bool { return a.totalPerSec > b.totalPerSec }),
+		"top_rx":         rank(func(a, b podTraffic) bool { return a.RxPerSec > b.RxPerSec }),
+		"top_tx":         rank(func(a, b podTraffic) bool { return a.TxPerSec > b.TxPerSec }),
+		"tx_dominant":    txDominant,
+	}
+}
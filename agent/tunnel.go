@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// ---------------------------------------------
+// PORT-FORWARD TUNNELS
+// Agent-initiated reverse connections from the backend relay to a pod port.
+// The agent port-forwards the pod to a loopback port, then keeps a few
+// connections parked at the relay; when a user attaches, the relay answers
+// 101 Switching Protocols and the connection is piped to the pod. The relay
+// must be served over https from the API_ENDPOINT host and only ever sees
+// the per-tunnel token, never the agent's own credentials.
+// ---------------------------------------------
+
+const (
+	defaultTunnelTTL = 10 * time.Minute
+	maxTunnelTTL     = time.Hour
+	// tunnelRelayConns is how many idle relay connections are kept per tunnel
+	tunnelRelayConns = 4
+	// tunnelUpgradeProtocol is the Upgrade token expected by the relay
+	tunnelUpgradeProtocol = "kodo-tunnel"
+)
+
+// Tunnel is one active port-forward exposed through the relay
+type Tunnel struct {
+	ID         string
+	Namespace  string
+	PodName    string
+	RemotePort int
+	LocalPort  uint16
+	OpenedAt   time.Time
+	ExpiresAt  time.Time
+
+	cancel   context.CancelFunc
+	streams  int64
+	bytesIn  int64
+	bytesOut int64
+}
+
+// TunnelManager tracks open tunnels by ID
+type TunnelManager struct {
+	mu      sync.Mutex
+	tunnels map[string]*Tunnel
+}
+
+var tunnels = &TunnelManager{tunnels: map[string]*Tunnel{}}
+
+func (m *TunnelManager) add(t *Tunnel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.tunnels[t.ID]; exists {
+		return fmt.Errorf("tunnel %s is already open", t.ID)
+	}
+	m.tunnels[t.ID] = t
+	return nil
+}
+
+func (m *TunnelManager) remove(id string) *Tunnel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.tunnels[id]
+	delete(m.tunnels, id)
+	return t
+}
+
+// openTunnel implements the "open_tunnel" command
+func openTunnel(clientset kubernetes.Interface, kubeconfig *rest.Config, config AgentConfig, params map[string]interface{}) (map[string]interface{}, error) {
+	var p OpenTunnelParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	tunnelID, podName, namespace := p.TunnelID, p.PodName, p.Namespace
+	relayURL, token, port := p.RelayURL, p.TunnelToken, p.Port
+	if err := checkRelayURL(config, relayURL); err != nil {
+		return nil, err
+	}
+
+	ttl := defaultTunnelTTL
+	if p.TTLSeconds > 0 {
+		ttl = time.Duration(p.TTLSeconds) * time.Second
+	}
+	if ttl > maxTunnelTTL {
+		ttl = maxTunnelTTL
+	}
+
+	ctx, cancel := apiContext()
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("pod %s/%s is %s, not Running", namespace, podName, pod.Status.Phase)
+	}
+
+	tunnelCtx, tunnelCancel := context.WithTimeout(context.Background(), ttl)
+	localPort, err := startPortForward(tunnelCtx, clientset, kubeconfig, namespace, podName, port)
+	if err != nil {
+		tunnelCancel()
+		return nil, fmt.Errorf("failed to port-forward %s/%s:%d: %v", namespace, podName, port, err)
+	}
+
+	now := time.Now()
+	t := &Tunnel{
+		ID:         tunnelID,
+		Namespace:  namespace,
+		PodName:    podName,
+		RemotePort: port,
+		LocalPort:  localPort,
+		OpenedAt:   now,
+		ExpiresAt:  now.Add(ttl),
+		cancel:     tunnelCancel,
+	}
+	if err := tunnels.add(t); err != nil {
+		tunnelCancel()
+		return nil, err
+	}
+
+	for i := 0; i < tunnelRelayConns; i++ {
+		go t.serveRelay(tunnelCtx, relayURL, token)
+	}
+
+	// Audit: close and log once the TTL expires or the tunnel is closed
+	go func() {
+		<-tunnelCtx.Done()
+		tunnels.remove(t.ID)
+		log.Printf("🔒 [audit] Tunnel %s to %s/%s:%d closed after %v (streams=%d, bytes_in=%d, bytes_out=%d)",
+			t.ID, t.Namespace, t.PodName, t.RemotePort, time.Since(t.OpenedAt).Round(time.Second),
+			atomic.LoadInt64(&t.streams), atomic.LoadInt64(&t.bytesIn), atomic.LoadInt64(&t.bytesOut))
+	}()
+
+	log.Printf("🔓 [audit] Tunnel %s opened to %s/%s:%d via relay %s (expires %s)",
+		tunnelID, namespace, podName, port, relayURL, t.ExpiresAt.UTC().Format(time.RFC3339))
+
+	return map[string]interface{}{
+		"action":     "tunnel_opened",
+		"tunnel_id":  tunnelID,
+		"pod":        podName,
+		"namespace":  namespace,
+		"port":       port,
+		"expires_at": t.ExpiresAt.UTC().Format(time.RFC3339),
+		"message":    "Tunnel is ready. The relay can now attach clients.",
+	}, nil
+}
+
+// closeTunnel implements the "close_tunnel" command
+func closeTunnel(params map[string]interface{}) (map[string]interface{}, error) {
+	var p CloseTunnelParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	tunnelID := p.TunnelID
+
+	t := tunnels.remove(tunnelID)
+	if t == nil {
+		return nil, fmt.Errorf("tunnel %s is not open", tunnelID)
+	}
+	t.cancel()
+
+	return map[string]interface{}{
+		"action":    "tunnel_closed",
+		"tunnel_id": tunnelID,
+		"streams":   atomic.LoadInt64(&t.streams),
+	}, nil
+}
+
+// startPortForward forwards a loopback port to the pod port until ctx is done
+// and returns the local port chosen.
+func startPortForward(ctx context.Context, clientset kubernetes.Interface, kubeconfig *rest.Config, namespace, podName string, port int) (uint16, error) {
+	restClient, err := coreRESTClient(clientset)
+	if err != nil {
+		return 0, err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(kubeconfig)
+	if err != nil {
+		return 0, err
+	}
+
+	url := restClient.Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		return 0, err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fw.ForwardPorts()
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return 0, err
+	case <-time.After(apiCallTimeout):
+		close(stopCh)
+		return 0, fmt.Errorf("timed out waiting for port-forward")
+	}
+
+	go func() {
+		<-ctx.Done()
+		close(stopCh)
+	}()
+
+	ports, err := fw.GetPorts()
+	if err != nil || len(ports) == 0 {
+		return 0, fmt.Errorf("port-forward did not report a local port: %v", err)
+	}
+	return ports[0].Local, nil
+}
+
+// serveRelay keeps one connection parked at the relay until the tunnel closes
+func (t *Tunnel) serveRelay(ctx context.Context, relayURL, token string) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		if err := t.relayOnce(ctx, relayURL, token); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("⚠️  Tunnel %s relay connection failed: %v", t.ID, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// relayClient does not follow redirects, which would carry the tunnel token
+// to another host
+var relayClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// checkRelayURL accepts only https relays on the API_ENDPOINT host, so a
+// command cannot point the tunnel (and its pod traffic) somewhere else
+func checkRelayURL(config AgentConfig, relayURL string) error {
+	relay, err := url.Parse(relayURL)
+	if err != nil || relay.Scheme != "https" || relay.Host == "" {
+		return fmt.Errorf("relay_url must be an https:// URL")
+	}
+	endpoint, err := url.Parse(config.APIEndpoint)
+	if err != nil || endpoint.Host == "" {
+		return fmt.Errorf("API_ENDPOINT %q has no host to check relay_url against", config.APIEndpoint)
+	}
+	if !strings.EqualFold(relay.Host, endpoint.Host) {
+		return fmt.Errorf("relay_url host %s is not the API_ENDPOINT host %s", relay.Host, endpoint.Host)
+	}
+	return nil
+}
+
+// relayOnce waits for the relay to attach a client, then pipes it to the pod;
+// the relay authenticates the connection by the tunnel token alone
+func (t *Tunnel) relayOnce(ctx context.Context, relayURL, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", relayURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", tunnelUpgradeProtocol)
+	req.Header.Set("x-agent-version", AgentVersion)
+	req.Header.Set("x-tunnel-id", t.ID)
+	req.Header.Set("x-tunnel-token", token)
+
+	// No client timeout: the relay holds the request until a user attaches
+	resp, err := relayClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return fmt.Errorf("relay returned %d", resp.StatusCode)
+	}
+	remote, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return fmt.Errorf("relay connection is not writable")
+	}
+	defer remote.Close()
+
+	local, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", t.LocalPort))
+	if err != nil {
+		return fmt.Errorf("failed to reach forwarded port: %v", err)
+	}
+	defer local.Close()
+
+	streamID := atomic.AddInt64(&t.streams, 1)
+	start := time.Now()
+	log.Printf("🔌 [audit] Tunnel %s stream #%d attached (client=%s)", t.ID, streamID, resp.Header.Get("x-tunnel-client"))
+
+	done := make(chan struct{}, 2)
+	go func() {
+		n, _ := io.Copy(local, remote)
+		atomic.AddInt64(&t.bytesIn, n)
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := io.Copy(remote, local)
+		atomic.AddInt64(&t.bytesOut, n)
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	log.Printf("🔌 [audit] Tunnel %s stream #%d detached after %v", t.ID, streamID, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ---------------------------------------------
+// UPGRADE READINESS
+// What would block or complicate a node pool upgrade, per node: operating
+// systems past (or near) end of life and kernels with known container escape
+// bugs or missing features the runtime needs, dockershim-era runtimes, and
+// runtime/kubelet version skew across the pool. The tables below are maintained
+// by hand; bump upgradeMappingVersion whenever they change so the backend
+// knows which mapping a payload was judged against.
+// ---------------------------------------------
+
+// upgradeMappingVersion identifies the revision of the EOL and kernel tables
+const upgradeMappingVersion = "2026-10"
+
+// osEOLWarnWindow flags an OS whose end of life is this close
+const osEOLWarnWindow = 180 * 24 * time.Hour
+
+// osLifecycle is the end of standard support of one distribution release,
+// matched as a prefix of the node's osImage
+type osLifecycle struct {
+	prefix string
+	eol    string
+}
+
+// osLifecycles lists the releases seen on managed and self-managed nodes.
+// Rolling distributions (Container-Optimized OS, Bottlerocket, Flatcar,
+// Fedora CoreOS, Talos) have no fixed EOL and are left out on purpose.
+var osLifecycles = []osLifecycle{
+	{prefix: "Ubuntu 16.04", eol: "2021-04-30"},
+	{prefix: "Ubuntu 18.04", eol: "2023-05-31"},
+	{prefix: "Ubuntu 20.04", eol: "2025-05-31"},
+	{prefix: "Ubuntu 22.04", eol: "2027-06-01"},
+	{prefix: "Ubuntu 24.04", eol: "2029-05-31"},
+	{prefix: "Debian GNU/Linux 9", eol: "2022-06-30"},
+	{prefix: "Debian GNU/Linux 10", eol: "2024-06-30"},
+	{prefix: "Debian GNU/Linux 11", eol: "2026-08-31"},
+	{prefix: "Debian GNU/Linux 12", eol: "2028-06-30"},
+	{prefix: "CentOS Linux 7", eol: "2024-06-30"},
+	{prefix: "CentOS Linux 8", eol: "2021-12-31"},
+	{prefix: "CentOS Stream 8", eol: "2024-05-31"},
+	{prefix: "CentOS Stream 9", eol: "2027-05-31"},
+	{prefix: "Red Hat Enterprise Linux Server 7", eol: "2024-06-30"},
+	{prefix: "Red Hat Enterprise Linux 7", eol: "2024-06-30"},
+	{prefix: "Red Hat Enterprise Linux 8", eol: "2029-05-31"},
+	{prefix: "Red Hat Enterprise Linux 9", eol: "2032-05-31"},
+	{prefix: "Rocky Linux 8", eol: "2029-05-31"},
+	{prefix: "Rocky Linux 9", eol: "2032-05-31"},
+	{prefix: "Amazon Linux 2023", eol: "2029-06-30"},
+	{prefix: "Amazon Linux 2", eol: "2026-06-30"},
+	{prefix: "SUSE Linux Enterprise Server 12", eol: "2024-10-31"},
+	{prefix: "SUSE Linux Enterprise Server 15", eol: "2031-07-31"},
+	{prefix: "Windows Server 2016", eol: "2022-01-11"},
+	{prefix: "Windows Server 2019", eol: "2024-01-09"},
+	{prefix: "Windows Server 2022", eol: "2026-10-13"},
+}
+
+// kernelIssue is a kernel range [from, before) with a problem for containers.
+// runtime restricts it to one container runtime ("" = any).
+type kernelIssue struct {
+	id       string
+	runtime  string
+	from     string
+	before   string
+	severity string
+	detail   string
+}
+
+// kernelIssues compare upstream versions. Distribution kernels backport fixes
+// without changing the upstream number, so CVE entries mean "verify the
+// vendor patch level", not "vulnerable".
+var kernelIssues = []kernelIssue{
+	{id: "unsupported_kernel", before: "3.10", severity: "high", detail: "runc requires Linux 3.10 or later"},
+	{id: "overlayfs_multi_lower", runtime: "containerd", before: "4.0", severity: "high", detail: "containerd's overlayfs snapshotter needs multiple lower dirs (Linux 4.0+)"},
+	{id: "old_kernel", from: "3.10", before: "4.19", severity: "medium", detail: "older than the minimum kernel validated by current kubeadm releases (4.19)"},
+	{id: "no_cgroup_v2", from: "4.19", before: "5.8", severity: "low", detail: "Kubernetes cgroup v2 support needs Linux 5.8+; cgroup v1 is in maintenance mode"},
+	{id: "CVE-2022-0185", from: "5.1", before: "5.4.173", severity: "high", detail: "fsconfig heap overflow usable for container escape"},
+	{id: "CVE-2022-0185", from: "5.5", before: "5.10.93", severity: "high", detail: "fsconfig heap overflow usable for container escape"},
+	{id: "CVE-2022-0185", from: "5.11", before: "5.15.16", severity: "high", detail: "fsconfig heap overflow usable for container escape"},
+	{id: "CVE-2022-0847", from: "5.8", before: "5.10.102", severity: "high", detail: "Dirty Pipe: overwrite of read-only files, including image layers"},
+	{id: "CVE-2022-0847", from: "5.11", before: "5.15.25", severity: "high", detail: "Dirty Pipe: overwrite of read-only files, including image layers"},
+	{id: "CVE-2022-0847", from: "5.16", before: "5.16.11", severity: "high", detail: "Dirty Pipe: overwrite of read-only files, including image layers"},
+}
+
+// runtimeMinimums is the oldest still-supported release line per runtime.
+// CRI-O is not listed: its minors track Kubernetes minors (see runtimeFindings).
+var runtimeMinimums = map[string]string{
+	"containerd": "1.7", // 1.6 LTS ended in 2025
+}
+
+// kubeletMaxSkew is how many minors a kubelet may trail the API server (1.28+)
+const kubeletMaxSkew = 3
+
+// dockershimSockets identify a kubelet still wired to the Docker shims
+var dockershimSockets = []string{"dockershim.sock", "cri-dockerd.sock", "docker.sock"}
+
+// parseVersion reads the leading "major.minor.patch" of a version string
+// ("5.15.0-1049-aws", "v1.7.2", "1.30.1-eks-abc") into numbers
+func parseVersion(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	var parts []int
+	for _, field := range strings.SplitN(v, ".", 3) {
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, _ := strconv.Atoi(field[:end])
+		parts = append(parts, n)
+		if end < len(field) {
+			break
+		}
+	}
+	return parts
+}
+
+// compareVersions orders two parsed versions, missing parts counting as 0
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// splitRuntimeVersion splits "containerd://1.7.2" into runtime and version
+func splitRuntimeVersion(v string) (string, string) {
+	runtime, version, ok := strings.Cut(v, "://")
+	if !ok {
+		return "", v
+	}
+	return runtime, version
+}
+
+// osEndOfLife matches the node's osImage against osLifecycles
+func osEndOfLife(node corev1.Node, now time.Time) map[string]interface{} {
+	image := node.Status.NodeInfo.OSImage
+	for _, lc := range osLifecycles {
+		if !strings.HasPrefix(image, lc.prefix) {
+			continue
+		}
+		eol, _ := time.Parse("2006-01-02", lc.eol)
+		status := "supported"
+		switch {
+		case !now.Before(eol):
+			status = "eol"
+		case eol.Sub(now) < osEOLWarnWindow:
+			status = "eol_soon"
+		}
+		return map[string]interface{}{
+			"release":  lc.prefix,
+			"eol_date": lc.eol,
+			"status":   status,
+		}
+	}
+	return map[string]interface{}{"status": "unknown"}
+}
+
+// nodeKernelIssues returns the kernelIssues matching the node's kernel and runtime
+func nodeKernelIssues(node corev1.Node, runtime string) []map[string]interface{} {
+	if nodeOS(node) != "linux" {
+		return nil
+	}
+	kernel := parseVersion(node.Status.NodeInfo.KernelVersion)
+	if len(kernel) == 0 {
+		return nil
+	}
+	var issues []map[string]interface{}
+	for _, issue := range kernelIssues {
+		if issue.runtime != "" && issue.runtime != runtime {
+			continue
+		}
+		if issue.from != "" && compareVersions(kernel, parseVersion(issue.from)) < 0 {
+			continue
+		}
+		if compareVersions(kernel, parseVersion(issue.before)) >= 0 {
+			continue
+		}
+		issues = append(issues, map[string]interface{}{
+			"id":       issue.id,
+			"severity": issue.severity,
+			"fixed_in": issue.before,
+			"detail":   issue.detail,
+		})
+	}
+	return issues
+}
+
+// runtimeFindings flags dockershim-era setups and unsupported runtime releases
+func runtimeFindings(node corev1.Node, config *KubeletConfig, runtime, runtimeVersion string) []map[string]interface{} {
+	var findings []map[string]interface{}
+	add := func(id, severity, detail string) {
+		findings = append(findings, map[string]interface{}{"id": id, "severity": severity, "detail": detail})
+	}
+	kubelet := parseVersion(node.Status.NodeInfo.KubeletVersion)
+	version := parseVersion(runtimeVersion)
+
+	if runtime == "docker" {
+		if compareVersions(kubelet, []int{1, 24}) < 0 {
+			add("dockershim", "high", "kubelet uses the built-in dockershim, removed in Kubernetes 1.24; migrate to containerd or CRI-O before upgrading")
+		} else {
+			add("cri_dockerd", "medium", "Docker Engine through cri-dockerd, an out-of-tree shim outside the Kubernetes release cycle")
+		}
+	}
+
+	// The socket outlives the migration on nodes converted in place
+	endpoint := node.Annotations["kubeadm.alpha.kubernetes.io/cri-socket"]
+	if config != nil && config.ContainerRuntimeEndpoint != "" {
+		endpoint = config.ContainerRuntimeEndpoint
+	}
+	for _, socket := range dockershimSockets {
+		if runtime != "docker" && strings.Contains(endpoint, socket) {
+			add("dockershim_socket", "high", fmt.Sprintf("kubelet runtime endpoint %q points at a Docker shim socket", endpoint))
+			break
+		}
+	}
+
+	if minimum, ok := runtimeMinimums[runtime]; ok && len(version) > 0 && compareVersions(version, parseVersion(minimum)) < 0 {
+		add("runtime_unsupported", "medium", fmt.Sprintf("%s %s is older than the oldest supported line (%s)", runtime, runtimeVersion, minimum))
+	}
+	if runtime == "cri-o" && len(version) >= 2 && len(kubelet) >= 2 && (version[0] != kubelet[0] || version[1] != kubelet[1]) {
+		add("crio_kubelet_skew", "medium", fmt.Sprintf("CRI-O %d.%d does not match kubelet %d.%d; CRI-O minors are released per Kubernetes minor", version[0], version[1], kubelet[0], kubelet[1]))
+	}
+	return findings
+}
+
+// versionSpread summarizes the versions seen for one component across nodes
+type versionSpread struct {
+	min, max   []int
+	minS, maxS string
+	counts     map[string]int
+}
+
+func (v *versionSpread) observe(version string) {
+	if v.counts == nil {
+		v.counts = map[string]int{}
+	}
+	v.counts[version]++
+	parsed := parseVersion(version)
+	if len(parsed) == 0 {
+		return
+	}
+	if v.min == nil || compareVersions(parsed, v.min) < 0 {
+		v.min, v.minS = parsed, version
+	}
+	if v.max == nil || compareVersions(parsed, v.max) > 0 {
+		v.max, v.maxS = parsed, version
+	}
+}
+
+// minorSkew is the number of minor releases between the oldest and newest
+// version (a major difference counts as unbounded)
+func (v *versionSpread) minorSkew() int {
+	if len(v.min) < 2 || len(v.max) < 2 {
+		return 0
+	}
+	if v.min[0] != v.max[0] {
+		return 100
+	}
+	return v.max[1] - v.min[1]
+}
+
+func (v *versionSpread) report() map[string]interface{} {
+	return map[string]interface{}{
+		"versions":   v.counts,
+		"oldest":     v.minS,
+		"newest":     v.maxS,
+		"minor_skew": v.minorSkew(),
+	}
+}
+
+// collectUpgradeReadiness builds the "upgrade_readiness" metric
+func collectUpgradeReadiness(clientset kubernetes.Interface, snap *ClusterSnapshot, status *CollectorStatus) map[string]interface{} {
+	defer diagnostics.recordDuration("upgrade_readiness", time.Now())
+
+	now := snap.TakenAt
+	nodes := make([]map[string]interface{}, 0, len(snap.Nodes))
+	summary := map[string]int{"eol": 0, "eol_soon": 0, "unknown_os": 0, "kernel_issues": 0, "runtime_issues": 0}
+	runtimes := map[string]*versionSpread{}
+	kubelets := &versionSpread{}
+	for _, node := range snap.Nodes {
+		info := node.Status.NodeInfo
+		runtime, runtimeVersion := splitRuntimeVersion(info.ContainerRuntimeVersion)
+		if runtimes[runtime] == nil {
+			runtimes[runtime] = &versionSpread{}
+		}
+		runtimes[runtime].observe(runtimeVersion)
+		kubelets.observe(info.KubeletVersion)
+		eol := osEndOfLife(node, now)
+		switch eol["status"] {
+		case "eol":
+			summary["eol"]++
+		case "eol_soon":
+			summary["eol_soon"]++
+		case "unknown":
+			summary["unknown_os"]++
+		}
+		issues := nodeKernelIssues(node, runtime)
+		if len(issues) > 0 {
+			summary["kernel_issues"]++
+		}
+		findings := runtimeFindings(node, snap.NodeConfigs[node.Name], runtime, runtimeVersion)
+		if len(findings) > 0 {
+			summary["runtime_issues"]++
+		}
+		nodes = append(nodes, map[string]interface{}{
+			"name":            node.Name,
+			"os_image":        info.OSImage,
+			"kernel":          info.KernelVersion,
+			"runtime":         runtime,
+			"runtime_version": runtimeVersion,
+			"kubelet_version": info.KubeletVersion,
+			"os_eol":          eol,
+			"kernel_issues":   issues,
+			"runtime_issues":  findings,
+		})
+	}
+
+	// Pool-wide skew: mixed runtimes or minors drifting apart make the
+	// upgrade a per-node exercise
+	runtimeNames := make([]string, 0, len(runtimes))
+	runtimeReport := map[string]interface{}{}
+	var skewed []string
+	for name, spread := range runtimes {
+		runtimeNames = append(runtimeNames, name)
+		runtimeReport[name] = spread.report()
+		if spread.minorSkew() >= 2 {
+			skewed = append(skewed, name)
+		}
+	}
+	sort.Strings(runtimeNames)
+	sort.Strings(skewed)
+
+	kubeletReport := kubelets.report()
+	if serverVersion, _, err := kubernetesServerVersion(clientset); err != nil {
+		status.Partial("server_version", err)
+	} else if server := parseVersion(serverVersion); len(server) >= 2 && server[0] > 0 && len(kubelets.min) >= 2 {
+		kubeletReport["apiserver"] = serverVersion
+		behind := server[1] - kubelets.min[1]
+		if server[0] != kubelets.min[0] {
+			behind = 100
+		}
+		kubeletReport["oldest_minors_behind_apiserver"] = behind
+		kubeletReport["outside_skew_policy"] = behind > kubeletMaxSkew || compareVersions(kubelets.max[:2], server[:2]) > 0
+		// After the next control plane upgrade the oldest kubelets must still be within policy
+		kubeletReport["blocks_next_minor"] = behind+1 > kubeletMaxSkew
+	}
+
+	return map[string]interface{}{
+		"mapping_version": upgradeMappingVersion,
+		"nodes":           nodes,
+		"summary":         summary,
+		"runtimes":        runtimeReport,
+		"mixed_runtimes":  len(runtimeNames) > 1,
+		"runtime_skew":    skewed,
+		"kubelets":        kubeletReport,
+	}
+}
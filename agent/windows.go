@@ -0,0 +1,75 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ---------------------------------------------
+// WINDOWS NODES
+// Windows nodes report different Kubelet stats and do not support Linux
+// security features (capabilities, runAsNonRoot, hostPID), so heuristics
+// built for Linux must skip them instead of producing bogus findings.
+// ---------------------------------------------
+
+const osWindows = "windows"
+
+// nodeOS returns the node's operating system ("linux", "windows", ...)
+func nodeOS(node corev1.Node) string {
+	if os := node.Labels["kubernetes.io/os"]; os != "" {
+		return os
+	}
+	if os := node.Labels["beta.kubernetes.io/os"]; os != "" {
+		return os
+	}
+	return node.Status.NodeInfo.OperatingSystem
+}
+
+// windowsNodeNames returns the names of the snapshot's Windows nodes
+func windowsNodeNames(snap *ClusterSnapshot) map[string]bool {
+	names := map[string]bool{}
+	for _, node := range snap.Nodes {
+		if nodeOS(node) == osWindows {
+			names[node.Name] = true
+		}
+	}
+	return names
+}
+
+// isWindowsPod reports whether a pod runs (or will run) Windows containers
+func isWindowsPod(pod corev1.Pod, windowsNodes map[string]bool) bool {
+	if pod.Spec.OS != nil {
+		return pod.Spec.OS.Name == corev1.Windows
+	}
+	if pod.Spec.NodeSelector["kubernetes.io/os"] == osWindows {
+		return true
+	}
+	return windowsNodes[pod.Spec.NodeName]
+}
+
+// isHostProcessPod reports whether a Windows pod runs HostProcess containers,
+// the Windows equivalent of a privileged container
+func isHostProcessPod(pod corev1.Pod) bool {
+	if sc := pod.Spec.SecurityContext; sc != nil && sc.WindowsOptions != nil &&
+		sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+		return true
+	}
+	for _, c := range pod.Spec.Containers {
+		if sc := c.SecurityContext; sc != nil && sc.WindowsOptions != nil &&
+			sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeFsStats returns the node filesystem stats. Windows kubelets may omit
+// node.fs and only report the runtime image filesystem (the C: volume).
+func nodeFsStats(summary *StatsSummary) *FsStats {
+	if summary.Node.Fs != nil {
+		return summary.Node.Fs
+	}
+	if summary.Node.Runtime != nil {
+		return summary.Node.Runtime.ImageFs
+	}
+	return nil
+}
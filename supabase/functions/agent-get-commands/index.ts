@@ -158,6 +158,23 @@ serve(async (req) => {
       });
     }
 
+    // Resolve who issued each command so the agent can record "on behalf of"
+    const userIds = [...new Set((commands || []).map(c => c.user_id).filter(Boolean))];
+    if (userIds.length > 0) {
+      const { data: profiles, error: profilesError } = await supabaseClient
+        .from('profiles')
+        .select('id, email')
+        .in('id', userIds);
+
+      if (profilesError) {
+        console.warn('Could not resolve command requesters');
+      }
+      const emails = new Map((profiles || []).map(p => [p.id, p.email]));
+      for (const command of commands || []) {
+        command.requested_by = emails.get(command.user_id) ?? null;
+      }
+    }
+
     // Mark commands as sent
     if (commands && commands.length > 0) {
       const commandIds = commands.map(c => c.id);